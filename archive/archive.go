@@ -0,0 +1,407 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	log "github.com/sirupsen/logrus"
+)
+
+// Version is the version of the archive format written by Export
+const Version = 1
+
+// manifestFile is the name of the file in the archive describing its content
+const manifestFile = "manifest.json"
+
+// pageSize is the number of entities read from the database at once
+const pageSize = 1000
+
+var logger *log.Entry
+
+func setupLogger() {
+	l, err := gostLog.GetPackageLogger("gost.server.archive")
+	if err != nil {
+		log.Error(err)
+	}
+
+	logger = l
+}
+
+// Manifest describes an archive, Counts holds the number of exported entities per entity set
+type Manifest struct {
+	Version int            `json:"version"`
+	Created string         `json:"created"`
+	Counts  map[string]int `json:"counts"`
+}
+
+// Record is a line in the NDJSON file of an entity set, ID is the id of the entity in the exported
+// database, Entity holds the properties without relations and Links the ids of the related entities
+// by navigation property, for example {"Thing": [1], "Sensor": [2], "ObservedProperty": [3]}
+type Record struct {
+	ID     interface{}              `json:"id"`
+	Entity json.RawMessage          `json:"entity"`
+	Links  map[string][]interface{} `json:"links,omitempty"`
+}
+
+// entitySet describes how the entities of a type are read from and written to the database
+type entitySet struct {
+	name    string
+	expand  string
+	list    func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error)
+	restore func(r *restorer, entity entities.Entity, links map[string][]interface{}) (interface{}, error)
+	new     func() entities.Entity
+}
+
+// file returns the name of the NDJSON file of the entity set in the archive
+func (s *entitySet) file() string {
+	return s.name + ".ndjson"
+}
+
+// entitySets are ordered so that related entities are restored before the entities linking to them
+var entitySets = []*entitySet{
+	{
+		name: "Locations",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			l, _, hasNext, err := db.GetLocations(qo)
+			e := make([]entities.Entity, len(l))
+			for i := range l {
+				e[i] = l[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreLocation,
+		new:     func() entities.Entity { return &entities.Location{} },
+	},
+	{
+		name:   "Things",
+		expand: "Locations",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			t, _, hasNext, err := db.GetThings(qo)
+			e := make([]entities.Entity, len(t))
+			for i := range t {
+				e[i] = t[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreThing,
+		new:     func() entities.Entity { return &entities.Thing{} },
+	},
+	{
+		name:   "HistoricalLocations",
+		expand: "Thing,Locations",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			h, _, hasNext, err := db.GetHistoricalLocations(qo)
+			e := make([]entities.Entity, len(h))
+			for i := range h {
+				e[i] = h[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreHistoricalLocation,
+		new:     func() entities.Entity { return &entities.HistoricalLocation{} },
+	},
+	{
+		name: "Sensors",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			s, _, hasNext, err := db.GetSensors(qo)
+			e := make([]entities.Entity, len(s))
+			for i := range s {
+				e[i] = s[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreSensor,
+		new:     func() entities.Entity { return &entities.Sensor{} },
+	},
+	{
+		name: "ObservedProperties",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			o, _, hasNext, err := db.GetObservedProperties(qo)
+			e := make([]entities.Entity, len(o))
+			for i := range o {
+				e[i] = o[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreObservedProperty,
+		new:     func() entities.Entity { return &entities.ObservedProperty{} },
+	},
+	{
+		name:   "Datastreams",
+		expand: "Thing,Sensor,ObservedProperty",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			d, _, hasNext, err := db.GetDatastreams(qo)
+			e := make([]entities.Entity, len(d))
+			for i := range d {
+				e[i] = d[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreDatastream,
+		new:     func() entities.Entity { return &entities.Datastream{} },
+	},
+	{
+		name: "FeaturesOfInterest",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			f, _, hasNext, err := db.GetFeatureOfInterests(qo)
+			e := make([]entities.Entity, len(f))
+			for i := range f {
+				e[i] = f[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreFeatureOfInterest,
+		new:     func() entities.Entity { return &entities.FeatureOfInterest{} },
+	},
+	{
+		name:   "Observations",
+		expand: "Datastream,FeatureOfInterest",
+		list: func(db models.Database, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+			o, _, hasNext, err := db.GetObservations(qo)
+			e := make([]entities.Entity, len(o))
+			for i := range o {
+				e[i] = o[i]
+			}
+			return e, hasNext, err
+		},
+		restore: restoreObservation,
+		new:     func() entities.Entity { return &entities.Observation{} },
+	},
+}
+
+// IsArchive returns true when path has the extension of an archive written by Export
+func IsArchive(path string) bool {
+	p := strings.ToLower(path)
+	return strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
+
+// Export writes all entities in the database to a gzipped tar archive at path holding a NDJSON file
+// of Records per entity set and a manifest.json, the archive can be loaded into another database by Restore
+func Export(db models.Database, path string) (*Manifest, error) {
+	setupLogger()
+	dir, err := ioutil.TempDir("", "gost-export")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := &Manifest{Version: Version, Created: time.Now().UTC().Format(time.RFC3339), Counts: make(map[string]int)}
+	for _, set := range entitySets {
+		count, err := exportEntitySet(db, set, filepath.Join(dir, set.file()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to export %s: %v", set.name, err)
+		}
+
+		logger.Infof("Exported %d %s", count, set.name)
+		manifest.Counts[set.name] = count
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, manifestFile), b, 0644); err != nil {
+		return nil, err
+	}
+
+	// the manifest is written last, entity sets are written in restore order so Restore can read the archive as stream
+	files := make([]string, 0, len(entitySets)+1)
+	for _, set := range entitySets {
+		files = append(files, set.file())
+	}
+
+	if err = writeArchive(path, dir, append(files, manifestFile)); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// exportEntitySet writes the entities of the set to file page by page and returns the number of entities
+func exportEntitySet(db models.Database, set *entitySet, file string) (int, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	count := 0
+	top := godata.GoDataTopQuery(pageSize)
+	qo := &odata.QueryOptions{}
+	qo.Top = &top
+	if len(set.expand) > 0 {
+		if qo.Expand, err = godata.ParseExpandString(set.expand); err != nil {
+			return 0, err
+		}
+	}
+
+	for {
+		page, hasNext, err := set.list(db, qo)
+		if err != nil {
+			return count, err
+		}
+
+		for _, e := range page {
+			r, err := newRecord(e)
+			if err != nil {
+				return count, err
+			}
+
+			if err = encoder.Encode(r); err != nil {
+				return count, err
+			}
+		}
+
+		count += len(page)
+		if !hasNext || len(page) == 0 {
+			break
+		}
+
+		// continue after the last entity of the page, entities are ordered by id
+		qo.SkipToken = odata.SkipToken{page[len(page)-1].GetID()}
+	}
+
+	if err = w.Flush(); err != nil {
+		return count, err
+	}
+
+	return count, f.Close()
+}
+
+// newRecord converts an entity with expanded relations into a Record
+func newRecord(e entities.Entity) (*Record, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	var properties map[string]json.RawMessage
+	if err = json.Unmarshal(b, &properties); err != nil {
+		return nil, err
+	}
+
+	// relations and links start with an upper case letter or contain @iot., such as Thing@iot.navigationLink
+	for k := range properties {
+		if strings.Contains(k, "@iot.") || (len(k) > 0 && unicode.IsUpper([]rune(k)[0])) {
+			delete(properties, k)
+		}
+	}
+
+	entity, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{ID: e.GetID(), Entity: entity, Links: links(e)}, nil
+}
+
+// links returns the ids of the related entities that are restored with the entity
+func links(e entities.Entity) map[string][]interface{} {
+	l := make(map[string][]interface{})
+	switch entity := e.(type) {
+	case *entities.Thing:
+		for _, location := range entity.Locations {
+			l["Locations"] = append(l["Locations"], location.ID)
+		}
+	case *entities.HistoricalLocation:
+		if entity.Thing != nil {
+			l["Thing"] = []interface{}{entity.Thing.ID}
+		}
+
+		for _, location := range entity.Locations {
+			l["Locations"] = append(l["Locations"], location.ID)
+		}
+	case *entities.Datastream:
+		if entity.Thing != nil {
+			l["Thing"] = []interface{}{entity.Thing.ID}
+		}
+
+		if entity.Sensor != nil {
+			l["Sensor"] = []interface{}{entity.Sensor.ID}
+		}
+
+		if entity.ObservedProperty != nil {
+			l["ObservedProperty"] = []interface{}{entity.ObservedProperty.ID}
+		}
+	case *entities.Observation:
+		if entity.Datastream != nil {
+			l["Datastream"] = []interface{}{entity.Datastream.ID}
+		}
+
+		if entity.FeatureOfInterest != nil {
+			l["FeatureOfInterest"] = []interface{}{entity.FeatureOfInterest.ID}
+		}
+	}
+
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// writeArchive writes the files in dir to a gzipped tar archive at path in the given order
+func writeArchive(path, dir string, files []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, name := range files {
+		if err = addFile(tw, filepath.Join(dir, name), name); err != nil {
+			return err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+
+	if err = gw.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func addFile(tw *tar.Writer, file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,94 @@
+package archive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	"github.com/gost/server/database/memory"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestGraph(db models.Database) {
+	location, _ := db.PostLocation(&entities.Location{Name: "home", EncodingType: "application/vnd.geo+json", Location: map[string]interface{}{"type": "Point", "coordinates": []float64{5.1, 52.1}}})
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test", Properties: map[string]interface{}{"owner": "gost"}})
+	db.LinkLocation(thing.ID, location.ID)
+	hl := &entities.HistoricalLocation{Time: "2017-01-01T00:00:00Z", Thing: &entities.Thing{}, Locations: []*entities.Location{{}}}
+	hl.Thing.ID = thing.ID
+	hl.Locations[0].ID = location.ID
+	db.PostHistoricalLocation(hl)
+	sensor, _ := db.PostSensor(&entities.Sensor{Name: "sensor", EncodingType: "application/pdf", Metadata: "datasheet"})
+	op, _ := db.PostObservedProperty(&entities.ObservedProperty{Name: "temperature", Definition: "temperature"})
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi", EncodingType: "application/vnd.geo+json", Feature: map[string]interface{}{"type": "Point", "coordinates": []float64{5.1, 52.1}}})
+
+	// an unused datastream makes the new ids differ from the exported ids
+	for _, name := range []string{"unused", "temperature"} {
+		ds := &entities.Datastream{Name: name, Thing: &entities.Thing{}, Sensor: &entities.Sensor{}, ObservedProperty: &entities.ObservedProperty{}}
+		ds.Thing.ID = thing.ID
+		ds.Sensor.ID = sensor.ID
+		ds.ObservedProperty.ID = op.ID
+		ds, _ = db.PostDatastream(ds)
+		if name == "unused" {
+			db.DeleteDatastream(ds.ID)
+			continue
+		}
+
+		for _, t := range []string{"2017-01-01T00:00:00Z", "2017-01-01T01:00:00Z"} {
+			o := &entities.Observation{PhenomenonTime: t, Result: json.RawMessage("20.5"), Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+			o.Datastream.ID = ds.ID
+			o.FeatureOfInterest.ID = foi.ID
+			db.PostObservation(o)
+		}
+	}
+}
+
+func TestExportAndRestore(t *testing.T) {
+	// arrange
+	source := memory.NewDatabase(200)
+	createTestGraph(source)
+	target := memory.NewDatabase(200)
+	dir, _ := ioutil.TempDir("", "gost-archive")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "backup.tar.gz")
+
+	// act
+	exported, exportErr := Export(source, path)
+	restored, restoreErr := Restore(target, path)
+	qo := &odata.QueryOptions{}
+	qo.Expand, _ = godata.ParseExpandString("Datastream/Thing/Locations,FeatureOfInterest")
+	observations, count, _, _ := target.GetObservations(qo)
+	hls, _, _, _ := target.GetHistoricalLocations(&odata.QueryOptions{})
+
+	// assert
+	assert.Nil(t, exportErr)
+	assert.Nil(t, restoreErr)
+	assert.True(t, IsArchive(path))
+	assert.Equal(t, map[string]int{"Locations": 1, "Things": 1, "HistoricalLocations": 1, "Sensors": 1, "ObservedProperties": 1, "Datastreams": 1, "FeaturesOfInterest": 1, "Observations": 2}, exported.Counts)
+	assert.Equal(t, exported, restored)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "temperature", observations[0].Datastream.Name)
+	assert.Equal(t, "home", observations[0].Datastream.Thing.Locations[0].Name)
+	assert.Equal(t, "gost", observations[0].Datastream.Thing.Properties["owner"])
+	assert.Equal(t, "foi", observations[0].FeatureOfInterest.Name)
+	assert.Equal(t, 1, len(hls))
+}
+
+func TestRestoreWithoutManifest(t *testing.T) {
+	// arrange
+	dir, _ := ioutil.TempDir("", "gost-archive")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "backup.tar.gz")
+	writeArchive(path, dir, []string{})
+
+	// act
+	_, err := Restore(memory.NewDatabase(200), path)
+
+	// assert
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,315 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/sensorthings/models"
+)
+
+// restorer recreates the entities of an archive, ids maps the exported ids to the ids in the database per entity set
+type restorer struct {
+	db           models.Database
+	ids          map[string]map[string]interface{}
+	observations []*entities.Observation
+}
+
+// Restore creates the entities of an archive written by Export in the database, the entities get new ids
+// and their relations are linked using the new ids. The manifest of the archive is returned
+func Restore(db models.Database, path string) (*Manifest, error) {
+	setupLogger()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	r := &restorer{db: db, ids: make(map[string]map[string]interface{})}
+	counts := make(map[string]int)
+	var manifest *Manifest
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if header.Name == manifestFile {
+			manifest = &Manifest{}
+			if err = json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("invalid manifest: %v", err)
+			}
+
+			continue
+		}
+
+		set := entitySetByFile(header.Name)
+		if set == nil {
+			logger.Warnf("Skipping unknown file %s in archive", header.Name)
+			continue
+		}
+
+		if counts[set.name], err = r.restoreEntitySet(set, tr); err != nil {
+			return nil, fmt.Errorf("unable to restore %s: %v", set.name, err)
+		}
+
+		logger.Infof("Restored %d %s", counts[set.name], set.name)
+	}
+
+	if manifest == nil {
+		return nil, errors.New("archive has no manifest")
+	}
+
+	for name, count := range manifest.Counts {
+		if counts[name] != count {
+			return manifest, fmt.Errorf("archive holds %d %s, %d were restored", count, name, counts[name])
+		}
+	}
+
+	return manifest, nil
+}
+
+func entitySetByFile(name string) *entitySet {
+	for _, set := range entitySets {
+		if set.file() == name {
+			return set
+		}
+	}
+
+	return nil
+}
+
+// restoreEntitySet creates the entities in the NDJSON file of the set and returns the number of restored entities
+func (r *restorer) restoreEntitySet(set *entitySet, file io.Reader) (int, error) {
+	r.ids[set.name] = make(map[string]interface{})
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record Record
+		d := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		d.UseNumber()
+		if err := d.Decode(&record); err != nil {
+			return count, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		entity := set.new()
+		if err := json.Unmarshal(record.Entity, entity); err != nil {
+			return count, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		id, err := set.restore(r, entity, record.Links)
+		if err != nil {
+			return count, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if id != nil {
+			r.ids[set.name][key(record.ID)] = id
+		}
+
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+
+	// observations are created in batches, the remaining batch is flushed at the end of the file
+	return count, r.flushObservations()
+}
+
+// id returns the new id of a related entity in the given entity set
+func (r *restorer) id(set string, links map[string][]interface{}, relation string) (interface{}, error) {
+	ids := r.ids[set]
+	l := links[relation]
+	if len(l) == 0 {
+		return nil, fmt.Errorf("missing %s", relation)
+	}
+
+	id, ok := ids[key(l[0])]
+	if !ok {
+		return nil, fmt.Errorf("%s %v not found in archive", relation, l[0])
+	}
+
+	return id, nil
+}
+
+func key(id interface{}) string {
+	return fmt.Sprintf("%v", id)
+}
+
+func restoreLocation(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	l, err := r.db.PostLocation(e.(*entities.Location))
+	if err != nil {
+		return nil, err
+	}
+
+	return l.ID, nil
+}
+
+func restoreThing(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	t, err := r.db.PostThing(e.(*entities.Thing))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range links["Locations"] {
+		id, ok := r.ids["Locations"][key(l)]
+		if !ok {
+			return nil, fmt.Errorf("Location %v not found in archive", l)
+		}
+
+		if err = r.db.LinkLocation(t.ID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.ID, nil
+}
+
+func restoreHistoricalLocation(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	hl := e.(*entities.HistoricalLocation)
+	thingID, err := r.id("Things", links, "Thing")
+	if err != nil {
+		return nil, err
+	}
+
+	hl.Thing = &entities.Thing{}
+	hl.Thing.ID = thingID
+	for _, l := range links["Locations"] {
+		id, ok := r.ids["Locations"][key(l)]
+		if !ok {
+			return nil, fmt.Errorf("Location %v not found in archive", l)
+		}
+
+		location := &entities.Location{}
+		location.ID = id
+		hl.Locations = append(hl.Locations, location)
+	}
+
+	t := hl.Time
+	created, err := r.db.PostHistoricalLocation(hl)
+	if err != nil {
+		return nil, err
+	}
+
+	// not every database stores the posted time, keep the time of the archive
+	if len(t) > 0 {
+		if _, err = r.db.PatchHistoricalLocation(created.ID, &entities.HistoricalLocation{Time: t}); err != nil {
+			return nil, err
+		}
+	}
+
+	return created.ID, nil
+}
+
+func restoreSensor(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	s, err := r.db.PostSensor(e.(*entities.Sensor))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ID, nil
+}
+
+func restoreObservedProperty(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	op, err := r.db.PostObservedProperty(e.(*entities.ObservedProperty))
+	if err != nil {
+		return nil, err
+	}
+
+	return op.ID, nil
+}
+
+func restoreDatastream(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	ds := e.(*entities.Datastream)
+	thingID, err := r.id("Things", links, "Thing")
+	if err != nil {
+		return nil, err
+	}
+
+	sensorID, err := r.id("Sensors", links, "Sensor")
+	if err != nil {
+		return nil, err
+	}
+
+	opID, err := r.id("ObservedProperties", links, "ObservedProperty")
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Thing, ds.Sensor, ds.ObservedProperty = &entities.Thing{}, &entities.Sensor{}, &entities.ObservedProperty{}
+	ds.Thing.ID = thingID
+	ds.Sensor.ID = sensorID
+	ds.ObservedProperty.ID = opID
+	created, err := r.db.PostDatastream(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	return created.ID, nil
+}
+
+func restoreFeatureOfInterest(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	foi, err := r.db.PostFeatureOfInterest(e.(*entities.FeatureOfInterest))
+	if err != nil {
+		return nil, err
+	}
+
+	return foi.ID, nil
+}
+
+// restoreObservation adds the observation to the batch that is created using the bulk insert of the database,
+// no entity links to observations so their new ids are not needed
+func restoreObservation(r *restorer, e entities.Entity, links map[string][]interface{}) (interface{}, error) {
+	o := e.(*entities.Observation)
+	datastreamID, err := r.id("Datastreams", links, "Datastream")
+	if err != nil {
+		return nil, err
+	}
+
+	foiID, err := r.id("FeaturesOfInterest", links, "FeatureOfInterest")
+	if err != nil {
+		return nil, err
+	}
+
+	o.Datastream, o.FeatureOfInterest = &entities.Datastream{}, &entities.FeatureOfInterest{}
+	o.Datastream.ID = datastreamID
+	o.FeatureOfInterest.ID = foiID
+	r.observations = append(r.observations, o)
+	if len(r.observations) >= pageSize {
+		return nil, r.flushObservations()
+	}
+
+	return nil, nil
+}
+
+func (r *restorer) flushObservations() error {
+	if len(r.observations) == 0 {
+		return nil
+	}
+
+	_, err := r.db.PostObservationsBulk(r.observations)
+	r.observations = nil
+	return err
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/sensorthings/models"
+)
+
+// Role describes what a user is allowed to do
+type Role string
+
+// Role is a "enumeration" of the supported roles, RoleRead can only retrieve entities, RoleIngest can only
+// post Observations (including CreateObservations) and RoleAdmin is allowed to do everything
+const (
+	RoleRead   Role = "read"
+	RoleIngest Role = "ingest"
+	RoleAdmin  Role = "admin"
+)
+
+var (
+	// ErrNoCredentials is returned when a request contains no credentials and anonymous access is disabled
+	ErrNoCredentials = errors.New("Authentication required")
+
+	// ErrInvalidCredentials is returned when the given credentials are unknown or invalid
+	ErrInvalidCredentials = errors.New("Invalid credentials")
+)
+
+// ParseRole converts a configured role into a Role, an error is returned for unknown roles
+func ParseRole(role string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(role)))
+	switch r {
+	case RoleRead, RoleIngest, RoleAdmin:
+		return r, nil
+	}
+
+	return "", fmt.Errorf("Unknown role %s, supported: %s, %s, %s", role, RoleRead, RoleIngest, RoleAdmin)
+}
+
+// IsAllowed returns true when the role is allowed to execute the HTTP operation on the given entity type
+func (r Role) IsAllowed(operation models.HTTPOperation, entityType entities.EntityType) bool {
+	switch r {
+	case RoleAdmin:
+		return true
+	case RoleRead:
+		return operation == models.HTTPOperationGet
+	case RoleIngest:
+		return operation == models.HTTPOperationPost &&
+			(entityType == entities.EntityTypeObservation || entityType == entities.EntityTypeCreateObservations)
+	}
+
+	return false
+}
+
+// Identity describes an authenticated user or the anonymous user of a request without credentials
+type Identity struct {
+	Name      string
+	Roles     []Role
+	Anonymous bool
+}
+
+// IsAllowed returns true when one of the roles of the identity allows the operation on the given entity type
+func (i *Identity) IsAllowed(operation models.HTTPOperation, entityType entities.EntityType) bool {
+	for _, r := range i.Roles {
+		if r.IsAllowed(operation, entityType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticator authenticates incoming HTTP requests and the credentials of MQTT clients
+type Authenticator interface {
+	// Authenticate returns the identity of the user sending the request or an error when
+	// the request is not authenticated
+	Authenticate(r *http.Request) (*Identity, error)
+	// AuthenticateCredentials returns the identity of the user with the given name and password, empty
+	// credentials are authenticated as anonymous user
+	AuthenticateCredentials(name, password string) (*Identity, error)
+
+	// Challenges returns the WWW-Authenticate values send back on a request that is not authenticated
+	Challenges() []string
+}
+
+// GostAuthenticator authenticates users with HTTP Basic against the users file and/or with a bearer JWT
+type GostAuthenticator struct {
+	users         *Users
+	jwt           *JWTValidator
+	anonymousRole Role
+}
+
+// NewAuthenticator creates the Authenticator configured in the auth section, nil is returned when auth is disabled
+func NewAuthenticator(conf configuration.AuthConfig) (Authenticator, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	a := &GostAuthenticator{}
+	if len(conf.UsersFile) > 0 {
+		users, err := LoadUsers(conf.UsersFile)
+		if err != nil {
+			return nil, err
+		}
+		a.users = users
+	}
+
+	if len(conf.JWTKey) > 0 || len(conf.JWTKeyFile) > 0 {
+		v, err := NewJWTValidator(conf.JWTKey, conf.JWTKeyFile, conf.JWTRoleClaim)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = v
+	}
+
+	if len(conf.AnonymousRole) > 0 {
+		role, err := ParseRole(conf.AnonymousRole)
+		if err != nil {
+			return nil, err
+		}
+		a.anonymousRole = role
+	}
+
+	if a.users == nil && a.jwt == nil {
+		return nil, errors.New("Auth is enabled but no usersFile, jwtKey or jwtKeyFile is configured")
+	}
+
+	return a, nil
+}
+
+// Authenticate checks the Authorization header of the request, Basic credentials are checked against
+// the users file and Bearer tokens are validated with the JWT key
+func (a *GostAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) == 0 {
+		return a.anonymous()
+	}
+
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCredentials
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "basic":
+		if a.users != nil {
+			name, password, ok := r.BasicAuth()
+			if !ok {
+				return nil, ErrInvalidCredentials
+			}
+
+			return a.users.Authenticate(name, password)
+		}
+	case "bearer":
+		if a.jwt != nil {
+			return a.jwt.Validate(strings.TrimSpace(parts[1]))
+		}
+	}
+
+	return nil, ErrInvalidCredentials
+}
+
+// AuthenticateCredentials checks a name and password such as the username and password of a MQTT client,
+// the credentials are checked against the users file and the password is validated as JWT when the user
+// is not found in the users file
+func (a *GostAuthenticator) AuthenticateCredentials(name, password string) (*Identity, error) {
+	if len(name) == 0 && len(password) == 0 {
+		return a.anonymous()
+	}
+
+	if a.users != nil {
+		identity, err := a.users.Authenticate(name, password)
+		if err == nil || a.jwt == nil {
+			return identity, err
+		}
+	}
+
+	if a.jwt != nil && len(password) > 0 {
+		return a.jwt.Validate(password)
+	}
+
+	return nil, ErrInvalidCredentials
+}
+
+// anonymous returns the identity used without credentials when an anonymous role is configured
+func (a *GostAuthenticator) anonymous() (*Identity, error) {
+	if len(a.anonymousRole) > 0 {
+		return &Identity{Roles: []Role{a.anonymousRole}, Anonymous: true}, nil
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// Challenges returns the supported authentication schemes
+func (a *GostAuthenticator) Challenges() []string {
+	challenges := make([]string, 0)
+	if a.users != nil {
+		challenges = append(challenges, `Basic realm="GOST"`)
+	}
+
+	if a.jwt != nil {
+		challenges = append(challenges, `Bearer realm="GOST"`)
+	}
+
+	return challenges
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRole(t *testing.T) {
+	// act
+	admin, err := ParseRole(" Admin")
+	_, unknownErr := ParseRole("superuser")
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, RoleAdmin, admin)
+	assert.NotNil(t, unknownErr)
+}
+
+func TestRoleIsAllowed(t *testing.T) {
+	// assert
+	assert.True(t, RoleRead.IsAllowed(models.HTTPOperationGet, entities.EntityTypeThing))
+	assert.False(t, RoleRead.IsAllowed(models.HTTPOperationDelete, entities.EntityTypeThing))
+	assert.True(t, RoleIngest.IsAllowed(models.HTTPOperationPost, entities.EntityTypeObservation))
+	assert.True(t, RoleIngest.IsAllowed(models.HTTPOperationPost, entities.EntityTypeCreateObservations))
+	assert.False(t, RoleIngest.IsAllowed(models.HTTPOperationPost, entities.EntityTypeThing))
+	assert.False(t, RoleIngest.IsAllowed(models.HTTPOperationGet, entities.EntityTypeObservation))
+	assert.True(t, RoleAdmin.IsAllowed(models.HTTPOperationDelete, entities.EntityTypeThing))
+	assert.False(t, Role("").IsAllowed(models.HTTPOperationGet, entities.EntityTypeThing))
+}
+
+func TestNewAuthenticatorDisabled(t *testing.T) {
+	// act
+	a, err := NewAuthenticator(configuration.AuthConfig{})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Nil(t, a)
+}
+
+func TestNewAuthenticatorWithoutCredentials(t *testing.T) {
+	// act
+	_, err := NewAuthenticator(configuration.AuthConfig{Enabled: true})
+
+	// assert
+	assert.NotNil(t, err)
+}
+
+func TestAuthenticateAnonymous(t *testing.T) {
+	// arrange
+	a, _ := NewAuthenticator(configuration.AuthConfig{Enabled: true, JWTKey: "secret", AnonymousRole: "read"})
+	req := httptest.NewRequest("GET", "/v1.0/Things", nil)
+
+	// act
+	identity, err := a.Authenticate(req)
+
+	// assert
+	assert.Nil(t, err)
+	assert.True(t, identity.Anonymous)
+	assert.True(t, identity.IsAllowed(models.HTTPOperationGet, entities.EntityTypeThing))
+	assert.False(t, identity.IsAllowed(models.HTTPOperationPost, entities.EntityTypeThing))
+}
+
+func TestAuthenticateNoCredentials(t *testing.T) {
+	// arrange
+	a, _ := NewAuthenticator(configuration.AuthConfig{Enabled: true, JWTKey: "secret"})
+	req := httptest.NewRequest("GET", "/v1.0/Things", nil)
+
+	// act
+	_, err := a.Authenticate(req)
+
+	// assert
+	assert.Equal(t, ErrNoCredentials, err)
+	assert.Equal(t, []string{`Bearer realm="GOST"`}, a.Challenges())
+}
+
+func TestAuthenticateUnsupportedScheme(t *testing.T) {
+	// arrange
+	a, _ := NewAuthenticator(configuration.AuthConfig{Enabled: true, JWTKey: "secret"})
+	req := httptest.NewRequest("GET", "/v1.0/Things", nil)
+	req.SetBasicAuth("admin", "admin")
+
+	// act
+	_, err := a.Authenticate(req)
+
+	// assert
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestAuthenticateCredentials(t *testing.T) {
+	// arrange
+	users, _ := ParseUsers(createUsersFile("ingest"))
+	a := &GostAuthenticator{users: users}
+
+	// act
+	identity, err := a.AuthenticateCredentials("sensor", "secret")
+	_, wrongPasswordErr := a.AuthenticateCredentials("sensor", "wrong")
+	_, anonymousErr := a.AuthenticateCredentials("", "")
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "sensor", identity.Name)
+	assert.False(t, identity.Anonymous)
+	assert.Equal(t, ErrInvalidCredentials, wrongPasswordErr)
+	assert.Equal(t, ErrNoCredentials, anonymousErr)
+}
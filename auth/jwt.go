@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gost/server/configuration"
+)
+
+// JWTValidator validates bearer tokens signed with HMAC using a shared secret or
+// with RSA/ECDSA using a public key read from a PEM file
+type JWTValidator struct {
+	secret    []byte
+	publicKey interface{}
+	roleClaim string
+}
+
+// NewJWTValidator creates a JWTValidator for the shared secret or for the public key in keyFile,
+// roleClaim is the claim containing the role or list of roles of the user
+func NewJWTValidator(secret, keyFile, roleClaim string) (*JWTValidator, error) {
+	v := &JWTValidator{roleClaim: roleClaim}
+	if len(v.roleClaim) == 0 {
+		v.roleClaim = configuration.DefaultJWTRoleClaim
+	}
+
+	if len(keyFile) == 0 {
+		v.secret = []byte(secret)
+		return v, nil
+	}
+
+	pem, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return v, nil
+	}
+
+	if v.publicKey, err = jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("Unable to read RSA or ECDSA public key from %s", keyFile)
+}
+
+// Validate checks the signature and the exp, nbf and iat claims of the token and returns
+// the identity holding the subject and the roles of the token
+func (v *JWTValidator) Validate(token string) (*Identity, error) {
+	t, err := jwt.Parse(token, v.key)
+	if err != nil || !t.Valid {
+		return nil, ErrInvalidCredentials
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	identity := &Identity{Roles: make([]Role, 0)}
+	identity.Name, _ = claims["sub"].(string)
+
+	switch roles := claims[v.roleClaim].(type) {
+	case string:
+		identity.Roles = appendRole(identity.Roles, roles)
+	case []interface{}:
+		for _, r := range roles {
+			if s, ok := r.(string); ok {
+				identity.Roles = appendRole(identity.Roles, s)
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+// key returns the key to verify the token with, the signing method of the token has to match
+// the configured key to prevent a token signed with the public key as HMAC secret to be accepted
+func (v *JWTValidator) key(t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.secret != nil {
+			return v.secret, nil
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		if v.publicKey != nil {
+			return v.publicKey, nil
+		}
+	}
+
+	return nil, errors.New("Unexpected signing method")
+}
+
+// appendRole adds the role when known, unknown roles in a token are ignored
+func appendRole(roles []Role, role string) []Role {
+	if r, err := ParseRole(role); err == nil {
+		return append(roles, r)
+	}
+
+	return roles
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func createToken(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	token, _ := jwt.NewWithClaims(method, claims).SignedString(key)
+	return token
+}
+
+func TestValidateToken(t *testing.T) {
+	// arrange
+	v, _ := NewJWTValidator("secret", "", "")
+	token := createToken(jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+		"sub":  "admin",
+		"role": "admin",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+
+	// act
+	identity, err := v.Validate(token)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", identity.Name)
+	assert.Equal(t, []Role{RoleAdmin}, identity.Roles)
+}
+
+func TestValidateTokenRoleList(t *testing.T) {
+	// arrange
+	v, _ := NewJWTValidator("secret", "", "roles")
+	token := createToken(jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+		"roles": []string{"read", "unknown", "ingest"},
+	})
+
+	// act
+	identity, err := v.Validate(token)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, []Role{RoleRead, RoleIngest}, identity.Roles)
+}
+
+func TestValidateTokenInvalid(t *testing.T) {
+	// arrange
+	v, _ := NewJWTValidator("secret", "", "")
+	wrongKey := createToken(jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{"role": "admin"})
+	expired := createToken(jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+		"role": "admin",
+		"exp":  time.Now().Add(-time.Hour).Unix(),
+	})
+
+	// act
+	_, wrongKeyErr := v.Validate(wrongKey)
+	_, expiredErr := v.Validate(expired)
+	_, malformedErr := v.Validate("not a token")
+
+	// assert
+	assert.Equal(t, ErrInvalidCredentials, wrongKeyErr)
+	assert.Equal(t, ErrInvalidCredentials, expiredErr)
+	assert.Equal(t, ErrInvalidCredentials, malformedErr)
+}
+
+func TestNewJWTValidatorKeyFileNotFound(t *testing.T) {
+	// act
+	_, err := NewJWTValidator("", "doesnotexist.pem", "")
+
+	// assert
+	assert.NotNil(t, err)
+}
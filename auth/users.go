@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// User is a user in the users file, the password is stored as bcrypt hash
+type User struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"`
+	Role     string `yaml:"role"`
+}
+
+// Users contains the users that can authenticate using HTTP Basic
+type Users struct {
+	users map[string]User
+	roles map[string]Role
+}
+
+type usersFile struct {
+	Users []User `yaml:"users"`
+}
+
+// LoadUsers reads the users from the YAML file at location
+func LoadUsers(location string) (*Users, error) {
+	content, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseUsers(content)
+}
+
+// ParseUsers parses the content of a users file, every user needs a name, a bcrypt password hash and a known role
+func ParseUsers(content []byte) (*Users, error) {
+	f := usersFile{}
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, err
+	}
+
+	u := &Users{
+		users: make(map[string]User),
+		roles: make(map[string]Role),
+	}
+
+	for _, user := range f.Users {
+		if len(user.Name) == 0 || len(user.Password) == 0 {
+			return nil, fmt.Errorf("User without name or password in users file")
+		}
+
+		role, err := ParseRole(user.Role)
+		if err != nil {
+			return nil, fmt.Errorf("User %s: %v", user.Name, err)
+		}
+
+		u.users[user.Name] = user
+		u.roles[user.Name] = role
+	}
+
+	return u, nil
+}
+
+// Authenticate returns the identity of the user when the password matches
+func (u *Users) Authenticate(name, password string) (*Identity, error) {
+	user, ok := u.users[name]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Identity{Name: name, Roles: []Role{u.roles[name]}}, nil
+}
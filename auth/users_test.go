@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func createUsersFile(role string) []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	return []byte(fmt.Sprintf("users:\n  - name: sensor\n    password: %s\n    role: %s\n", hash, role))
+}
+
+func TestParseUsers(t *testing.T) {
+	// arrange
+	users, err := ParseUsers(createUsersFile("ingest"))
+
+	// act
+	identity, authErr := users.Authenticate("sensor", "secret")
+	_, wrongPasswordErr := users.Authenticate("sensor", "wrong")
+	_, unknownUserErr := users.Authenticate("unknown", "secret")
+
+	// assert
+	assert.Nil(t, err)
+	assert.Nil(t, authErr)
+	assert.Equal(t, "sensor", identity.Name)
+	assert.Equal(t, []Role{RoleIngest}, identity.Roles)
+	assert.Equal(t, ErrInvalidCredentials, wrongPasswordErr)
+	assert.Equal(t, ErrInvalidCredentials, unknownUserErr)
+}
+
+func TestParseUsersUnknownRole(t *testing.T) {
+	// act
+	_, err := ParseUsers(createUsersFile("superuser"))
+
+	// assert
+	assert.NotNil(t, err)
+}
+
+func TestLoadUsersFileNotFound(t *testing.T) {
+	// act
+	_, err := LoadUsers("doesnotexist.yaml")
+
+	// assert
+	assert.NotNil(t, err)
+}
@@ -10,10 +10,12 @@ var CurrentConfig Config
 
 // Config contains the settings for the Http server, databases and mqtt
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	MQTT     MQTTConfig     `yaml:"mqtt"`
-	Logger   LoggerConfig   `yaml:"logger"`
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	MQTT      MQTTConfig      `yaml:"mqtt"`
+	Logger    LoggerConfig    `yaml:"logger"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Retention RetentionConfig `yaml:"retention"`
 }
 
 // ServerConfig contains the general server information
@@ -27,10 +29,14 @@ type ServerConfig struct {
 	HTTPSKey          string `yaml:"httpsKey"`
 	MaxEntityResponse int    `yaml:"maxEntityResponse"`
 	IndentedJSON      bool   `yaml:"indentedJson"`
+	// ShutdownTimeoutSec is the time GOST waits for requests and MQTT messages being handled on shutdown
+	ShutdownTimeoutSec int `yaml:"shutdownTimeoutSec"`
 }
 
 // DatabaseConfig contains the database server information, can be overruled by environment variables
 type DatabaseConfig struct {
+	Type         string `yaml:"type"`
+	Path         string `yaml:"path"`
 	Host         string `yaml:"host"`
 	Port         int    `yaml:"port"`
 	User         string `yaml:"user"`
@@ -40,9 +46,13 @@ type DatabaseConfig struct {
 	SSL          bool   `yaml:"ssl"`
 	MaxIdleConns int    `yaml:"maxIdleConns"`
 	MaxOpenConns int    `yaml:"maxOpenConns"`
+	AutoMigrate  bool   `yaml:"autoMigrate"`
 }
 
-// MQTTConfig contains the MQTT client information
+// MQTTConfig contains the MQTT client information, received messages are queued in a queue of QueueSize
+// messages and handled by Workers workers in batches of up to BatchSize messages. A message received while
+// the queue is full waits up to QueueTimeoutSec seconds for a place, or until shutdown when Order is set.
+// The errors of messages that are not handled are published on ErrorTopic when set
 type MQTTConfig struct {
 	Enabled         bool   `yaml:"enabled"`
 	Verbose         bool   `yaml:"verbose"`
@@ -61,12 +71,88 @@ type MQTTConfig struct {
 	PrivateKeyFile  string `yaml:"privateKeyFile"`
 	KeepAliveSec	int    `yaml:"keepAliveSec"`
 	PingTimeoutSec	int    `yaml:"pingTimeoutSec"`
+	Workers         int    `yaml:"workers"`
+	QueueSize       int    `yaml:"queueSize"`
+	QueueTimeoutSec int    `yaml:"queueTimeoutSec"`
+	BatchSize       int    `yaml:"batchSize"`
+	ErrorTopic      string `yaml:"errorTopic"`
+	ACL             MQTTACLConfig `yaml:"acl"`
+	Broker          MQTTBrokerConfig `yaml:"broker"`
 }
 
-// LoggerConfig contains the logging configuration used to initialize the logger
+// MQTTBrokerConfig enables the MQTT 3.1.1 broker embedded in GOST which is used instead of connecting to
+// the broker on host and port, clients connect over TCP on Host:Port and over websockets on WebsocketPath
+// of WebsocketPort when it is set
+type MQTTBrokerConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Host          string `yaml:"host"`
+	Port          int    `yaml:"port"`
+	WebsocketPort int    `yaml:"websocketPort"`
+	WebsocketPath string `yaml:"websocketPath"`
+}
+
+// MQTTACLConfig contains the publishers allowed to post observations per Datastream, set by id in Datastreams
+// or in the ThingProperty property of the Thing of the Datastream. A publisher is identified by the first
+// topic level after the prefix, the broker has to restrict this level to the username or client id
+type MQTTACLConfig struct {
+	Enabled       bool                `yaml:"enabled"`
+	Datastreams   map[string][]string `yaml:"datastreams"`
+	ThingProperty string              `yaml:"thingProperty"`
+	CacheSec      int                 `yaml:"cacheSec"`
+}
+
+// Log formats supported by logger.format
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LoggerConfig contains the logging configuration used to initialize the logger, Level overrules Verbose
+// and Levels sets the level by package field such as gost.server.http. The log file is rotated when it
+// reaches MaxSizeMB or MaxAgeHours, MaxBackups rotated files are kept and compressed when Compress is set
 type LoggerConfig struct {
-	FileName string `yaml:"fileName"`
-	Verbose  bool   `yaml:"verbose"`
+	FileName    string            `yaml:"fileName"`
+	Verbose     bool              `yaml:"verbose"`
+	Level       string            `yaml:"level"`
+	Levels      map[string]string `yaml:"levels"`
+	Format      string            `yaml:"format"`
+	AccessLog   bool              `yaml:"accessLog"`
+	MaxSizeMB   int               `yaml:"maxSizeMB"`
+	MaxAgeHours int               `yaml:"maxAgeHours"`
+	MaxBackups  int               `yaml:"maxBackups"`
+	Compress    bool              `yaml:"compress"`
+}
+
+// AuthConfig contains the authentication and authorization settings of the HTTP server and the embedded MQTT
+// broker, users are authenticated using HTTP Basic or the MQTT username and password against the users file
+// and/or a bearer JWT validated with the JWT key
+type AuthConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	UsersFile     string `yaml:"usersFile"`
+	JWTKey        string `yaml:"jwtKey"`
+	JWTKeyFile    string `yaml:"jwtKeyFile"`
+	JWTRoleClaim  string `yaml:"jwtRoleClaim"`
+	AnonymousRole string `yaml:"anonymousRole"`
+}
+
+// RetentionConfig contains the retention policies enforced by the retention job every IntervalSec seconds, the
+// inlined policy is used for all Datastreams without a policy in Datastreams (by id) or in the ThingProperty
+// property of the Thing of the Datastream
+type RetentionConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	IntervalSec     int    `yaml:"intervalSec"`
+	ThingProperty   string `yaml:"thingProperty"`
+	RetentionPolicy `yaml:",inline"`
+	Datastreams     map[string]RetentionPolicy `yaml:"datastreams"`
+}
+
+// RetentionPolicy describes how long the Observations of a Datastream are kept, raw Observations older than RawDays
+// are deleted after being aggregated into rollups of RollupIntervalSec seconds which are kept for RollupDays.
+// A value of 0 keeps the data forever or, for RollupIntervalSec, deletes the Observations without rollups
+type RetentionPolicy struct {
+	RawDays           int `yaml:"rawDays"`
+	RollupIntervalSec int `yaml:"rollupIntervalSec"`
+	RollupDays        int `yaml:"rollupDays"`
 }
 
 // GetInternalServerURI gets the internal Http server address
@@ -9,4 +9,49 @@ const (
 
 	// DefaultMaxEntries is used when config maxEntries is empty or $top exceeds this default value
 	DefaultMaxEntries int = 200
+
+	// DatabaseTypePostGIS stores the entities in PostgreSQL with PostGIS, used when the database type is empty
+	DatabaseTypePostGIS string = "postgis"
+
+	// DatabaseTypeMemory keeps all entities in memory, nothing is persisted
+	DatabaseTypeMemory string = "memory"
+
+	// DatabaseTypeSQLite stores the entities in the SQLite database file set by database.path
+	DatabaseTypeSQLite string = "sqlite"
+
+	// DefaultSQLitePath is the SQLite database file used when database.path is empty
+	DefaultSQLitePath string = "gost.db"
+
+	// DefaultMQTTACLThingProperty is the Thing property listing the publishers of its Datastreams when mqtt.acl.thingProperty is empty
+	DefaultMQTTACLThingProperty string = "mqttPublishers"
+
+	// DefaultMQTTACLCacheSec is the number of seconds the publishers read from a Thing are cached when mqtt.acl.cacheSec is not set
+	DefaultMQTTACLCacheSec int = 60
+
+	// DefaultMQTTWorkers is the number of workers handling MQTT messages when mqtt.workers is not set
+	DefaultMQTTWorkers int = 4
+
+	// DefaultMQTTQueueSize is the number of MQTT messages waiting to be handled when mqtt.queueSize is not set
+	DefaultMQTTQueueSize int = 1000
+
+	// DefaultMQTTQueueTimeoutSec is the number of seconds a MQTT message waits for a place in a full queue when mqtt.queueTimeoutSec is not set
+	DefaultMQTTQueueTimeoutSec int = 10
+
+	// DefaultMQTTBatchSize is the maximum number of MQTT messages a worker handles at once when mqtt.batchSize is not set
+	DefaultMQTTBatchSize int = 100
+
+	// DefaultMQTTBrokerPort is the TCP port of the embedded MQTT broker when mqtt.broker.port is not set
+	DefaultMQTTBrokerPort int = 1883
+
+	// DefaultMQTTBrokerWebsocketPath is the path of the websocket listener of the embedded MQTT broker when mqtt.broker.websocketPath is empty
+	DefaultMQTTBrokerWebsocketPath string = "/mqtt"
+
+	// DefaultJWTRoleClaim is the JWT claim holding the role(s) of the user when auth.jwtRoleClaim is empty
+	DefaultJWTRoleClaim string = "role"
+
+	// DefaultRetentionIntervalSec is the number of seconds between two runs of the retention job when retention.intervalSec is not set
+	DefaultRetentionIntervalSec int = 3600
+
+	// DefaultRetentionThingProperty is the Thing property holding the retention policy of its Datastreams when retention.thingProperty is empty
+	DefaultRetentionThingProperty string = "retention"
 )
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // SetEnvironmentVariables changes config settings when certain environment variables are found
@@ -12,6 +13,8 @@ func SetEnvironmentVariables(conf *Config) {
 	setEnvironmentDatabaseSettings(conf)
 	setEnvironmentMQTTSettings(conf)
 	setEnvironmentLoggerSettings(conf)
+	setEnvironmentAuthSettings(conf)
+	setEnvironmentRetentionSettings(conf)
 }
 
 func setEnvironmentServerSettings(conf *Config) {
@@ -56,6 +59,13 @@ func setEnvironmentServerSettings(conf *Config) {
 		}
 	}
 
+	gostServerShutdownTimeout := os.Getenv("GOST_SERVER_SHUTDOWN_TIMEOUT_SEC")
+	if gostServerShutdownTimeout != "" {
+		if timeout, err := strconv.Atoi(gostServerShutdownTimeout); err == nil {
+			conf.Server.ShutdownTimeoutSec = timeout
+		}
+	}
+
 	gostServerHTTPS := os.Getenv("GOST_SERVER_HTTPS")
 	if gostServerHTTPS != "" {
 		h, err := strconv.ParseBool(gostServerHTTPS)
@@ -76,6 +86,16 @@ func setEnvironmentServerSettings(conf *Config) {
 }
 
 func setEnvironmentDatabaseSettings(conf *Config) {
+	gostDbType := os.Getenv("GOST_DB_TYPE")
+	if gostDbType != "" {
+		conf.Database.Type = gostDbType
+	}
+
+	gostDbPath := os.Getenv("GOST_DB_PATH")
+	if gostDbPath != "" {
+		conf.Database.Path = gostDbPath
+	}
+
 	gostDbHost := os.Getenv("GOST_DB_HOST")
 	if gostDbHost != "" {
 		conf.Database.Host = gostDbHost
@@ -132,6 +152,14 @@ func setEnvironmentDatabaseSettings(conf *Config) {
 			conf.Database.MaxOpenConns = open
 		}
 	}
+
+	gostDbAutoMigrate := os.Getenv("GOST_DB_AUTO_MIGRATE")
+	if gostDbAutoMigrate != "" {
+		migrate, err := strconv.ParseBool(gostDbAutoMigrate)
+		if err == nil {
+			conf.Database.AutoMigrate = migrate
+		}
+	}
 }
 
 func setEnvironmentMQTTSettings(conf *Config) {
@@ -199,6 +227,39 @@ func setEnvironmentMQTTSettings(conf *Config) {
 		}
 	}
 
+	gostMQTTWorkers := os.Getenv("GOST_MQTT_WORKERS")
+	if gostMQTTWorkers != "" {
+		if workers, err := strconv.Atoi(gostMQTTWorkers); err == nil {
+			conf.MQTT.Workers = workers
+		}
+	}
+
+	gostMQTTQueueSize := os.Getenv("GOST_MQTT_QUEUE_SIZE")
+	if gostMQTTQueueSize != "" {
+		if queueSize, err := strconv.Atoi(gostMQTTQueueSize); err == nil {
+			conf.MQTT.QueueSize = queueSize
+		}
+	}
+
+	gostMQTTQueueTimeoutSec := os.Getenv("GOST_MQTT_QUEUE_TIMEOUT_SEC")
+	if gostMQTTQueueTimeoutSec != "" {
+		if queueTimeoutSec, err := strconv.Atoi(gostMQTTQueueTimeoutSec); err == nil {
+			conf.MQTT.QueueTimeoutSec = queueTimeoutSec
+		}
+	}
+
+	gostMQTTBatchSize := os.Getenv("GOST_MQTT_BATCH_SIZE")
+	if gostMQTTBatchSize != "" {
+		if batchSize, err := strconv.Atoi(gostMQTTBatchSize); err == nil {
+			conf.MQTT.BatchSize = batchSize
+		}
+	}
+
+	gostMQTTErrorTopic := os.Getenv("GOST_MQTT_ERROR_TOPIC")
+	if gostMQTTErrorTopic != "" {
+		conf.MQTT.ErrorTopic = gostMQTTErrorTopic
+	}
+
 	gostMQTTssl := os.Getenv("GOST_MQTT_SSL")
 	if gostMQTTssl != "" {
 		ssl, err := strconv.ParseBool(gostMQTTssl)
@@ -249,6 +310,49 @@ func setEnvironmentMQTTSettings(conf *Config) {
 		}
 	}
 
+	gostMQTTACLEnabled := os.Getenv("GOST_MQTT_ACL_ENABLED")
+	if gostMQTTACLEnabled != "" {
+		if enabled, err := strconv.ParseBool(gostMQTTACLEnabled); err == nil {
+			conf.MQTT.ACL.Enabled = enabled
+		}
+	}
+
+	gostMQTTACLThingProperty := os.Getenv("GOST_MQTT_ACL_THING_PROPERTY")
+	if gostMQTTACLThingProperty != "" {
+		conf.MQTT.ACL.ThingProperty = gostMQTTACLThingProperty
+	}
+
+	gostMQTTBrokerEnabled := os.Getenv("GOST_MQTT_BROKER_ENABLED")
+	if gostMQTTBrokerEnabled != "" {
+		if enabled, err := strconv.ParseBool(gostMQTTBrokerEnabled); err == nil {
+			conf.MQTT.Broker.Enabled = enabled
+		}
+	}
+
+	gostMQTTBrokerHost := os.Getenv("GOST_MQTT_BROKER_HOST")
+	if gostMQTTBrokerHost != "" {
+		conf.MQTT.Broker.Host = gostMQTTBrokerHost
+	}
+
+	gostMQTTBrokerPort := os.Getenv("GOST_MQTT_BROKER_PORT")
+	if gostMQTTBrokerPort != "" {
+		if port, err := strconv.Atoi(gostMQTTBrokerPort); err == nil {
+			conf.MQTT.Broker.Port = port
+		}
+	}
+
+	gostMQTTBrokerWebsocketPort := os.Getenv("GOST_MQTT_BROKER_WEBSOCKET_PORT")
+	if gostMQTTBrokerWebsocketPort != "" {
+		if port, err := strconv.Atoi(gostMQTTBrokerWebsocketPort); err == nil {
+			conf.MQTT.Broker.WebsocketPort = port
+		}
+	}
+
+	gostMQTTBrokerWebsocketPath := os.Getenv("GOST_MQTT_BROKER_WEBSOCKET_PATH")
+	if gostMQTTBrokerWebsocketPath != "" {
+		conf.MQTT.Broker.WebsocketPath = gostMQTTBrokerWebsocketPath
+	}
+
 }
 
 func setEnvironmentLoggerSettings(conf *Config) {
@@ -263,4 +367,136 @@ func setEnvironmentLoggerSettings(conf *Config) {
 			conf.Logger.Verbose = verboseFlag
 		}
 	}
+
+	gostLoggerLevel := os.Getenv("GOST_LOG_LEVEL")
+	if gostLoggerLevel != "" {
+		conf.Logger.Level = gostLoggerLevel
+	}
+
+	// GOST_LOG_LEVELS holds package=level pairs: gost.server.http=warn,gost.server.mqtt=debug
+	gostLoggerLevels := os.Getenv("GOST_LOG_LEVELS")
+	if gostLoggerLevels != "" {
+		conf.Logger.Levels = make(map[string]string)
+		for _, pair := range strings.Split(gostLoggerLevels, ",") {
+			if kv := strings.SplitN(strings.TrimSpace(pair), "=", 2); len(kv) == 2 {
+				conf.Logger.Levels[kv[0]] = kv[1]
+			}
+		}
+	}
+
+	gostLoggerMaxSize := os.Getenv("GOST_LOG_MAX_SIZE_MB")
+	if gostLoggerMaxSize != "" {
+		if maxSize, err := strconv.Atoi(gostLoggerMaxSize); err == nil {
+			conf.Logger.MaxSizeMB = maxSize
+		}
+	}
+
+	gostLoggerMaxAge := os.Getenv("GOST_LOG_MAX_AGE_HOURS")
+	if gostLoggerMaxAge != "" {
+		if maxAge, err := strconv.Atoi(gostLoggerMaxAge); err == nil {
+			conf.Logger.MaxAgeHours = maxAge
+		}
+	}
+
+	gostLoggerMaxBackups := os.Getenv("GOST_LOG_MAX_BACKUPS")
+	if gostLoggerMaxBackups != "" {
+		if maxBackups, err := strconv.Atoi(gostLoggerMaxBackups); err == nil {
+			conf.Logger.MaxBackups = maxBackups
+		}
+	}
+
+	gostLoggerCompress := os.Getenv("GOST_LOG_COMPRESS")
+	if gostLoggerCompress != "" {
+		if compress, err := strconv.ParseBool(gostLoggerCompress); err == nil {
+			conf.Logger.Compress = compress
+		}
+	}
+
+	gostLoggerFormat := os.Getenv("GOST_LOG_FORMAT")
+	if gostLoggerFormat != "" {
+		conf.Logger.Format = gostLoggerFormat
+	}
+
+	gostLoggerAccessLog := os.Getenv("GOST_LOG_ACCESS_LOG")
+	if gostLoggerAccessLog != "" {
+		if accessLog, err := strconv.ParseBool(gostLoggerAccessLog); err == nil {
+			conf.Logger.AccessLog = accessLog
+		}
+	}
+}
+
+func setEnvironmentAuthSettings(conf *Config) {
+	gostAuthEnabled := os.Getenv("GOST_AUTH_ENABLED")
+	if gostAuthEnabled != "" {
+		if enabled, err := strconv.ParseBool(gostAuthEnabled); err == nil {
+			conf.Auth.Enabled = enabled
+		}
+	}
+
+	gostAuthUsersFile := os.Getenv("GOST_AUTH_USERS_FILE")
+	if gostAuthUsersFile != "" {
+		conf.Auth.UsersFile = gostAuthUsersFile
+	}
+
+	gostAuthJWTKey := os.Getenv("GOST_AUTH_JWT_KEY")
+	if gostAuthJWTKey != "" {
+		conf.Auth.JWTKey = gostAuthJWTKey
+	}
+
+	gostAuthJWTKeyFile := os.Getenv("GOST_AUTH_JWT_KEY_FILE")
+	if gostAuthJWTKeyFile != "" {
+		conf.Auth.JWTKeyFile = gostAuthJWTKeyFile
+	}
+
+	gostAuthJWTRoleClaim := os.Getenv("GOST_AUTH_JWT_ROLE_CLAIM")
+	if gostAuthJWTRoleClaim != "" {
+		conf.Auth.JWTRoleClaim = gostAuthJWTRoleClaim
+	}
+
+	gostAuthAnonymousRole := os.Getenv("GOST_AUTH_ANONYMOUS_ROLE")
+	if gostAuthAnonymousRole != "" {
+		conf.Auth.AnonymousRole = gostAuthAnonymousRole
+	}
+}
+
+func setEnvironmentRetentionSettings(conf *Config) {
+	gostRetentionEnabled := os.Getenv("GOST_RETENTION_ENABLED")
+	if gostRetentionEnabled != "" {
+		if enabled, err := strconv.ParseBool(gostRetentionEnabled); err == nil {
+			conf.Retention.Enabled = enabled
+		}
+	}
+
+	gostRetentionIntervalSec := os.Getenv("GOST_RETENTION_INTERVAL_SEC")
+	if gostRetentionIntervalSec != "" {
+		if interval, err := strconv.Atoi(gostRetentionIntervalSec); err == nil {
+			conf.Retention.IntervalSec = interval
+		}
+	}
+
+	gostRetentionThingProperty := os.Getenv("GOST_RETENTION_THING_PROPERTY")
+	if gostRetentionThingProperty != "" {
+		conf.Retention.ThingProperty = gostRetentionThingProperty
+	}
+
+	gostRetentionRawDays := os.Getenv("GOST_RETENTION_RAW_DAYS")
+	if gostRetentionRawDays != "" {
+		if days, err := strconv.Atoi(gostRetentionRawDays); err == nil {
+			conf.Retention.RawDays = days
+		}
+	}
+
+	gostRetentionRollupIntervalSec := os.Getenv("GOST_RETENTION_ROLLUP_INTERVAL_SEC")
+	if gostRetentionRollupIntervalSec != "" {
+		if interval, err := strconv.Atoi(gostRetentionRollupIntervalSec); err == nil {
+			conf.Retention.RollupIntervalSec = interval
+		}
+	}
+
+	gostRetentionRollupDays := os.Getenv("GOST_RETENTION_ROLLUP_DAYS")
+	if gostRetentionRollupDays != "" {
+		if days, err := strconv.Atoi(gostRetentionRollupDays); err == nil {
+			conf.Retention.RollupDays = days
+		}
+	}
 }
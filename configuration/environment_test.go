@@ -42,6 +42,8 @@ func TestEnvironmentVariabels(t *testing.T) {
 	mqttPortParsed, _ := strconv.Atoi(mqttPort)
 	dbSSLEnabled := "true"
 	dbSSLEnabledParsed, _ := strconv.ParseBool(dbSSLEnabled)
+	dbType := "memory"
+	dbPath := "/data/gost.db"
 	dbHost := "db_host"
 	dbPort := "5432"
 	dbPortParsed, _ := strconv.Atoi(dbPort)
@@ -53,6 +55,9 @@ func TestEnvironmentVariabels(t *testing.T) {
 	dbMaxIdleConsParsed, _ := strconv.Atoi(dbMaxIdleCons)
 	dbMaxOpenCons := "1"
 	dbMaxOpenConsParsed, _ := strconv.Atoi(dbMaxOpenCons)
+	authEnabled := "true"
+	authUsersFile := "users.yaml"
+	authAnonymousRole := "read"
 	// act
 	os.Setenv("GOST_SERVER_NAME", server)
 	os.Setenv("GOST_SERVER_HOST", host)
@@ -66,6 +71,8 @@ func TestEnvironmentVariabels(t *testing.T) {
 	os.Setenv("GOST_MQTT_ENABLED", mqttEnabled)
 	os.Setenv("GOST_MQTT_HOST", mqttHost)
 	os.Setenv("GOST_MQTT_PORT", mqttPort)
+	os.Setenv("GOST_DB_TYPE", dbType)
+	os.Setenv("GOST_DB_PATH", dbPath)
 	os.Setenv("GOST_DB_HOST", dbHost)
 	os.Setenv("GOST_DB_PORT", dbPort)
 	os.Setenv("GOST_DB_USER", dbUser)
@@ -75,6 +82,33 @@ func TestEnvironmentVariabels(t *testing.T) {
 	os.Setenv("GOST_DB_SSL_ENABLED", dbSSLEnabled)
 	os.Setenv("GOST_DB_MAX_IDLE_CONS", dbMaxIdleCons)
 	os.Setenv("GOST_DB_MAX_OPEN_CONS", dbMaxOpenCons)
+	os.Setenv("GOST_DB_AUTO_MIGRATE", "true")
+	os.Setenv("GOST_MQTT_ACL_ENABLED", "true")
+	os.Setenv("GOST_MQTT_WORKERS", "8")
+	os.Setenv("GOST_MQTT_QUEUE_SIZE", "5000")
+	os.Setenv("GOST_MQTT_QUEUE_TIMEOUT_SEC", "30")
+	os.Setenv("GOST_MQTT_BATCH_SIZE", "50")
+	os.Setenv("GOST_MQTT_ERROR_TOPIC", "{prefix}/errors/{clientId}")
+	os.Setenv("GOST_MQTT_ACL_THING_PROPERTY", "publishers")
+	os.Setenv("GOST_MQTT_BROKER_ENABLED", "true")
+	os.Setenv("GOST_MQTT_BROKER_PORT", "1884")
+	os.Setenv("GOST_MQTT_BROKER_WEBSOCKET_PORT", "9001")
+	os.Setenv("GOST_AUTH_ENABLED", authEnabled)
+	os.Setenv("GOST_AUTH_USERS_FILE", authUsersFile)
+	os.Setenv("GOST_AUTH_ANONYMOUS_ROLE", authAnonymousRole)
+	os.Setenv("GOST_RETENTION_ENABLED", "true")
+	os.Setenv("GOST_RETENTION_RAW_DAYS", "90")
+	os.Setenv("GOST_RETENTION_ROLLUP_INTERVAL_SEC", "3600")
+	os.Setenv("GOST_RETENTION_ROLLUP_DAYS", "1825")
+	os.Setenv("GOST_LOG_FORMAT", "json")
+	os.Setenv("GOST_SERVER_SHUTDOWN_TIMEOUT_SEC", "10")
+	os.Setenv("GOST_LOG_ACCESS_LOG", "true")
+	os.Setenv("GOST_LOG_LEVEL", "warn")
+	os.Setenv("GOST_LOG_LEVELS", "gost.server.http=debug, gost.server.mqtt=error")
+	os.Setenv("GOST_LOG_MAX_SIZE_MB", "10")
+	os.Setenv("GOST_LOG_MAX_AGE_HOURS", "24")
+	os.Setenv("GOST_LOG_MAX_BACKUPS", "5")
+	os.Setenv("GOST_LOG_COMPRESS", "true")
 
 	SetEnvironmentVariables(&conf)
 
@@ -94,6 +128,8 @@ func TestEnvironmentVariabels(t *testing.T) {
 	assert.Equal(t, mqttPortParsed, conf.MQTT.Port)
 	assert.Equal(t, dbDB, conf.Database.Database)
 	assert.Equal(t, dbPortParsed, conf.Database.Port)
+	assert.Equal(t, dbType, conf.Database.Type)
+	assert.Equal(t, dbPath, conf.Database.Path)
 	assert.Equal(t, dbHost, conf.Database.Host)
 	assert.Equal(t, dbMaxIdleConsParsed, conf.Database.MaxIdleConns)
 	assert.Equal(t, dbMaxOpenConsParsed, conf.Database.MaxOpenConns)
@@ -101,4 +137,31 @@ func TestEnvironmentVariabels(t *testing.T) {
 	assert.Equal(t, dbSchema, conf.Database.Schema)
 	assert.Equal(t, dbSSLEnabledParsed, conf.Database.SSL)
 	assert.Equal(t, dbUser, conf.Database.User)
+	assert.True(t, conf.Database.AutoMigrate)
+	assert.True(t, conf.MQTT.ACL.Enabled)
+	assert.Equal(t, 8, conf.MQTT.Workers)
+	assert.Equal(t, 5000, conf.MQTT.QueueSize)
+	assert.Equal(t, 30, conf.MQTT.QueueTimeoutSec)
+	assert.Equal(t, 50, conf.MQTT.BatchSize)
+	assert.Equal(t, "{prefix}/errors/{clientId}", conf.MQTT.ErrorTopic)
+	assert.Equal(t, "publishers", conf.MQTT.ACL.ThingProperty)
+	assert.True(t, conf.MQTT.Broker.Enabled)
+	assert.Equal(t, 1884, conf.MQTT.Broker.Port)
+	assert.Equal(t, 9001, conf.MQTT.Broker.WebsocketPort)
+	assert.True(t, conf.Auth.Enabled)
+	assert.Equal(t, authUsersFile, conf.Auth.UsersFile)
+	assert.Equal(t, authAnonymousRole, conf.Auth.AnonymousRole)
+	assert.True(t, conf.Retention.Enabled)
+	assert.Equal(t, 90, conf.Retention.RawDays)
+	assert.Equal(t, 3600, conf.Retention.RollupIntervalSec)
+	assert.Equal(t, 1825, conf.Retention.RollupDays)
+	assert.Equal(t, "json", conf.Logger.Format)
+	assert.Equal(t, 10, conf.Server.ShutdownTimeoutSec)
+	assert.True(t, conf.Logger.AccessLog)
+	assert.Equal(t, "warn", conf.Logger.Level)
+	assert.Equal(t, map[string]string{"gost.server.http": "debug", "gost.server.mqtt": "error"}, conf.Logger.Levels)
+	assert.Equal(t, 10, conf.Logger.MaxSizeMB)
+	assert.Equal(t, 24, conf.Logger.MaxAgeHours)
+	assert.Equal(t, 5, conf.Logger.MaxBackups)
+	assert.True(t, conf.Logger.Compress)
 }
@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// interval collects the numeric results and the number of observations with a phenomenonTime in the
+// interval starting at start seconds since the Unix epoch
+type interval struct {
+	start   int64
+	count   int64
+	results []float64
+}
+
+// GetObservationAggregates returns the observations of the given datastream, or of all datastreams when the id is nil,
+// aggregated by the $apply of the QueryOptions
+func (db *MemoryDatabase) GetObservationAggregates(dataStreamID interface{}, qo *odata.QueryOptions) ([]*models.ObservationAggregate, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	records := db.all(entities.EntityTypeObservation)
+	if dataStreamID != nil {
+		if _, ok := ToIntID(dataStreamID); !ok {
+			return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+		}
+
+		records = make([]*record, 0)
+		if ds, ok := db.get(entities.EntityTypeDatastream, dataStreamID); ok {
+			records = db.related(ds, entities.EntityTypeObservation)
+		}
+	}
+
+	return db.aggregate(records, qo)
+}
+
+// aggregate groups the records matching the filter by the interval of their phenomenonTime and returns a page of
+// the aggregated intervals, count is the total number of intervals
+func (db *MemoryDatabase) aggregate(records []*record, qo *odata.QueryOptions) ([]*models.ObservationAggregate, int, bool, error) {
+	seconds := qo.Aggregation.Seconds()
+	intervals := make(map[int64]*interval)
+	for _, r := range records {
+		ok, err := db.matchFilter(r, qo)
+		if err != nil {
+			return nil, 0, false, gostErrors.NewBadRequestError(err)
+		}
+
+		t, isTime := toTime(propertyValue(r, "phenomenonTime"))
+		if !ok || !isTime {
+			continue
+		}
+
+		start := intervalStart(t, seconds)
+		i, exists := intervals[start]
+		if !exists {
+			i = &interval{start: start}
+			intervals[start] = i
+		}
+
+		i.count++
+		if result, isNumber := propertyValue(r, "result").(float64); isNumber {
+			i.results = append(i.results, result)
+		}
+	}
+
+	sorted := make([]*interval, 0, len(intervals))
+	for _, i := range intervals {
+		sorted = append(sorted, i)
+	}
+
+	descending := qo.IntervalsDescending()
+	sort.Slice(sorted, func(a, b int) bool {
+		if descending {
+			return sorted[a].start > sorted[b].start
+		}
+
+		return sorted[a].start < sorted[b].start
+	})
+
+	count := len(sorted)
+	if skip := db.getSkip(qo); skip >= len(sorted) {
+		sorted = sorted[:0]
+	} else {
+		sorted = sorted[skip:]
+	}
+
+	hasNext := false
+	if top := db.getTop(qo); top >= 0 && len(sorted) > top {
+		hasNext = true
+		sorted = sorted[:top]
+	}
+
+	aggregates := make([]*models.ObservationAggregate, 0, len(sorted))
+	for _, i := range sorted {
+		aggregates = append(aggregates, i.toAggregate(qo.Aggregation))
+	}
+
+	return aggregates, count, hasNext, nil
+}
+
+// intervalStart returns the start of the interval of the given length in seconds containing t, the
+// intervals start at a multiple of their length since the Unix epoch
+func intervalStart(t time.Time, seconds int64) int64 {
+	start := t.Unix() / seconds * seconds
+	if start > t.Unix() {
+		start -= seconds
+	}
+
+	return start
+}
+
+// toAggregate calculates the aggregates of the interval, aggregates of an interval without numeric results are nil
+func (i *interval) toAggregate(aggregation *odata.Aggregation) *models.ObservationAggregate {
+	a := &models.ObservationAggregate{
+		Start:  time.Unix(i.start, 0).UTC(),
+		Values: make(map[string]interface{}),
+	}
+	a.End = a.Start.Add(aggregation.Interval)
+
+	for _, agg := range aggregation.Aggregates {
+		if agg.Method == odata.AggregateMethodCount {
+			a.Values[agg.Alias] = i.count
+			continue
+		}
+
+		if len(i.results) == 0 {
+			a.Values[agg.Alias] = nil
+			continue
+		}
+
+		sum, min, max := 0.0, i.results[0], i.results[0]
+		for _, r := range i.results {
+			sum += r
+			if r < min {
+				min = r
+			}
+			if r > max {
+				max = r
+			}
+		}
+
+		switch agg.Method {
+		case odata.AggregateMethodAverage:
+			a.Values[agg.Alias] = sum / float64(len(i.results))
+		case odata.AggregateMethodMin:
+			a.Values[agg.Alias] = min
+		case odata.AggregateMethodMax:
+			a.Values[agg.Alias] = max
+		case odata.AggregateMethodSum:
+			a.Values[agg.Alias] = sum
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"errors"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetDatastream returns a datastream by id
+func (db *MemoryDatabase) GetDatastream(id interface{}, qo *odata.QueryOptions) (*entities.Datastream, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeDatastream, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Datastream), nil
+}
+
+// GetDatastreams returns all datastreams
+func (db *MemoryDatabase) GetDatastreams(qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeDatastream), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toDatastreams(result), count, hasNext, nil
+}
+
+// GetDatastreamByObservation returns the datastream of the given observation
+func (db *MemoryDatabase) GetDatastreamByObservation(observationID interface{}, qo *odata.QueryOptions) (*entities.Datastream, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getRelatedOne(entities.EntityTypeObservation, observationID, entities.EntityTypeDatastream, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Datastream), nil
+}
+
+// GetDatastreamsByThing returns the datastreams of the given thing
+func (db *MemoryDatabase) GetDatastreamsByThing(thingID interface{}, qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	return db.getDatastreamsByRelation(entities.EntityTypeThing, thingID, qo)
+}
+
+// GetDatastreamsBySensor returns the datastreams of the given sensor
+func (db *MemoryDatabase) GetDatastreamsBySensor(sensorID interface{}, qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	return db.getDatastreamsByRelation(entities.EntityTypeSensor, sensorID, qo)
+}
+
+// GetDatastreamsByObservedProperty returns the datastreams of the given ObservedProperty
+func (db *MemoryDatabase) GetDatastreamsByObservedProperty(oID interface{}, qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	return db.getDatastreamsByRelation(entities.EntityTypeObservedProperty, oID, qo)
+}
+
+func (db *MemoryDatabase) getDatastreamsByRelation(et entities.EntityType, id interface{}, qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(et, id, entities.EntityTypeDatastream, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toDatastreams(result), count, hasNext, nil
+}
+
+// datastreamRelations returns the thing, sensor and ObservedProperty records of a datastream to post,
+// a bad request is returned when one of them does not exist
+func (db *MemoryDatabase) datastreamRelations(d *entities.Datastream) ([]*record, error) {
+	var tID, sID, oID interface{}
+	if d.Thing != nil {
+		tID = d.Thing.ID
+	}
+
+	if d.Sensor != nil {
+		sID = d.Sensor.ID
+	}
+
+	if d.ObservedProperty != nil {
+		oID = d.ObservedProperty.ID
+	}
+
+	types := []entities.EntityType{entities.EntityTypeThing, entities.EntityTypeSensor, entities.EntityTypeObservedProperty}
+	records := make([]*record, 0, len(types))
+	for i, id := range []interface{}{tID, sID, oID} {
+		r, ok := db.get(types[i], id)
+		if !ok {
+			return nil, gostErrors.NewBadRequestError(errors.New(types[i].ToString() + " does not exist"))
+		}
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// PostDatastream adds a datastream linked to its thing, sensor and ObservedProperty
+func (db *MemoryDatabase) PostDatastream(d *entities.Datastream) (*entities.Datastream, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	relations, err := db.datastreamRelations(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = entities.GetObservationTypeByValue(d.ObservationType); err != nil {
+		return nil, gostErrors.NewBadRequestError(errors.New("ObservationType does not exist"))
+	}
+
+	r := db.insert(entities.EntityTypeDatastream, d)
+	for _, related := range relations {
+		db.link(r, related)
+	}
+
+	// clear inner entities to serves links upon response
+	d.Thing = nil
+	d.Sensor = nil
+	d.ObservedProperty = nil
+
+	return d, nil
+}
+
+// PatchDatastream updates a datastream
+func (db *MemoryDatabase) PatchDatastream(id interface{}, ds *entities.Datastream) (*entities.Datastream, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if len(ds.ObservationType) > 0 {
+		if _, err := entities.GetObservationTypeByValue(ds.ObservationType); err != nil {
+			return nil, gostErrors.NewBadRequestError(errors.New("ObservationType does not exist"))
+		}
+	}
+
+	r, err := db.patch(entities.EntityTypeDatastream, id, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Datastream), nil
+}
+
+// PutDatastream replaces a datastream
+func (db *MemoryDatabase) PutDatastream(id interface{}, datastream *entities.Datastream) (*entities.Datastream, error) {
+	return db.PatchDatastream(id, datastream)
+}
+
+// DeleteDatastream removes a datastream and its observations
+func (db *MemoryDatabase) DeleteDatastream(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeDatastream, id)
+}
+
+// DatastreamExists checks if a datastream is present based on a given id
+func (db *MemoryDatabase) DatastreamExists(id int) bool {
+	db.RLock()
+	defer db.RUnlock()
+
+	return db.exists(entities.EntityTypeDatastream, id)
+}
+
+func toDatastreams(result []entities.Entity) []*entities.Datastream {
+	datastreams := make([]*entities.Datastream, len(result))
+	for i, e := range result {
+		datastreams[i] = e.(*entities.Datastream)
+	}
+
+	return datastreams
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"errors"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetFeatureOfInterestIDByLocationID returns the id of the FeatureOfInterest created from the given location
+func (db *MemoryDatabase) GetFeatureOfInterestIDByLocationID(id interface{}) (interface{}, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+	}
+
+	for _, r := range db.all(entities.EntityTypeFeatureOfInterest) {
+		if r.originalLocationID == intID {
+			return r.id, nil
+		}
+	}
+
+	return nil, errors.New("Linked FeatureOfINterest not found")
+}
+
+// GetFeatureOfInterest returns a FeatureOfInterest by id
+func (db *MemoryDatabase) GetFeatureOfInterest(id interface{}, qo *odata.QueryOptions) (*entities.FeatureOfInterest, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeFeatureOfInterest, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.FeatureOfInterest), nil
+}
+
+// GetFeatureOfInterestByObservation returns the FeatureOfInterest of the given observation
+func (db *MemoryDatabase) GetFeatureOfInterestByObservation(id interface{}, qo *odata.QueryOptions) (*entities.FeatureOfInterest, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getRelatedOne(entities.EntityTypeObservation, id, entities.EntityTypeFeatureOfInterest, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.FeatureOfInterest), nil
+}
+
+// GetFeatureOfInterests returns all FeatureOfInterests
+func (db *MemoryDatabase) GetFeatureOfInterests(qo *odata.QueryOptions) ([]*entities.FeatureOfInterest, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeFeatureOfInterest), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	fois := make([]*entities.FeatureOfInterest, len(result))
+	for i, e := range result {
+		fois[i] = e.(*entities.FeatureOfInterest)
+	}
+
+	return fois, count, hasNext, nil
+}
+
+// PostFeatureOfInterest adds a FeatureOfInterest
+func (db *MemoryDatabase) PostFeatureOfInterest(f *entities.FeatureOfInterest) (*entities.FeatureOfInterest, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	r := db.insert(entities.EntityTypeFeatureOfInterest, f)
+	if f.OriginalLocationID != nil {
+		r.originalLocationID, _ = ToIntID(f.OriginalLocationID)
+	}
+
+	return f, nil
+}
+
+// PutFeatureOfInterest replaces a FeatureOfInterest
+func (db *MemoryDatabase) PutFeatureOfInterest(id interface{}, f *entities.FeatureOfInterest) (*entities.FeatureOfInterest, error) {
+	return db.PatchFeatureOfInterest(id, f)
+}
+
+// PatchFeatureOfInterest updates a FeatureOfInterest
+func (db *MemoryDatabase) PatchFeatureOfInterest(id interface{}, foi *entities.FeatureOfInterest) (*entities.FeatureOfInterest, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	r, err := db.patch(entities.EntityTypeFeatureOfInterest, id, foi)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.FeatureOfInterest), nil
+}
+
+// DeleteFeatureOfInterest removes a FeatureOfInterest and its observations
+func (db *MemoryDatabase) DeleteFeatureOfInterest(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeFeatureOfInterest, id)
+}
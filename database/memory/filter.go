@@ -0,0 +1,596 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gost/godata"
+)
+
+var funcToValueMap map[string]func(args []interface{}) (interface{}, error)
+
+// init is used to work around the initialization loop error (circular reference)
+func init() {
+	funcToValueMap = map[string]func(args []interface{}) (interface{}, error){
+		"contains":           containsValue,
+		"substringof":        substringofValue,
+		"endswith":           endswithValue,
+		"startswith":         startswithValue,
+		"length":             lengthValue,
+		"indexof":            indexofValue,
+		"substring":          substringValue,
+		"tolower":            tolowerValue,
+		"toupper":            toupperValue,
+		"trim":               trimValue,
+		"concat":             concatValue,
+		"round":              mathValue(round),
+		"floor":              mathValue(math.Floor),
+		"ceiling":            mathValue(math.Ceil),
+		"year":               timePartValue(func(t time.Time) float64 { return float64(t.Year()) }),
+		"month":              timePartValue(func(t time.Time) float64 { return float64(t.Month()) }),
+		"day":                timePartValue(func(t time.Time) float64 { return float64(t.Day()) }),
+		"hour":               timePartValue(func(t time.Time) float64 { return float64(t.Hour()) }),
+		"minute":             timePartValue(func(t time.Time) float64 { return float64(t.Minute()) }),
+		"second":             timePartValue(func(t time.Time) float64 { return float64(t.Second()) }),
+		"fractionalseconds":  timePartValue(func(t time.Time) float64 { return float64(t.Nanosecond()) / 1e9 }),
+		"totaloffsetminutes": timePartValue(func(t time.Time) float64 { _, offset := t.Zone(); return float64(offset / 60) }),
+		"date":               dateValue,
+		"time":               timeValue,
+		"now":                nowValue,
+		"maxdatetime":        maxdatetimeValue,
+		"mindatetime":        mindatetimeValue,
+		"geo.length":         geolengthValue,
+		"geo.distance":       geodistanceValue,
+		"geo.intersects":     spatialValue(intersects),
+		"st_equals":          spatialValue(equals),
+		"st_contains":        spatialValue(func(a, b *geometry) bool { return within(b, a) }),
+		"st_disjoint":        spatialValue(func(a, b *geometry) bool { return !intersects(a, b) }),
+		"st_within":          spatialValue(within),
+		"st_intersects":      spatialValue(intersects),
+	}
+}
+
+// evaluate returns the value of a $filter parse node for the given record
+func (db *MemoryDatabase) evaluate(r *record, pn *godata.ParseNode) (interface{}, error) {
+	switch pn.Token.Type {
+	case godata.FilterTokenLogical:
+		return db.evaluateLogical(r, pn)
+	case godata.FilterTokenOp:
+		return db.evaluateArithmetic(r, pn)
+	case godata.FilterTokenFunc:
+		return db.evaluateFunction(r, pn)
+	case godata.FilterTokenNav:
+		return db.resolvePath(r, navigationPath(pn)), nil
+	case godata.FilterTokenLiteral:
+		return propertyValue(r, pn.Token.Value), nil
+	case godata.FilterTokenGeography:
+		if len(pn.Children) == 0 {
+			return nil, errors.New("Geography without value")
+		}
+		return parseWKT(unquote(pn.Children[0].Token.Value))
+	case godata.FilterTokenString:
+		return unquote(pn.Token.Value), nil
+	case godata.FilterTokenInteger, godata.FilterTokenFloat:
+		return strconv.ParseFloat(pn.Token.Value, 64)
+	case godata.FilterTokenBoolean:
+		return strings.ToLower(pn.Token.Value) == "true", nil
+	case godata.FilterTokenNull:
+		return nil, nil
+	case godata.FilterTokenDate, godata.FilterTokenDateTime:
+		t, ok := parseTime(pn.Token.Value)
+		if !ok {
+			return nil, fmt.Errorf("Unable to parse %s", pn.Token.Value)
+		}
+		return t, nil
+	case godata.FilterTokenTime:
+		return pn.Token.Value, nil
+	}
+
+	return nil, fmt.Errorf("Filter %s not supported", pn.Token.Value)
+}
+
+func (db *MemoryDatabase) evaluateLogical(r *record, pn *godata.ParseNode) (interface{}, error) {
+	operator := strings.ToLower(pn.Token.Value)
+	if operator == "not" {
+		v, err := db.evaluate(r, pn.Children[0])
+		b, _ := v.(bool)
+		return !b, err
+	}
+
+	if len(pn.Children) != 2 {
+		return nil, fmt.Errorf("Operator %s needs two operands", operator)
+	}
+
+	left, err := db.evaluate(r, pn.Children[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if operator == "and" || operator == "or" {
+		l, _ := left.(bool)
+		if (operator == "and" && !l) || (operator == "or" && l) {
+			return l, nil
+		}
+
+		right, err := db.evaluate(r, pn.Children[1])
+		rb, _ := right.(bool)
+		return rb, err
+	}
+
+	right, err := db.evaluate(r, pn.Children[1])
+	if err != nil {
+		return nil, err
+	}
+
+	switch operator {
+	case "eq":
+		return equal(left, right), nil
+	case "ne":
+		return !equal(left, right), nil
+	}
+
+	c, ok := compare(left, right)
+	if !ok {
+		return false, nil
+	}
+
+	switch operator {
+	case "gt":
+		return c > 0, nil
+	case "ge":
+		return c >= 0, nil
+	case "lt":
+		return c < 0, nil
+	case "le":
+		return c <= 0, nil
+	}
+
+	return nil, fmt.Errorf("Operator %s not supported", operator)
+}
+
+func (db *MemoryDatabase) evaluateArithmetic(r *record, pn *godata.ParseNode) (interface{}, error) {
+	if len(pn.Children) != 2 {
+		return nil, fmt.Errorf("Operator %s needs two operands", pn.Token.Value)
+	}
+
+	args, err := db.evaluateChildren(r, pn)
+	if err != nil {
+		return nil, err
+	}
+
+	a, ok := toFloat(args[0])
+	b, ok2 := toFloat(args[1])
+	if !ok || !ok2 {
+		return nil, nil
+	}
+
+	switch pn.Token.Value {
+	case "add":
+		return a + b, nil
+	case "sub":
+		return a - b, nil
+	case "mul":
+		return a * b, nil
+	case "div":
+		if b == 0 {
+			return nil, errors.New("Division by zero")
+		}
+		return a / b, nil
+	case "mod":
+		if int(b) == 0 {
+			return nil, errors.New("Division by zero")
+		}
+		return float64(int(a) % int(b)), nil
+	}
+
+	return nil, fmt.Errorf("Operator %s not supported", pn.Token.Value)
+}
+
+func (db *MemoryDatabase) evaluateFunction(r *record, pn *godata.ParseNode) (interface{}, error) {
+	f, ok := funcToValueMap[strings.ToLower(pn.Token.Value)]
+	if !ok {
+		return nil, fmt.Errorf("Function %s not supported", pn.Token.Value)
+	}
+
+	args, err := db.evaluateChildren(r, pn)
+	if err != nil {
+		return nil, err
+	}
+
+	return f(args)
+}
+
+func (db *MemoryDatabase) evaluateChildren(r *record, pn *godata.ParseNode) ([]interface{}, error) {
+	args := make([]interface{}, len(pn.Children))
+	for i, c := range pn.Children {
+		v, err := db.evaluate(r, c)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = v
+	}
+
+	return args, nil
+}
+
+// navigationPath flattens a navigation node such as Datastream/Thing/name into its segments
+func navigationPath(pn *godata.ParseNode) []string {
+	if pn.Token.Type != godata.FilterTokenNav {
+		return []string{pn.Token.Value}
+	}
+
+	path := make([]string, 0)
+	for _, c := range pn.Children {
+		path = append(path, navigationPath(c)...)
+	}
+
+	return path
+}
+
+// unquote removes the quotes of an OData string literal and unescapes the quotes inside
+func unquote(literal string) string {
+	if len(literal) >= 2 && strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") {
+		literal = literal[1 : len(literal)-1]
+	}
+
+	return strings.Replace(literal, "''", "'", -1)
+}
+
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// compare compares two values of the same kind, numbers and times are converted when the other
+// value is a string. False is returned when the values can not be compared
+func compare(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return compareFloat(fa, fb), true
+		}
+	}
+
+	ta, aIsTime := a.(time.Time)
+	tb, bIsTime := b.(time.Time)
+	if aIsTime || bIsTime {
+		var ok bool
+		if !aIsTime {
+			if ta, ok = toTime(a); !ok {
+				return 0, false
+			}
+		}
+		if !bIsTime {
+			if tb, ok = toTime(b); !ok {
+				return 0, false
+			}
+		}
+
+		return compareFloat(float64(ta.UnixNano()), float64(tb.UnixNano())), true
+	}
+
+	sa, aIsString := a.(string)
+	sb, bIsString := b.(string)
+	if aIsString && bIsString {
+		return strings.Compare(sa, sb), true
+	}
+
+	ba, aIsBool := a.(bool)
+	bb, bIsBool := b.(bool)
+	if aIsBool && bIsBool {
+		if ba == bb {
+			return 0, true
+		} else if bb {
+			return -1, true
+		}
+		return 1, true
+	}
+
+	return 0, false
+}
+
+func compareFloat(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+// toFloat converts numbers and numeric strings, observation results can be posted as both
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		return parseTime(t)
+	}
+
+	return time.Time{}, false
+}
+
+// parseTime parses an ISO 8601 time or date, for time intervals the start is returned
+func parseTime(s string) (time.Time, bool) {
+	if i := strings.Index(s, "/"); i != -1 {
+		s = s[:i]
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
+func checkArgs(args []interface{}, min, max int) error {
+	if len(args) < min || len(args) > max {
+		return fmt.Errorf("Expected %v to %v arguments, got %v", min, max, len(args))
+	}
+
+	return nil
+}
+
+func containsValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.Contains(toString(args[0]), toString(args[1])), nil
+}
+
+func substringofValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.Contains(toString(args[1]), toString(args[0])), nil
+}
+
+func endswithValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasSuffix(toString(args[0]), toString(args[1])), nil
+}
+
+func startswithValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+}
+
+func lengthValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return float64(len([]rune(toString(args[0])))), nil
+}
+
+func indexofValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	s := toString(args[0])
+	i := strings.Index(s, toString(args[1]))
+	if i > 0 {
+		i = len([]rune(s[:i]))
+	}
+
+	return float64(i), nil
+}
+
+func substringValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 3); err != nil {
+		return nil, err
+	}
+
+	s := []rune(toString(args[0]))
+	start, _ := toFloat(args[1])
+	from := int(math.Min(math.Max(start, 0), float64(len(s))))
+	to := len(s)
+	if len(args) == 3 {
+		length, _ := toFloat(args[2])
+		to = int(math.Min(float64(from)+math.Max(length, 0), float64(len(s))))
+	}
+
+	return string(s[from:to]), nil
+}
+
+func tolowerValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return strings.ToLower(toString(args[0])), nil
+}
+
+func toupperValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(toString(args[0])), nil
+}
+
+func trimValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return strings.TrimSpace(toString(args[0])), nil
+}
+
+func concatValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	return toString(args[0]) + toString(args[1]), nil
+}
+
+func mathValue(f func(float64) float64) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		v, ok := toFloat(args[0])
+		if !ok {
+			return nil, nil
+		}
+
+		return f(v), nil
+	}
+}
+
+// round rounds half away from zero, the same as PostgreSQL round
+func round(v float64) float64 {
+	if v < 0 {
+		return math.Ceil(v - 0.5)
+	}
+
+	return math.Floor(v + 0.5)
+}
+
+func timePartValue(f func(time.Time) float64) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		t, ok := toTime(args[0])
+		if !ok {
+			return nil, nil
+		}
+
+		return f(t), nil
+	}
+}
+
+func dateValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	t, ok := toTime(args[0])
+	if !ok {
+		return nil, nil
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func timeValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	t, ok := toTime(args[0])
+	if !ok {
+		return nil, nil
+	}
+
+	return t.Format("15:04:05"), nil
+}
+
+func nowValue(args []interface{}) (interface{}, error) {
+	return time.Now(), nil
+}
+
+func maxdatetimeValue(args []interface{}) (interface{}, error) {
+	return time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC), nil
+}
+
+func mindatetimeValue(args []interface{}) (interface{}, error) {
+	return time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+func geolengthValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	g, err := toGeometry(args[0])
+	if err != nil || g == nil {
+		return nil, err
+	}
+
+	return length(g), nil
+}
+
+func geodistanceValue(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	a, err := toGeometry(args[0])
+	if err != nil || a == nil {
+		return nil, err
+	}
+
+	b, err := toGeometry(args[1])
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	return distance(a, b), nil
+}
+
+func spatialValue(predicate func(a, b *geometry) bool) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 2, 2); err != nil {
+			return nil, err
+		}
+
+		a, err := toGeometry(args[0])
+		if err != nil || a == nil {
+			return false, err
+		}
+
+		b, err := toGeometry(args[1])
+		if err != nil || b == nil {
+			return false, err
+		}
+
+		return predicate(a, b), nil
+	}
+}
@@ -0,0 +1,406 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const epsilon = 1e-9
+
+type point struct {
+	x, y float64
+}
+
+// geometry is a simplified planar geometry used by the spatial filter functions: points holds all
+// vertices, lines the linestrings and polygons the rings of polygons, holes are not supported
+type geometry struct {
+	points   []point
+	lines    [][]point
+	polygons [][]point
+}
+
+// toGeometry converts a GeoJSON value from an entity or a parsed WKT literal into a geometry
+func toGeometry(v interface{}) (*geometry, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case *geometry:
+		return t, nil
+	case map[string]interface{}:
+		g := &geometry{}
+		return g, g.addGeoJSON(t)
+	case string:
+		return parseWKT(t)
+	}
+
+	return nil, fmt.Errorf("Unable to use %v as geometry", v)
+}
+
+func (g *geometry) addGeoJSON(m map[string]interface{}) error {
+	geometryType, _ := m["type"].(string)
+	switch geometryType {
+	case "Feature":
+		if child, ok := m["geometry"].(map[string]interface{}); ok {
+			return g.addGeoJSON(child)
+		}
+		return nil
+	case "FeatureCollection", "GeometryCollection":
+		key := "geometries"
+		if geometryType == "FeatureCollection" {
+			key = "features"
+		}
+
+		children, _ := m[key].([]interface{})
+		for _, c := range children {
+			if child, ok := c.(map[string]interface{}); ok {
+				if err := g.addGeoJSON(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	coordinates, _ := m["coordinates"].([]interface{})
+	switch geometryType {
+	case "Point":
+		p, err := toPoint(coordinates)
+		if err != nil {
+			return err
+		}
+		g.points = append(g.points, p)
+	case "MultiPoint", "LineString":
+		points, err := toPoints(coordinates)
+		if err != nil {
+			return err
+		}
+		g.addLine(points, geometryType == "LineString")
+	case "MultiLineString", "Polygon":
+		for _, c := range coordinates {
+			points, err := toPoints(c)
+			if err != nil {
+				return err
+			}
+
+			if geometryType == "Polygon" {
+				g.addPolygon(points)
+			} else {
+				g.addLine(points, true)
+			}
+		}
+	case "MultiPolygon":
+		for _, polygon := range coordinates {
+			rings, _ := polygon.([]interface{})
+			for _, r := range rings {
+				points, err := toPoints(r)
+				if err != nil {
+					return err
+				}
+				g.addPolygon(points)
+			}
+		}
+	default:
+		return fmt.Errorf("Geometry type %v not supported", geometryType)
+	}
+
+	return nil
+}
+
+func (g *geometry) addLine(points []point, isLine bool) {
+	g.points = append(g.points, points...)
+	if isLine {
+		g.lines = append(g.lines, points)
+	}
+}
+
+func (g *geometry) addPolygon(points []point) {
+	g.points = append(g.points, points...)
+	g.polygons = append(g.polygons, points)
+}
+
+func toPoint(v interface{}) (point, error) {
+	c, ok := v.([]interface{})
+	if !ok || len(c) < 2 {
+		return point{}, fmt.Errorf("Invalid coordinate %v", v)
+	}
+
+	x, ok := toFloat(c[0])
+	y, ok2 := toFloat(c[1])
+	if !ok || !ok2 {
+		return point{}, fmt.Errorf("Invalid coordinate %v", v)
+	}
+
+	return point{x, y}, nil
+}
+
+func toPoints(v interface{}) ([]point, error) {
+	c, _ := v.([]interface{})
+	points := make([]point, 0, len(c))
+	for _, p := range c {
+		pt, err := toPoint(p)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, pt)
+	}
+
+	return points, nil
+}
+
+// parseWKT parses the WKT types POINT, LINESTRING and POLYGON, an optional SRID=4326; prefix is ignored
+func parseWKT(wkt string) (*geometry, error) {
+	wkt = strings.TrimSpace(wkt)
+	if i := strings.Index(wkt, ";"); i != -1 && strings.HasPrefix(strings.ToUpper(wkt), "SRID=") {
+		wkt = wkt[i+1:]
+	}
+
+	open := strings.Index(wkt, "(")
+	if open == -1 || !strings.HasSuffix(wkt, ")") {
+		return nil, fmt.Errorf("Invalid WKT %s", wkt)
+	}
+
+	geometryType := strings.ToUpper(strings.TrimSpace(wkt[:open]))
+	body := wkt[open+1 : len(wkt)-1]
+	g := &geometry{}
+
+	switch geometryType {
+	case "POINT":
+		points, err := parseWKTPoints(body)
+		if err != nil || len(points) != 1 {
+			return nil, fmt.Errorf("Invalid WKT %s", wkt)
+		}
+		g.points = points
+	case "LINESTRING":
+		points, err := parseWKTPoints(body)
+		if err != nil {
+			return nil, err
+		}
+		g.addLine(points, true)
+	case "POLYGON":
+		for _, ring := range strings.Split(body, "),") {
+			points, err := parseWKTPoints(strings.Trim(strings.TrimSpace(ring), "()"))
+			if err != nil {
+				return nil, err
+			}
+			g.addPolygon(points)
+		}
+	default:
+		return nil, fmt.Errorf("WKT type %s not supported, supported: POINT, LINESTRING, POLYGON", geometryType)
+	}
+
+	return g, nil
+}
+
+func parseWKTPoints(s string) ([]point, error) {
+	points := make([]point, 0)
+	for _, p := range strings.Split(s, ",") {
+		xy := strings.Fields(p)
+		if len(xy) < 2 {
+			return nil, fmt.Errorf("Invalid WKT coordinate %s", p)
+		}
+
+		x, err := strconv.ParseFloat(xy[0], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := strconv.ParseFloat(xy[1], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, point{x, y})
+	}
+
+	return points, nil
+}
+
+// segments returns all line segments of the linestrings and polygon rings
+func (g *geometry) segments() [][2]point {
+	segments := make([][2]point, 0)
+	for _, line := range g.lines {
+		for i := 1; i < len(line); i++ {
+			segments = append(segments, [2]point{line[i-1], line[i]})
+		}
+	}
+
+	for _, ring := range g.polygons {
+		for i := range ring {
+			segments = append(segments, [2]point{ring[i], ring[(i+1)%len(ring)]})
+		}
+	}
+
+	return segments
+}
+
+// containsPoint returns true if p is inside or on the border of one of the polygons, on one of the lines
+// or equal to one of the points
+func (g *geometry) containsPoint(p point) bool {
+	for _, ring := range g.polygons {
+		if pointInRing(p, ring) {
+			return true
+		}
+	}
+
+	for _, s := range g.segments() {
+		if onSegment(p, s[0], s[1]) {
+			return true
+		}
+	}
+
+	for _, gp := range g.points {
+		if samePoint(p, gp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func intersects(a, b *geometry) bool {
+	for _, p := range a.points {
+		if b.containsPoint(p) {
+			return true
+		}
+	}
+
+	for _, p := range b.points {
+		if a.containsPoint(p) {
+			return true
+		}
+	}
+
+	for _, s1 := range a.segments() {
+		for _, s2 := range b.segments() {
+			if segmentsIntersect(s1[0], s1[1], s2[0], s2[1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// within returns true when all vertices of a are inside b, edges leaving concave polygons are not checked
+func within(a, b *geometry) bool {
+	if len(a.points) == 0 {
+		return false
+	}
+
+	for _, p := range a.points {
+		if !b.containsPoint(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equals(a, b *geometry) bool {
+	return containsAllPoints(a.points, b.points) && containsAllPoints(b.points, a.points)
+}
+
+func containsAllPoints(points, other []point) bool {
+	for _, p := range points {
+		found := false
+		for _, o := range other {
+			if samePoint(p, o) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return len(points) > 0
+}
+
+// distance returns the minimum planar distance between two geometries in the units of the coordinates
+func distance(a, b *geometry) float64 {
+	if intersects(a, b) {
+		return 0
+	}
+
+	d := math.Inf(1)
+	for _, p := range a.points {
+		for _, o := range b.points {
+			d = math.Min(d, math.Hypot(p.x-o.x, p.y-o.y))
+		}
+
+		for _, s := range b.segments() {
+			d = math.Min(d, pointSegmentDistance(p, s[0], s[1]))
+		}
+	}
+
+	for _, p := range b.points {
+		for _, s := range a.segments() {
+			d = math.Min(d, pointSegmentDistance(p, s[0], s[1]))
+		}
+	}
+
+	return d
+}
+
+// length returns the length of the linestrings of a geometry
+func length(g *geometry) float64 {
+	l := 0.0
+	for _, line := range g.lines {
+		for i := 1; i < len(line); i++ {
+			l += math.Hypot(line[i].x-line[i-1].x, line[i].y-line[i-1].y)
+		}
+	}
+
+	return l
+}
+
+func samePoint(a, b point) bool {
+	return math.Abs(a.x-b.x) < epsilon && math.Abs(a.y-b.y) < epsilon
+}
+
+// pointInRing uses ray casting to check if p is inside the ring
+func pointInRing(p point, ring []point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func orientation(a, b, c point) float64 {
+	return (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+}
+
+func onSegment(p, a, b point) bool {
+	return math.Abs(orientation(a, b, p)) < epsilon &&
+		p.x >= math.Min(a.x, b.x)-epsilon && p.x <= math.Max(a.x, b.x)+epsilon &&
+		p.y >= math.Min(a.y, b.y)-epsilon && p.y <= math.Max(a.y, b.y)+epsilon
+}
+
+func segmentsIntersect(a, b, c, d point) bool {
+	o1, o2 := orientation(a, b, c), orientation(a, b, d)
+	o3, o4 := orientation(c, d, a), orientation(c, d, b)
+	if ((o1 > 0 && o2 < 0) || (o1 < 0 && o2 > 0)) && ((o3 > 0 && o4 < 0) || (o3 < 0 && o4 > 0)) {
+		return true
+	}
+
+	return onSegment(c, a, b) || onSegment(d, a, b) || onSegment(a, c, d) || onSegment(b, c, d)
+}
+
+func pointSegmentDistance(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	}
+
+	t := math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/(dx*dx+dy*dy)))
+	return math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy))
+}
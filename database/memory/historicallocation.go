@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"errors"
+	"time"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetHistoricalLocation returns a HistoricalLocation by id
+func (db *MemoryDatabase) GetHistoricalLocation(id interface{}, qo *odata.QueryOptions) (*entities.HistoricalLocation, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeHistoricalLocation, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.HistoricalLocation), nil
+}
+
+// GetHistoricalLocations returns all HistoricalLocations
+func (db *MemoryDatabase) GetHistoricalLocations(qo *odata.QueryOptions) ([]*entities.HistoricalLocation, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeHistoricalLocation), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toHistoricalLocations(result), count, hasNext, nil
+}
+
+// GetHistoricalLocationsByLocation returns the HistoricalLocations linked to the given location
+func (db *MemoryDatabase) GetHistoricalLocationsByLocation(locationID interface{}, qo *odata.QueryOptions) ([]*entities.HistoricalLocation, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(entities.EntityTypeLocation, locationID, entities.EntityTypeHistoricalLocation, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toHistoricalLocations(result), count, hasNext, nil
+}
+
+// GetHistoricalLocationsByThing returns the HistoricalLocations linked to the given thing
+func (db *MemoryDatabase) GetHistoricalLocationsByThing(thingID interface{}, qo *odata.QueryOptions) ([]*entities.HistoricalLocation, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(entities.EntityTypeThing, thingID, entities.EntityTypeHistoricalLocation, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toHistoricalLocations(result), count, hasNext, nil
+}
+
+// PostHistoricalLocation adds a HistoricalLocation for the given thing and locations
+func (db *MemoryDatabase) PostHistoricalLocation(hl *entities.HistoricalLocation) (*entities.HistoricalLocation, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.postHistoricalLocation(hl)
+}
+
+// postHistoricalLocation adds a HistoricalLocation, the caller should hold the lock
+func (db *MemoryDatabase) postHistoricalLocation(hl *entities.HistoricalLocation) (*entities.HistoricalLocation, error) {
+	var t *record
+	var ok bool
+	if hl.Thing == nil {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	if t, ok = db.get(entities.EntityTypeThing, hl.Thing.ID); !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	locations, err := db.historicalLocationLocations(hl)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hl.Time) == 0 {
+		hl.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	r := db.insert(entities.EntityTypeHistoricalLocation, hl)
+	db.link(r, t)
+	for _, l := range locations {
+		db.link(r, l)
+	}
+
+	hl.Locations = nil
+	return hl, nil
+}
+
+// historicalLocationLocations returns the records of the locations of a HistoricalLocation
+func (db *MemoryDatabase) historicalLocationLocations(hl *entities.HistoricalLocation) ([]*record, error) {
+	locations := make([]*record, 0, len(hl.Locations))
+	for _, l := range hl.Locations {
+		r, ok := db.get(entities.EntityTypeLocation, l.ID)
+		if !ok {
+			return nil, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+		}
+
+		locations = append(locations, r)
+	}
+
+	return locations, nil
+}
+
+// PutHistoricalLocation replaces a HistoricalLocation
+func (db *MemoryDatabase) PutHistoricalLocation(id interface{}, hl *entities.HistoricalLocation) (*entities.HistoricalLocation, error) {
+	return db.PatchHistoricalLocation(id, hl)
+}
+
+// PatchHistoricalLocation updates a HistoricalLocation, given locations are added to it
+func (db *MemoryDatabase) PatchHistoricalLocation(id interface{}, hl *entities.HistoricalLocation) (*entities.HistoricalLocation, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if !db.exists(entities.EntityTypeHistoricalLocation, id) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("HistoricalLocation does not exist"))
+	}
+
+	locations, err := db.historicalLocationLocations(hl)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := db.patch(entities.EntityTypeHistoricalLocation, id, hl)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range locations {
+		db.link(r, l)
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.HistoricalLocation), nil
+}
+
+// DeleteHistoricalLocation removes a HistoricalLocation
+func (db *MemoryDatabase) DeleteHistoricalLocation(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeHistoricalLocation, id)
+}
+
+func toHistoricalLocations(result []entities.Entity) []*entities.HistoricalLocation {
+	hls := make([]*entities.HistoricalLocation, len(result))
+	for i, e := range result {
+		hls[i] = e.(*entities.HistoricalLocation)
+	}
+
+	return hls
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"errors"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetLocation returns a location by id
+func (db *MemoryDatabase) GetLocation(id interface{}, qo *odata.QueryOptions) (*entities.Location, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeLocation, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Location), nil
+}
+
+// GetLocations returns all locations
+func (db *MemoryDatabase) GetLocations(qo *odata.QueryOptions) ([]*entities.Location, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeLocation), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toLocations(result), count, hasNext, nil
+}
+
+// GetLocationsByHistoricalLocation returns the locations linked to the given HistoricalLocation
+func (db *MemoryDatabase) GetLocationsByHistoricalLocation(hlID interface{}, qo *odata.QueryOptions) ([]*entities.Location, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(entities.EntityTypeHistoricalLocation, hlID, entities.EntityTypeLocation, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toLocations(result), count, hasNext, nil
+}
+
+// GetLocationByDatastreamID returns the location of the thing linked to the given datastream
+func (db *MemoryDatabase) GetLocationByDatastreamID(datastreamID interface{}, qo *odata.QueryOptions) (*entities.Location, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	d, ok := db.get(entities.EntityTypeDatastream, datastreamID)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("datastream does not exist"))
+	}
+
+	records := make([]*record, 0)
+	for _, t := range db.related(d, entities.EntityTypeThing) {
+		records = append(records, db.related(t, entities.EntityTypeLocation)...)
+	}
+
+	e, err := db.queryOne(records, nil, entities.EntityTypeLocation.ToString())
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Location), nil
+}
+
+// GetLocationsByThing returns the locations linked to the given thing
+func (db *MemoryDatabase) GetLocationsByThing(thingID interface{}, qo *odata.QueryOptions) ([]*entities.Location, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(entities.EntityTypeThing, thingID, entities.EntityTypeLocation, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toLocations(result), count, hasNext, nil
+}
+
+// PostLocation adds a location
+func (db *MemoryDatabase) PostLocation(location *entities.Location) (*entities.Location, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	db.insert(entities.EntityTypeLocation, location)
+	return location, nil
+}
+
+// LinkLocation links a location to a thing
+func (db *MemoryDatabase) LinkLocation(thingID interface{}, locationID interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	t, ok := db.get(entities.EntityTypeThing, thingID)
+	if !ok {
+		return gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	l, ok := db.get(entities.EntityTypeLocation, locationID)
+	if !ok {
+		return gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+	}
+
+	db.link(t, l)
+	return nil
+}
+
+// LocationExists checks if a location is present based on a given id
+func (db *MemoryDatabase) LocationExists(id interface{}) bool {
+	db.RLock()
+	defer db.RUnlock()
+
+	return db.exists(entities.EntityTypeLocation, id)
+}
+
+// PatchLocation updates a location
+func (db *MemoryDatabase) PatchLocation(id interface{}, l *entities.Location) (*entities.Location, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	r, err := db.patch(entities.EntityTypeLocation, id, l)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Location), nil
+}
+
+// DeleteLocation removes a location
+func (db *MemoryDatabase) DeleteLocation(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeLocation, id)
+}
+
+// PutLocation replaces a location
+func (db *MemoryDatabase) PutLocation(id interface{}, location *entities.Location) (*entities.Location, error) {
+	return db.PatchLocation(id, location)
+}
+
+func toLocations(result []entities.Entity) []*entities.Location {
+	locations := make([]*entities.Location, len(result))
+	for i, e := range result {
+		locations[i] = e.(*entities.Location)
+	}
+
+	return locations
+}
@@ -0,0 +1,385 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger *log.Entry
+
+// MemoryDatabase implements models.Database by keeping all entities and their relations in memory, it can
+// be used to run GOST without PostgreSQL for tests and demos. Nothing is persisted
+type MemoryDatabase struct {
+	sync.RWMutex
+	maxTop  int
+	lastIDs map[entities.EntityType]int
+	records map[entities.EntityType]map[int]*record
+	rollups map[int]map[rollupKey]*rollup
+}
+
+// record is a stored entity, values holds the JSON representation of the entity without id, links and
+// related entities, the relations are stored in links by entity type and id
+type record struct {
+	id                 int
+	entityType         entities.EntityType
+	values             map[string]interface{}
+	links              map[entities.EntityType]map[int]bool
+	originalLocationID int
+}
+
+func setupLogger() {
+	l, err := gostLog.GetPackageLogger("gost.server.database.memory")
+	if err != nil {
+		log.Error(err)
+	}
+
+	logger = l
+}
+
+// NewDatabase initialises an empty in-memory database, maxTop is the number of entities returned when
+// no $top is requested
+func NewDatabase(maxTop int) models.Database {
+	setupLogger()
+	return &MemoryDatabase{
+		maxTop:  maxTop,
+		lastIDs: make(map[entities.EntityType]int),
+		records: make(map[entities.EntityType]map[int]*record),
+		rollups: make(map[int]map[rollupKey]*rollup),
+	}
+}
+
+// Start the database
+func (db *MemoryDatabase) Start() {
+	logger.Infof("Using in-memory database, entities are not persisted")
+}
+
+// Ping always succeeds, the in-memory database has no connection
+func (db *MemoryDatabase) Ping() error {
+	return nil
+}
+
+// Close does nothing, the in-memory database has no connection
+func (db *MemoryDatabase) Close() error {
+	return nil
+}
+
+// WithContext returns the database itself, the in-memory database executes no statements to log
+func (db *MemoryDatabase) WithContext(ctx context.Context) models.Database {
+	return db
+}
+
+// CreateSchema does nothing, the in-memory database needs no schema
+func (db *MemoryDatabase) CreateSchema(location string) error {
+	return nil
+}
+
+// SchemaVersion returns 0 for both versions, the in-memory database needs no migrations
+func (db *MemoryDatabase) SchemaVersion() (int, int, error) {
+	return 0, 0, nil
+}
+
+// Migrate does nothing, the in-memory database needs no migrations
+func (db *MemoryDatabase) Migrate() (int, error) {
+	return 0, nil
+}
+
+// ToIntID converts an id from a request or entity to an int, false is returned when the id is no number
+func ToIntID(id interface{}) (int, bool) {
+	switch t := id.(type) {
+	case int:
+		return t, true
+	case float64:
+		return int(t), true
+	}
+
+	intID, err := strconv.Atoi(fmt.Sprintf("%v", id))
+	if err != nil {
+		return 0, false
+	}
+
+	return intID, true
+}
+
+// insert stores the given entity as a new record of the given type and sets the generated id on the entity
+func (db *MemoryDatabase) insert(et entities.EntityType, entity entities.Entity) *record {
+	db.lastIDs[et]++
+	r := &record{
+		id:         db.lastIDs[et],
+		entityType: et,
+		values:     entityValues(entity),
+		links:      make(map[entities.EntityType]map[int]bool),
+	}
+
+	if _, ok := db.records[et]; !ok {
+		db.records[et] = make(map[int]*record)
+	}
+
+	db.records[et][r.id] = r
+	entity.SetID(r.id)
+	return r
+}
+
+// update merges the set values of the given entity into the record, empty values are ignored
+func (db *MemoryDatabase) update(r *record, entity entities.Entity) {
+	for k, v := range entityValues(entity) {
+		if isEmptyValue(v) {
+			continue
+		}
+
+		r.values[k] = v
+	}
+}
+
+// get returns the record of the given type and id
+func (db *MemoryDatabase) get(et entities.EntityType, id interface{}) (*record, bool) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, false
+	}
+
+	r, ok := db.records[et][intID]
+	return r, ok
+}
+
+// exists checks if a record of the given type and id is present
+func (db *MemoryDatabase) exists(et entities.EntityType, id interface{}) bool {
+	_, ok := db.get(et, id)
+	return ok
+}
+
+// all returns all records of the given type ordered by id
+func (db *MemoryDatabase) all(et entities.EntityType) []*record {
+	records := make([]*record, 0, len(db.records[et]))
+	for _, r := range db.records[et] {
+		records = append(records, r)
+	}
+
+	sortRecordsByID(records)
+	return records
+}
+
+// related returns the records of the given type linked to record r ordered by id
+func (db *MemoryDatabase) related(r *record, et entities.EntityType) []*record {
+	records := make([]*record, 0, len(r.links[et]))
+	for id := range r.links[et] {
+		if related, ok := db.records[et][id]; ok {
+			records = append(records, related)
+		}
+	}
+
+	sortRecordsByID(records)
+	return records
+}
+
+// link creates a relation between two records, relations can be navigated in both directions
+func (db *MemoryDatabase) link(a, b *record) {
+	addLink(a, b)
+	addLink(b, a)
+}
+
+// unlinkType removes all relations of record r with records of the given type
+func (db *MemoryDatabase) unlinkType(r *record, et entities.EntityType) {
+	for _, related := range db.related(r, et) {
+		delete(related.links[r.entityType], r.id)
+	}
+
+	delete(r.links, et)
+}
+
+// remove deletes a record, its relations and the records depending on it, see cascades
+func (db *MemoryDatabase) remove(r *record) {
+	for _, et := range cascades[r.entityType] {
+		for _, related := range db.related(r, et) {
+			db.remove(related)
+		}
+	}
+
+	for et := range r.links {
+		db.unlinkType(r, et)
+	}
+
+	if r.entityType == entities.EntityTypeDatastream {
+		delete(db.rollups, r.id)
+	}
+
+	delete(db.records[r.entityType], r.id)
+}
+
+// deleteEntity deletes an entity of the given type by id
+func (db *MemoryDatabase) deleteEntity(et entities.EntityType, id interface{}) error {
+	if _, ok := ToIntID(id); !ok {
+		return gostErrors.NewRequestNotFound(fmt.Errorf("%s does not exist", et.ToString()))
+	}
+
+	r, ok := db.get(et, id)
+	if !ok {
+		return gostErrors.NewRequestNotFound(fmt.Errorf("%s not found", et.ToString()))
+	}
+
+	db.remove(r)
+	return nil
+}
+
+// patch merges the entity into the stored record of the given type and id
+func (db *MemoryDatabase) patch(et entities.EntityType, id interface{}, entity entities.Entity) (*record, error) {
+	r, ok := db.get(et, id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(fmt.Errorf("%s does not exist", et.ToString()))
+	}
+
+	db.update(r, entity)
+	return r, nil
+}
+
+// getByID returns the entity of the given type and id applying the query options
+func (db *MemoryDatabase) getByID(et entities.EntityType, id interface{}, qo *odata.QueryOptions) (entities.Entity, error) {
+	r, ok := db.get(et, id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(fmt.Errorf("%s not found", et.ToString()))
+	}
+
+	return db.queryOne([]*record{r}, qo, et.ToString())
+}
+
+// getRelated returns the entities of type et related to the parent of type pt and the given id
+func (db *MemoryDatabase) getRelated(pt entities.EntityType, id interface{}, et entities.EntityType, qo *odata.QueryOptions) ([]entities.Entity, int, bool, error) {
+	if _, ok := ToIntID(id); !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(fmt.Errorf("%s does not exist", pt.ToString()))
+	}
+
+	records := make([]*record, 0)
+	if parent, ok := db.get(pt, id); ok {
+		records = db.related(parent, et)
+	}
+
+	return db.query(records, qo)
+}
+
+// getRelatedOne returns the single entity of type et related to the parent of type pt and the given id
+func (db *MemoryDatabase) getRelatedOne(pt entities.EntityType, id interface{}, et entities.EntityType, qo *odata.QueryOptions) (entities.Entity, error) {
+	if _, ok := ToIntID(id); !ok {
+		return nil, gostErrors.NewRequestNotFound(fmt.Errorf("%s does not exist", pt.ToString()))
+	}
+
+	records := make([]*record, 0)
+	if parent, ok := db.get(pt, id); ok {
+		records = db.related(parent, et)
+	}
+
+	return db.queryOne(records, qo, et.ToString())
+}
+
+func addLink(from, to *record) {
+	if _, ok := from.links[to.entityType]; !ok {
+		from.links[to.entityType] = make(map[int]bool)
+	}
+
+	from.links[to.entityType][to.id] = true
+}
+
+func sortRecordsByID(records []*record) {
+	sort.Slice(records, func(i, j int) bool { return records[i].id < records[j].id })
+}
+
+// entityValues converts an entity into its JSON representation without id, links and related entities
+func entityValues(entity entities.Entity) map[string]interface{} {
+	values := make(map[string]interface{})
+	b, err := json.Marshal(entity)
+	if err != nil {
+		return values
+	}
+
+	json.Unmarshal(b, &values)
+	for k := range values {
+		// related entities start with an uppercase character: Thing, Datastreams, etc
+		if strings.Contains(k, "@iot.") || (len(k) > 0 && strings.ToUpper(k[:1]) == k[:1]) {
+			delete(values, k)
+		}
+	}
+
+	return values
+}
+
+// toEntity creates a new entity from a record, when selected is not nil only the selected values are set
+func toEntity(r *record, selected map[string]bool) (entities.Entity, error) {
+	entity := newEntity(r.entityType)
+	if entity == nil {
+		return nil, fmt.Errorf("Entity type %s not supported", r.entityType.ToString())
+	}
+
+	values := r.values
+	if selected != nil {
+		values = make(map[string]interface{})
+		for k, v := range r.values {
+			if selected[strings.ToLower(k)] {
+				values[k] = v
+			}
+		}
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(b, entity); err != nil {
+		return nil, errors.New("Unable to create " + r.entityType.ToString())
+	}
+
+	if selected == nil || selected["id"] {
+		entity.SetID(r.id)
+	}
+
+	return entity, nil
+}
+
+func newEntity(et entities.EntityType) entities.Entity {
+	switch et {
+	case entities.EntityTypeThing:
+		return &entities.Thing{}
+	case entities.EntityTypeLocation:
+		return &entities.Location{}
+	case entities.EntityTypeHistoricalLocation:
+		return &entities.HistoricalLocation{}
+	case entities.EntityTypeDatastream:
+		return &entities.Datastream{}
+	case entities.EntityTypeSensor:
+		return &entities.Sensor{}
+	case entities.EntityTypeObservedProperty:
+		return &entities.ObservedProperty{}
+	case entities.EntityTypeObservation:
+		return &entities.Observation{}
+	case entities.EntityTypeFeatureOfInterest:
+		return &entities.FeatureOfInterest{}
+	}
+
+	return nil
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return len(t) == 0
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+
+	return false
+}
@@ -0,0 +1,363 @@
+package memory
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestDatabase() (*MemoryDatabase, *entities.Datastream) {
+	db := NewDatabase(200).(*MemoryDatabase)
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test thing"})
+	sensor, _ := db.PostSensor(&entities.Sensor{Name: "sensor"})
+	op, _ := db.PostObservedProperty(&entities.ObservedProperty{Name: "temperature"})
+	ds := &entities.Datastream{
+		Name:             "datastream",
+		ObservationType:  "http://www.opengis.net/def/observationType/OGC-OM/2.0/OM_Measurement",
+		Thing:            &entities.Thing{},
+		Sensor:           &entities.Sensor{},
+		ObservedProperty: &entities.ObservedProperty{},
+	}
+	ds.Thing.ID = thing.ID
+	ds.Sensor.ID = sensor.ID
+	ds.ObservedProperty.ID = op.ID
+	db.PostDatastream(ds)
+
+	return db, ds
+}
+
+func postTestObservation(db *MemoryDatabase, dsID, foiID interface{}, result string) (*entities.Observation, error) {
+	o := &entities.Observation{Result: json.RawMessage(result), PhenomenonTime: "2017-01-01T00:00:00.000Z", Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+	o.Datastream.ID = dsID
+	o.FeatureOfInterest.ID = foiID
+	return db.PostObservation(o)
+}
+
+func TestPostAndGetThing(t *testing.T) {
+	// arrange
+	db := NewDatabase(200)
+
+	// act
+	thing, err := db.PostThing(&entities.Thing{Name: "thing", Description: "test"})
+	stored, err2 := db.GetThing(thing.ID, nil)
+	_, err3 := db.GetThing(999, nil)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Nil(t, err2)
+	assert.Equal(t, 1, thing.ID)
+	assert.Equal(t, "thing", stored.Name)
+	assert.Equal(t, http.StatusNotFound, err3.(gostErrors.APIError).GetHTTPErrorStatusCode())
+}
+
+func TestPatchThing(t *testing.T) {
+	// arrange
+	db := NewDatabase(200)
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test"})
+
+	// act
+	patched, err := db.PatchThing(thing.ID, &entities.Thing{Name: "patched"})
+	_, err2 := db.PatchThing(999, &entities.Thing{Name: "patched"})
+
+	// assert
+	assert.Nil(t, err)
+	assert.NotNil(t, err2)
+	assert.Equal(t, "patched", patched.Name)
+	assert.Equal(t, "test", patched.Description)
+}
+
+func TestPostDatastreamRelationsExist(t *testing.T) {
+	// arrange
+	db, ds := createTestDatabase()
+
+	// act
+	_, err := db.PostDatastream(&entities.Datastream{Name: "no relations"})
+	thing, err2 := db.GetThingByDatastream(ds.ID, nil)
+
+	// assert
+	assert.NotNil(t, err)
+	assert.Nil(t, err2)
+	assert.Equal(t, "thing", thing.Name)
+	assert.Nil(t, ds.Thing)
+}
+
+func TestPostObservationForeignKeys(t *testing.T) {
+	// arrange
+	db, ds := createTestDatabase()
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+
+	// act
+	_, err := postTestObservation(db, ds.ID, 999, "1")
+	_, err2 := postTestObservation(db, 999, foi.ID, "1")
+	o, err3 := postTestObservation(db, ds.ID, foi.ID, "1")
+	observations, count, _, _ := db.GetObservationsByDatastream(ds.ID, nil)
+
+	// assert
+	assert.NotNil(t, err)
+	assert.NotNil(t, err2)
+	assert.Nil(t, err3)
+	assert.Nil(t, o.Datastream)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, len(observations))
+}
+
+func TestPostObservationsAtomic(t *testing.T) {
+	// arrange
+	db, ds := createTestDatabase()
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+	create := func() []*entities.Observation {
+		observations := make([]*entities.Observation, 2)
+		for i := range observations {
+			observations[i] = &entities.Observation{Result: json.RawMessage(strconv.Itoa(i)), Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+			observations[i].Datastream.ID = ds.ID
+			observations[i].FeatureOfInterest.ID = foi.ID
+		}
+
+		observations[1].Datastream.ID = 999
+		return observations
+	}
+
+	// act
+	_, errs := db.PostObservations(create(), true)
+	_, countAtomic, _, _ := db.GetObservations(nil)
+	created, errs2 := db.PostObservations(create(), false)
+	_, count, _, _ := db.GetObservations(nil)
+
+	// assert
+	assert.Equal(t, 0, countAtomic)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, 1, len(errs2))
+	assert.NotNil(t, created[0])
+	assert.Nil(t, created[1])
+	assert.Equal(t, 1, count)
+}
+
+func TestQueryFilterOrderByTopSkip(t *testing.T) {
+	// arrange
+	db := NewDatabase(200)
+	for _, name := range []string{"b", "a", "c", "a"} {
+		db.PostThing(&entities.Thing{Name: name, Description: "test"})
+	}
+
+	filter, _ := godata.ParseFilterString("name eq 'a' or startswith(name, 'c')")
+	orderBy, _ := godata.ParseOrderByString("name desc,id asc")
+	top, _ := godata.ParseTopString("2")
+	skip, _ := godata.ParseSkipString("1")
+
+	filterQo := &odata.QueryOptions{}
+	filterQo.Filter = filter
+	orderQo := &odata.QueryOptions{}
+	orderQo.OrderBy = orderBy
+	pageQo := &odata.QueryOptions{}
+	pageQo.Top = top
+	pageQo.Skip = skip
+
+	// act
+	all, count, hasNext, _ := db.GetThings(nil)
+	filtered, filteredCount, _, _ := db.GetThings(filterQo)
+	ordered, _, _, _ := db.GetThings(orderQo)
+	paged, pagedCount, pagedHasNext, _ := db.GetThings(pageQo)
+
+	// assert
+	assert.Equal(t, 4, count)
+	assert.False(t, hasNext)
+	assert.Equal(t, 4, all[0].ID, "default order should be id descending")
+	assert.Equal(t, 3, filteredCount)
+	assert.Equal(t, 3, len(filtered))
+	assert.Equal(t, "c", ordered[0].Name)
+	assert.Equal(t, 2, ordered[2].ID)
+	assert.Equal(t, 4, ordered[3].ID)
+	assert.Equal(t, 4, pagedCount)
+	assert.True(t, pagedHasNext)
+	assert.Equal(t, 2, len(paged))
+	assert.Equal(t, 3, paged[0].ID)
+}
+
+func TestQuerySkipToken(t *testing.T) {
+	// arrange
+	db := NewDatabase(200)
+	for _, name := range []string{"b", "a", "c", "a"} {
+		db.PostThing(&entities.Thing{Name: name, Description: "test"})
+	}
+
+	orderBy, _ := godata.ParseOrderByString("name")
+	top, _ := godata.ParseTopString("2")
+	qo := &odata.QueryOptions{SkipToken: odata.SkipToken{"a", int64(4)}}
+	qo.OrderBy = orderBy
+	qo.Top = top
+
+	// act
+	page, count, hasNext, _ := db.GetThings(qo)
+
+	// assert
+	assert.Equal(t, 4, count)
+	assert.True(t, hasNext)
+	assert.Equal(t, 2, len(page))
+	assert.Equal(t, 2, page[0].ID, "things with the same name should be ordered by id descending")
+	assert.Equal(t, "b", page[1].Name)
+}
+
+func TestGetObservationAggregates(t *testing.T) {
+	// arrange
+	db, ds := createTestDatabase()
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+	for i, phenomenonTime := range []string{"2017-01-01T00:10:00.000Z", "2017-01-01T00:50:00.000Z", "2017-01-01T01:30:00.000Z/2017-01-01T01:40:00.000Z", "2017-01-01T03:00:00.000Z"} {
+		o := &entities.Observation{Result: json.RawMessage(strconv.Itoa(i)), PhenomenonTime: phenomenonTime, Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+		o.Datastream.ID = ds.ID
+		o.FeatureOfInterest.ID = foi.ID
+		db.PostObservation(o)
+	}
+
+	qo := &odata.QueryOptions{}
+	qo.Aggregation, _ = odata.ParseApply("groupby((interval(phenomenonTime,PT1H)),aggregate(result with average as avg,result with max as max,$count as count))")
+	qo.OrderBy, _ = godata.ParseOrderByString("phenomenonTime asc")
+	qo.Top, _ = godata.ParseTopString("2")
+
+	// act
+	aggregates, count, hasNext, err := db.GetObservationAggregates(ds.ID, qo)
+	b, _ := json.Marshal(aggregates[0])
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+	assert.True(t, hasNext)
+	assert.Equal(t, 2, len(aggregates))
+	assert.JSONEq(t, `{"phenomenonTime":"2017-01-01T00:00:00.000Z/2017-01-01T01:00:00.000Z","avg":0.5,"max":1,"count":2}`, string(b))
+	assert.Equal(t, int64(1), aggregates[1].Values["count"])
+	assert.Equal(t, 2.0, aggregates[1].Values["avg"])
+}
+
+func TestPruneObservations(t *testing.T) {
+	// arrange
+	db, ds := createTestDatabase()
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+	for i, phenomenonTime := range []string{"2017-01-01T00:10:00.000Z", "2017-01-01T00:50:00.000Z", "2017-01-01T01:30:00.000Z", "2017-01-02T00:00:00.000Z"} {
+		o := &entities.Observation{Result: json.RawMessage(strconv.Itoa(i)), PhenomenonTime: phenomenonTime, Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+		o.Datastream.ID = ds.ID
+		o.FeatureOfInterest.ID = foi.ID
+		db.PostObservation(o)
+	}
+	before, _ := time.Parse(time.RFC3339, "2017-01-01T02:00:00Z")
+
+	id, _ := ToIntID(ds.ID)
+
+	// act
+	pruned, err := db.PruneObservations(ds.ID, before, time.Hour)
+	_, count, _, _ := db.GetObservationsByDatastream(ds.ID, &odata.QueryOptions{})
+	rollups := make(map[rollupKey]*rollup)
+	for k, v := range db.rollups[id] {
+		rollups[k] = v
+	}
+	prunedRollups, rollupErr := db.PruneObservationRollups(ds.ID, before.Add(-time.Hour))
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), pruned)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 2, len(rollups))
+	assert.Equal(t, &rollup{observations: 2, results: 2, sum: 1, min: 0, max: 1}, rollups[rollupKey{interval: 3600, start: before.Unix() - 7200}])
+	assert.Nil(t, rollupErr)
+	assert.Equal(t, int64(1), prunedRollups)
+	assert.Equal(t, 1, len(db.rollups[id]))
+}
+
+func TestQueryInvalidFilter(t *testing.T) {
+	// arrange
+	db := NewDatabase(200)
+	db.PostThing(&entities.Thing{Name: "thing", Description: "test"})
+	qo := &odata.QueryOptions{}
+	qo.Filter, _ = godata.ParseFilterString("st_touches(name, 'POINT(1 1)')")
+
+	// act
+	_, _, _, err := db.GetThings(qo)
+
+	// assert
+	assert.NotNil(t, err)
+}
+
+func TestExpand(t *testing.T) {
+	// arrange
+	db, ds := createTestDatabase()
+	qo := &odata.QueryOptions{}
+	qo.Expand, _ = godata.ParseExpandString("Datastreams/Sensor,Locations")
+
+	// act
+	things, _, _, err := db.GetThings(qo)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(things[0].Datastreams))
+	assert.Equal(t, ds.ID, things[0].Datastreams[0].ID)
+	assert.Equal(t, "sensor", things[0].Datastreams[0].Sensor.Name)
+	assert.Equal(t, 0, len(things[0].Locations))
+}
+
+func TestDeleteCascade(t *testing.T) {
+	// arrange
+	db, ds := createTestDatabase()
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+	o, _ := postTestObservation(db, ds.ID, foi.ID, "1")
+	sensor, _ := db.GetSensorByDatastream(ds.ID, nil)
+
+	// act
+	err := db.DeleteSensor(sensor.ID)
+	err2 := db.DeleteSensor(sensor.ID)
+
+	// assert
+	assert.Nil(t, err)
+	assert.NotNil(t, err2)
+	assert.False(t, db.DatastreamExists(ds.ID.(int)))
+	_, oErr := db.GetObservation(o.ID, nil)
+	assert.NotNil(t, oErr)
+	assert.True(t, db.ThingExists(1))
+}
+
+func TestPatchThingLocations(t *testing.T) {
+	// arrange
+	db := NewDatabase(200)
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test"})
+	l1, _ := db.PostLocation(&entities.Location{Name: "l1"})
+	l2, _ := db.PostLocation(&entities.Location{Name: "l2"})
+	db.LinkLocation(thing.ID, l1.ID)
+	patch := &entities.Thing{Locations: []*entities.Location{{}}}
+	patch.Locations[0].ID = l2.ID
+
+	// act
+	_, err := db.PatchThing(thing.ID, patch)
+	locations, _, _, _ := db.GetLocationsByThing(thing.ID, nil)
+	hls, _, _, _ := db.GetHistoricalLocationsByThing(thing.ID, nil)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(locations))
+	assert.Equal(t, "l2", locations[0].Name)
+	assert.Equal(t, 1, len(hls))
+}
+
+func TestGeometry(t *testing.T) {
+	// arrange
+	polygon, _ := parseWKT("SRID=4326;POLYGON((0 0,10 0,10 10,0 10,0 0))")
+	inside, _ := parseWKT("POINT(5 5)")
+	outside, _ := toGeometry(map[string]interface{}{"type": "Point", "coordinates": []interface{}{13.0, 14.0}})
+	line, _ := parseWKT("LINESTRING(-5 5,15 5)")
+
+	// assert
+	assert.True(t, within(inside, polygon))
+	assert.False(t, within(outside, polygon))
+	assert.True(t, intersects(line, polygon))
+	assert.False(t, intersects(outside, polygon))
+	assert.Equal(t, 5.0, distance(outside, polygon))
+	assert.Equal(t, 20.0, length(line))
+	assert.True(t, equals(polygon, polygon))
+
+	_, err := parseWKT("CIRCLE(1 1)")
+	assert.NotNil(t, err)
+}
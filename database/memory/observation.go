@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"errors"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetObservation returns an observation by id
+func (db *MemoryDatabase) GetObservation(id interface{}, qo *odata.QueryOptions) (*entities.Observation, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeObservation, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Observation), nil
+}
+
+// GetObservations returns all observations
+func (db *MemoryDatabase) GetObservations(qo *odata.QueryOptions) ([]*entities.Observation, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeObservation), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toObservations(result), count, hasNext, nil
+}
+
+// GetObservationsByFeatureOfInterest returns the observations of the given FeatureOfInterest
+func (db *MemoryDatabase) GetObservationsByFeatureOfInterest(foiID interface{}, qo *odata.QueryOptions) ([]*entities.Observation, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(entities.EntityTypeFeatureOfInterest, foiID, entities.EntityTypeObservation, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toObservations(result), count, hasNext, nil
+}
+
+// GetObservationsByDatastream returns the observations of the given datastream
+func (db *MemoryDatabase) GetObservationsByDatastream(dataStreamID interface{}, qo *odata.QueryOptions) ([]*entities.Observation, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(entities.EntityTypeDatastream, dataStreamID, entities.EntityTypeObservation, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toObservations(result), count, hasNext, nil
+}
+
+// PostObservation adds an observation
+func (db *MemoryDatabase) PostObservation(o *entities.Observation) (*entities.Observation, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	d, f, err := db.observationRelations(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.insertObservation(o, d, f), nil
+}
+
+// PostObservations adds a batch of observations, the returned slice holds the created observations in the same
+// order, the returned map holds the errors by index. When atomic is true nothing is added when an observation
+// fails, otherwise only the failing observations are skipped
+func (db *MemoryDatabase) PostObservations(observations []*entities.Observation, atomic bool) ([]*entities.Observation, map[int]error) {
+	db.Lock()
+	defer db.Unlock()
+
+	created := make([]*entities.Observation, len(observations))
+	errs := make(map[int]error)
+	relations := make([][2]*record, len(observations))
+	for i, o := range observations {
+		d, f, err := db.observationRelations(o)
+		if err != nil {
+			errs[i] = err
+			if atomic {
+				return created, errs
+			}
+			continue
+		}
+
+		relations[i] = [2]*record{d, f}
+	}
+
+	for i, o := range observations {
+		if _, failed := errs[i]; !failed {
+			created[i] = db.insertObservation(o, relations[i][0], relations[i][1])
+		}
+	}
+
+	return created, errs
+}
+
+// PostObservationsBulk adds a batch of observations all or nothing
+func (db *MemoryDatabase) PostObservationsBulk(observations []*entities.Observation) ([]*entities.Observation, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	relations := make([][2]*record, len(observations))
+	for i, o := range observations {
+		d, f, err := db.observationRelations(o)
+		if err != nil {
+			return nil, err
+		}
+
+		relations[i] = [2]*record{d, f}
+	}
+
+	for i, o := range observations {
+		db.insertObservation(o, relations[i][0], relations[i][1])
+	}
+
+	return observations, nil
+}
+
+// observationRelations returns the datastream and FeatureOfInterest records of an observation to insert
+func (db *MemoryDatabase) observationRelations(o *entities.Observation) (*record, *record, error) {
+	if o.Datastream == nil {
+		return nil, nil, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
+	}
+
+	d, ok := db.get(entities.EntityTypeDatastream, o.Datastream.ID)
+	if !ok {
+		return nil, nil, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
+	}
+
+	if o.FeatureOfInterest == nil || o.FeatureOfInterest.ID == nil {
+		return nil, nil, gostErrors.NewBadRequestError(errors.New("No FeatureOfInterest supplied or Location found on linked thing"))
+	}
+
+	f, ok := db.get(entities.EntityTypeFeatureOfInterest, o.FeatureOfInterest.ID)
+	if !ok {
+		return nil, nil, gostErrors.NewBadRequestError(errors.New("FeatureOfInterest does not exist"))
+	}
+
+	return d, f, nil
+}
+
+func (db *MemoryDatabase) insertObservation(o *entities.Observation, d, f *record) *entities.Observation {
+	r := db.insert(entities.EntityTypeObservation, o)
+	db.link(r, d)
+	db.link(r, f)
+
+	// clear inner entities to serves links upon response
+	o.Datastream = nil
+	o.FeatureOfInterest = nil
+
+	return o
+}
+
+// PatchObservation updates an observation
+func (db *MemoryDatabase) PatchObservation(id interface{}, o *entities.Observation) (*entities.Observation, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	r, err := db.patch(entities.EntityTypeObservation, id, o)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Observation), nil
+}
+
+// PutObservation replaces an observation
+func (db *MemoryDatabase) PutObservation(id interface{}, o *entities.Observation) (*entities.Observation, error) {
+	return db.PatchObservation(id, o)
+}
+
+// DeleteObservation removes an observation
+func (db *MemoryDatabase) DeleteObservation(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeObservation, id)
+}
+
+func toObservations(result []entities.Entity) []*entities.Observation {
+	observations := make([]*entities.Observation, len(result))
+	for i, e := range result {
+		observations[i] = e.(*entities.Observation)
+	}
+
+	return observations
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	entities "github.com/gost/core"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetObservedProperty returns an ObservedProperty by id
+func (db *MemoryDatabase) GetObservedProperty(id interface{}, qo *odata.QueryOptions) (*entities.ObservedProperty, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeObservedProperty, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.ObservedProperty), nil
+}
+
+// GetObservedPropertyByDatastream returns the ObservedProperty of the given datastream
+func (db *MemoryDatabase) GetObservedPropertyByDatastream(id interface{}, qo *odata.QueryOptions) (*entities.ObservedProperty, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getRelatedOne(entities.EntityTypeDatastream, id, entities.EntityTypeObservedProperty, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.ObservedProperty), nil
+}
+
+// GetObservedProperties returns all ObservedProperties
+func (db *MemoryDatabase) GetObservedProperties(qo *odata.QueryOptions) ([]*entities.ObservedProperty, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeObservedProperty), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	ops := make([]*entities.ObservedProperty, len(result))
+	for i, e := range result {
+		ops[i] = e.(*entities.ObservedProperty)
+	}
+
+	return ops, count, hasNext, nil
+}
+
+// PostObservedProperty adds an ObservedProperty
+func (db *MemoryDatabase) PostObservedProperty(op *entities.ObservedProperty) (*entities.ObservedProperty, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	db.insert(entities.EntityTypeObservedProperty, op)
+	return op, nil
+}
+
+// PatchObservedProperty updates an ObservedProperty
+func (db *MemoryDatabase) PatchObservedProperty(id interface{}, op *entities.ObservedProperty) (*entities.ObservedProperty, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	r, err := db.patch(entities.EntityTypeObservedProperty, id, op)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.ObservedProperty), nil
+}
+
+// PutObservedProperty replaces an ObservedProperty
+func (db *MemoryDatabase) PutObservedProperty(id interface{}, op *entities.ObservedProperty) (*entities.ObservedProperty, error) {
+	return db.PatchObservedProperty(id, op)
+}
+
+// DeleteObservedProperty removes an ObservedProperty and its datastreams
+func (db *MemoryDatabase) DeleteObservedProperty(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeObservedProperty, id)
+}
@@ -0,0 +1,327 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// expandNode groups the expand items by their first navigation property, Datastreams and
+// Datastreams/Observations are both expanded on the same Datastreams
+type expandNode struct {
+	name  string
+	qo    *odata.QueryOptions
+	items []*godata.ExpandItem
+}
+
+// query filters, orders and pages the records using the given query options and creates the entities including
+// their expanded relations, count is the number of records matching the filter. The same as the PostGIS query
+// an extra record is checked to see if there is a next page
+func (db *MemoryDatabase) query(records []*record, qo *odata.QueryOptions) ([]entities.Entity, int, bool, error) {
+	matched := make([]*record, 0, len(records))
+	for _, r := range records {
+		ok, err := db.matchFilter(r, qo)
+		if err != nil {
+			return nil, 0, false, gostErrors.NewBadRequestError(err)
+		}
+
+		if ok {
+			matched = append(matched, r)
+		}
+	}
+
+	db.orderRecords(matched, qo)
+	count := len(matched)
+	matched = db.afterSkipToken(matched, qo)
+
+	skip := db.getSkip(qo)
+	if skip >= len(matched) {
+		matched = matched[:0]
+	} else {
+		matched = matched[skip:]
+	}
+
+	hasNext := false
+	if top := db.getTop(qo); top >= 0 && len(matched) > top {
+		hasNext = true
+		matched = matched[:top]
+	}
+
+	result := make([]entities.Entity, 0, len(matched))
+	for _, r := range matched {
+		e, err := db.createEntity(r, qo)
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		result = append(result, e)
+	}
+
+	return result, count, hasNext, nil
+}
+
+// queryOne returns the first entity found by query, notFound is returned as error when there is none
+func (db *MemoryDatabase) queryOne(records []*record, qo *odata.QueryOptions, notFound string) (entities.Entity, error) {
+	result, _, _, err := db.query(records, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, gostErrors.NewRequestNotFound(fmt.Errorf("%s not found", notFound))
+	}
+
+	return result[0], nil
+}
+
+// getTop returns the max entities to retrieve, set by $top or the configured maximum, -1 means no limit
+func (db *MemoryDatabase) getTop(qo *odata.QueryOptions) int {
+	if qo != nil && qo.Top != nil {
+		if int(*qo.Top) < 0 {
+			return -1
+		}
+
+		return int(*qo.Top)
+	}
+
+	return db.maxTop
+}
+
+// getSkip returns the number of entities to skip set by $skip, $skip is ignored when a $skiptoken is given
+func (db *MemoryDatabase) getSkip(qo *odata.QueryOptions) int {
+	if qo != nil && qo.Skip != nil && int(*qo.Skip) > 0 && len(qo.SkipToken) == 0 {
+		return int(*qo.Skip)
+	}
+
+	return 0
+}
+
+func (db *MemoryDatabase) matchFilter(r *record, qo *odata.QueryOptions) (bool, error) {
+	if qo == nil || qo.Filter == nil || qo.Filter.Tree == nil || qo.Filter.Tree.Token == nil {
+		return true, nil
+	}
+
+	v, err := db.evaluate(r, qo.Filter.Tree)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	return ok && b, nil
+}
+
+// orderRecords sorts the records by $orderby followed by id descending, when not given the records are
+// ordered by id descending
+func (db *MemoryDatabase) orderRecords(records []*record, qo *odata.QueryOptions) {
+	keys := qo.SortKeys()
+	sort.SliceStable(records, func(i, j int) bool {
+		return compareSortValues(db.sortValues(records[i], keys), db.sortValues(records[j], keys), keys) < 0
+	})
+}
+
+// afterSkipToken returns the ordered records placed after the position of the $skiptoken
+func (db *MemoryDatabase) afterSkipToken(records []*record, qo *odata.QueryOptions) []*record {
+	if qo == nil || len(qo.SkipToken) == 0 {
+		return records
+	}
+
+	keys := qo.SortKeys()
+	for i, r := range records {
+		if compareSortValues(db.sortValues(r, keys), qo.SkipToken, keys) > 0 {
+			return records[i:]
+		}
+	}
+
+	return records[:0]
+}
+
+// sortValues returns the values of the sort keys of a record
+func (db *MemoryDatabase) sortValues(r *record, keys []odata.SortKey) []interface{} {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = db.resolvePath(r, strings.Split(key.Field, "/"))
+	}
+
+	return values
+}
+
+// compareSortValues returns a negative number when the values a are ordered before b and a positive
+// number when they are ordered after b
+func compareSortValues(a, b []interface{}, keys []odata.SortKey) int {
+	for i, key := range keys {
+		c := compareOrder(a[i], b[i])
+		if c == 0 {
+			continue
+		}
+
+		if key.Desc {
+			return -c
+		}
+
+		return c
+	}
+
+	return 0
+}
+
+// compareOrder compares two values for ordering, nil values are placed last
+func compareOrder(a, b interface{}) int {
+	if a == nil && b == nil {
+		return 0
+	} else if a == nil {
+		return 1
+	} else if b == nil {
+		return -1
+	}
+
+	c, ok := compare(a, b)
+	if !ok {
+		return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+	}
+
+	return c
+}
+
+// resolvePath returns the value of a property path of a record such as name, properties/owner or
+// Datastream/Thing/name, only related entities with a single relation can be navigated
+func (db *MemoryDatabase) resolvePath(r *record, path []string) interface{} {
+	current := r
+	for i, segment := range path {
+		if n, ok := getNavigation(current.entityType, segment); ok && n.single && i < len(path)-1 {
+			related := db.related(current, n.entityType)
+			if len(related) == 0 {
+				return nil
+			}
+
+			current = related[0]
+			continue
+		}
+
+		value := propertyValue(current, segment)
+		for _, s := range path[i+1:] {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+
+			value = lookup(m, s)
+		}
+
+		return value
+	}
+
+	return nil
+}
+
+// propertyValue returns the value of a property of the record by case insensitive name
+func propertyValue(r *record, name string) interface{} {
+	if strings.ToLower(name) == "id" {
+		return float64(r.id)
+	}
+
+	return lookup(r.values, name)
+}
+
+func lookup(m map[string]interface{}, name string) interface{} {
+	if v, ok := m[name]; ok {
+		return v
+	}
+
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// createEntity creates the entity of a record applying $select and $expand
+func (db *MemoryDatabase) createEntity(r *record, qo *odata.QueryOptions) (entities.Entity, error) {
+	var selected map[string]bool
+	if qo != nil && qo.Select != nil && len(qo.Select.SelectItems) > 0 {
+		selected = make(map[string]bool)
+		for _, si := range qo.Select.SelectItems {
+			if len(si.Segments) > 0 {
+				selected[strings.ToLower(si.Segments[0].Value)] = true
+			}
+		}
+	}
+
+	entity, err := toEntity(r, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	if qo != nil && qo.Expand != nil && len(qo.Expand.ExpandItems) > 0 {
+		if err = db.expand(r, entity, qo.Expand.ExpandItems); err != nil {
+			return nil, err
+		}
+	}
+
+	return entity, nil
+}
+
+// expand adds the related entities requested by the expand items to the entity
+func (db *MemoryDatabase) expand(r *record, entity entities.Entity, items []*godata.ExpandItem) error {
+	nodes := make([]*expandNode, 0)
+	for _, item := range items {
+		if len(item.Path) == 0 {
+			continue
+		}
+
+		var node *expandNode
+		for _, n := range nodes {
+			if strings.EqualFold(n.name, item.Path[0].Value) {
+				node = n
+			}
+		}
+
+		if node == nil {
+			node = &expandNode{name: item.Path[0].Value}
+			nodes = append(nodes, node)
+		}
+
+		if len(item.Path) == 1 {
+			node.qo = odata.ExpandItemToQueryOptions(item)
+			if item.Expand != nil {
+				node.items = append(node.items, item.Expand.ExpandItems...)
+			}
+		} else {
+			child := *item
+			child.Path = item.Path[1:]
+			node.items = append(node.items, &child)
+		}
+	}
+
+	for _, node := range nodes {
+		n, ok := getNavigation(r.entityType, node.name)
+		if !ok {
+			return gostErrors.NewBadRequestError(fmt.Errorf("Unable to expand %s on %s", node.name, r.entityType.ToString()))
+		}
+
+		qo := &odata.QueryOptions{}
+		if node.qo != nil {
+			*qo = *node.qo
+		}
+
+		qo.Expand = nil
+		if len(node.items) > 0 {
+			qo.Expand = &godata.GoDataExpandQuery{ExpandItems: node.items}
+		}
+
+		related, _, _, err := db.query(db.related(r, n.entityType), qo)
+		if err != nil {
+			return err
+		}
+
+		addRelationToEntity(entity, related)
+	}
+
+	return nil
+}
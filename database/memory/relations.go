@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"strings"
+
+	entities "github.com/gost/core"
+)
+
+// navigation describes a navigation property such as Thing/Datastreams, single is true when
+// at most one entity can be related (Observation/Datastream)
+type navigation struct {
+	entityType entities.EntityType
+	single     bool
+}
+
+// navigations contains the navigation properties per entity type by lowercase name
+var navigations = map[entities.EntityType]map[string]navigation{
+	entities.EntityTypeThing: {
+		"locations":           {entities.EntityTypeLocation, false},
+		"historicallocations": {entities.EntityTypeHistoricalLocation, false},
+		"datastreams":         {entities.EntityTypeDatastream, false},
+	},
+	entities.EntityTypeLocation: {
+		"things":              {entities.EntityTypeThing, false},
+		"historicallocations": {entities.EntityTypeHistoricalLocation, false},
+	},
+	entities.EntityTypeHistoricalLocation: {
+		"thing":     {entities.EntityTypeThing, true},
+		"locations": {entities.EntityTypeLocation, false},
+	},
+	entities.EntityTypeDatastream: {
+		"thing":            {entities.EntityTypeThing, true},
+		"sensor":           {entities.EntityTypeSensor, true},
+		"observedproperty": {entities.EntityTypeObservedProperty, true},
+		"observations":     {entities.EntityTypeObservation, false},
+	},
+	entities.EntityTypeSensor: {
+		"datastreams": {entities.EntityTypeDatastream, false},
+	},
+	entities.EntityTypeObservedProperty: {
+		"datastreams": {entities.EntityTypeDatastream, false},
+	},
+	entities.EntityTypeObservation: {
+		"datastream":        {entities.EntityTypeDatastream, true},
+		"featureofinterest": {entities.EntityTypeFeatureOfInterest, true},
+	},
+	entities.EntityTypeFeatureOfInterest: {
+		"observations": {entities.EntityTypeObservation, false},
+	},
+}
+
+// cascades contains the entity types that are deleted together with an entity, the same as the
+// ON DELETE CASCADE constraints in the PostGIS schema
+var cascades = map[entities.EntityType][]entities.EntityType{
+	entities.EntityTypeThing:             {entities.EntityTypeDatastream, entities.EntityTypeHistoricalLocation},
+	entities.EntityTypeSensor:            {entities.EntityTypeDatastream},
+	entities.EntityTypeObservedProperty:  {entities.EntityTypeDatastream},
+	entities.EntityTypeDatastream:        {entities.EntityTypeObservation},
+	entities.EntityTypeFeatureOfInterest: {entities.EntityTypeObservation},
+}
+
+// getNavigation returns the navigation property of an entity type by name, case insensitive
+func getNavigation(et entities.EntityType, name string) (navigation, bool) {
+	n, ok := navigations[et][strings.ToLower(name)]
+	return n, ok
+}
+
+func addRelationToEntity(parent entities.Entity, subEntities []entities.Entity) {
+	switch parentEntity := parent.(type) {
+	case *entities.Thing:
+		addRelationToThing(parentEntity, subEntities)
+	case *entities.Location:
+		addRelationToLocation(parentEntity, subEntities)
+	case *entities.HistoricalLocation:
+		addRelationToHistoricalLocation(parentEntity, subEntities)
+	case *entities.Datastream:
+		addRelationToDatastream(parentEntity, subEntities)
+	case *entities.Sensor:
+		addRelationToSensor(parentEntity, subEntities)
+	case *entities.ObservedProperty:
+		addRelationToObservedProperty(parentEntity, subEntities)
+	case *entities.Observation:
+		addRelationToObservation(parentEntity, subEntities)
+	case *entities.FeatureOfInterest:
+		addRelationToFeatureOfInterest(parentEntity, subEntities)
+	}
+}
+
+func addRelationToThing(parentEntity *entities.Thing, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.HistoricalLocation:
+			parentEntity.HistoricalLocations = append(parentEntity.HistoricalLocations, subEntity)
+		case *entities.Location:
+			parentEntity.Locations = append(parentEntity.Locations, subEntity)
+		case *entities.Datastream:
+			parentEntity.Datastreams = append(parentEntity.Datastreams, subEntity)
+		}
+	}
+}
+
+func addRelationToLocation(parentEntity *entities.Location, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.HistoricalLocation:
+			parentEntity.HistoricalLocations = append(parentEntity.HistoricalLocations, subEntity)
+		case *entities.Thing:
+			parentEntity.Things = append(parentEntity.Things, subEntity)
+		}
+	}
+}
+
+func addRelationToHistoricalLocation(parentEntity *entities.HistoricalLocation, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Thing:
+			parentEntity.Thing = subEntity
+		case *entities.Location:
+			parentEntity.Locations = append(parentEntity.Locations, subEntity)
+		}
+	}
+}
+
+func addRelationToDatastream(parentEntity *entities.Datastream, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Observation:
+			parentEntity.Observations = append(parentEntity.Observations, subEntity)
+		case *entities.Thing:
+			parentEntity.Thing = subEntity
+		case *entities.Sensor:
+			parentEntity.Sensor = subEntity
+		case *entities.ObservedProperty:
+			parentEntity.ObservedProperty = subEntity
+		}
+	}
+}
+
+func addRelationToSensor(parentEntity *entities.Sensor, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Datastream:
+			parentEntity.Datastreams = append(parentEntity.Datastreams, subEntity)
+		}
+	}
+}
+
+func addRelationToObservedProperty(parentEntity *entities.ObservedProperty, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Datastream:
+			parentEntity.Datastreams = append(parentEntity.Datastreams, subEntity)
+		}
+	}
+}
+
+func addRelationToObservation(parentEntity *entities.Observation, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Datastream:
+			parentEntity.Datastream = subEntity
+		case *entities.FeatureOfInterest:
+			parentEntity.FeatureOfInterest = subEntity
+		}
+	}
+}
+
+func addRelationToFeatureOfInterest(parentEntity *entities.FeatureOfInterest, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Observation:
+			parentEntity.Observations = append(parentEntity.Observations, subEntity)
+		}
+	}
+}
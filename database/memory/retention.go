@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"errors"
+	"time"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+)
+
+// rollupKey identifies the rollup of the interval of interval seconds starting at start seconds since the Unix epoch
+type rollupKey struct {
+	interval int64
+	start    int64
+}
+
+// rollup holds the aggregates of the pruned observations of a datastream in an interval, results is the
+// number of numeric results used for sum, min and max
+type rollup struct {
+	observations int64
+	results      int64
+	sum          float64
+	min          float64
+	max          float64
+}
+
+// PruneObservations deletes the observations of the datastream with a phenomenonTime before the given time, when
+// rollupInterval is set the observations are first added to the rollups of that interval
+func (db *MemoryDatabase) PruneObservations(datastreamID interface{}, before time.Time, rollupInterval time.Duration) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	ds, err := db.retentionDatastream(datastreamID)
+	if err != nil {
+		return 0, err
+	}
+
+	seconds := int64(rollupInterval / time.Second)
+	pruned := int64(0)
+	for _, r := range db.related(ds, entities.EntityTypeObservation) {
+		t, isTime := toTime(propertyValue(r, "phenomenonTime"))
+		if !isTime || !t.Before(before) {
+			continue
+		}
+
+		if seconds > 0 {
+			db.addToRollup(ds.id, rollupKey{interval: seconds, start: intervalStart(t, seconds)}, propertyValue(r, "result"))
+		}
+
+		db.remove(r)
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// PruneObservationRollups deletes the rollups of the datastream of intervals starting before the given time
+func (db *MemoryDatabase) PruneObservationRollups(datastreamID interface{}, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	ds, err := db.retentionDatastream(datastreamID)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := int64(0)
+	for key := range db.rollups[ds.id] {
+		if key.start < before.Unix() {
+			delete(db.rollups[ds.id], key)
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+// retentionDatastream returns the record of the datastream to prune
+func (db *MemoryDatabase) retentionDatastream(datastreamID interface{}) (*record, error) {
+	if _, ok := ToIntID(datastreamID); !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	ds, ok := db.get(entities.EntityTypeDatastream, datastreamID)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	return ds, nil
+}
+
+// addToRollup adds an observation with the given result to the rollup of the datastream, results that
+// are no number only count as observation
+func (db *MemoryDatabase) addToRollup(datastreamID int, key rollupKey, result interface{}) {
+	rollups, ok := db.rollups[datastreamID]
+	if !ok {
+		rollups = make(map[rollupKey]*rollup)
+		db.rollups[datastreamID] = rollups
+	}
+
+	r, ok := rollups[key]
+	if !ok {
+		r = &rollup{}
+		rollups[key] = r
+	}
+
+	r.observations++
+	value, isNumber := result.(float64)
+	if !isNumber {
+		return
+	}
+
+	if r.results == 0 || value < r.min {
+		r.min = value
+	}
+
+	if r.results == 0 || value > r.max {
+		r.max = value
+	}
+
+	r.sum += value
+	r.results++
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	entities "github.com/gost/core"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetSensor returns a sensor by id
+func (db *MemoryDatabase) GetSensor(id interface{}, qo *odata.QueryOptions) (*entities.Sensor, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeSensor, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Sensor), nil
+}
+
+// GetSensorByDatastream returns the sensor of the given datastream
+func (db *MemoryDatabase) GetSensorByDatastream(id interface{}, qo *odata.QueryOptions) (*entities.Sensor, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getRelatedOne(entities.EntityTypeDatastream, id, entities.EntityTypeSensor, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Sensor), nil
+}
+
+// GetSensors returns all sensors
+func (db *MemoryDatabase) GetSensors(qo *odata.QueryOptions) ([]*entities.Sensor, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeSensor), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	sensors := make([]*entities.Sensor, len(result))
+	for i, e := range result {
+		sensors[i] = e.(*entities.Sensor)
+	}
+
+	return sensors, count, hasNext, nil
+}
+
+// PostSensor adds a sensor
+func (db *MemoryDatabase) PostSensor(sensor *entities.Sensor) (*entities.Sensor, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	db.insert(entities.EntityTypeSensor, sensor)
+	return sensor, nil
+}
+
+// PatchSensor updates a sensor
+func (db *MemoryDatabase) PatchSensor(id interface{}, s *entities.Sensor) (*entities.Sensor, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	r, err := db.patch(entities.EntityTypeSensor, id, s)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Sensor), nil
+}
+
+// PutSensor replaces a sensor
+func (db *MemoryDatabase) PutSensor(id interface{}, sensor *entities.Sensor) (*entities.Sensor, error) {
+	return db.PatchSensor(id, sensor)
+}
+
+// DeleteSensor removes a sensor and its datastreams
+func (db *MemoryDatabase) DeleteSensor(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeSensor, id)
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	entities "github.com/gost/core"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// GetThing returns a thing by id
+func (db *MemoryDatabase) GetThing(id interface{}, qo *odata.QueryOptions) (*entities.Thing, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getByID(entities.EntityTypeThing, id, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Thing), nil
+}
+
+// GetThingByDatastream returns a thing linked to the given datastream
+func (db *MemoryDatabase) GetThingByDatastream(id interface{}, qo *odata.QueryOptions) (*entities.Thing, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getRelatedOne(entities.EntityTypeDatastream, id, entities.EntityTypeThing, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Thing), nil
+}
+
+// GetThingsByLocation returns things linked to the given location
+func (db *MemoryDatabase) GetThingsByLocation(id interface{}, qo *odata.QueryOptions) ([]*entities.Thing, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.getRelated(entities.EntityTypeLocation, id, entities.EntityTypeThing, qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toThings(result), count, hasNext, nil
+}
+
+// GetThingByHistoricalLocation returns the thing linked to the given HistoricalLocation
+func (db *MemoryDatabase) GetThingByHistoricalLocation(id interface{}, qo *odata.QueryOptions) (*entities.Thing, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	e, err := db.getRelatedOne(entities.EntityTypeHistoricalLocation, id, entities.EntityTypeThing, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Thing), nil
+}
+
+// GetThings returns all things
+func (db *MemoryDatabase) GetThings(qo *odata.QueryOptions) ([]*entities.Thing, int, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	result, count, hasNext, err := db.query(db.all(entities.EntityTypeThing), qo)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return toThings(result), count, hasNext, nil
+}
+
+// PostThing adds a thing
+func (db *MemoryDatabase) PostThing(thing *entities.Thing) (*entities.Thing, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	db.insert(entities.EntityTypeThing, thing)
+	return thing, nil
+}
+
+// PutThing replaces a thing
+func (db *MemoryDatabase) PutThing(id interface{}, thing *entities.Thing) (*entities.Thing, error) {
+	return db.PatchThing(id, thing)
+}
+
+// PatchThing updates a thing, when locations are given the current location of the thing is replaced
+// and a HistoricalLocation is added
+func (db *MemoryDatabase) PatchThing(id interface{}, thing *entities.Thing) (*entities.Thing, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	r, err := db.patch(entities.EntityTypeThing, id, thing)
+	if err != nil {
+		return nil, err
+	}
+
+	thing.ID = r.id
+	for _, l := range thing.Locations {
+		location, ok := db.get(entities.EntityTypeLocation, l.ID)
+		if !ok {
+			continue
+		}
+
+		db.unlinkType(r, entities.EntityTypeLocation)
+		db.link(r, location)
+
+		l.ID = location.id
+		hl := &entities.HistoricalLocation{
+			Thing:     thing,
+			Locations: []*entities.Location{l},
+		}
+
+		hl.ContainsMandatoryParams()
+		db.postHistoricalLocation(hl)
+	}
+
+	e, err := toEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.(*entities.Thing), nil
+}
+
+// DeleteThing removes a thing, its datastreams and HistoricalLocations
+func (db *MemoryDatabase) DeleteThing(id interface{}) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.deleteEntity(entities.EntityTypeThing, id)
+}
+
+// ThingExists checks if a thing is present based on a given id
+func (db *MemoryDatabase) ThingExists(id interface{}) bool {
+	db.RLock()
+	defer db.RUnlock()
+
+	return db.exists(entities.EntityTypeThing, id)
+}
+
+func toThings(result []entities.Entity) []*entities.Thing {
+	things := make([]*entities.Thing, len(result))
+	for i, e := range result {
+		things[i] = e.(*entities.Thing)
+	}
+
+	return things
+}
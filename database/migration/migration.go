@@ -0,0 +1,139 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrSchemaTooNew is returned when the database has been migrated by a newer version of GOST, the schema can
+// contain changes this version does not know about so it refuses to use the database
+var ErrSchemaTooNew = errors.New("database schema is newer than supported by this version of GOST, upgrade GOST")
+
+// Migration is an upgrade of the database schema, migrations are applied in order of Version and every
+// migration is applied once. The SQL of a released migration should never change, add a new migration instead
+type Migration struct {
+	Version     int
+	Description string
+	SQL         string
+}
+
+// Dialect holds the statements to keep track of the applied migrations in a database
+type Dialect struct {
+	// CreateTable creates the migrations table when it does not exist
+	CreateTable string
+	// SelectVersion selects the highest applied version or 0 when no migration has been applied
+	SelectVersion string
+	// Insert registers an applied migration, the parameters are the version and description
+	Insert string
+	// Lock is executed at the start of the transaction of a migration to keep other instances from
+	// migrating at the same time, it can be empty when the database only has a single writer
+	Lock string
+}
+
+// Latest returns the highest version of the migrations
+func Latest(migrations []Migration) int {
+	latest := 0
+	for _, m := range migrations {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+
+	return latest
+}
+
+// Pending returns the migrations with a version above current ordered by version
+func Pending(migrations []Migration, current int) []Migration {
+	pending := make([]Migration, 0)
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+	return pending
+}
+
+// Version returns the version of the schema in the database and the latest version of the migrations,
+// ErrSchemaTooNew is returned when the database has a higher version than the latest migration
+func Version(db *sql.DB, d Dialect, migrations []Migration) (int, int, error) {
+	if _, err := db.Exec(d.CreateTable); err != nil {
+		return 0, 0, fmt.Errorf("Error creating migrations table: %v", err)
+	}
+
+	var current int
+	if err := db.QueryRow(d.SelectVersion).Scan(&current); err != nil {
+		return 0, 0, fmt.Errorf("Error reading schema version: %v", err)
+	}
+
+	latest := Latest(migrations)
+	if current > latest {
+		return current, latest, ErrSchemaTooNew
+	}
+
+	return current, latest, nil
+}
+
+// Apply applies the pending migrations in order, every migration runs in its own transaction together with
+// its registration in the migrations table. The applied migrations are returned, when a migration fails the
+// migrations before it remain applied
+func Apply(db *sql.DB, d Dialect, migrations []Migration) ([]Migration, error) {
+	current, _, err := Version(db, d, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	for _, m := range Pending(migrations, current) {
+		ok, err := apply(db, d, m)
+		if err != nil {
+			return applied, fmt.Errorf("Error applying migration %d (%s): %v", m.Version, m.Description, err)
+		}
+
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+
+	return applied, nil
+}
+
+// apply runs a migration in a transaction, false is returned when another instance applied it in the meantime
+func apply(db *sql.DB, d Dialect, m Migration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	if len(d.Lock) > 0 {
+		if _, err = tx.Exec(d.Lock); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	var current int
+	if err = tx.QueryRow(d.SelectVersion).Scan(&current); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if current >= m.Version {
+		return false, tx.Rollback()
+	}
+
+	if _, err = tx.Exec(m.SQL); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if _, err = tx.Exec(d.Insert, m.Version, m.Description); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
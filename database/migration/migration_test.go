@@ -0,0 +1,98 @@
+package migration
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3" // sqlite driver
+	"github.com/stretchr/testify/assert"
+)
+
+var testDialect = Dialect{
+	CreateTable:   "CREATE TABLE IF NOT EXISTS migrations (version INTEGER PRIMARY KEY, description TEXT)",
+	SelectVersion: "SELECT COALESCE(MAX(version), 0) FROM migrations",
+	Insert:        "INSERT INTO migrations (version, description) VALUES (?1, ?2)",
+}
+
+var testMigrations = []Migration{
+	{Version: 2, Description: "add unit", SQL: "ALTER TABLE thing ADD COLUMN unit TEXT"},
+	{Version: 1, Description: "create thing", SQL: "CREATE TABLE thing (id INTEGER PRIMARY KEY, name TEXT)"},
+}
+
+func openTestDatabase() *sql.DB {
+	db, _ := sql.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestPending(t *testing.T) {
+	// act
+	all := Pending(testMigrations, 0)
+	none := Pending(testMigrations, 2)
+
+	// assert
+	assert.Equal(t, 2, Latest(testMigrations))
+	assert.Equal(t, 2, len(all))
+	assert.Equal(t, 1, all[0].Version)
+	assert.Equal(t, 2, all[1].Version)
+	assert.Equal(t, 0, len(none))
+}
+
+func TestApply(t *testing.T) {
+	// arrange
+	db := openTestDatabase()
+	defer db.Close()
+
+	// act
+	applied, err := Apply(db, testDialect, testMigrations[1:])
+	upgraded, upgradeErr := Apply(db, testDialect, testMigrations)
+	again, againErr := Apply(db, testDialect, testMigrations)
+	current, latest, versionErr := Version(db, testDialect, testMigrations)
+	_, insertErr := db.Exec("INSERT INTO thing (name, unit) VALUES ('thing', 'm')")
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(applied))
+	assert.Nil(t, upgradeErr)
+	assert.Equal(t, 1, len(upgraded))
+	assert.Equal(t, 2, upgraded[0].Version)
+	assert.Nil(t, againErr)
+	assert.Equal(t, 0, len(again))
+	assert.Nil(t, versionErr)
+	assert.Equal(t, 2, current)
+	assert.Equal(t, 2, latest)
+	assert.Nil(t, insertErr)
+}
+
+func TestApplyFailingMigration(t *testing.T) {
+	// arrange
+	db := openTestDatabase()
+	defer db.Close()
+	migrations := append(testMigrations, Migration{Version: 3, Description: "invalid", SQL: "ALTER TABLE unknown ADD COLUMN x TEXT"})
+
+	// act
+	applied, err := Apply(db, testDialect, migrations)
+	current, _, _ := Version(db, testDialect, migrations)
+
+	// assert
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, len(applied))
+	assert.Equal(t, 2, current)
+}
+
+func TestSchemaTooNew(t *testing.T) {
+	// arrange
+	db := openTestDatabase()
+	defer db.Close()
+	Apply(db, testDialect, testMigrations)
+
+	// act
+	_, err := Apply(db, testDialect, testMigrations[1:])
+	current, latest, versionErr := Version(db, testDialect, testMigrations[1:])
+
+	// assert
+	assert.Equal(t, ErrSchemaTooNew, err)
+	assert.Equal(t, ErrSchemaTooNew, versionErr)
+	assert.Equal(t, 2, current)
+	assert.Equal(t, 1, latest)
+}
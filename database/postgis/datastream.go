@@ -56,9 +56,9 @@ func datastreamParamFactory(values map[string]interface{}) (entities.Entity, err
 
 // GetObservedArea returns the observed area of all observations of datastream
 func (gdb *GostDatabase) GetObservedArea(id int) (map[string]interface{}, error) {
-	sqlString := "select ST_AsGeoJSON(ST_ConvexHull(ST_Collect(feature))) as geom from %s.featureofinterest where id in (select distinct featureofinterest_id from %s.observation where stream_id=%v)"
-	sql2 := fmt.Sprintf(sqlString, gdb.Schema, gdb.Schema, id)
-	rows, err := gdb.Db.Query(sql2)
+	sqlString := "select ST_AsGeoJSON(ST_ConvexHull(ST_Collect(feature))) as geom from %s.featureofinterest where id in (select distinct featureofinterest_id from %s.observation where stream_id=$1)"
+	sql2 := fmt.Sprintf(sqlString, gdb.Schema, gdb.Schema)
+	rows, err := gdb.Db.Query(sql2, id)
 	var geom string
 	var propMap map[string]interface{}
 	defer rows.Close()
@@ -84,8 +84,8 @@ func (gdb *GostDatabase) GetDatastream(id interface{}, qo *odata.QueryOptions) (
 		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, nil, intID, qo)
-	datastream, err := processDatastream(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, nil, intID, qo)
+	datastream, err := processDatastream(gdb.Db, query, args, qi)
 	if err != nil {
 		return nil, err
 	}
@@ -111,9 +111,9 @@ func (gdb *GostDatabase) GetDatastream(id interface{}, qo *odata.QueryOptions) (
 
 // GetDatastreams retrieves all datastreams
 func (gdb *GostDatabase) GetDatastreams(qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, nil, nil, qo)
-	return processDatastreams(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, nil, nil, qo)
+	return processDatastreams(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // GetDatastreamByObservation retrieves a datastream linked to the given observation
@@ -123,8 +123,8 @@ func (gdb *GostDatabase) GetDatastreamByObservation(observationID interface{}, q
 		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Observation{}, intID, qo)
-	return processDatastream(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Observation{}, intID, qo)
+	return processDatastream(gdb.Db, query, args, qi)
 }
 
 // GetDatastreamsByThing retrieves all datastreams linked to the given thing
@@ -134,9 +134,9 @@ func (gdb *GostDatabase) GetDatastreamsByThing(thingID interface{}, qo *odata.Qu
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Thing{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.Thing{}, intID, qo)
-	return processDatastreams(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Thing{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.Thing{}, intID, qo)
+	return processDatastreams(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // GetDatastreamsBySensor retrieves all datastreams linked to the given sensor
@@ -146,9 +146,9 @@ func (gdb *GostDatabase) GetDatastreamsBySensor(sensorID interface{}, qo *odata.
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Sensor{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.Sensor{}, intID, qo)
-	return processDatastreams(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Sensor{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.Sensor{}, intID, qo)
+	return processDatastreams(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // GetDatastreamsByObservedProperty retrieves all datastreams linked to the given ObservedProerty
@@ -158,13 +158,13 @@ func (gdb *GostDatabase) GetDatastreamsByObservedProperty(oID interface{}, qo *o
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.ObservedProperty{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.ObservedProperty{}, intID, qo)
-	return processDatastreams(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.ObservedProperty{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.ObservedProperty{}, intID, qo)
+	return processDatastreams(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
-func processDatastream(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Datastream, error) {
-	datastreams, _, _, err := processDatastreams(db, sql, nil, qi, "")
+func processDatastream(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Datastream, error) {
+	datastreams, _, _, err := processDatastreams(db, sql, args, nil, qi, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -176,8 +176,8 @@ func processDatastream(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Da
 	return datastreams[0], nil
 }
 
-func processDatastreams(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string) ([]*entities.Datastream, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processDatastreams(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Datastream, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, false, fmt.Errorf("Error executing query %v", err)
 	}
@@ -190,7 +190,7 @@ func processDatastreams(db *sql.DB, sql string, qo *odata.QueryOptions, qi *Quer
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, false, fmt.Errorf("Error executing count %v", err)
 		}
@@ -230,20 +230,18 @@ func (gdb *GostDatabase) PostDatastream(d *entities.Datastream) (*entities.Datas
 	var dsID int
 
 	unitOfMeasurement, _ := json.Marshal(d.UnitOfMeasurement)
-	geom := "NULL"
+	var geom, phenomenonTime, resultTime interface{}
 	if len(d.ObservedArea) != 0 {
 		observedAreaBytes, _ := json.Marshal(d.ObservedArea)
-		geom = fmt.Sprintf("ST_SetSRID(ST_GeomFromGeoJSON('%s'),4326)", string(observedAreaBytes[:]))
+		geom = string(observedAreaBytes[:])
 	}
 
-	phenomenonTime := "NULL"
 	if len(d.PhenomenonTime) != 0 {
-		phenomenonTime = "'" + now.Iso8601ToPostgresPeriod(d.PhenomenonTime) + "'"
+		phenomenonTime = now.Iso8601ToPostgresPeriod(d.PhenomenonTime)
 	}
 
-	resultTime := "NULL"
 	if len(d.ResultTime) != 0 {
-		resultTime = "'" + now.Iso8601ToPostgresPeriod(d.ResultTime) + "'"
+		resultTime = now.Iso8601ToPostgresPeriod(d.ResultTime)
 	}
 	// get the ObservationType id in the lookup table
 	observationType, err := entities.GetObservationTypeByValue(d.ObservationType)
@@ -252,8 +250,8 @@ func (gdb *GostDatabase) PostDatastream(d *entities.Datastream) (*entities.Datas
 		return nil, gostErrors.NewBadRequestError(errors.New("ObservationType does not exist"))
 	}
 
-	sql2 := fmt.Sprintf("INSERT INTO %s.datastream (name, description, unitofmeasurement, observedarea, thing_id, sensor_id, observedproperty_id, observationtype, phenomenonTime, resulttime) VALUES ($1, $2, $3, %s, $4, $5, $6, $7, %s, %s) RETURNING id", gdb.Schema, geom, phenomenonTime, resultTime)
-	err = gdb.Db.QueryRow(sql2, d.Name, d.Description, unitOfMeasurement, tID, sID, oID, observationType.Code).Scan(&dsID)
+	sql2 := fmt.Sprintf("INSERT INTO %s.datastream (name, description, unitofmeasurement, observedarea, thing_id, sensor_id, observedproperty_id, observationtype, phenomenonTime, resulttime) VALUES ($1, $2, $3, ST_SetSRID(ST_GeomFromGeoJSON($4::text),4326), $5, $6, $7, $8, $9, $10) RETURNING id", gdb.Schema)
+	err = gdb.statements(gdb.Db).QueryRow(sql2, d.Name, d.Description, unitOfMeasurement, geom, tID, sID, oID, observationType.Code, phenomenonTime, resultTime).Scan(&dsID)
 	if err != nil {
 		return nil, err
 	}
@@ -303,7 +301,7 @@ func (gdb *GostDatabase) PatchDatastream(id interface{}, ds *entities.Datastream
 
 	if len(ds.ObservedArea) > 0 {
 		observedAreaBytes, _ := json.Marshal(ds.ObservedArea)
-		updates["observedarea"] = fmt.Sprintf("ST_SetSRID(ST_GeomFromGeoJSON('%s'),4326)", string(observedAreaBytes[:]))
+		updates["observedarea"] = geometryUpdate(observedAreaBytes[:])
 	}
 
 	if len(ds.PhenomenonTime) > 0 {
@@ -52,8 +52,8 @@ func (gdb *GostDatabase) GetFeatureOfInterestIDByLocationID(id interface{}) (int
 	}
 
 	var fID interface{}
-	query := fmt.Sprintf("select id from %s.featureofinterest where original_location_id=%v", gdb.Schema, intID)
-	err := gdb.Db.QueryRow(query).Scan(&fID)
+	query := fmt.Sprintf("select id from %s.featureofinterest where original_location_id=$1", gdb.Schema)
+	err := gdb.Db.QueryRow(query, intID).Scan(&fID)
 	if err != nil {
 		return nil, err
 	}
@@ -72,8 +72,8 @@ func (gdb *GostDatabase) GetFeatureOfInterest(id interface{}, qo *odata.QueryOpt
 		return nil, gostErrors.NewRequestNotFound(errors.New("FeatureOfInterest does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, nil, intID, qo)
-	return processFeatureOfInterest(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, nil, intID, qo)
+	return processFeatureOfInterest(gdb.Db, query, args, qi)
 }
 
 // GetFeatureOfInterestByObservation returns a feature of interest by given observation id
@@ -83,15 +83,15 @@ func (gdb *GostDatabase) GetFeatureOfInterestByObservation(id interface{}, qo *o
 		return nil, gostErrors.NewRequestNotFound(errors.New("Observation does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, &entities.Observation{}, intID, qo)
-	return processFeatureOfInterest(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, &entities.Observation{}, intID, qo)
+	return processFeatureOfInterest(gdb.Db, query, args, qi)
 }
 
 // GetFeatureOfInterests returns all feature of interests
 func (gdb *GostDatabase) GetFeatureOfInterests(qo *odata.QueryOptions) ([]*entities.FeatureOfInterest, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.FeatureOfInterest{}, nil, nil, qo)
-	return processFeatureOfInterests(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.FeatureOfInterest{}, nil, nil, qo)
+	return processFeatureOfInterests(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // PostFeatureOfInterest inserts a new FeatureOfInterest into the database
@@ -99,8 +99,8 @@ func (gdb *GostDatabase) PostFeatureOfInterest(f *entities.FeatureOfInterest) (*
 	var fID int
 	locationBytes, _ := json.Marshal(f.Feature)
 	encoding, _ := entities.CreateEncodingType(f.EncodingType)
-	sql2 := fmt.Sprintf("INSERT INTO %s.featureofinterest (name, description, encodingtype, feature, original_location_id, geojson) VALUES ($1, $2, $3, ST_SetSRID(public.ST_GeomFromGeoJSON('%s'),4326), $4, $5) RETURNING id", gdb.Schema, string(locationBytes[:]))
-	err := gdb.Db.QueryRow(sql2, f.Name, f.Description, encoding.Code, f.OriginalLocationID, string(locationBytes[:])).Scan(&fID)
+	sql2 := fmt.Sprintf("INSERT INTO %s.featureofinterest (name, description, encodingtype, feature, original_location_id, geojson) VALUES ($1, $2, $3, ST_SetSRID(public.ST_GeomFromGeoJSON($4::text),4326), $5, $6) RETURNING id", gdb.Schema)
+	err := gdb.statements(gdb.Db).QueryRow(sql2, f.Name, f.Description, encoding.Code, string(locationBytes[:]), f.OriginalLocationID, string(locationBytes[:])).Scan(&fID)
 	if err != nil {
 		return nil, err
 	}
@@ -114,8 +114,8 @@ func (gdb *GostDatabase) PutFeatureOfInterest(id interface{}, f *entities.Featur
 	return gdb.PatchFeatureOfInterest(id, f)
 }
 
-func processFeatureOfInterest(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.FeatureOfInterest, error) {
-	locations, _, _, err := processFeatureOfInterests(db, sql, nil, qi, "")
+func processFeatureOfInterest(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.FeatureOfInterest, error) {
+	locations, _, _, err := processFeatureOfInterests(db, sql, args, nil, qi, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +127,8 @@ func processFeatureOfInterest(db *sql.DB, sql string, qi *QueryParseInfo) (*enti
 	return locations[0], nil
 }
 
-func processFeatureOfInterests(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string) ([]*entities.FeatureOfInterest, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processFeatureOfInterests(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.FeatureOfInterest, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, false, fmt.Errorf("Error executing query %v", err)
 	}
@@ -141,7 +141,7 @@ func processFeatureOfInterests(db *sql.DB, sql string, qo *odata.QueryOptions, q
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, false, fmt.Errorf("Error executing count %v", err)
 		}
@@ -176,7 +176,7 @@ func (gdb *GostDatabase) PatchFeatureOfInterest(id interface{}, foi *entities.Fe
 
 	if len(foi.Feature) > 0 {
 		locationBytes, _ := json.Marshal(foi.Feature)
-		updates["feature"] = fmt.Sprintf("ST_SetSRID(public.ST_GeomFromGeoJSON('%s'),4326)", string(locationBytes[:]))
+		updates["feature"] = geometryUpdate(locationBytes[:])
 		updates["geojson"] = string(locationBytes[:])
 	}
 
@@ -45,7 +45,7 @@ func filterNavToString(qb QueryBuilder, pn *godata.ParseNode, et entities.Entity
 		if i+1 == len(pn.Children) {
 			arrow = "->>"
 		}
-		q += fmt.Sprintf("%v '%v'", arrow, part.Token.Value)
+		q += fmt.Sprintf("%v %v::text", arrow, qb.addArg(part.Token.Value))
 	}
 	return q
 }
@@ -64,13 +64,13 @@ func filterLogicalToString(qb QueryBuilder, pn *godata.ParseNode, et entities.En
 	result := "observation.data -> 'result'"
 	if len(qb.odataLogicalOperatorToPostgreSQL(pn.Token.Value)) > 0 {
 		if left == result {
-			if strings.Index(right, "'") != 0 {
+			if !qb.isStringValue(right) {
 				left = qb.CastObservationResult(left, "double precision")
 			} else {
 				left = "observation.data ->> 'result'"
 			}
 		} else if right == result {
-			if strings.Index(left, "'") != 0 {
+			if !qb.isStringValue(left) {
 				right = qb.CastObservationResult(right, "double precision")
 			} else {
 				right = "observation.data ->> 'result'"
@@ -90,7 +90,13 @@ func filterFuncToString(qb QueryBuilder, pn *godata.ParseNode, et entities.Entit
 	return ""
 }
 
+// filterDefaultToString passes string literals as bind parameter, the other literals
+// (numbers, dates, booleans, null) only pass the OData lexer when they are valid
 func filterDefaultToString(qb QueryBuilder, pn *godata.ParseNode, et entities.EntityType, ignoreSelectAs bool) string {
+	if pn.Token.Type == godata.FilterTokenString {
+		return qb.addArg(filterStringValue(pn.Token.Value))
+	}
+
 	return fmt.Sprintf("%v", pn.Token.Value)
 }
 
@@ -111,7 +117,7 @@ func filterOpToString(qb QueryBuilder, pn *godata.ParseNode, et entities.EntityT
 }
 
 func filterGeographyToString(qb QueryBuilder, pn *godata.ParseNode, et entities.EntityType, ignoreSelectAs bool) string {
-	return fmt.Sprintf("ST_GeomFromText(%v)", pn.Children[0].Token.Value)
+	return fmt.Sprintf("ST_GeomFromText(%v)", qb.addArg(filterStringValue(pn.Children[0].Token.Value)))
 }
 
 func filterLiteralToString(qb QueryBuilder, pn *godata.ParseNode, et entities.EntityType, ignoreSelectAs bool) string {
@@ -38,15 +38,15 @@ func (gdb *GostDatabase) GetHistoricalLocation(id interface{}, qo *odata.QueryOp
 		return nil, gostErrors.NewRequestNotFound(errors.New("HistoricalLocation does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, nil, intID, qo)
-	return processHistoricalLocation(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, nil, intID, qo)
+	return processHistoricalLocation(gdb.Db, query, args, qi)
 }
 
 // GetHistoricalLocations retrieves all historicallocations
 func (gdb *GostDatabase) GetHistoricalLocations(qo *odata.QueryOptions) ([]*entities.HistoricalLocation, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, nil, nil, qo)
-	return processHistoricalLocations(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, nil, nil, qo)
+	return processHistoricalLocations(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // GetHistoricalLocationsByLocation retrieves all historicallocations linked to the given location
@@ -55,9 +55,9 @@ func (gdb *GostDatabase) GetHistoricalLocationsByLocation(locationID interface{}
 	if !ok {
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
 	}
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, &entities.Location{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, &entities.Location{}, intID, qo)
-	return processHistoricalLocations(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, &entities.Location{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, &entities.Location{}, intID, qo)
+	return processHistoricalLocations(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // GetHistoricalLocationsByThing retrieves all historicallocations linked to the given thing
@@ -66,13 +66,13 @@ func (gdb *GostDatabase) GetHistoricalLocationsByThing(thingID interface{}, qo *
 	if !ok {
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
 	}
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, &entities.Thing{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, &entities.Thing{}, intID, qo)
-	return processHistoricalLocations(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, &entities.Thing{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, &entities.Thing{}, intID, qo)
+	return processHistoricalLocations(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
-func processHistoricalLocation(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.HistoricalLocation, error) {
-	hls, _, _, err := processHistoricalLocations(db, sql, nil, qi, "")
+func processHistoricalLocation(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.HistoricalLocation, error) {
+	hls, _, _, err := processHistoricalLocations(db, sql, args, nil, qi, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -84,8 +84,8 @@ func processHistoricalLocation(db *sql.DB, sql string, qi *QueryParseInfo) (*ent
 	return hls[0], nil
 }
 
-func processHistoricalLocations(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string) ([]*entities.HistoricalLocation, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processHistoricalLocations(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.HistoricalLocation, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, hasNext, fmt.Errorf("Error executing query %v", err)
 	}
@@ -98,7 +98,7 @@ func processHistoricalLocations(db *sql.DB, sql string, qo *odata.QueryOptions,
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
 		}
@@ -126,7 +126,7 @@ func (gdb *GostDatabase) PostHistoricalLocation(hl *entities.HistoricalLocation)
 	}
 
 	query := fmt.Sprintf("INSERT INTO %s.historicallocation (time, thing_id) VALUES ($1, $2) RETURNING id", gdb.Schema)
-	err = gdb.Db.QueryRow(query, time.Now(), tid).Scan(&hlID)
+	err = gdb.statements(gdb.Db).QueryRow(query, time.Now(), tid).Scan(&hlID)
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +134,7 @@ func (gdb *GostDatabase) PostHistoricalLocation(hl *entities.HistoricalLocation)
 	for _, l := range hl.Locations {
 		lid, _ := ToIntID(l.ID)
 		query := fmt.Sprintf("INSERT INTO %s.location_to_historicallocation (location_id, historicallocation_id) VALUES ($1, $2)  RETURNING historicallocation_id", gdb.Schema)
-		err = gdb.Db.QueryRow(query, lid, hlID).Scan(&lid)
+		err = gdb.statements(gdb.Db).QueryRow(query, lid, hlID).Scan(&lid)
 		if err != nil {
 			return nil, err
 		}
@@ -183,7 +183,7 @@ func (gdb *GostDatabase) PatchHistoricalLocation(id interface{}, hl *entities.Hi
 
 	for _, l := range hl.Locations {
 		query := fmt.Sprintf("INSERT INTO %s.location_to_historicallocation (location_id, historicallocation_id) VALUES ($1, $2)", gdb.Schema)
-		_, err := gdb.Db.Exec(query, l.ID, intID)
+		_, err := gdb.statements(gdb.Db).Exec(query, l.ID, intID)
 		if err != nil {
 			return nil, err
 		}
@@ -53,15 +53,15 @@ func (gdb *GostDatabase) GetLocation(id interface{}, qo *odata.QueryOptions) (*e
 		return nil, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, nil, intID, qo)
-	return processLocation(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, nil, intID, qo)
+	return processLocation(gdb.Db, query, args, qi)
 }
 
 // GetLocations retrieves all locations
 func (gdb *GostDatabase) GetLocations(qo *odata.QueryOptions) ([]*entities.Location, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Location{}, nil, nil, qo)
-	return processLocations(gdb.Db, query, qo, qi, countSQL, false)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Location{}, nil, nil, qo)
+	return processLocations(gdb.Db, query, args, qo, qi, countSQL, countArgs, false)
 }
 
 // GetLocationsByHistoricalLocation retrieves all locations linked to the given HistoricalLocation
@@ -71,9 +71,9 @@ func (gdb *GostDatabase) GetLocationsByHistoricalLocation(hlID interface{}, qo *
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("HistoricaLocation does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, &entities.HistoricalLocation{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Location{}, &entities.HistoricalLocation{}, intID, qo)
-	return processLocations(gdb.Db, query, qo, qi, countSQL, true)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, &entities.HistoricalLocation{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Location{}, &entities.HistoricalLocation{}, intID, qo)
+	return processLocations(gdb.Db, query, args, qo, qi, countSQL, countArgs, true)
 }
 
 // GetLocationByDatastreamID returns a location linked to an observation
@@ -89,8 +89,8 @@ func (gdb *GostDatabase) GetLocationByDatastreamID(datastreamID interface{}, qo
 	tq := godata.GoDataTopQuery(-1)
 	qo.Top = &tq
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, &entities.Datastream{}, intID, qo)
-	return processLocation(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, &entities.Datastream{}, intID, qo)
+	return processLocation(gdb.Db, query, args, qi)
 }
 
 // GetLocationsByThing retrieves all locations linked to the given thing
@@ -103,13 +103,13 @@ func (gdb *GostDatabase) GetLocationsByThing(thingID interface{}, qo *odata.Quer
 	tq := godata.GoDataTopQuery(1)
 	qo.Top = &tq
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, &entities.Thing{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Location{}, &entities.Thing{}, intID, qo)
-	return processLocations(gdb.Db, query, qo, qi, countSQL, true)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Location{}, &entities.Thing{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Location{}, &entities.Thing{}, intID, qo)
+	return processLocations(gdb.Db, query, args, qo, qi, countSQL, countArgs, true)
 }
 
-func processLocation(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Location, error) {
-	locations, _, _, err := processLocations(db, sql, nil, qi, "", false)
+func processLocation(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Location, error) {
+	locations, _, _, err := processLocations(db, sql, args, nil, qi, "", nil, false)
 	if err != nil {
 		return nil, err
 	}
@@ -121,8 +121,8 @@ func processLocation(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Loca
 	return locations[0], nil
 }
 
-func processLocations(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, disableNextLink bool) ([]*entities.Location, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processLocations(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}, disableNextLink bool) ([]*entities.Location, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, hasNext, fmt.Errorf("Error executing query %v", err)
 	}
@@ -135,7 +135,7 @@ func processLocations(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryP
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, hasNext, fmt.Errorf("error executing count %v", err)
 		}
@@ -155,8 +155,8 @@ func (gdb *GostDatabase) PostLocation(location *entities.Location) (*entities.Lo
 	locationBytes, _ := json.Marshal(location.Location)
 	encoding, _ := entities.CreateEncodingType(location.EncodingType)
 
-	sql2 := fmt.Sprintf("INSERT INTO %s.location (name, description, encodingtype, geojson, location) VALUES ($1, $2, $3, $4, ST_SetSRID(ST_GeomFromGeoJSON('%s'),4326)) RETURNING id", gdb.Schema, string(locationBytes[:]))
-	err := gdb.Db.QueryRow(sql2, location.Name, location.Description, encoding.Code, string(locationBytes[:])).Scan(&locationID)
+	sql2 := fmt.Sprintf("INSERT INTO %s.location (name, description, encodingtype, geojson, location) VALUES ($1, $2, $3, $4, ST_SetSRID(ST_GeomFromGeoJSON($5::text),4326)) RETURNING id", gdb.Schema)
+	err := gdb.statements(gdb.Db).QueryRow(sql2, location.Name, location.Description, encoding.Code, string(locationBytes[:]), string(locationBytes[:])).Scan(&locationID)
 	if err != nil {
 		return nil, err
 	}
@@ -191,7 +191,7 @@ func (gdb *GostDatabase) PatchLocation(id interface{}, l *entities.Location) (*e
 
 	if len(l.Location) > 0 {
 		locationBytes, _ := json.Marshal(l.Location)
-		updates["location"] = fmt.Sprintf("ST_SetSRID(ST_GeomFromGeoJSON('%s'),4326)", string(locationBytes[:]))
+		updates["location"] = geometryUpdate(locationBytes[:])
 		updates["geojson"] = string(locationBytes[:])
 	}
 
@@ -233,7 +233,7 @@ func (gdb *GostDatabase) LinkLocation(thingID interface{}, locationID interface{
 	}
 
 	sql2 := fmt.Sprintf("INSERT INTO %s.thing_to_location (thing_id, location_id) VALUES ($1, $2)", gdb.Schema)
-	_, err3 := gdb.Db.Exec(sql2, tid, lid)
+	_, err3 := gdb.statements(gdb.Db).Exec(sql2, tid, lid)
 
 	return err3
 }
@@ -0,0 +1,169 @@
+package postgis
+
+import (
+	"fmt"
+
+	"github.com/gost/server/database/migration"
+)
+
+// migrationsTable keeps track of the migrations applied to the schema
+const migrationsTable = "gost_migrations"
+
+// migrationLockID is the key of the advisory lock keeping GOST instances from migrating at the same time
+const migrationLockID = 7461826
+
+// migrations are the ordered upgrades of the GOST schema, %[1]s is replaced by the schema. Version 1 is the
+// schema created by the former -install script, it only creates missing tables so existing deployments
+// installed by that script are registered as version 1 without changes. The postgis extension is created
+// before migrating, see Migrate
+var migrations = []migration.Migration{
+	{Version: 1, Description: "initial schema", SQL: `
+CREATE SCHEMA IF NOT EXISTS %[1]s;
+
+CREATE TABLE IF NOT EXISTS %[1]s.thing (
+	id bigserial NOT NULL PRIMARY KEY,
+	name character varying(255),
+	description character varying(500),
+	properties jsonb
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.location (
+	id bigserial NOT NULL PRIMARY KEY,
+	name character varying(255),
+	description character varying(500),
+	encodingtype integer,
+	location public.geometry(geometry, 4326),
+	geojson jsonb
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.thing_to_location (
+	thing_id bigint NOT NULL REFERENCES %[1]s.thing (id) ON DELETE CASCADE,
+	location_id bigint NOT NULL REFERENCES %[1]s.location (id) ON DELETE CASCADE,
+	PRIMARY KEY (thing_id, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.historicallocation (
+	id bigserial NOT NULL PRIMARY KEY,
+	time timestamp with time zone,
+	thing_id bigint NOT NULL REFERENCES %[1]s.thing (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.location_to_historicallocation (
+	location_id bigint NOT NULL REFERENCES %[1]s.location (id) ON DELETE CASCADE,
+	historicallocation_id bigint NOT NULL REFERENCES %[1]s.historicallocation (id) ON DELETE CASCADE,
+	PRIMARY KEY (location_id, historicallocation_id)
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.sensor (
+	id bigserial NOT NULL PRIMARY KEY,
+	name character varying(255),
+	description character varying(500),
+	encodingtype integer,
+	metadata character varying(500)
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.observedproperty (
+	id bigserial NOT NULL PRIMARY KEY,
+	name character varying(120),
+	definition character varying(255),
+	description character varying(500)
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.datastream (
+	id bigserial NOT NULL PRIMARY KEY,
+	name character varying(255),
+	description character varying(500),
+	unitofmeasurement jsonb,
+	observationtype integer,
+	observedarea public.geometry(polygon, 4326),
+	phenomenontime tstzrange,
+	resulttime tstzrange,
+	thing_id bigint NOT NULL REFERENCES %[1]s.thing (id) ON DELETE CASCADE,
+	sensor_id bigint NOT NULL REFERENCES %[1]s.sensor (id) ON DELETE CASCADE,
+	observedproperty_id bigint NOT NULL REFERENCES %[1]s.observedproperty (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.featureofinterest (
+	id bigserial NOT NULL PRIMARY KEY,
+	name character varying(255),
+	description character varying(500),
+	encodingtype integer,
+	feature public.geometry(geometry, 4326),
+	original_location_id bigint,
+	geojson jsonb
+);
+
+CREATE TABLE IF NOT EXISTS %[1]s.observation (
+	id bigserial NOT NULL PRIMARY KEY,
+	data jsonb,
+	stream_id bigint NOT NULL,
+	featureofinterest_id bigint NOT NULL,
+	CONSTRAINT fk_datastream FOREIGN KEY (stream_id) REFERENCES %[1]s.datastream (id) ON DELETE CASCADE,
+	CONSTRAINT fk_featureofinterest FOREIGN KEY (featureofinterest_id) REFERENCES %[1]s.featureofinterest (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS fki_thing_datastream ON %[1]s.datastream (thing_id);
+CREATE INDEX IF NOT EXISTS fki_sensor_datastream ON %[1]s.datastream (sensor_id);
+CREATE INDEX IF NOT EXISTS fki_observedproperty_datastream ON %[1]s.datastream (observedproperty_id);
+CREATE INDEX IF NOT EXISTS fki_thing_historicallocation ON %[1]s.historicallocation (thing_id);
+CREATE INDEX IF NOT EXISTS fki_datastream_observation ON %[1]s.observation (stream_id);
+CREATE INDEX IF NOT EXISTS fki_featureofinterest_observation ON %[1]s.observation (featureofinterest_id);
+CREATE INDEX IF NOT EXISTS fki_location_featureofinterest ON %[1]s.featureofinterest (original_location_id);
+`},
+	{Version: 2, Description: "observation rollups", SQL: `
+CREATE TABLE IF NOT EXISTS %[1]s.observation_rollup (
+	stream_id bigint NOT NULL REFERENCES %[1]s.datastream (id) ON DELETE CASCADE,
+	interval_start timestamp with time zone NOT NULL,
+	interval_sec integer NOT NULL,
+	observation_count bigint NOT NULL,
+	result_count bigint NOT NULL,
+	result_sum double precision,
+	result_min double precision,
+	result_max double precision,
+	PRIMARY KEY (stream_id, interval_sec, interval_start)
+);
+`},
+}
+
+// schemaMigrations returns the migrations for the schema of the database
+func (gdb *GostDatabase) schemaMigrations() []migration.Migration {
+	m := make([]migration.Migration, len(migrations))
+	for i := range migrations {
+		m[i] = migrations[i]
+		m[i].SQL = fmt.Sprintf(migrations[i].SQL, gdb.Schema)
+	}
+
+	return m
+}
+
+// migrationDialect returns the statements keeping track of the applied migrations in the schema
+func (gdb *GostDatabase) migrationDialect() migration.Dialect {
+	table := fmt.Sprintf("%s.%s", gdb.Schema, migrationsTable)
+	return migration.Dialect{
+		CreateTable:   fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s; CREATE TABLE IF NOT EXISTS %s (version integer NOT NULL PRIMARY KEY, description text, applied timestamp with time zone NOT NULL DEFAULT now())", gdb.Schema, table),
+		SelectVersion: fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", table),
+		Insert:        fmt.Sprintf("INSERT INTO %s (version, description) VALUES ($1, $2)", table),
+		Lock:          fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", migrationLockID),
+	}
+}
+
+// SchemaVersion returns the version of the schema in the database and the latest version known by GOST
+func (gdb *GostDatabase) SchemaVersion() (int, int, error) {
+	return migration.Version(gdb.Db, gdb.migrationDialect(), gdb.schemaMigrations())
+}
+
+// Migrate applies the pending migrations to the schema and returns the number of applied migrations. Creating
+// the postgis extension requires a superuser, when it fails the extension has to be created by the administrator
+// and the migrations report a missing geometry type
+func (gdb *GostDatabase) Migrate() (int, error) {
+	if _, err := gdb.Db.Exec("CREATE EXTENSION IF NOT EXISTS postgis"); err != nil {
+		logger.Warnf("Unable to create the postgis extension: %v", err)
+	}
+
+	applied, err := migration.Apply(gdb.Db, gdb.migrationDialect(), gdb.schemaMigrations())
+	for _, m := range applied {
+		logger.Infof("Applied migration %d: %s", m.Version, m.Description)
+	}
+
+	return len(applied), err
+}
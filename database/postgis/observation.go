@@ -6,10 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	entities "github.com/gost/core"
 	gostErrors "github.com/gost/server/errors"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/odata"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
 )
 
 func observationParamFactory(values map[string]interface{}) (entities.Entity, error) {
@@ -64,8 +69,8 @@ func (gdb *GostDatabase) GetObservation(id interface{}, qo *odata.QueryOptions)
 		return nil, gostErrors.NewRequestNotFound(errors.New("Observation does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, nil, intID, qo)
-	observation, err := processObservation(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, nil, intID, qo)
+	observation, err := processObservation(gdb.Db, query, args, qi)
 	if err != nil {
 		return nil, err
 	}
@@ -75,9 +80,9 @@ func (gdb *GostDatabase) GetObservation(id interface{}, qo *odata.QueryOptions)
 
 // GetObservations retrieves all observations
 func (gdb *GostDatabase) GetObservations(qo *odata.QueryOptions) ([]*entities.Observation, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, nil, nil, qo)
-	return processObservations(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, nil, nil, qo)
+	return processObservations(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // GetObservationsByFeatureOfInterest retrieves all observations by the given FeatureOfInterest id
@@ -87,9 +92,9 @@ func (gdb *GostDatabase) GetObservationsByFeatureOfInterest(foiID interface{}, q
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("FeatureOfInterest does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, &entities.FeatureOfInterest{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, &entities.FeatureOfInterest{}, intID, qo)
-	return processObservations(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, &entities.FeatureOfInterest{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, &entities.FeatureOfInterest{}, intID, qo)
+	return processObservations(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 // GetObservationsByDatastream retrieves all observations by the given datastream id
@@ -99,13 +104,87 @@ func (gdb *GostDatabase) GetObservationsByDatastream(dataStreamID interface{}, q
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, &entities.Datastream{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, &entities.Datastream{}, intID, qo)
-	return processObservations(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, &entities.Datastream{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, &entities.Datastream{}, intID, qo)
+	return processObservations(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
-func processObservation(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Observation, error) {
-	observations, _, _, err := processObservations(db, sql, nil, qi, "")
+// GetObservationAggregates retrieves the observations of the given datastream, or of all datastreams when the id is nil,
+// aggregated by the $apply of the QueryOptions
+func (gdb *GostDatabase) GetObservationAggregates(dataStreamID interface{}, qo *odata.QueryOptions) ([]*models.ObservationAggregate, int, bool, error) {
+	var id interface{}
+	if dataStreamID != nil {
+		intID, ok := ToIntID(dataStreamID)
+		if !ok {
+			return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+		}
+		id = intID
+	}
+
+	query, args := gdb.QueryBuilder.CreateAggregateQuery(id, qo)
+	rows, err := gdb.Db.Query(query, args...)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("Error executing query %v", err)
+	}
+	defer rows.Close()
+
+	aggregates := make([]*models.ObservationAggregate, 0)
+	for rows.Next() {
+		var start float64
+		values := make([]sql.NullFloat64, len(qo.Aggregation.Aggregates))
+		dest := []interface{}{&start}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, 0, false, fmt.Errorf("Error reading aggregates %v", err)
+		}
+
+		aggregates = append(aggregates, newObservationAggregate(int64(start), qo.Aggregation, values))
+	}
+
+	hasNext := false
+	if qo.Top != nil && int(*qo.Top) >= 0 && len(aggregates) > int(*qo.Top) {
+		hasNext = true
+		aggregates = aggregates[:int(*qo.Top)]
+	}
+
+	var count int
+	if countSQL, countArgs := gdb.QueryBuilder.CreateAggregateCountQuery(id, qo); len(countSQL) > 0 {
+		if count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo); err != nil {
+			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return aggregates, count, hasNext, nil
+}
+
+// newObservationAggregate creates the ObservationAggregate of the interval starting at the given seconds since
+// the Unix epoch, aggregates without numeric results are null and counts are returned as integer
+func newObservationAggregate(start int64, aggregation *odata.Aggregation, values []sql.NullFloat64) *models.ObservationAggregate {
+	a := &models.ObservationAggregate{
+		Start:  time.Unix(start, 0).UTC(),
+		Values: make(map[string]interface{}),
+	}
+	a.End = a.Start.Add(aggregation.Interval)
+
+	for i, agg := range aggregation.Aggregates {
+		switch {
+		case agg.Method == odata.AggregateMethodCount:
+			a.Values[agg.Alias] = int64(values[i].Float64)
+		case values[i].Valid:
+			a.Values[agg.Alias] = values[i].Float64
+		default:
+			a.Values[agg.Alias] = nil
+		}
+	}
+
+	return a
+}
+
+func processObservation(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Observation, error) {
+	observations, _, _, err := processObservations(db, sql, args, nil, qi, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -117,8 +196,8 @@ func processObservation(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.O
 	return observations[0], nil
 }
 
-func processObservations(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string) ([]*entities.Observation, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processObservations(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Observation, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, false, fmt.Errorf("Error executing query %v", err)
 	}
@@ -131,7 +210,7 @@ func processObservations(db *sql.DB, sql string, qo *odata.QueryOptions, qi *Que
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
 		}
@@ -147,43 +226,265 @@ func (gdb *GostDatabase) PutObservation(id interface{}, o *entities.Observation)
 
 // PostObservation adds an observation to the database
 func (gdb *GostDatabase) PostObservation(o *entities.Observation) (*entities.Observation, error) {
-	var oID int
+	id, err := gdb.insertObservation(gdb.Db, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdObservation(o, id), nil
+}
+
+// PostObservations adds a batch of observations to the database using a single transaction, the returned
+// slice holds the created observations in the same order, the returned map holds the errors by index.
+// When atomic is true the transaction is rolled back as soon as an observation fails, otherwise every
+// observation is inserted inside its own savepoint so only the failing observations are rolled back. The
+// given observations are only altered when the transaction is committed
+func (gdb *GostDatabase) PostObservations(observations []*entities.Observation, atomic bool) ([]*entities.Observation, map[int]error) {
+	created := make([]*entities.Observation, len(observations))
+	errs := make(map[int]error)
+	ids := make([]int, len(observations))
+
+	tx, err := gdb.Db.Begin()
+	if err != nil {
+		for i := range observations {
+			errs[i] = err
+		}
+		return created, errs
+	}
+
+	for i, o := range observations {
+		if !atomic {
+			if _, err = gdb.statements(tx).Exec("SAVEPOINT observation"); err != nil {
+				return rollbackObservations(tx, len(observations), errs, err)
+			}
+		}
+
+		id, err := gdb.insertObservation(tx, o)
+		if err != nil {
+			errs[i] = err
+			if atomic {
+				break
+			}
+
+			if _, err = gdb.statements(tx).Exec("ROLLBACK TO SAVEPOINT observation"); err != nil {
+				return rollbackObservations(tx, len(observations), errs, err)
+			}
+			continue
+		}
+
+		ids[i] = id
+		if !atomic {
+			if _, err = gdb.statements(tx).Exec("RELEASE SAVEPOINT observation"); err != nil {
+				return rollbackObservations(tx, len(observations), errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 && atomic {
+		tx.Rollback()
+		return make([]*entities.Observation, len(observations)), errs
+	}
+
+	if err = tx.Commit(); err != nil {
+		for i := range observations {
+			errs[i] = err
+		}
+		return created, errs
+	}
+
+	for i, o := range observations {
+		if _, failed := errs[i]; !failed {
+			created[i] = createdObservation(o, ids[i])
+		}
+	}
+
+	return created, errs
+}
+
+// rollbackObservations rolls back the transaction of PostObservations when a savepoint cannot be set, rolled
+// back or released. None of the observations are created, every observation without an error gets the given error
+func rollbackObservations(tx *sql.Tx, count int, errs map[int]error, err error) ([]*entities.Observation, map[int]error) {
+	tx.Rollback()
+	for i := 0; i < count; i++ {
+		if _, ok := errs[i]; !ok {
+			errs[i] = err
+		}
+	}
+
+	return make([]*entities.Observation, count), errs
+}
+
+// PostObservationsBulk adds a batch of observations, possibly for multiple datastreams, to the database using
+// PostgreSQL COPY. The observation ids are reserved upfront from the observation id sequence so the created
+// observations can be returned. The batch is inserted all or nothing and the given observations are only
+// altered when the batch is committed, a failing batch can be retried using PostObservations to find the
+// failing observations
+func (gdb *GostDatabase) PostObservationsBulk(observations []*entities.Observation) ([]*entities.Observation, error) {
+	if len(observations) == 0 {
+		return observations, nil
+	}
+
+	rows := make([][]interface{}, len(observations))
+	for i, o := range observations {
+		dID, fID, err := observationForeignKeys(o)
+		if err != nil {
+			return nil, err
+		}
+
+		json, _ := o.MarshalPostgresJSON()
+		rows[i] = []interface{}{nil, string(json[:]), dID, fID}
+	}
+
+	if l := gdb.statementLogger(); l.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(l, time.Now(), "copy %v observations", len(rows))
+	}
+
+	tx, err := gdb.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := gdb.reserveObservationIDs(tx, len(observations))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = copyObservations(tx, gdb.Schema, ids, rows); err != nil {
+		tx.Rollback()
+		return nil, observationInsertError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, observationInsertError(err)
+	}
+
+	for i, o := range observations {
+		createdObservation(o, ids[i])
+	}
+
+	return observations, nil
+}
+
+// reserveObservationIDs retrieves n new ids from the sequence behind observation.id
+func (gdb *GostDatabase) reserveObservationIDs(tx *sql.Tx, n int) ([]int, error) {
+	query := "SELECT nextval(pg_get_serial_sequence($1, 'id')) FROM generate_series(1, $2)"
+	rows, err := tx.Query(query, fmt.Sprintf("%s.observation", gdb.Schema), n)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]int, 0, n)
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) != n {
+		return nil, fmt.Errorf("Unable to reserve %v observation ids", n)
+	}
+
+	return ids, rows.Err()
+}
+
+// copyObservations streams the given rows (id, data, stream_id, featureofinterest_id) into the observation table
+func copyObservations(tx *sql.Tx, schema string, ids []int, rows [][]interface{}) error {
+	stmt, err := tx.Prepare(pq.CopyInSchema(schema, "observation", "id", "data", "stream_id", "featureofinterest_id"))
+	if err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		row[0] = ids[i]
+		if _, err = stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	// an Exec without arguments flushes the buffered rows to the database
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+
+	return stmt.Close()
+}
+
+// observationForeignKeys returns the datastream and FeatureOfInterest id of an observation to insert
+func observationForeignKeys(o *entities.Observation) (int, interface{}, error) {
+	if o.Datastream == nil {
+		return 0, nil, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
+	}
 
 	dID, ok := ToIntID(o.Datastream.ID)
 	if !ok {
-		return nil, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
+		return 0, nil, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
 	}
 
 	if o.FeatureOfInterest == nil || len(fmt.Sprintf("%v", o.FeatureOfInterest.ID)) == 0 {
-		return nil, gostErrors.NewBadRequestError(errors.New("No FeatureOfInterest supplied or Location found on linked thing"))
+		return 0, nil, gostErrors.NewBadRequestError(errors.New("No FeatureOfInterest supplied or Location found on linked thing"))
 	}
 
-	fID := o.FeatureOfInterest.ID
+	return dID, o.FeatureOfInterest.ID, nil
+}
 
-	json, _ := o.MarshalPostgresJSON()
-	obs := fmt.Sprintf("'%s'", string(json[:]))
-	sql2 := fmt.Sprintf("INSERT INTO %s.observation (data, stream_id, featureofinterest_id) VALUES (%v, %v, %v) RETURNING id", gdb.Schema, obs, dID, fID)
+// foreignKeyViolation is the SQLSTATE of an insert referencing a row that does not exist
+const foreignKeyViolation = "23503"
+
+// observationInsertError converts foreign key violations into readable bad request errors, the violated key is
+// found by constraint name or by column so schemas with generated constraint names are handled as well
+func observationInsertError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok || pqErr.Code != foreignKeyViolation {
+		return err
+	}
+
+	if pqErr.Constraint == "fk_datastream" || strings.Contains(pqErr.Detail, "(stream_id)") {
+		return gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
+	}
+	if pqErr.Constraint == "fk_featureofinterest" || strings.Contains(pqErr.Detail, "(featureofinterest_id)") {
+		return gostErrors.NewBadRequestError(errors.New("FeatureOfInterest does not exist"))
+	}
+
+	return err
+}
+
+// insertObservation inserts an observation and returns its id, the observation is not altered so it stays
+// unchanged when the transaction of db is rolled back
+func (gdb *GostDatabase) insertObservation(db executor, o *entities.Observation) (int, error) {
+	var oID int
 
-	err := gdb.Db.QueryRow(sql2).Scan(&oID)
+	dID, fID, err := observationForeignKeys(o)
 	if err != nil {
-		errString := fmt.Sprintf("%v", err.Error())
-		if strings.Contains(errString, "violates foreign key constraint \"fk_datastream\"") {
-			return nil, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
-		}
-		if strings.Contains(errString, "violates foreign key constraint \"fk_featureofinterest\"") {
-			return nil, gostErrors.NewBadRequestError(errors.New("FeatureOfInterest does not exist"))
-		}
+		return 0, err
+	}
 
-		return nil, err
+	json, _ := o.MarshalPostgresJSON()
+	sql2 := fmt.Sprintf("INSERT INTO %s.observation (data, stream_id, featureofinterest_id) VALUES ($1, $2, $3) RETURNING id", gdb.Schema)
+
+	err = gdb.statements(db).QueryRow(sql2, string(json[:]), dID, fID).Scan(&oID)
+	if err != nil {
+		return 0, observationInsertError(err)
 	}
 
-	o.ID = oID
+	return oID, nil
+}
+
+// createdObservation sets the id of an inserted observation
+func createdObservation(o *entities.Observation, id int) *entities.Observation {
+	o.ID = id
 
 	// clear inner entities to serves links upon response
 	o.Datastream = nil
 	o.FeatureOfInterest = nil
 
-	return o, nil
+	return o
 }
 
 // ObservationExists checks if an Observation is present in the database based on a given id.
@@ -242,3 +543,54 @@ func (gdb *GostDatabase) PatchObservation(id interface{}, o *entities.Observatio
 func (gdb *GostDatabase) DeleteObservation(id interface{}) error {
 	return DeleteEntity(gdb, id, "observation")
 }
+
+// PruneObservations deletes the observations of the datastream with a phenomenonTime before the given time, when
+// rollupInterval is set the observations are first added to the rollups of that interval in the same transaction
+func (gdb *GostDatabase) PruneObservations(datastreamID interface{}, before time.Time, rollupInterval time.Duration) (int64, error) {
+	id, ok := ToIntID(datastreamID)
+	if !ok {
+		return 0, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	tx, err := gdb.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	if rollupInterval > 0 {
+		query, args := gdb.QueryBuilder.CreateRollupObservationsQuery(id, before, rollupInterval)
+		if _, err = gdb.statements(tx).Exec(query, args...); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("Error creating rollups %v", err)
+		}
+	}
+
+	query, args := gdb.QueryBuilder.CreatePruneObservationsQuery(id, before)
+	res, err := gdb.statements(tx).Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Error pruning observations %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// PruneObservationRollups deletes the rollups of the datastream of intervals starting before the given time
+func (gdb *GostDatabase) PruneObservationRollups(datastreamID interface{}, before time.Time) (int64, error) {
+	id, ok := ToIntID(datastreamID)
+	if !ok {
+		return 0, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	query, args := gdb.QueryBuilder.CreatePruneRollupsQuery(id, before)
+	res, err := gdb.statements(gdb.Db).Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("Error pruning rollups %v", err)
+	}
+
+	return res.RowsAffected()
+}
@@ -1,7 +1,10 @@
 package postgis
 
 import (
+	"errors"
+
 	entities "github.com/gost/core"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -35,3 +38,45 @@ func TestObservationParamFactory(t *testing.T) {
 	assert.True(t, entitytype == entities.EntityTypeObservation)
 	// assert.True(t,*observation.ResultTime == resultTime)
 }
+
+func TestObservationForeignKeys(t *testing.T) {
+	// arrange
+	withoutDatastream := &entities.Observation{}
+	withoutFoi := &entities.Observation{Datastream: &entities.Datastream{}}
+	withoutFoi.Datastream.ID = 1
+	valid := &entities.Observation{Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+	valid.Datastream.ID = "2"
+	valid.FeatureOfInterest.ID = 3
+
+	// act
+	_, _, err1 := observationForeignKeys(withoutDatastream)
+	_, _, err2 := observationForeignKeys(withoutFoi)
+	dID, fID, err3 := observationForeignKeys(valid)
+
+	// assert
+	assert.NotNil(t, err1)
+	assert.NotNil(t, err2)
+	assert.Nil(t, err3)
+	assert.Equal(t, 2, dID)
+	assert.Equal(t, 3, fID)
+}
+
+func TestObservationInsertError(t *testing.T) {
+	// arrange
+	datastreamErr := &pq.Error{Code: "23503", Constraint: "observation_stream_id_fkey", Detail: `Key (stream_id)=(9) is not present in table "datastream".`}
+	foiErr := &pq.Error{Code: "23503", Constraint: "fk_featureofinterest"}
+	otherErr := &pq.Error{Code: "23505", Constraint: "observation_pkey"}
+	connectionErr := errors.New("connection refused")
+
+	// act
+	err := observationInsertError(datastreamErr)
+	err2 := observationInsertError(foiErr)
+	err3 := observationInsertError(otherErr)
+	err4 := observationInsertError(connectionErr)
+
+	// assert
+	assert.Equal(t, "Datastream does not exist", err.Error())
+	assert.Equal(t, "FeatureOfInterest does not exist", err2.Error())
+	assert.Equal(t, otherErr, err3)
+	assert.Equal(t, connectionErr, err4)
+}
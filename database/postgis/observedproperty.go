@@ -40,8 +40,8 @@ func (gdb *GostDatabase) GetObservedProperty(id interface{}, qo *odata.QueryOpti
 		return nil, gostErrors.NewRequestNotFound(errors.New("ObservedProperty does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, nil, intID, qo)
-	observedProperty, err := processObservedProperty(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, nil, intID, qo)
+	observedProperty, err := processObservedProperty(gdb.Db, query, args, qi)
 	if err != nil {
 		return nil, err
 	}
@@ -56,8 +56,8 @@ func (gdb *GostDatabase) GetObservedPropertyByDatastream(id interface{}, qo *oda
 		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, &entities.Datastream{}, intID, qo)
-	observedProperty, err := processObservedProperty(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, &entities.Datastream{}, intID, qo)
+	observedProperty, err := processObservedProperty(gdb.Db, query, args, qi)
 	if err != nil {
 		return nil, err
 	}
@@ -67,13 +67,13 @@ func (gdb *GostDatabase) GetObservedPropertyByDatastream(id interface{}, qo *oda
 
 // GetObservedProperties returns all bool, observed properties
 func (gdb *GostDatabase) GetObservedProperties(qo *odata.QueryOptions) ([]*entities.ObservedProperty, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.ObservedProperty{}, nil, nil, qo)
-	return processObservedProperties(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.ObservedProperty{}, nil, nil, qo)
+	return processObservedProperties(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
-func processObservedProperty(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.ObservedProperty, error) {
-	ops, _, _, err := processObservedProperties(db, sql, nil, qi, "")
+func processObservedProperty(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.ObservedProperty, error) {
+	ops, _, _, err := processObservedProperties(db, sql, args, nil, qi, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -85,8 +85,8 @@ func processObservedProperty(db *sql.DB, sql string, qi *QueryParseInfo) (*entit
 	return ops[0], nil
 }
 
-func processObservedProperties(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string) ([]*entities.ObservedProperty, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processObservedProperties(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.ObservedProperty, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, hasNext, fmt.Errorf("Error executing query %v", err)
 	}
@@ -99,7 +99,7 @@ func processObservedProperties(db *sql.DB, sql string, qo *odata.QueryOptions, q
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
 		}
@@ -112,7 +112,7 @@ func processObservedProperties(db *sql.DB, sql string, qo *odata.QueryOptions, q
 func (gdb *GostDatabase) PostObservedProperty(op *entities.ObservedProperty) (*entities.ObservedProperty, error) {
 	var opID int
 	query := fmt.Sprintf("INSERT INTO %s.observedproperty (name, definition, description) VALUES ($1, $2, $3) RETURNING id", gdb.Schema)
-	err := gdb.Db.QueryRow(query, op.Name, op.Definition, op.Description).Scan(&opID)
+	err := gdb.statements(gdb.Db).QueryRow(query, op.Name, op.Definition, op.Description).Scan(&opID)
 	if err != nil {
 		return nil, err
 	}
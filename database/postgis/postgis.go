@@ -1,6 +1,7 @@
 package postgis
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -38,15 +39,16 @@ type GostDatabase struct {
 	MaxOpenConns int
 	Db           *sql.DB
 	QueryBuilder *QueryBuilder
+	requestID    string
 }
 
 func setupLogger() {
-	l, err := gostLog.GetLoggerInstance()
+	l, err := gostLog.GetPackageLogger("gost.server.database.postgis")
 	if err != nil {
 		log.Error(err)
 	}
 
-	logger = l.WithFields(log.Fields{"package": "gost.server.database.postgis"})
+	logger = l
 }
 
 // NewDatabase initialises the PostgreSQL database
@@ -81,19 +83,63 @@ func (gdb *GostDatabase) Start() {
 	logger.Infof("Creating database connection, host: %v, port: %v user: %v, database: %v, schema: %v ssl: %v", gdb.Host, gdb.Port, gdb.User, gdb.Database, gdb.Schema, gdb.Ssl)
 	dbInfo := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=%s", gdb.Host, gdb.User, gdb.Password, gdb.Database, ssl)
 	db, err := sql.Open("postgres", dbInfo)
-	db.SetMaxIdleConns(gdb.MaxIdeConns)
-	db.SetMaxOpenConns(gdb.MaxOpenConns)
-
 	if err != nil {
 		logger.Fatal(err)
 	}
 
+	db.SetMaxIdleConns(gdb.MaxIdeConns)
+	db.SetMaxOpenConns(gdb.MaxOpenConns)
 	gdb.Db = db
+
+	// sql.Open does not connect, ping so an unreachable database is reported at startup
+	if err = db.Ping(); err != nil {
+		logger.Warnf("Unable to connect to database: %v", err)
+		return
+	}
+
 	logger.Infof("Connected to database")
 }
 
-// CreateSchema creates the needed schema in the database
+// WithContext returns a copy of the database bound to the request of ctx, the statements executed by the
+// copy are logged with the id of the request
+func (gdb *GostDatabase) WithContext(ctx context.Context) models.Database {
+	c := *gdb
+	c.requestID = gostLog.RequestIDFromContext(ctx)
+	return &c
+}
+
+// statementLogger returns the logger for the statements of the request the database is bound to
+func (gdb *GostDatabase) statementLogger() *log.Entry {
+	return gostLog.WithRequestID(logger, gdb.requestID)
+}
+
+// statements returns db logging its statements with the id of the request the database is bound to
+func (gdb *GostDatabase) statements(db executor) executor {
+	return statementExecutor{executor: db, logger: gdb.statementLogger()}
+}
+
+// Ping checks if a connection to the database can be made
+func (gdb *GostDatabase) Ping() error {
+	return gdb.Db.Ping()
+}
+
+// Close closes the connections to the database
+func (gdb *GostDatabase) Close() error {
+	if gdb.Db == nil {
+		return nil
+	}
+
+	return gdb.Db.Close()
+}
+
+// CreateSchema creates the needed schema in the database using the script at location, the
+// migrations are applied when location is empty
 func (gdb *GostDatabase) CreateSchema(location string) error {
+	if len(location) == 0 {
+		_, err := gdb.Migrate()
+		return err
+	}
+
 	create, err := GetCreateDatabaseQuery(location, gdb.Schema)
 	if err != nil {
 		return err
@@ -148,7 +194,7 @@ func DeleteEntity(gdb *GostDatabase, id interface{}, entityName string) error {
 		return gostErrors.NewRequestNotFound(errors.New(errorMessage))
 	}
 
-	r, err := gdb.Db.Exec(fmt.Sprintf("DELETE FROM %s.%s WHERE id = $1", gdb.Schema, entityName), intID)
+	r, err := gdb.statements(gdb.Db).Exec(fmt.Sprintf("DELETE FROM %s.%s WHERE id = $1", gdb.Schema, entityName), intID)
 	if err != nil {
 		return err
 	}
@@ -198,6 +244,10 @@ func ToIntID(id interface{}) (int, bool) {
 	return intID, true
 }
 
+// geometryUpdate is the GeoJSON value for a geometry column in updateEntityColumns
+type geometryUpdate string
+
+// updateEntityColumns updates the given columns of an entity, all values are passed as bind parameters
 func (gdb *GostDatabase) updateEntityColumns(table string, updates map[string]interface{}, entityID int) error {
 	if len(updates) == 0 {
 		return nil
@@ -205,22 +255,24 @@ func (gdb *GostDatabase) updateEntityColumns(table string, updates map[string]in
 
 	columns := ""
 	prefix := ""
+	args := []interface{}{entityID}
 	for k, v := range updates {
+		placeholder := fmt.Sprintf("$%v", len(args)+1)
 		switch t := v.(type) {
-		case string:
-			// do not format when value contains ST_ at the start
-			if !strings.HasPrefix(t, "ST_") {
-				v = fmt.Sprintf("'%s'", t)
-			}
+		case geometryUpdate:
+			args = append(args, string(t))
+			placeholder = fmt.Sprintf("ST_SetSRID(public.ST_GeomFromGeoJSON(%s::text),4326)", placeholder)
+		default:
+			args = append(args, v)
 		}
 
-		columns += fmt.Sprintf("%s%s=%v", prefix, k, v)
+		columns += fmt.Sprintf("%s%s=%s", prefix, k, placeholder)
 		if prefix != ", " {
 			prefix = ", "
 		}
 	}
 
 	sql := fmt.Sprintf("update %s.%s set %s where id = $1", gdb.Schema, table, columns)
-	_, err := gdb.Db.Exec(sql, entityID)
+	_, err := gdb.statements(gdb.Db).Exec(sql, args...)
 	return err
 }
@@ -2,12 +2,14 @@ package postgis
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	entities "github.com/gost/core"
 	"github.com/gost/godata"
 	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/metrics"
 	"github.com/gost/server/sensorthings/odata"
 	log "github.com/sirupsen/logrus"
 )
@@ -17,6 +19,13 @@ type QueryBuilder struct {
 	maxTop int
 	schema string
 	tables map[entities.EntityType]string
+	args   *queryArgs
+}
+
+// queryArgs holds the bind parameters of a query, values are never written into the
+// SQL text, only their placeholder ($1, $2, ...) is
+type queryArgs struct {
+	values []interface{}
 }
 
 // CreateQueryBuilder instantiates a new queryBuilder, the queryBuilder is used to create
@@ -27,11 +36,81 @@ func CreateQueryBuilder(schema string, maxTop int) *QueryBuilder {
 		schema: schema,
 		maxTop: maxTop,
 		tables: createTableMappings(schema),
+		args:   &queryArgs{},
 	}
 
 	return qb
 }
 
+// newQuery returns a copy of the QueryBuilder with its own bind parameters, the QueryBuilder
+// is shared by all requests so every query needs to collect its parameters separately
+func (qb *QueryBuilder) newQuery() *QueryBuilder {
+	query := *qb
+	query.args = &queryArgs{}
+	return &query
+}
+
+// addArg adds a bind parameter to the query and returns the placeholder to use in the SQL
+func (qb *QueryBuilder) addArg(value interface{}) string {
+	qb.args.values = append(qb.args.values, value)
+	return fmt.Sprintf("$%v", len(qb.args.values))
+}
+
+// getArg returns the value of the bind parameter behind the given placeholder, returns false
+// if the input is not a placeholder of the query
+func (qb *QueryBuilder) getArg(placeholder string) (interface{}, bool) {
+	if !strings.HasPrefix(placeholder, "$") {
+		return nil, false
+	}
+
+	i, err := strconv.Atoi(placeholder[1:])
+	if err != nil || i < 1 || i > len(qb.args.values) {
+		return nil, false
+	}
+
+	return qb.args.values[i-1], true
+}
+
+// setArg changes the value of the bind parameter behind the given placeholder, returns false
+// if the input is not a placeholder of the query
+func (qb *QueryBuilder) setArg(placeholder string, value interface{}) bool {
+	if _, ok := qb.getArg(placeholder); !ok {
+		return false
+	}
+
+	i, _ := strconv.Atoi(placeholder[1:])
+	qb.args.values[i-1] = value
+	return true
+}
+
+// isStringValue returns true if a filter operand is a string literal or a bind parameter holding a string
+func (qb *QueryBuilder) isStringValue(operand string) bool {
+	if strings.HasPrefix(operand, "'") {
+		return true
+	}
+
+	value, _ := qb.getArg(operand)
+	_, ok := value.(string)
+	return ok
+}
+
+// setFilterValue sets the value of a filter operand, if the operand is a bind parameter the
+// parameter is changed else the operand is replaced by the given literal
+func (qb *QueryBuilder) setFilterValue(operand *string, value interface{}, literal string) {
+	if !qb.setArg(*operand, value) {
+		*operand = literal
+	}
+}
+
+// filterStringValue converts an OData string literal such as 'it''s' to its value it's
+func filterStringValue(literal string) string {
+	if len(literal) > 1 && strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") {
+		literal = literal[1 : len(literal)-1]
+	}
+
+	return strings.Replace(literal, "''", "'", -1)
+}
+
 // removeSchema removes the prefix in front of a table
 func (qb *QueryBuilder) removeSchema(table string) string {
 	i := strings.Index(table, ".")
@@ -53,8 +132,9 @@ func (qb *QueryBuilder) getLimit(qo *odata.QueryOptions, extra int) int {
 
 // getOffset returns the offset, this number is set by ODATA's
 // $skip, if not provided do not skip anything = return "0"
+// a $skiptoken replaces the offset by the keyset condition of getKeyset
 func (qb *QueryBuilder) getOffset(qo *odata.QueryOptions) string {
-	if qo != nil && qo.Skip != nil {
+	if qo != nil && qo.Skip != nil && len(qo.SkipToken) == 0 {
 		return fmt.Sprintf("%v", *qo.Skip)
 	}
 	return "0"
@@ -62,12 +142,17 @@ func (qb *QueryBuilder) getOffset(qo *odata.QueryOptions) string {
 
 // getOrderBy returns the string that needs to be placed after ORDER BY, this is set using
 // ODATA's $orderby if not given use the default ORDER BY "table".id DESC
+// when $orderby does not contain the id it is added as last key to get a unique order
 func (qb *QueryBuilder) getOrderBy(et entities.EntityType, qo *odata.QueryOptions, fromAs bool) string {
 	if qo != nil && qo.OrderBy != nil && len(qo.OrderBy.OrderByItems) > 0 {
 		obString := ""
+		hasID := false
 		for _, obi := range qo.OrderBy.OrderByItems {
 			propertyName := strings.ToLower(obi.Field.Value)
 			propertyName = qb.changeLocationField(propertyName)
+			if propertyName == idField {
+				hasID = true
+			}
 
 			if fromAs {
 				propertyName = asMappings[et][propertyName]
@@ -82,6 +167,14 @@ func (qb *QueryBuilder) getOrderBy(et entities.EntityType, qo *odata.QueryOption
 			}
 		}
 
+		if !hasID {
+			if fromAs {
+				obString = fmt.Sprintf("%s, %s DESC", obString, asMappings[et][idField])
+			} else {
+				obString = fmt.Sprintf("%s, %s DESC", obString, selectMappings[et][idField])
+			}
+		}
+
 		return obString
 	}
 
@@ -92,6 +185,67 @@ func (qb *QueryBuilder) getOrderBy(et entities.EntityType, qo *odata.QueryOption
 	return fmt.Sprintf("%s DESC", selectMappings[et][idField])
 }
 
+// getKeyset returns the condition selecting the entities ordered after the entity of the $skiptoken, for the
+// sort keys a and b this is a > $1 OR (a = $2 AND b > $3). PostgreSQL places NULL values last when
+// ordering ascending and first when ordering descending, an empty string is returned without $skiptoken
+func (qb *QueryBuilder) getKeyset(et entities.EntityType, qo *odata.QueryOptions) string {
+	if qo == nil || len(qo.SkipToken) == 0 {
+		return ""
+	}
+
+	equal := make([]string, 0)
+	after := make([]string, 0)
+	keys := qo.SortKeys()
+	for i, key := range keys {
+		field := selectMappings[et][qb.changeLocationField(strings.ToLower(key.Field))]
+		if len(field) == 0 {
+			continue
+		}
+
+		// the id is never null, its condition needs no IS NULL check
+		id := strings.ToLower(key.Field) == "id"
+		value := qo.SkipToken[i]
+		condition := ""
+		switch {
+		case value == nil && key.Desc:
+			condition = fmt.Sprintf("%s IS NOT NULL", field)
+		case value != nil && (key.Desc || id):
+			condition = fmt.Sprintf("%s %s %s", field, keysetOperator(key.Desc), qb.addArg(value))
+		case value != nil:
+			condition = fmt.Sprintf("(%s > %s OR %s IS NULL)", field, qb.addArg(value), field)
+		}
+
+		if len(condition) > 0 {
+			after = append(after, strings.Join(append(append([]string{}, equal...), condition), " AND "))
+		}
+
+		// the last key is unique, there are no further keys needing its equality
+		if i == len(keys)-1 {
+			break
+		}
+
+		if value == nil {
+			equal = append(equal, fmt.Sprintf("%s IS NULL", field))
+		} else {
+			equal = append(equal, fmt.Sprintf("%s = %s", field, qb.addArg(value)))
+		}
+	}
+
+	if len(after) == 0 {
+		return "0 = 1"
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(after, " OR "))
+}
+
+func keysetOperator(desc bool) string {
+	if desc {
+		return "<"
+	}
+
+	return ">"
+}
+
 // if location or feature is requested get the geojson field
 func (qb *QueryBuilder) changeLocationField(input string) string {
 	field := strings.ToLower(input)
@@ -313,10 +467,9 @@ func (qb *QueryBuilder) createJoin(e1 entities.Entity, e2 entities.Entity, id in
 }
 
 func (qb *QueryBuilder) createSelectByRelationString(e1 entities.Entity, e2 entities.Entity, id interface{}, qpi *QueryParseInfo) string {
-	// getJoinByID to get by id if the e1 table has an e2 fk
-	joinOnID := getJoinByID(qb.tables, e1.GetEntityType(), e2.GetEntityType(), id)
-	if joinOnID != "" {
-		return joinOnID
+	// getJoinByID to get by id if the e1 table has an e2 fk, only add the id as bind parameter when it is used
+	if getJoinByID(qb.tables, e1.GetEntityType(), e2.GetEntityType(), id) != "" {
+		return getJoinByID(qb.tables, e1.GetEntityType(), e2.GetEntityType(), qb.addArg(id))
 	}
 
 	// no e2 foreign key in e1, generate inner join and check on given id
@@ -503,7 +656,7 @@ func (qb *QueryBuilder) prepareFilter(et entities.EntityType, originalLeft, left
 		if property == "encodingtype" {
 			et, err := entities.CreateEncodingType(e)
 			if err == nil {
-				*str[1] = fmt.Sprintf("%v", et.Code)
+				qb.setFilterValue(str[1], et.Code, fmt.Sprintf("%v", et.Code))
 			}
 			return left, right
 		}
@@ -511,14 +664,15 @@ func (qb *QueryBuilder) prepareFilter(et entities.EntityType, originalLeft, left
 		if property == "observationtype" {
 			et, err := entities.GetObservationTypeByValue(e)
 			if err == nil {
-				*str[1] = fmt.Sprintf("%v", et.Code)
+				qb.setFilterValue(str[1], et.Code, fmt.Sprintf("%v", et.Code))
 			}
 			return left, right
 		}
 
 		if property == "phenomenontime" || property == "resulttime" || property == "time" {
 			if t, err := time.Parse(time.RFC3339Nano, e); err == nil {
-				*str[1] = fmt.Sprintf("'%s'", t.UTC().Format("2006-01-02T15:04:05.000Z"))
+				formatted := t.UTC().Format("2006-01-02T15:04:05.000Z")
+				qb.setFilterValue(str[1], formatted, fmt.Sprintf("'%s'", formatted))
 			}
 
 			return left, right
@@ -612,25 +766,26 @@ const (
 	LikeContains   LikeType = 2
 )
 
+// createLike adds the LIKE wildcards to a string bind parameter, other input is returned unchanged
 func (qb *QueryBuilder) createLike(input string, like LikeType) string {
-	if !strings.HasPrefix(input, "'") || !strings.HasSuffix(input, "'") {
+	value, _ := qb.getArg(input)
+	s, ok := value.(string)
+	if !ok {
 		return input
 	}
 
-	input = input[1 : len(input)-1]
-
 	switch like {
 	case LikeStartsWith:
 		{
-			return fmt.Sprintf("%s%s%s", "'", input, "%'")
+			qb.setArg(input, fmt.Sprintf("%s%s", s, "%"))
 		}
 	case LikeEndsWith:
 		{
-			return fmt.Sprintf("%s%s%s", "'%", input, "'")
+			qb.setArg(input, fmt.Sprintf("%s%s", "%", s))
 		}
 	case LikeContains:
 		{
-			return fmt.Sprintf("%s%s%s", "'%", input, "%'")
+			qb.setArg(input, fmt.Sprintf("%s%s%s", "%", s, "%"))
 		}
 	}
 
@@ -883,39 +1038,195 @@ func findFirstCouplingParseNode(pn *godata.ParseNode) *godata.ParseNode {
 	return findFirstCouplingParseNode(pn.Parent)
 }
 
+// aggregateResult converts the result of an observation into a number for the aggregates of $apply,
+// results that are no number are NULL and ignored by AVG, MIN, MAX and SUM
+const aggregateResult = "CASE WHEN jsonb_typeof(observation.data -> 'result') = 'number' THEN (observation.data ->> 'result')::double precision END"
+
+// aggregateInterval returns the start of the interval of the phenomenonTime of an observation as seconds since the Unix
+// epoch, when the phenomenonTime is a time interval itself its start is used
+func aggregateInterval(seconds int64) string {
+	return fmt.Sprintf("floor(extract(epoch FROM split_part(observation.data ->> 'phenomenonTime', '/', 1)::timestamptz) / %[1]d) * %[1]d", seconds)
+}
+
+// getAggregateWhere returns the WHERE clause selecting the observations of the Datastream with the given id, or of
+// all Datastreams when the id is nil, matching the $filter
+func (qb *QueryBuilder) getAggregateWhere(datastreamID interface{}, qo *odata.QueryOptions) string {
+	conditions := []string{"observation.data ->> 'phenomenonTime' IS NOT NULL"}
+	if datastreamID != nil {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", selectMappings[entities.EntityTypeObservation][observationStreamID], qb.addArg(datastreamID)))
+	}
+
+	if filter := strings.TrimSpace(qb.getFilterQueryString(entities.EntityTypeObservation, qo, "", false)); len(filter) > 0 {
+		conditions = append(conditions, fmt.Sprintf("(%s)", filter))
+	}
+
+	return fmt.Sprintf("WHERE %s", strings.Join(conditions, " AND "))
+}
+
+// CreateAggregateQuery creates the query for the $apply of the QueryOptions, the observations of the Datastream with the
+// given id, or of all Datastreams when the id is nil, are grouped by the interval of their phenomenonTime. Every row
+// contains the start of the interval in seconds since the Unix epoch followed by the aggregates in the order of $apply
+func (qb *QueryBuilder) CreateAggregateQuery(datastreamID interface{}, qo *odata.QueryOptions) (string, []interface{}) {
+	query := qb.newQuery()
+	aggregation := qo.Aggregation
+	columns := []string{fmt.Sprintf("%s AS interval_start", aggregateInterval(aggregation.Seconds()))}
+	for _, a := range aggregation.Aggregates {
+		switch a.Method {
+		case odata.AggregateMethodAverage:
+			columns = append(columns, fmt.Sprintf("AVG(%s)", aggregateResult))
+		case odata.AggregateMethodMin:
+			columns = append(columns, fmt.Sprintf("MIN(%s)", aggregateResult))
+		case odata.AggregateMethodMax:
+			columns = append(columns, fmt.Sprintf("MAX(%s)", aggregateResult))
+		case odata.AggregateMethodSum:
+			columns = append(columns, fmt.Sprintf("SUM(%s)", aggregateResult))
+		default:
+			columns = append(columns, "COUNT(*)")
+		}
+	}
+
+	order := "DESC"
+	if !qo.IntervalsDescending() {
+		order = "ASC"
+	}
+
+	limit := ""
+	if qo.Top != nil && int(*qo.Top) != -1 {
+		limit = fmt.Sprintf("LIMIT %v", qb.getLimit(qo, 1))
+	}
+
+	queryString := fmt.Sprintf("SELECT %s FROM %s %s GROUP BY interval_start ORDER BY interval_start %s %s OFFSET %s",
+		strings.Join(columns, ", "),
+		qb.tables[entities.EntityTypeObservation],
+		query.getAggregateWhere(datastreamID, qo),
+		order,
+		limit,
+		qb.getOffset(qo),
+	)
+
+	return queryString, query.args.values
+}
+
+// CreateAggregateCountQuery creates the query counting the intervals of the $apply of the QueryOptions,
+// an empty string is returned if ODATA Query Count is not set
+func (qb *QueryBuilder) CreateAggregateCountQuery(datastreamID interface{}, qo *odata.QueryOptions) (string, []interface{}) {
+	if qo.Count == nil {
+		return "", nil
+	}
+
+	query := qb.newQuery()
+	queryString := fmt.Sprintf("SELECT COUNT(DISTINCT %s) FROM %s %s",
+		aggregateInterval(qo.Aggregation.Seconds()),
+		qb.tables[entities.EntityTypeObservation],
+		query.getAggregateWhere(datastreamID, qo),
+	)
+
+	return queryString, query.args.values
+}
+
+// observationRollupTable is the companion table of observation holding the rollups of pruned observations
+const observationRollupTable = "observation_rollup"
+
+// rollupTable returns the name of the rollup table including the schema
+func (qb *QueryBuilder) rollupTable() string {
+	if len(qb.schema) > 0 {
+		return fmt.Sprintf("%s.%s", qb.schema, observationRollupTable)
+	}
+
+	return observationRollupTable
+}
+
+// getPruneWhere returns the WHERE clause selecting the observations of the datastream with a phenomenonTime before
+// the given time, the start of an interval phenomenonTime is used
+func (qb *QueryBuilder) getPruneWhere(datastreamID interface{}, before time.Time) string {
+	return fmt.Sprintf("WHERE %s = %s AND split_part(observation.data ->> 'phenomenonTime', '/', 1)::timestamptz < %s",
+		selectMappings[entities.EntityTypeObservation][observationStreamID],
+		qb.addArg(datastreamID),
+		qb.addArg(before),
+	)
+}
+
+// CreateRollupObservationsQuery creates the query adding the observations of the datastream with a phenomenonTime
+// before the given time to the rollups of the interval, existing rollups of the interval are updated
+func (qb *QueryBuilder) CreateRollupObservationsQuery(datastreamID interface{}, before time.Time, interval time.Duration) (string, []interface{}) {
+	query := qb.newQuery()
+	seconds := int64(interval / time.Second)
+	queryString := fmt.Sprintf("INSERT INTO %[1]s AS rollup (stream_id, interval_start, interval_sec, observation_count, result_count, result_sum, result_min, result_max) "+
+		"SELECT observation.stream_id, to_timestamp(%[2]s) AS interval_start, %[3]d, COUNT(*), COUNT(%[4]s), SUM(%[4]s), MIN(%[4]s), MAX(%[4]s) FROM %[5]s %[6]s "+
+		"GROUP BY observation.stream_id, interval_start "+
+		"ON CONFLICT (stream_id, interval_sec, interval_start) DO UPDATE SET "+
+		"observation_count = rollup.observation_count + EXCLUDED.observation_count, "+
+		"result_count = rollup.result_count + EXCLUDED.result_count, "+
+		"result_sum = COALESCE(rollup.result_sum + EXCLUDED.result_sum, rollup.result_sum, EXCLUDED.result_sum), "+
+		"result_min = LEAST(rollup.result_min, EXCLUDED.result_min), "+
+		"result_max = GREATEST(rollup.result_max, EXCLUDED.result_max)",
+		qb.rollupTable(),
+		aggregateInterval(seconds),
+		seconds,
+		aggregateResult,
+		qb.tables[entities.EntityTypeObservation],
+		query.getPruneWhere(datastreamID, before),
+	)
+
+	return queryString, query.args.values
+}
+
+// CreatePruneObservationsQuery creates the query deleting the observations of the datastream with a phenomenonTime
+// before the given time
+func (qb *QueryBuilder) CreatePruneObservationsQuery(datastreamID interface{}, before time.Time) (string, []interface{}) {
+	query := qb.newQuery()
+	queryString := fmt.Sprintf("DELETE FROM %s %s", qb.tables[entities.EntityTypeObservation], query.getPruneWhere(datastreamID, before))
+	return queryString, query.args.values
+}
+
+// CreatePruneRollupsQuery creates the query deleting the rollups of the datastream of intervals starting before the given time
+func (qb *QueryBuilder) CreatePruneRollupsQuery(datastreamID interface{}, before time.Time) (string, []interface{}) {
+	query := qb.newQuery()
+	queryString := fmt.Sprintf("DELETE FROM %s WHERE stream_id = %s AND interval_start < %s", qb.rollupTable(), query.addArg(datastreamID), query.addArg(before))
+	return queryString, query.args.values
+}
+
 // CreateCountQuery creates the correct count query based on the given info
 //   e1: entity to get
 //   e2: from entity
 //   id: e2 == nil: where e1.id = ... | e2 != nil: where e2.id = ...
 // Returns an empty string if ODATA Query Count is set to false.
+// The returned args are the bind parameters for the placeholders in the query
 // example: Datastreams(1)/Thing = CreateCountQuery(&entities.Thing, &entities.Datastream, 1, nil)
-func (qb *QueryBuilder) CreateCountQuery(e1 entities.Entity, e2 entities.Entity, id interface{}, queryOptions *odata.QueryOptions) string {
+func (qb *QueryBuilder) CreateCountQuery(e1 entities.Entity, e2 entities.Entity, id interface{}, queryOptions *odata.QueryOptions) (string, []interface{}) {
 	if queryOptions.Count == nil {
-		return ""
+		return "", nil
 	}
 
 	if logger.Logger.Level == log.DebugLevel {
-		defer gostLog.DebugWithElapsedTime(logger, time.Now(), "constructing count query")
+		defer gostLog.DebugWithElapsedTime(queryLogger(queryOptions), time.Now(), "constructing count query")
 	}
 
-	queryString, _ := qb.getQueryString(e1, e2, id, queryOptions, true)
+	defer metrics.QueryBuildDuration.ObserveSince(time.Now(), "postgis", "count")
 
-	return queryString
+	query := qb.newQuery()
+	queryString, _ := query.getQueryString(e1, e2, id, queryOptions, true)
+
+	return queryString, query.args.values
 }
 
 // CreateQuery creates a new count query based on given input
 //   e1: entity to get
 //   e2: from entity
 //   id: e2 == nil: where e1.id = ... | e2 != nil: where e2.id = ...
+// The returned args are the bind parameters for the placeholders in the query
 // example: Datastreams(1)/Thing = CreateQuery(&entities.Thing, &entities.Datastream, 1, nil)
-func (qb *QueryBuilder) CreateQuery(e1 entities.Entity, e2 entities.Entity, id interface{}, queryOptions *odata.QueryOptions) (string, *QueryParseInfo) {
+func (qb *QueryBuilder) CreateQuery(e1 entities.Entity, e2 entities.Entity, id interface{}, queryOptions *odata.QueryOptions) (string, []interface{}, *QueryParseInfo) {
 	if logger.Logger.Level == log.DebugLevel {
-		defer gostLog.DebugWithElapsedTime(logger, time.Now(), "constructing select query")
+		defer gostLog.DebugWithElapsedTime(queryLogger(queryOptions), time.Now(), "constructing select query")
 	}
 
-	queryString, qpi := qb.getQueryString(e1, e2, id, queryOptions, false)
+	defer metrics.QueryBuildDuration.ObserveSince(time.Now(), "postgis", "select")
 
-	return queryString, qpi
+	query := qb.newQuery()
+	queryString, qpi := query.getQueryString(e1, e2, id, queryOptions, false)
+
+	return queryString, query.args.values, qpi
 }
 
 func (qb *QueryBuilder) getQueryString(e1 entities.Entity, e2 entities.Entity, id interface{}, queryOptions *odata.QueryOptions, isCount bool) (string, *QueryParseInfo) {
@@ -965,7 +1276,7 @@ func (qb *QueryBuilder) getQueryString(e1 entities.Entity, e2 entities.Entity, i
 	where := ""
 
 	if id != nil && e2 == nil {
-		where = fmt.Sprintf("%s WHERE %s = %v", where, selectMappings[et1][idField], id)
+		where = fmt.Sprintf("%s WHERE %s = %s", where, selectMappings[et1][idField], qb.addArg(id))
 	}
 
 	if qo != nil && qo.Filter != nil {
@@ -993,6 +1304,18 @@ func (qb *QueryBuilder) getQueryString(e1 entities.Entity, e2 entities.Entity, i
 		queryString = fmt.Sprintf("%s %s %s", queryString, prefix, selectBy)
 	}
 
+	// continue after the entity of the $skiptoken
+	if !isCount {
+		if keyset := qb.getKeyset(et1, qo); len(keyset) > 0 {
+			prefix := "WHERE"
+			if strings.TrimSpace(where) != "" || e2 != nil {
+				prefix = "AND"
+			}
+
+			queryString = fmt.Sprintf("%s %s %s", queryString, prefix, keyset)
+		}
+	}
+
 	if isCount {
 		queryString = fmt.Sprintf("%s ORDER BY %s) AS %s",
 			queryString,
@@ -2,7 +2,9 @@ package postgis
 
 import (
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	entities "github.com/gost/core"
 	"github.com/gost/godata"
@@ -32,7 +34,7 @@ func TestCreateFilter(t *testing.T) {
 	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenRoot, Value: "ho"}}, false) == "ho")
 	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenFloat, Value: "ho"}}, false) == "ho")
 	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenInteger, Value: "ho"}}, false) == "ho")
-	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenString, Value: "ho"}}, false) == "ho")
+	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenString, Value: "ho"}}, false) == "$1")
 	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenDate, Value: "ho"}}, false) == "ho")
 	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenTime, Value: "ho"}}, false) == "ho")
 	assert.True(t, qb.createFilter(entities.EntityTypeThing, &godata.ParseNode{Token: &godata.Token{Type: godata.FilterTokenDateTime, Value: "ho"}}, false) == "ho")
@@ -125,7 +127,7 @@ func TestCreateJoinWithExpand(t *testing.T) {
 func TestCreateCountQuery(t *testing.T) {
 	// arrange
 	qb := CreateQueryBuilder("v1.0", 1)
-	expected := "SELECT COUNT(DISTINCT A_datastream.datastream_id) FROM (SELECT datastream.thing_id AS datastream_thing_id, datastream.observedproperty_id AS datastream_observedproperty_id, datastream.sensor_id AS datastream_sensor_id, datastream.id AS datastream_id, datastream.name AS datastream_name, datastream.description AS datastream_description, datastream.unitofmeasurement AS datastream_unitofmeasurement, datastream.observationtype AS datastream_observationtype, public.ST_AsGeoJSON(datastream.observedarea) AS datastream_observedarea, datastream.phenomenontime AS datastream_phenomenontime, datastream.resulttime AS datastream_resulttime FROM v1.0.datastream WHERE datastream.name = 'Milk' AND Price < 2.55 AND datastream.thing_id = 1 ORDER BY datastream_id DESC) AS A_datastream "
+	expected := "SELECT COUNT(DISTINCT A_datastream.datastream_id) FROM (SELECT datastream.thing_id AS datastream_thing_id, datastream.observedproperty_id AS datastream_observedproperty_id, datastream.sensor_id AS datastream_sensor_id, datastream.id AS datastream_id, datastream.name AS datastream_name, datastream.description AS datastream_description, datastream.unitofmeasurement AS datastream_unitofmeasurement, datastream.observationtype AS datastream_observationtype, public.ST_AsGeoJSON(datastream.observedarea) AS datastream_observedarea, datastream.phenomenontime AS datastream_phenomenontime, datastream.resulttime AS datastream_resulttime FROM v1.0.datastream WHERE datastream.name = $1 AND Price < 2.55 AND datastream.thing_id = $2 ORDER BY datastream_id DESC) AS A_datastream "
 	qo := &odata.QueryOptions{}
 	cs, _ := godata.ParseCountString("true")
 	qo.Count = cs
@@ -134,11 +136,12 @@ func TestCreateCountQuery(t *testing.T) {
 	filter, _ := godata.ParseFilterString(input)
 	qo.Filter = filter
 
-	res := qb.CreateCountQuery(&entities.Datastream{}, &entities.Thing{}, 1, qo)
+	res, args := qb.CreateCountQuery(&entities.Datastream{}, &entities.Thing{}, 1, qo)
 
 	// assert
 	assert.NotNil(t, res)
 	assert.Equal(t, expected, res)
+	assert.Equal(t, []interface{}{"Milk", 1}, args)
 }
 
 func TestGetOrderByWithQueryOptions(t *testing.T) {
@@ -187,7 +190,7 @@ func TestOdataLogicalOperatorToPostgreSQL(t *testing.T) {
 func TestCreateCountQueryWithoutId(t *testing.T) {
 	// arrange
 	qb := CreateQueryBuilder("v1.0", 1)
-	expected := "SELECT COUNT(DISTINCT A_datastream.datastream_id) FROM (SELECT datastream.thing_id AS datastream_thing_id, datastream.observedproperty_id AS datastream_observedproperty_id, datastream.sensor_id AS datastream_sensor_id, datastream.id AS datastream_id, datastream.name AS datastream_name, datastream.description AS datastream_description, datastream.unitofmeasurement AS datastream_unitofmeasurement, datastream.observationtype AS datastream_observationtype, public.ST_AsGeoJSON(datastream.observedarea) AS datastream_observedarea, datastream.phenomenontime AS datastream_phenomenontime, datastream.resulttime AS datastream_resulttime FROM v1.0.datastream WHERE datastream.name = 'Milk' AND Price < 2.55 AND datastream.thing_id = <nil> ORDER BY datastream_id DESC) AS A_datastream "
+	expected := "SELECT COUNT(DISTINCT A_datastream.datastream_id) FROM (SELECT datastream.thing_id AS datastream_thing_id, datastream.observedproperty_id AS datastream_observedproperty_id, datastream.sensor_id AS datastream_sensor_id, datastream.id AS datastream_id, datastream.name AS datastream_name, datastream.description AS datastream_description, datastream.unitofmeasurement AS datastream_unitofmeasurement, datastream.observationtype AS datastream_observationtype, public.ST_AsGeoJSON(datastream.observedarea) AS datastream_observedarea, datastream.phenomenontime AS datastream_phenomenontime, datastream.resulttime AS datastream_resulttime FROM v1.0.datastream WHERE datastream.name = $1 AND Price < 2.55 AND datastream.thing_id = $2 ORDER BY datastream_id DESC) AS A_datastream "
 	qo := &odata.QueryOptions{}
 	cs, _ := godata.ParseCountString("true")
 	qo.Count = cs
@@ -195,24 +198,76 @@ func TestCreateCountQueryWithoutId(t *testing.T) {
 	filter, _ := godata.ParseFilterString(input)
 	qo.Filter = filter
 
-	res := qb.CreateCountQuery(&entities.Datastream{}, &entities.Thing{}, nil, qo)
+	res, args := qb.CreateCountQuery(&entities.Datastream{}, &entities.Thing{}, nil, qo)
 
 	// assert
 	assert.NotNil(t, res)
 	assert.Equal(t, expected, res)
+	assert.Equal(t, []interface{}{"Milk", nil}, args)
 }
 
 func TestCreateQuery(t *testing.T) {
 	// arrange
 	qb := CreateQueryBuilder("v1.0", 1)
-	expected := "SELECT A_datastream.datastream_id AS A_datastream_id, A_datastream.datastream_name AS A_datastream_name, A_datastream.datastream_description AS A_datastream_description, A_datastream.datastream_unitofmeasurement AS A_datastream_unitofmeasurement, A_datastream.datastream_observationtype AS A_datastream_observationtype, A_datastream.datastream_observedarea AS A_datastream_observedarea, A_datastream.datastream_phenomenontime AS A_datastream_phenomenontime, A_datastream.datastream_resulttime AS A_datastream_resulttime FROM (SELECT datastream.thing_id AS datastream_thing_id, datastream.observedproperty_id AS datastream_observedproperty_id, datastream.sensor_id AS datastream_sensor_id, datastream.id AS datastream_id, datastream.name AS datastream_name, datastream.description AS datastream_description, datastream.unitofmeasurement AS datastream_unitofmeasurement, datastream.observationtype AS datastream_observationtype, public.ST_AsGeoJSON(datastream.observedarea) AS datastream_observedarea, datastream.phenomenontime AS datastream_phenomenontime, datastream.resulttime AS datastream_resulttime FROM v1.0.datastream  WHERE datastream.thing_id = 0 ORDER BY datastream_id DESC  OFFSET 0) AS A_datastream "
+	expected := "SELECT A_datastream.datastream_id AS A_datastream_id, A_datastream.datastream_name AS A_datastream_name, A_datastream.datastream_description AS A_datastream_description, A_datastream.datastream_unitofmeasurement AS A_datastream_unitofmeasurement, A_datastream.datastream_observationtype AS A_datastream_observationtype, A_datastream.datastream_observedarea AS A_datastream_observedarea, A_datastream.datastream_phenomenontime AS A_datastream_phenomenontime, A_datastream.datastream_resulttime AS A_datastream_resulttime FROM (SELECT datastream.thing_id AS datastream_thing_id, datastream.observedproperty_id AS datastream_observedproperty_id, datastream.sensor_id AS datastream_sensor_id, datastream.id AS datastream_id, datastream.name AS datastream_name, datastream.description AS datastream_description, datastream.unitofmeasurement AS datastream_unitofmeasurement, datastream.observationtype AS datastream_observationtype, public.ST_AsGeoJSON(datastream.observedarea) AS datastream_observedarea, datastream.phenomenontime AS datastream_phenomenontime, datastream.resulttime AS datastream_resulttime FROM v1.0.datastream  WHERE datastream.thing_id = $1 ORDER BY datastream_id DESC  OFFSET 0) AS A_datastream "
 
 	// act
-	query, _ := qb.CreateQuery(&entities.Datastream{}, &entities.Thing{}, 0, nil)
+	query, args, _ := qb.CreateQuery(&entities.Datastream{}, &entities.Thing{}, 0, nil)
 
 	// assert
 	assert.NotNil(t, query)
 	assert.Equal(t, expected, query)
+	assert.Equal(t, []interface{}{0}, args)
+}
+
+func TestCreateQueryPassesFilterStringsAsBindParameters(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder("v1.0", 1)
+	qo := &odata.QueryOptions{}
+	qo.Filter, _ = godata.ParseFilterString("name eq 'x'' OR 1=1 --' and contains(description, 'test')")
+
+	// act
+	query, args, _ := qb.CreateQuery(&entities.Thing{}, nil, 5, qo)
+
+	// assert
+	assert.True(t, strings.Contains(query, "WHERE thing.id = $1 AND"), query)
+	assert.True(t, strings.Contains(query, "thing.name = $2 AND thing.description LIKE $3"), query)
+	assert.False(t, strings.Contains(query, "OR 1=1"))
+	assert.Equal(t, []interface{}{5, "x' OR 1=1 --", "%test%"}, args)
+}
+
+func TestCreateQueryUsesSeparateBindParameters(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder("v1.0", 1)
+	qo := &odata.QueryOptions{}
+	qo.Filter, _ = godata.ParseFilterString("name eq 'test'")
+
+	// act
+	_, args1, _ := qb.CreateQuery(&entities.Thing{}, nil, nil, qo)
+	_, args2, _ := qb.CreateQuery(&entities.Thing{}, nil, nil, qo)
+
+	// assert
+	assert.Equal(t, []interface{}{"test"}, args1)
+	assert.Equal(t, []interface{}{"test"}, args2)
+}
+
+func TestPrepareFilterWithBindParameter(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder("v1.0", 1)
+	placeholder := qb.addArg("application/vnd.geo+json")
+
+	// act
+	_, right := qb.prepareFilter(entities.EntityTypeLocation, "encodingtype", "encodingtype", "'application/vnd.geo+json'", placeholder)
+
+	// assert
+	assert.Equal(t, "$1", right)
+	assert.Equal(t, []interface{}{1}, qb.args.values)
+}
+
+func TestFilterStringValue(t *testing.T) {
+	assert.Equal(t, "ho", filterStringValue("'ho'"))
+	assert.Equal(t, "it's", filterStringValue("'it''s'"))
+	assert.Equal(t, "'", filterStringValue("'"))
 }
 
 func TestConstructQueryParseInfo(t *testing.T) {
@@ -319,3 +374,83 @@ func TestSortQueryOptionsScenario4(t *testing.T) {
 	assert.Equal(t, "name", qo.Expand.ExpandItems[0].Filter.Tree.Children[1].Children[0].Children[0].Token.Value)
 	assert.Equal(t, "10", qo.Expand.ExpandItems[0].Filter.Tree.Children[1].Children[1].Token.Value)
 }
+
+func TestGetOrderByAddsID(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder("v1.0", 1)
+	qo := &odata.QueryOptions{}
+	qo.OrderBy, _ = godata.ParseOrderByString("name desc")
+
+	// act
+	res := qb.getOrderBy(entities.EntityTypeDatastream, qo, false)
+
+	// assert
+	assert.Equal(t, "datastream.name desc, datastream.id DESC", res)
+}
+
+func TestCreateQueryWithSkipToken(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder("v1.0", 1)
+	qo := &odata.QueryOptions{}
+	qo.OrderBy, _ = godata.ParseOrderByString("name asc")
+	qo.Top, _ = godata.ParseTopString("2")
+	qo.Skip, _ = godata.ParseSkipString("4")
+	qo.SkipToken = odata.SkipToken{"a", int64(3)}
+
+	// act
+	query, args, _ := qb.CreateQuery(&entities.Thing{}, nil, nil, qo)
+	countQuery, countArgs := qb.CreateCountQuery(&entities.Thing{}, nil, nil, qo)
+
+	// assert
+	assert.True(t, strings.Contains(query, "WHERE ((thing.name > $1 OR thing.name IS NULL) OR thing.name = $2 AND thing.id < $3) ORDER BY"), query)
+	assert.True(t, strings.Contains(query, "OFFSET 0)"), "the $skiptoken replaces $skip")
+	assert.Equal(t, []interface{}{"a", "a", int64(3)}, args)
+	assert.False(t, strings.Contains(countQuery, "thing.name >"), "the count should not be limited by the $skiptoken")
+	assert.Equal(t, 0, len(countArgs))
+}
+
+func TestCreateAggregateQuery(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder("v1.0", 1)
+	qo := &odata.QueryOptions{}
+	qo.Aggregation, _ = odata.ParseApply("groupby((interval(phenomenonTime,PT1H)),aggregate(result with average as avg,$count as count))")
+	qo.Filter, _ = godata.ParseFilterString("result gt 10")
+	qo.Top, _ = godata.ParseTopString("24")
+	count := godata.GoDataCountQuery(true)
+	qo.Count = &count
+	interval := "floor(extract(epoch FROM split_part(observation.data ->> 'phenomenonTime', '/', 1)::timestamptz) / 3600) * 3600"
+
+	// act
+	query, args := qb.CreateAggregateQuery(5, qo)
+	countQuery, countArgs := qb.CreateAggregateCountQuery(5, qo)
+
+	// assert
+	assert.True(t, strings.HasPrefix(query, "SELECT "+interval+" AS interval_start, AVG(CASE WHEN jsonb_typeof(observation.data -> 'result') = 'number'"), query)
+	assert.True(t, strings.Contains(query, "COUNT(*) FROM v1.0.observation WHERE observation.data ->> 'phenomenonTime' IS NOT NULL AND observation.stream_id = $1 AND ("), query)
+	assert.True(t, strings.HasSuffix(query, "GROUP BY interval_start ORDER BY interval_start DESC LIMIT 25 OFFSET 0"), query)
+	assert.Equal(t, 5, args[0])
+	assert.True(t, strings.HasPrefix(countQuery, "SELECT COUNT(DISTINCT "+interval+") FROM v1.0.observation WHERE"), countQuery)
+	assert.Equal(t, args, countArgs)
+}
+
+func TestCreateRollupObservationsQuery(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder("v1.0", 1)
+	before := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// act
+	query, args := qb.CreateRollupObservationsQuery(5, before, time.Hour)
+	pruneQuery, pruneArgs := qb.CreatePruneObservationsQuery(5, before)
+	rollupsQuery, rollupsArgs := qb.CreatePruneRollupsQuery(5, before)
+
+	// assert
+	assert.True(t, strings.HasPrefix(query, "INSERT INTO v1.0.observation_rollup AS rollup (stream_id, interval_start, interval_sec,"), query)
+	assert.True(t, strings.Contains(query, "AS interval_start, 3600, COUNT(*), COUNT(CASE WHEN jsonb_typeof"), query)
+	assert.True(t, strings.Contains(query, "FROM v1.0.observation WHERE observation.stream_id = $1 AND split_part(observation.data ->> 'phenomenonTime', '/', 1)::timestamptz < $2 GROUP BY"), query)
+	assert.True(t, strings.Contains(query, "ON CONFLICT (stream_id, interval_sec, interval_start) DO UPDATE SET"), query)
+	assert.Equal(t, []interface{}{5, before}, args)
+	assert.Equal(t, "DELETE FROM v1.0.observation WHERE observation.stream_id = $1 AND split_part(observation.data ->> 'phenomenonTime', '/', 1)::timestamptz < $2", pruneQuery)
+	assert.Equal(t, args, pruneArgs)
+	assert.Equal(t, "DELETE FROM v1.0.observation_rollup WHERE stream_id = $1 AND interval_start < $2", rollupsQuery)
+	assert.Equal(t, args, rollupsArgs)
+}
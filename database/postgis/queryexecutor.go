@@ -15,26 +15,65 @@ import (
 
 var idAsSuffix = fmt.Sprintf("%s%s", asSeparator, idField)
 
-// ExecuteSelectCount runs a given count query and returns the value
-func ExecuteSelectCount(db *sql.DB, sql string) (int, error) {
+// queryLogger returns the logger for the queries of a request, the lines hold the id of the request
+func queryLogger(qo *odata.QueryOptions) *log.Entry {
+	if qo == nil {
+		return logger
+	}
+
+	return gostLog.WithRequestID(logger, qo.RequestID)
+}
+
+// executor is implemented by both sql.DB and sql.Tx so statements can run with or without a transaction
+type executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// statementExecutor logs the insert, update and delete statements it executes at debug level
+type statementExecutor struct {
+	executor
+	logger *log.Entry
+}
+
+// Exec executes the statement on the wrapped database or transaction
+func (e statementExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if e.logger.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(e.logger, time.Now(), "execute statement: %s", query)
+	}
+
+	return e.executor.Exec(query, args...)
+}
+
+// QueryRow executes a statement returning a row, such as an insert returning the new id
+func (e statementExecutor) QueryRow(query string, args ...interface{}) *sql.Row {
+	if e.logger.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(e.logger, time.Now(), "execute statement: %s", query)
+	}
+
+	return e.executor.QueryRow(query, args...)
+}
+
+// ExecuteSelectCount runs a given count query with its bind parameters and returns the value
+func ExecuteSelectCount(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions) (int, error) {
 	if logger.Logger.Level == log.DebugLevel {
-		defer gostLog.DebugfWithElapsedTime(logger, time.Now(), "execute count query: %s", sql)
+		defer gostLog.DebugfWithElapsedTime(queryLogger(qo), time.Now(), "execute count query: %s", sql)
 	}
 
 	var count int
-	db.QueryRow(sql).Scan(&count)
+	db.QueryRow(sql, args...).Scan(&count)
 
 	return count, nil
 }
 
-// ExecuteSelect executes the select query and creates the retrieved entities
-func ExecuteSelect(db *sql.DB, q *QueryParseInfo, sql string, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+// ExecuteSelect executes the select query with its bind parameters and creates the retrieved entities
+func ExecuteSelect(db *sql.DB, q *QueryParseInfo, sql string, args []interface{}, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
 	hasNextPage := false
 	if logger.Logger.Level == log.DebugLevel {
-		defer gostLog.DebugfWithElapsedTime(logger, time.Now(), "execute select query: %s", sql)
+		defer gostLog.DebugfWithElapsedTime(queryLogger(qo), time.Now(), "execute select query: %s", sql)
 	}
 
-	rows, err := db.Query(sql)
+	rows, err := db.Query(sql, args...)
 	if err != nil {
 		return nil, hasNextPage, err
 	}
@@ -43,8 +43,8 @@ func (gdb *GostDatabase) GetSensor(id interface{}, qo *odata.QueryOptions) (*ent
 		return nil, gostErrors.NewRequestNotFound(errors.New("Sensor does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, nil, intID, qo)
-	sensor, err := processSensor(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, nil, intID, qo)
+	sensor, err := processSensor(gdb.Db, query, args, qi)
 
 	if err != nil {
 		return nil, err
@@ -60,8 +60,8 @@ func (gdb *GostDatabase) GetSensorByDatastream(id interface{}, qo *odata.QueryOp
 		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, &entities.Datastream{}, intID, qo)
-	sensor, err := processSensor(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, &entities.Datastream{}, intID, qo)
+	sensor, err := processSensor(gdb.Db, query, args, qi)
 	if err != nil {
 		return nil, err
 	}
@@ -71,13 +71,13 @@ func (gdb *GostDatabase) GetSensorByDatastream(id interface{}, qo *odata.QueryOp
 
 // GetSensors retrieves all sensors based on the QueryOptions
 func (gdb *GostDatabase) GetSensors(qo *odata.QueryOptions) ([]*entities.Sensor, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Sensor{}, nil, nil, qo)
-	return processSensors(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Sensor{}, nil, nil, qo)
+	return processSensors(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
-func processSensor(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Sensor, error) {
-	sensors, _, _, err := processSensors(db, sql, nil, qi, "")
+func processSensor(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Sensor, error) {
+	sensors, _, _, err := processSensors(db, sql, args, nil, qi, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -89,8 +89,8 @@ func processSensor(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Sensor
 	return sensors[0], nil
 }
 
-func processSensors(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string) ([]*entities.Sensor, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processSensors(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Sensor, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, hasNext, fmt.Errorf("Error executing query %v", err)
 	}
@@ -103,7 +103,7 @@ func processSensors(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryPar
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
 		}
@@ -121,7 +121,7 @@ func (gdb *GostDatabase) PostSensor(sensor *entities.Sensor) (*entities.Sensor,
 	}
 
 	sql2 := fmt.Sprintf("INSERT INTO %s.sensor (name, description, encodingtype, metadata) VALUES ($1, $2, $3, $4) RETURNING id", gdb.Schema)
-	err2 := gdb.Db.QueryRow(sql2, sensor.Name, sensor.Description, encoding.Code, sensor.Metadata).Scan(&sensorID)
+	err2 := gdb.statements(gdb.Db).QueryRow(sql2, sensor.Name, sensor.Description, encoding.Code, sensor.Metadata).Scan(&sensorID)
 	if err2 != nil {
 		return nil, err2
 	}
@@ -47,8 +47,8 @@ func (gdb *GostDatabase) GetThing(id interface{}, qo *odata.QueryOptions) (*enti
 		return nil, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, nil, intID, qo)
-	return processThing(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, nil, intID, qo)
+	return processThing(gdb.Db, query, args, qi)
 }
 
 //GetThingByDatastream retrieves the thing linked to a datastream
@@ -58,8 +58,8 @@ func (gdb *GostDatabase) GetThingByDatastream(id interface{}, qo *odata.QueryOpt
 		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.Datastream{}, intID, qo)
-	return processThing(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.Datastream{}, intID, qo)
+	return processThing(gdb.Db, query, args, qi)
 }
 
 //GetThingsByLocation retrieves the thing linked to a location
@@ -69,9 +69,9 @@ func (gdb *GostDatabase) GetThingsByLocation(id interface{}, qo *odata.QueryOpti
 		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.Location{}, intID, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Thing{}, &entities.Location{}, intID, qo)
-	return processThings(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.Location{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Thing{}, &entities.Location{}, intID, qo)
+	return processThings(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
 //GetThingByHistoricalLocation retrieves the thing linked to a HistoricalLocation
@@ -81,19 +81,19 @@ func (gdb *GostDatabase) GetThingByHistoricalLocation(id interface{}, qo *odata.
 		return nil, gostErrors.NewRequestNotFound(errors.New("HistoricalLocation does not exist"))
 	}
 
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.HistoricalLocation{}, intID, qo)
-	return processThing(gdb.Db, query, qi)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.HistoricalLocation{}, intID, qo)
+	return processThing(gdb.Db, query, args, qi)
 }
 
 // GetThings returns an array of things
 func (gdb *GostDatabase) GetThings(qo *odata.QueryOptions) ([]*entities.Thing, int, bool, error) {
-	query, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, nil, nil, qo)
-	countSQL := gdb.QueryBuilder.CreateCountQuery(&entities.Thing{}, nil, nil, qo)
-	return processThings(gdb.Db, query, qo, qi, countSQL)
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Thing{}, nil, nil, qo)
+	return processThings(gdb.Db, query, args, qo, qi, countSQL, countArgs)
 }
 
-func processThing(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Thing, error) {
-	things, _, _, err := processThings(db, sql, nil, qi, "")
+func processThing(db *sql.DB, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Thing, error) {
+	things, _, _, err := processThings(db, sql, args, nil, qi, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +105,8 @@ func processThing(db *sql.DB, sql string, qi *QueryParseInfo) (*entities.Thing,
 	return things[0], nil
 }
 
-func processThings(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string) ([]*entities.Thing, int, bool, error) {
-	data, hasNext, err := ExecuteSelect(db, qi, sql, qo)
+func processThings(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Thing, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(db, qi, sql, args, qo)
 	if err != nil {
 		return nil, 0, hasNext, fmt.Errorf("Error executing query %v", err)
 	}
@@ -119,7 +119,7 @@ func processThings(db *sql.DB, sql string, qo *odata.QueryOptions, qi *QueryPars
 
 	var count int
 	if len(countSQL) > 0 {
-		count, err = ExecuteSelectCount(db, countSQL)
+		count, err = ExecuteSelectCount(db, countSQL, countArgs, qo)
 		if err != nil {
 			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
 		}
@@ -134,7 +134,7 @@ func (gdb *GostDatabase) PostThing(thing *entities.Thing) (*entities.Thing, erro
 	jsonProperties, _ := json.Marshal(thing.Properties)
 	var thingID int
 	query := fmt.Sprintf("INSERT INTO %s.thing (name, description, properties) VALUES ($1, $2, $3) RETURNING id", gdb.Schema)
-	err := gdb.Db.QueryRow(query, thing.Name, thing.Description, jsonProperties).Scan(&thingID)
+	err := gdb.statements(gdb.Db).QueryRow(query, thing.Name, thing.Description, jsonProperties).Scan(&thingID)
 	if err != nil {
 		return nil, err
 	}
@@ -183,13 +183,13 @@ func (gdb *GostDatabase) PatchThing(id interface{}, thing *entities.Thing) (*ent
 				// todo: check if location exist
 				if location != nil {
 					query := fmt.Sprintf("update %s.thing_to_location set location_id  = $1 where thing_id= $2", gdb.Schema)
-					res, err := gdb.Db.Exec(query, location.ID, intID)
+					res, err := gdb.statements(gdb.Db).Exec(query, location.ID, intID)
 					if err != nil {
 						return nil, err
 					}
 					if c, _ := res.RowsAffected(); c == 0 {
 						sqlInsert := fmt.Sprintf("insert into %s.thing_to_location (location_id,thing_id) values ($1, $2)", gdb.Schema)
-						_, err := gdb.Db.Exec(sqlInsert, location.ID, intID)
+						_, err := gdb.statements(gdb.Db).Exec(sqlInsert, location.ID, intID)
 						if err != nil {
 							return nil, err
 						}
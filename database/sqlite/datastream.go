@@ -0,0 +1,330 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+func datastreamParamFactory(values map[string]interface{}) (entities.Entity, error) {
+	ds := &entities.Datastream{}
+	for property, value := range values {
+		if value == nil {
+			continue
+		}
+
+		switch property {
+		case "id":
+			ds.ID = value
+		case "name":
+			ds.Name = value.(string)
+		case "description":
+			ds.Description = value.(string)
+		case "unitOfMeasurement":
+			ds.UnitOfMeasurement, _ = value.(map[string]interface{})
+		case "observationType":
+			ds.ObservationType = value.(string)
+		case "observedArea":
+			ds.ObservedArea, _ = value.(map[string]interface{})
+		case "phenomenonTime":
+			ds.PhenomenonTime = value.(string)
+		case "resultTime":
+			ds.ResultTime = value.(string)
+		}
+	}
+
+	return ds, nil
+}
+
+// GetObservedArea returns the observed area of all observations of datastream, the area can only
+// be calculated when SpatiaLite is loaded
+func (gdb *GostDatabase) GetObservedArea(id int) (map[string]interface{}, error) {
+	if !gdb.SpatiaLite {
+		return nil, nil
+	}
+
+	query := "SELECT AsGeoJSON(ConvexHull(ST_Collect(GeomFromGeoJSON(feature)))) FROM featureofinterest WHERE id IN (SELECT DISTINCT featureofinterest_id FROM observation WHERE stream_id = ?1)"
+	var geom *string
+	if err := gdb.Db.QueryRow(query, id).Scan(&geom); err != nil {
+		return nil, err
+	}
+
+	return JSONToMap(geom)
+}
+
+// GetDatastream retrieves a datastream by id
+func (gdb *GostDatabase) GetDatastream(id interface{}, qo *odata.QueryOptions) (*entities.Datastream, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, nil, intID, qo)
+	datastream, err := processDatastream(gdb, query, args, qi)
+	if err != nil {
+		return nil, err
+	}
+
+	// calculate observedArea on the fly when not present in database
+	if qo != nil && datastream.ObservedArea == nil {
+		if qo.Select == nil || containsProperty(qi.Properties, "observedArea") {
+			datastream.ObservedArea, _ = gdb.GetObservedArea(intID)
+		}
+	}
+
+	return datastream, nil
+}
+
+// GetDatastreams retrieves all datastreams
+func (gdb *GostDatabase) GetDatastreams(qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, nil, nil, qo)
+	return processDatastreams(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetDatastreamByObservation returns a datastream linked to an observation
+func (gdb *GostDatabase) GetDatastreamByObservation(observationID interface{}, qo *odata.QueryOptions) (*entities.Datastream, error) {
+	intID, ok := ToIntID(observationID)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Observation does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Observation{}, intID, qo)
+	return processDatastream(gdb, query, args, qi)
+}
+
+// GetDatastreamsByThing retrieves all datastreams linked to the given thing
+func (gdb *GostDatabase) GetDatastreamsByThing(thingID interface{}, qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	intID, ok := ToIntID(thingID)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Thing{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.Thing{}, intID, qo)
+	return processDatastreams(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetDatastreamsBySensor retrieves all datastreams linked to the given sensor
+func (gdb *GostDatabase) GetDatastreamsBySensor(sensorID interface{}, qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	intID, ok := ToIntID(sensorID)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Sensor does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.Sensor{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.Sensor{}, intID, qo)
+	return processDatastreams(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetDatastreamsByObservedProperty retrieves all datastreams linked to the given ObservedProperty
+func (gdb *GostDatabase) GetDatastreamsByObservedProperty(oID interface{}, qo *odata.QueryOptions) ([]*entities.Datastream, int, bool, error) {
+	intID, ok := ToIntID(oID)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("ObservedProperty does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Datastream{}, &entities.ObservedProperty{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Datastream{}, &entities.ObservedProperty{}, intID, qo)
+	return processDatastreams(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+func processDatastream(gdb *GostDatabase, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Datastream, error) {
+	datastreams, _, _, err := processDatastreams(gdb, sql, args, nil, qi, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(datastreams) == 0 {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream not found"))
+	}
+
+	return datastreams[0], nil
+}
+
+func processDatastreams(gdb *GostDatabase, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Datastream, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(gdb, qi, sql, args, qo)
+	if err != nil {
+		return nil, 0, hasNext, selectError(err)
+	}
+
+	datastreams := make([]*entities.Datastream, 0)
+	for _, d := range data {
+		entity := d.(*entities.Datastream)
+		datastreams = append(datastreams, entity)
+	}
+
+	var count int
+	if len(countSQL) > 0 {
+		count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+		if err != nil {
+			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return datastreams, count, hasNext, nil
+}
+
+// CheckDatastreamRelationsExist check if the related entities exist
+func CheckDatastreamRelationsExist(gdb *GostDatabase, d *entities.Datastream) error {
+	var tID, sID, oID int
+	var ok bool
+
+	if d.Thing == nil {
+		return gostErrors.NewBadRequestError(errors.New("Thing does not exist"))
+	}
+
+	if tID, ok = ToIntID(d.Thing.ID); !ok || !gdb.ThingExists(tID) {
+		return gostErrors.NewBadRequestError(errors.New("Thing does not exist"))
+	}
+
+	if d.Sensor == nil {
+		return gostErrors.NewBadRequestError(errors.New("Sensor does not exist"))
+	}
+
+	if sID, ok = ToIntID(d.Sensor.ID); !ok || !gdb.SensorExists(sID) {
+		return gostErrors.NewBadRequestError(errors.New("Sensor does not exist"))
+	}
+
+	if d.ObservedProperty == nil {
+		return gostErrors.NewBadRequestError(errors.New("ObservedProperty does not exist"))
+	}
+
+	if oID, ok = ToIntID(d.ObservedProperty.ID); !ok || !gdb.ObservedPropertyExists(oID) {
+		return gostErrors.NewBadRequestError(errors.New("ObservedProperty does not exist"))
+	}
+	return nil
+}
+
+// PostDatastream posts a datastream
+func (gdb *GostDatabase) PostDatastream(d *entities.Datastream) (*entities.Datastream, error) {
+	err := CheckDatastreamRelationsExist(gdb, d)
+	if err != nil {
+		return nil, err
+	}
+
+	tID, _ := ToIntID(d.Thing.ID)
+	sID, _ := ToIntID(d.Sensor.ID)
+	oID, _ := ToIntID(d.ObservedProperty.ID)
+
+	unitOfMeasurement, _ := json.Marshal(d.UnitOfMeasurement)
+	var observedArea, phenomenonTime, resultTime interface{}
+	if len(d.ObservedArea) != 0 {
+		observedAreaBytes, _ := json.Marshal(d.ObservedArea)
+		observedArea = string(observedAreaBytes[:])
+	}
+
+	if len(d.PhenomenonTime) != 0 {
+		phenomenonTime = d.PhenomenonTime
+	}
+
+	if len(d.ResultTime) != 0 {
+		resultTime = d.ResultTime
+	}
+
+	// get the ObservationType id in the lookup table
+	observationType, err := entities.GetObservationTypeByValue(d.ObservationType)
+	if err != nil {
+		return nil, gostErrors.NewBadRequestError(errors.New("ObservationType does not exist"))
+	}
+
+	query := "INSERT INTO datastream (name, description, unitofmeasurement, observedarea, thing_id, sensor_id, observedproperty_id, observationtype, phenomenontime, resulttime) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)"
+	r, err := gdb.statements(gdb.Db).Exec(query, d.Name, d.Description, string(unitOfMeasurement[:]), observedArea, tID, sID, oID, observationType.Code, phenomenonTime, resultTime)
+	if err != nil {
+		return nil, err
+	}
+
+	dsID, _ := r.LastInsertId()
+	d.ID = int(dsID)
+
+	// clear inner entities to serves links upon response
+	d.Thing = nil
+	d.Sensor = nil
+	d.ObservedProperty = nil
+
+	return d, nil
+}
+
+// PatchDatastream updates a Datastream in the database
+func (gdb *GostDatabase) PatchDatastream(id interface{}, ds *entities.Datastream) (*entities.Datastream, error) {
+	var err error
+	var ok bool
+	var intID int
+	updates := make(map[string]interface{})
+
+	if intID, ok = ToIntID(id); !ok || !gdb.DatastreamExists(intID) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	if len(ds.Name) > 0 {
+		updates["name"] = ds.Name
+	}
+
+	if len(ds.Description) > 0 {
+		updates["description"] = ds.Description
+	}
+
+	if len(ds.ObservationType) > 0 {
+		observationType, err := entities.GetObservationTypeByValue(ds.ObservationType)
+		if err != nil {
+			return nil, gostErrors.NewBadRequestError(errors.New("ObservationType does not exist"))
+		}
+
+		updates["observationtype"] = observationType.Code
+	}
+
+	if len(ds.UnitOfMeasurement) > 0 {
+		j, _ := json.Marshal(ds.UnitOfMeasurement)
+		updates["unitofmeasurement"] = string(j[:])
+	}
+
+	if len(ds.ObservedArea) > 0 {
+		observedAreaBytes, _ := json.Marshal(ds.ObservedArea)
+		updates["observedarea"] = string(observedAreaBytes[:])
+	}
+
+	if len(ds.PhenomenonTime) > 0 {
+		updates["phenomenontime"] = ds.PhenomenonTime
+	}
+
+	if len(ds.ResultTime) > 0 {
+		updates["resulttime"] = ds.ResultTime
+	}
+
+	if err = gdb.updateEntityColumns("datastream", updates, intID); err != nil {
+		return nil, err
+	}
+
+	nd, _ := gdb.GetDatastream(intID, nil)
+	return nd, nil
+}
+
+// PutDatastream receives a Datastream entity and changes it in the database
+// returns the adapted Datastream
+func (gdb *GostDatabase) PutDatastream(id interface{}, datastream *entities.Datastream) (*entities.Datastream, error) {
+	return gdb.PatchDatastream(id, datastream)
+}
+
+// DeleteDatastream tries to delete a Datastream by the given id
+func (gdb *GostDatabase) DeleteDatastream(id interface{}) error {
+	return DeleteEntity(gdb, id, "datastream")
+}
+
+// DatastreamExists checks if a Datastream is present in the database based on a given id
+func (gdb *GostDatabase) DatastreamExists(id int) bool {
+	return EntityExists(gdb, id, "datastream")
+}
+
+func containsProperty(properties []string, property string) bool {
+	for _, p := range properties {
+		if p == property {
+			return true
+		}
+	}
+
+	return false
+}
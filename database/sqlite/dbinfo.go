@@ -0,0 +1,192 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	entities "github.com/gost/core"
+)
+
+// tables as defined in the SQLite schema
+var (
+	thingTable                        = "thing"
+	locationTable                     = "location"
+	historicalLocationTable           = "historicallocation"
+	sensorTable                       = "sensor"
+	observedPropertyTable             = "observedproperty"
+	datastreamTable                   = "datastream"
+	observationTable                  = "observation"
+	featureOfInterestTable            = "featureofinterest"
+	thingToLocationTable              = "thing_to_location"
+	locationToHistoricalLocationTable = "location_to_historicallocation"
+)
+
+var idField = "id"
+
+var tableMappings = map[entities.EntityType]string{
+	entities.EntityTypeThing:              thingTable,
+	entities.EntityTypeLocation:           locationTable,
+	entities.EntityTypeHistoricalLocation: historicalLocationTable,
+	entities.EntityTypeSensor:             sensorTable,
+	entities.EntityTypeObservedProperty:   observedPropertyTable,
+	entities.EntityTypeObservation:        observationTable,
+	entities.EntityTypeFeatureOfInterest:  featureOfInterestTable,
+	entities.EntityTypeDatastream:         datastreamTable,
+}
+
+// columnKind describes how the value of a column is converted into an entity property
+type columnKind int
+
+const (
+	columnValue columnKind = iota
+	columnJSON
+	columnEncodingType
+	columnObservationType
+	columnObservationData
+)
+
+// column maps an entity property to the SQL expression used to select it (field) and to filter and order on it
+// (filter), JSON properties stored in a text column can be navigated in a filter using json_extract
+type column struct {
+	property string
+	field    string
+	filter   string
+	kind     columnKind
+}
+
+func valueColumn(table, property, name string) column {
+	field := fmt.Sprintf("%s.%s", table, name)
+	return column{property: property, field: field, filter: field, kind: columnValue}
+}
+
+func jsonColumn(table, property, name string) column {
+	c := valueColumn(table, property, name)
+	c.kind = columnJSON
+	return c
+}
+
+func codeColumn(table, property, name string, kind columnKind) column {
+	c := valueColumn(table, property, name)
+	c.kind = kind
+	return c
+}
+
+// observationColumn selects the data document of an observation, the property is read from the parsed
+// document so the JSON type of the result is kept
+func observationColumn(property string) column {
+	return column{
+		property: property,
+		field:    fmt.Sprintf("%s.data", observationTable),
+		filter:   fmt.Sprintf("json_extract(%s.data, '$.%s')", observationTable, property),
+		kind:     columnObservationData,
+	}
+}
+
+// columnMappings contains the columns per entity type, the properties are the JSON names of the entity
+var columnMappings = map[entities.EntityType][]column{
+	entities.EntityTypeThing: {
+		valueColumn(thingTable, "id", "id"),
+		valueColumn(thingTable, "name", "name"),
+		valueColumn(thingTable, "description", "description"),
+		jsonColumn(thingTable, "properties", "properties"),
+	},
+	entities.EntityTypeLocation: {
+		valueColumn(locationTable, "id", "id"),
+		valueColumn(locationTable, "name", "name"),
+		valueColumn(locationTable, "description", "description"),
+		codeColumn(locationTable, "encodingType", "encodingtype", columnEncodingType),
+		jsonColumn(locationTable, "location", "location"),
+	},
+	entities.EntityTypeHistoricalLocation: {
+		valueColumn(historicalLocationTable, "id", "id"),
+		valueColumn(historicalLocationTable, "time", "time"),
+	},
+	entities.EntityTypeSensor: {
+		valueColumn(sensorTable, "id", "id"),
+		valueColumn(sensorTable, "name", "name"),
+		valueColumn(sensorTable, "description", "description"),
+		codeColumn(sensorTable, "encodingType", "encodingtype", columnEncodingType),
+		valueColumn(sensorTable, "metadata", "metadata"),
+	},
+	entities.EntityTypeObservedProperty: {
+		valueColumn(observedPropertyTable, "id", "id"),
+		valueColumn(observedPropertyTable, "name", "name"),
+		valueColumn(observedPropertyTable, "definition", "definition"),
+		valueColumn(observedPropertyTable, "description", "description"),
+	},
+	entities.EntityTypeDatastream: {
+		valueColumn(datastreamTable, "id", "id"),
+		valueColumn(datastreamTable, "name", "name"),
+		valueColumn(datastreamTable, "description", "description"),
+		jsonColumn(datastreamTable, "unitOfMeasurement", "unitofmeasurement"),
+		codeColumn(datastreamTable, "observationType", "observationtype", columnObservationType),
+		jsonColumn(datastreamTable, "observedArea", "observedarea"),
+		valueColumn(datastreamTable, "phenomenonTime", "phenomenontime"),
+		valueColumn(datastreamTable, "resultTime", "resulttime"),
+	},
+	entities.EntityTypeObservation: {
+		valueColumn(observationTable, "id", "id"),
+		observationColumn("phenomenonTime"),
+		observationColumn("resultTime"),
+		observationColumn("result"),
+		observationColumn("validTime"),
+		observationColumn("resultQuality"),
+		observationColumn("parameters"),
+	},
+	entities.EntityTypeFeatureOfInterest: {
+		valueColumn(featureOfInterestTable, "id", "id"),
+		valueColumn(featureOfInterestTable, "name", "name"),
+		valueColumn(featureOfInterestTable, "description", "description"),
+		codeColumn(featureOfInterestTable, "encodingType", "encodingtype", columnEncodingType),
+		jsonColumn(featureOfInterestTable, "feature", "feature"),
+	},
+}
+
+// getColumn returns the column of an entity property, the property name is case insensitive
+func getColumn(et entities.EntityType, property string) (column, bool) {
+	for _, c := range columnMappings[et] {
+		if strings.EqualFold(c.property, property) {
+			return c, true
+		}
+	}
+
+	return column{}, false
+}
+
+// relationFilters contain the WHERE clause to select entities of the first type related to the entity of the
+// second type with the id set by the placeholder (%[1]s)
+var relationFilters = map[entities.EntityType]map[entities.EntityType]string{
+	entities.EntityTypeThing: {
+		entities.EntityTypeDatastream:         "thing.id = (SELECT thing_id FROM datastream WHERE id = %[1]s)",
+		entities.EntityTypeHistoricalLocation: "thing.id = (SELECT thing_id FROM historicallocation WHERE id = %[1]s)",
+		entities.EntityTypeLocation:           "thing.id IN (SELECT thing_id FROM thing_to_location WHERE location_id = %[1]s)",
+	},
+	entities.EntityTypeLocation: {
+		entities.EntityTypeThing:              "location.id IN (SELECT location_id FROM thing_to_location WHERE thing_id = %[1]s)",
+		entities.EntityTypeHistoricalLocation: "location.id IN (SELECT location_id FROM location_to_historicallocation WHERE historicallocation_id = %[1]s)",
+		entities.EntityTypeDatastream:         "location.id IN (SELECT tl.location_id FROM thing_to_location tl INNER JOIN datastream d ON d.thing_id = tl.thing_id WHERE d.id = %[1]s)",
+	},
+	entities.EntityTypeHistoricalLocation: {
+		entities.EntityTypeThing:    "historicallocation.thing_id = %[1]s",
+		entities.EntityTypeLocation: "historicallocation.id IN (SELECT historicallocation_id FROM location_to_historicallocation WHERE location_id = %[1]s)",
+	},
+	entities.EntityTypeDatastream: {
+		entities.EntityTypeThing:            "datastream.thing_id = %[1]s",
+		entities.EntityTypeSensor:           "datastream.sensor_id = %[1]s",
+		entities.EntityTypeObservedProperty: "datastream.observedproperty_id = %[1]s",
+		entities.EntityTypeObservation:      "datastream.id = (SELECT stream_id FROM observation WHERE id = %[1]s)",
+	},
+	entities.EntityTypeSensor: {
+		entities.EntityTypeDatastream: "sensor.id = (SELECT sensor_id FROM datastream WHERE id = %[1]s)",
+	},
+	entities.EntityTypeObservedProperty: {
+		entities.EntityTypeDatastream: "observedproperty.id = (SELECT observedproperty_id FROM datastream WHERE id = %[1]s)",
+	},
+	entities.EntityTypeObservation: {
+		entities.EntityTypeDatastream:        "observation.stream_id = %[1]s",
+		entities.EntityTypeFeatureOfInterest: "observation.featureofinterest_id = %[1]s",
+	},
+	entities.EntityTypeFeatureOfInterest: {
+		entities.EntityTypeObservation: "featureofinterest.id = (SELECT featureofinterest_id FROM observation WHERE id = %[1]s)",
+	},
+}
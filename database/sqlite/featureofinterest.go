@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+func featureOfInterestParamFactory(values map[string]interface{}) (entities.Entity, error) {
+	foi := &entities.FeatureOfInterest{}
+	for property, value := range values {
+		if value == nil {
+			continue
+		}
+
+		switch property {
+		case "id":
+			foi.ID = value
+		case "name":
+			foi.Name = value.(string)
+		case "description":
+			foi.Description = value.(string)
+		case "encodingType":
+			foi.EncodingType = value.(string)
+		case "feature":
+			foi.Feature, _ = value.(map[string]interface{})
+		}
+	}
+
+	return foi, nil
+}
+
+// GetFeatureOfInterestIDByLocationID returns the FeatureOfInterest id in the database
+// where original_location_id equals the given parameter
+func (gdb *GostDatabase) GetFeatureOfInterestIDByLocationID(id interface{}) (interface{}, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+	}
+
+	var fID interface{}
+	err := gdb.Db.QueryRow("SELECT id FROM featureofinterest WHERE original_location_id = ?1", intID).Scan(&fID)
+	if err != nil {
+		return nil, err
+	}
+
+	return fID, nil
+}
+
+// GetFeatureOfInterest returns a feature of interest by id
+func (gdb *GostDatabase) GetFeatureOfInterest(id interface{}, qo *odata.QueryOptions) (*entities.FeatureOfInterest, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("FeatureOfInterest does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, nil, intID, qo)
+	return processFeatureOfInterest(gdb, query, args, qi)
+}
+
+// GetFeatureOfInterestByObservation returns the FeatureOfInterest linked to an observation
+func (gdb *GostDatabase) GetFeatureOfInterestByObservation(id interface{}, qo *odata.QueryOptions) (*entities.FeatureOfInterest, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Observation does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, &entities.Observation{}, intID, qo)
+	return processFeatureOfInterest(gdb, query, args, qi)
+}
+
+// GetFeatureOfInterests returns all feature of interests
+func (gdb *GostDatabase) GetFeatureOfInterests(qo *odata.QueryOptions) ([]*entities.FeatureOfInterest, int, bool, error) {
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.FeatureOfInterest{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.FeatureOfInterest{}, nil, nil, qo)
+	return processFeatureOfInterests(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// PostFeatureOfInterest inserts a new FeatureOfInterest into the database
+func (gdb *GostDatabase) PostFeatureOfInterest(f *entities.FeatureOfInterest) (*entities.FeatureOfInterest, error) {
+	featureBytes, _ := json.Marshal(f.Feature)
+	encoding, _ := entities.CreateEncodingType(f.EncodingType)
+
+	var originalLocationID interface{}
+	if id, ok := ToIntID(f.OriginalLocationID); ok && f.OriginalLocationID != nil {
+		originalLocationID = id
+	}
+
+	query := "INSERT INTO featureofinterest (name, description, encodingtype, feature, original_location_id) VALUES (?1, ?2, ?3, ?4, ?5)"
+	r, err := gdb.statements(gdb.Db).Exec(query, f.Name, f.Description, encoding.Code, string(featureBytes[:]), originalLocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	fID, _ := r.LastInsertId()
+	f.ID = int(fID)
+	return f, nil
+}
+
+// PutFeatureOfInterest updates a FeatureOfInterest in the database
+func (gdb *GostDatabase) PutFeatureOfInterest(id interface{}, f *entities.FeatureOfInterest) (*entities.FeatureOfInterest, error) {
+	return gdb.PatchFeatureOfInterest(id, f)
+}
+
+func processFeatureOfInterest(gdb *GostDatabase, sql string, args []interface{}, qi *QueryParseInfo) (*entities.FeatureOfInterest, error) {
+	fois, _, _, err := processFeatureOfInterests(gdb, sql, args, nil, qi, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fois) == 0 {
+		return nil, gostErrors.NewRequestNotFound(errors.New("FeatureOfInterest not found"))
+	}
+
+	return fois[0], nil
+}
+
+func processFeatureOfInterests(gdb *GostDatabase, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.FeatureOfInterest, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(gdb, qi, sql, args, qo)
+	if err != nil {
+		return nil, 0, false, selectError(err)
+	}
+
+	fois := make([]*entities.FeatureOfInterest, 0)
+	for _, d := range data {
+		entity := d.(*entities.FeatureOfInterest)
+		fois = append(fois, entity)
+	}
+
+	var count int
+	if len(countSQL) > 0 {
+		count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return fois, count, hasNext, nil
+}
+
+// PatchFeatureOfInterest updates a FeatureOfInterest in the database
+func (gdb *GostDatabase) PatchFeatureOfInterest(id interface{}, foi *entities.FeatureOfInterest) (*entities.FeatureOfInterest, error) {
+	var err error
+	var ok bool
+	var intID int
+	updates := make(map[string]interface{})
+
+	if intID, ok = ToIntID(id); !ok || !gdb.FeatureOfInterestExists(intID) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("FeatureOfInterest does not exist"))
+	}
+
+	if len(foi.Name) > 0 {
+		updates["name"] = foi.Name
+	}
+
+	if len(foi.Description) > 0 {
+		updates["description"] = foi.Description
+	}
+
+	if len(foi.EncodingType) > 0 {
+		encoding, _ := entities.CreateEncodingType(foi.EncodingType)
+		updates["encodingtype"] = encoding.Code
+	}
+
+	if len(foi.Feature) > 0 {
+		featureBytes, _ := json.Marshal(foi.Feature)
+		updates["feature"] = string(featureBytes[:])
+	}
+
+	if err = gdb.updateEntityColumns("featureofinterest", updates, intID); err != nil {
+		return nil, err
+	}
+
+	nfoi, _ := gdb.GetFeatureOfInterest(intID, nil)
+	return nfoi, nil
+}
+
+// DeleteFeatureOfInterest tries to delete a FeatureOfInterest by the given id
+func (gdb *GostDatabase) DeleteFeatureOfInterest(id interface{}) error {
+	return DeleteEntity(gdb, id, "featureofinterest")
+}
+
+// FeatureOfInterestExists checks if a FeatureOfInterest is present in the database based on a given id.
+func (gdb *GostDatabase) FeatureOfInterestExists(id int) bool {
+	return EntityExists(gdb, id, "featureofinterest")
+}
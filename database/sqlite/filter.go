@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+)
+
+var filterToStringMap map[int]func(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string
+
+// init is used to work around the initialization loop error (circular reference)
+func init() {
+	filterToStringMap = map[int]func(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string{
+		godata.FilterTokenNav:       filterNavToString,
+		godata.FilterTokenLogical:   filterLogicalToString,
+		godata.FilterTokenFunc:      filterFuncToString,
+		godata.FilterTokenOp:        filterOpToString,
+		godata.FilterTokenGeography: filterGeographyToString,
+		godata.FilterTokenLiteral:   filterLiteralToString,
+		godata.FilterTokenLambda:    filterDefaultToString,
+		godata.FilterTokenNull:      filterDefaultToString,
+		godata.FilterTokenIt:        filterDefaultToString,
+		godata.FilterTokenRoot:      filterDefaultToString,
+		godata.FilterTokenFloat:     filterDefaultToString,
+		godata.FilterTokenInteger:   filterDefaultToString,
+		godata.FilterTokenString:    filterDefaultToString,
+		godata.FilterTokenDate:      filterDefaultToString,
+		godata.FilterTokenTime:      filterDefaultToString,
+		godata.FilterTokenDateTime:  filterDefaultToString,
+		godata.FilterTokenBoolean:   filterDefaultToString,
+	}
+}
+
+// navigationPath returns the segments of a navigation such as properties/owner/name
+func navigationPath(pn *godata.ParseNode) []string {
+	path := make([]string, 0)
+	for _, part := range pn.Children {
+		if part.Token != nil && part.Token.Type == godata.FilterTokenNav {
+			path = append(path, navigationPath(part)...)
+		} else if part.Token != nil {
+			path = append(path, part.Token.Value)
+		}
+	}
+
+	return path
+}
+
+// filterNavToString navigates into a JSON column using json_extract, the path is passed as bind parameter
+func filterNavToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	path := navigationPath(pn)
+	if len(path) == 0 {
+		return ""
+	}
+
+	field := path[0]
+	jsonPath := "$"
+	if c, ok := getColumn(et, path[0]); ok {
+		field = c.field
+		if c.kind == columnObservationData {
+			jsonPath = fmt.Sprintf("$.%s", c.property)
+		}
+	}
+
+	for _, p := range path[1:] {
+		jsonPath = fmt.Sprintf("%s.%s", jsonPath, p)
+	}
+
+	return fmt.Sprintf("json_extract(%s, %s)", field, qb.addArg(jsonPath))
+}
+
+func filterLogicalToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left := qb.createFilter(et, pn.Children[0])
+
+	if len(pn.Children) == 1 && strings.ToLower(pn.Token.Value) == "not" {
+		return fmt.Sprintf("%v (%v)", qb.odataLogicalOperatorToSQLite(pn.Token.Value), left)
+	}
+
+	right := qb.createFilter(et, pn.Children[1])
+	left, right = qb.prepareFilter(pn.Children[0].Token.Value, left, pn.Children[1].Token.Value, right)
+	operator := qb.odataLogicalOperatorToSQLite(pn.Token.Value)
+
+	// comparing with null only works using IS (NOT) NULL
+	if right == "NULL" || left == "NULL" {
+		if left == "NULL" {
+			left = right
+		}
+
+		if operator == "=" {
+			return fmt.Sprintf("%v IS NULL", left)
+		} else if operator == "!=" {
+			return fmt.Sprintf("%v IS NOT NULL", left)
+		}
+	}
+
+	if operator == "AND" || operator == "OR" {
+		return fmt.Sprintf("(%v) %v (%v)", left, operator, right)
+	}
+
+	return fmt.Sprintf("%v %v %v", left, operator, right)
+}
+
+func filterFuncToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	if convertFunction, ok := funcToStringMap[pn.Token.Value]; ok {
+		return convertFunction(qb, pn, et)
+	}
+
+	return ""
+}
+
+// filterDefaultToString passes string and time literals as bind parameter, the other literals
+// (numbers, booleans, null) only pass the OData lexer when they are valid
+func filterDefaultToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	switch pn.Token.Type {
+	case godata.FilterTokenString:
+		return qb.addArg(filterStringValue(pn.Token.Value))
+	case godata.FilterTokenDateTime:
+		if t, err := time.Parse(time.RFC3339Nano, pn.Token.Value); err == nil {
+			return qb.addArg(t.UTC().Format(TimeFormat))
+		}
+		return qb.addArg(pn.Token.Value)
+	case godata.FilterTokenDate, godata.FilterTokenTime:
+		return qb.addArg(pn.Token.Value)
+	case godata.FilterTokenBoolean:
+		if strings.ToLower(pn.Token.Value) == "true" {
+			return "1"
+		}
+		return "0"
+	case godata.FilterTokenNull:
+		return "NULL"
+	}
+
+	return fmt.Sprintf("%v", pn.Token.Value)
+}
+
+func filterOpToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	if pn.Token.Value == "add" {
+		return qb.createArithmetic(et, pn, "+", "REAL")
+	} else if pn.Token.Value == "sub" {
+		return qb.createArithmetic(et, pn, "-", "REAL")
+	} else if pn.Token.Value == "mul" {
+		return qb.createArithmetic(et, pn, "*", "REAL")
+	} else if pn.Token.Value == "div" {
+		return qb.createArithmetic(et, pn, "/", "REAL")
+	} else if pn.Token.Value == "mod" {
+		return qb.createArithmetic(et, pn, "%", "INTEGER")
+	}
+
+	return ""
+}
+
+func filterGeographyToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("GeomFromText(%v, 4326)", qb.addArg(filterStringValue(pn.Children[0].Token.Value)))
+}
+
+func filterLiteralToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	if c, ok := getColumn(et, pn.Token.Value); ok {
+		return c.filter
+	}
+
+	return pn.Token.Value
+}
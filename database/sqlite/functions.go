@@ -0,0 +1,235 @@
+package sqlite
+
+import (
+	"fmt"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+)
+
+var funcToStringMap map[string]func(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string
+
+// init is used to work around the initialization loop error (circular reference)
+func init() {
+	funcToStringMap = map[string]func(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string{
+		"contains":           containsToString,
+		"substringof":        substringofToString,
+		"endswith":           endswithToString,
+		"startswith":         startswithToString,
+		"length":             lengthToString,
+		"indexof":            indexofToString,
+		"substring":          substringToString,
+		"tolower":            tolowerToString,
+		"toupper":            toupperToString,
+		"trim":               trimToString,
+		"concat":             concatToString,
+		"round":              roundToString,
+		"floor":              floorToString,
+		"ceiling":            ceilingToString,
+		"year":               yearToString,
+		"month":              monthToString,
+		"day":                dayToString,
+		"hour":               hourToString,
+		"minute":             minuteToString,
+		"second":             secondToString,
+		"fractionalseconds":  fractionalsecondsToString,
+		"date":               dateToString,
+		"time":               timeToString,
+		"totaloffsetminutes": totaloffsetminutesToString,
+		"now":                nowToString,
+		"maxdatetime":        maxdatetimeToString,
+		"mindatetime":        mindatetimeToString,
+		"totalseconds":       totalsecondsToString,
+		"geo.length":         geolengthToString,
+		"geo.distance":       geodistanceToString,
+		"geo.intersects":     stintersectsToString,
+		"st_equals":          stequalsToString,
+		"st_touches":         sttouchesToString,
+		"st_overlaps":        stoverlapsToString,
+		"st_crosses":         stcrossesToString,
+		"st_contains":        stcontainsToString,
+		"st_disjoint":        stdisjointToString,
+		"st_relate":          strelateToString,
+		"st_within":          stwithinToString,
+		"st_intersects":      stintersectsToString,
+	}
+}
+
+func getLeftAndRightFilter(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) (string, string) {
+	left := qb.createFilter(et, pn.Children[0])
+	right := qb.createFilter(et, pn.Children[1])
+	return left, right
+}
+
+func containsToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left, right := getLeftAndRightFilter(qb, pn, et)
+	return fmt.Sprintf("%s LIKE %s", qb.createLike(left, LikeContains), qb.createLike(right, LikeContains))
+}
+
+func substringofToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left, right := getLeftAndRightFilter(qb, pn, et)
+	return fmt.Sprintf("%s LIKE %s", qb.createLike(right, LikeContains), qb.createLike(left, LikeContains))
+}
+
+func endswithToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left, right := getLeftAndRightFilter(qb, pn, et)
+	return fmt.Sprintf("%s LIKE %s", qb.createLike(left, LikeEndsWith), qb.createLike(right, LikeEndsWith))
+}
+
+func startswithToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left, right := getLeftAndRightFilter(qb, pn, et)
+	return fmt.Sprintf("%s LIKE %s", qb.createLike(left, LikeStartsWith), qb.createLike(right, LikeStartsWith))
+}
+
+func lengthToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("LENGTH(%s)", qb.createFilter(et, pn.Children[0]))
+}
+
+func indexofToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left, right := getLeftAndRightFilter(qb, pn, et)
+	return fmt.Sprintf("INSTR(%s, %s) - 1", left, right)
+}
+
+func substringToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left, right := getLeftAndRightFilter(qb, pn, et)
+	if len(pn.Children) > 2 {
+		return fmt.Sprintf("SUBSTR(%s, %s + 1, %s)", left, right, qb.createFilter(et, pn.Children[2]))
+	}
+
+	return fmt.Sprintf("SUBSTR(%s, %s + 1)", left, right)
+}
+
+func tolowerToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("LOWER(%s)", qb.createFilter(et, pn.Children[0]))
+}
+
+func toupperToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("UPPER(%s)", qb.createFilter(et, pn.Children[0]))
+}
+
+func trimToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("TRIM(%s, ' ')", qb.createFilter(et, pn.Children[0]))
+}
+
+func concatToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left, right := getLeftAndRightFilter(qb, pn, et)
+	return fmt.Sprintf("(%s || %s)", left, right)
+}
+
+func roundToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("ROUND(CAST(%s AS REAL))", qb.createFilter(et, pn.Children[0]))
+}
+
+// floorToString rounds down, SQLite has no FLOOR function so the value is truncated and corrected for negative values
+func floorToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left := fmt.Sprintf("CAST(%s AS REAL)", qb.createFilter(et, pn.Children[0]))
+	return fmt.Sprintf("(CAST(%[1]s AS INTEGER) - (%[1]s < CAST(%[1]s AS INTEGER)))", left)
+}
+
+// ceilingToString rounds up, SQLite has no CEILING function so the value is truncated and corrected for positive values
+func ceilingToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left := fmt.Sprintf("CAST(%s AS REAL)", qb.createFilter(et, pn.Children[0]))
+	return fmt.Sprintf("(CAST(%[1]s AS INTEGER) + (%[1]s > CAST(%[1]s AS INTEGER)))", left)
+}
+
+func yearToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createExtractDateQuery(pn, et, "%Y")
+}
+
+func monthToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createExtractDateQuery(pn, et, "%m")
+}
+
+func dayToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createExtractDateQuery(pn, et, "%d")
+}
+
+func hourToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createExtractDateQuery(pn, et, "%H")
+}
+
+func minuteToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createExtractDateQuery(pn, et, "%M")
+}
+
+func secondToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createExtractDateQuery(pn, et, "%S")
+}
+
+func fractionalsecondsToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	left := qb.createFilter(et, pn.Children[0])
+	return fmt.Sprintf("(CAST(strftime('%%f', %[1]s) AS REAL) - CAST(strftime('%%S', %[1]s) AS INTEGER))", left)
+}
+
+func dateToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("date(%s)", qb.createFilter(et, pn.Children[0]))
+}
+
+func timeToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("time(%s)", qb.createFilter(et, pn.Children[0]))
+}
+
+// totaloffsetminutesToString returns 0, times are stored in UTC
+func totaloffsetminutesToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return "0"
+}
+
+func nowToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return "strftime('%Y-%m-%dT%H:%M:%fZ', 'now')"
+}
+
+func maxdatetimeToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return "'9999-12-31T23:59:59.999Z'"
+}
+
+func mindatetimeToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return "'0001-01-01T00:00:00.000Z'"
+}
+
+func totalsecondsToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return fmt.Sprintf("CAST(strftime('%%s', %s) AS INTEGER)", qb.createFilter(et, pn.Children[0]))
+}
+
+func geodistanceToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Distance(%s, %s)", 2)
+}
+
+func geolengthToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Length(%s)", 1)
+}
+
+func stequalsToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Equals(%s, %s)", 2)
+}
+
+func sttouchesToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Touches(%s, %s)", 2)
+}
+
+func stoverlapsToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Overlaps(%s, %s)", 2)
+}
+
+func stcrossesToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Crosses(%s, %s)", 2)
+}
+
+func stcontainsToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Contains(%s, %s)", 2)
+}
+
+func stdisjointToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Disjoint(%s, %s)", 2)
+}
+
+func strelateToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Relate(%s, %s, %s)", 3)
+}
+
+func stwithinToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Within(%s, %s)", 2)
+}
+
+func stintersectsToString(qb *QueryBuilder, pn *godata.ParseNode, et entities.EntityType) string {
+	return qb.createSpatialQuery(pn, et, "ST_Intersects(%s, %s)", 2)
+}
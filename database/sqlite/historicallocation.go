@@ -0,0 +1,202 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+func historicalLocationParamFactory(values map[string]interface{}) (entities.Entity, error) {
+	h := &entities.HistoricalLocation{}
+	for property, value := range values {
+		if value == nil {
+			continue
+		}
+
+		switch property {
+		case "id":
+			h.ID = value
+		case "time":
+			h.Time = value.(string)
+		}
+	}
+
+	return h, nil
+}
+
+// GetHistoricalLocation retrieves a HistoricalLocation by id
+func (gdb *GostDatabase) GetHistoricalLocation(id interface{}, qo *odata.QueryOptions) (*entities.HistoricalLocation, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("HistoricalLocation does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, nil, intID, qo)
+	return processHistoricalLocation(gdb, query, args, qi)
+}
+
+// GetHistoricalLocations retrieves all historicallocations
+func (gdb *GostDatabase) GetHistoricalLocations(qo *odata.QueryOptions) ([]*entities.HistoricalLocation, int, bool, error) {
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, nil, nil, qo)
+	return processHistoricalLocations(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetHistoricalLocationsByLocation retrieves all historicallocations linked to the given location
+func (gdb *GostDatabase) GetHistoricalLocationsByLocation(locationID interface{}, qo *odata.QueryOptions) ([]*entities.HistoricalLocation, int, bool, error) {
+	intID, ok := ToIntID(locationID)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, &entities.Location{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, &entities.Location{}, intID, qo)
+	return processHistoricalLocations(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetHistoricalLocationsByThing retrieves all historicallocations linked to the given thing
+func (gdb *GostDatabase) GetHistoricalLocationsByThing(thingID interface{}, qo *odata.QueryOptions) ([]*entities.HistoricalLocation, int, bool, error) {
+	intID, ok := ToIntID(thingID)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.HistoricalLocation{}, &entities.Thing{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.HistoricalLocation{}, &entities.Thing{}, intID, qo)
+	return processHistoricalLocations(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+func processHistoricalLocation(gdb *GostDatabase, sql string, args []interface{}, qi *QueryParseInfo) (*entities.HistoricalLocation, error) {
+	hls, _, _, err := processHistoricalLocations(gdb, sql, args, nil, qi, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hls) == 0 {
+		return nil, gostErrors.NewRequestNotFound(errors.New("HistoricalLocation not found"))
+	}
+
+	return hls[0], nil
+}
+
+func processHistoricalLocations(gdb *GostDatabase, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.HistoricalLocation, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(gdb, qi, sql, args, qo)
+	if err != nil {
+		return nil, 0, hasNext, selectError(err)
+	}
+
+	hls := make([]*entities.HistoricalLocation, 0)
+	for _, d := range data {
+		entity := d.(*entities.HistoricalLocation)
+		hls = append(hls, entity)
+	}
+
+	var count int
+	if len(countSQL) > 0 {
+		count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+		if err != nil {
+			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return hls, count, hasNext, nil
+}
+
+// PostHistoricalLocation adds a historical location to the database
+// returns the created historical location including the generated id
+// fails when a thing or location cannot be found for the given id's
+func (gdb *GostDatabase) PostHistoricalLocation(hl *entities.HistoricalLocation) (*entities.HistoricalLocation, error) {
+	tid, ok := ToIntID(hl.Thing.ID)
+	if !ok || !gdb.ThingExists(tid) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	for _, l := range hl.Locations {
+		lid, ok := ToIntID(l.ID)
+		if !ok || !gdb.LocationExists(lid) {
+			return nil, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+		}
+	}
+
+	hlTime := time.Now().UTC().Format(TimeFormat)
+	if t, err := time.Parse(time.RFC3339Nano, hl.Time); err == nil {
+		hlTime = t.UTC().Format(TimeFormat)
+	}
+
+	r, err := gdb.statements(gdb.Db).Exec("INSERT INTO historicallocation (time, thing_id) VALUES (?1, ?2)", hlTime, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	hlID, _ := r.LastInsertId()
+	for _, l := range hl.Locations {
+		lid, _ := ToIntID(l.ID)
+		if _, err = gdb.statements(gdb.Db).Exec("INSERT INTO location_to_historicallocation (location_id, historicallocation_id) VALUES (?1, ?2)", lid, hlID); err != nil {
+			return nil, err
+		}
+	}
+
+	hl.ID = int(hlID)
+	hl.Time = hlTime
+	hl.Locations = nil
+	return hl, nil
+}
+
+// HistoricalLocationExists checks if a HistoricalLocation is present in the database based on a given id
+func (gdb *GostDatabase) HistoricalLocationExists(id interface{}) bool {
+	return EntityExists(gdb, id, "historicallocation")
+}
+
+// PutHistoricalLocation updates a HistoricalLocation in the database
+func (gdb *GostDatabase) PutHistoricalLocation(id interface{}, hl *entities.HistoricalLocation) (*entities.HistoricalLocation, error) {
+	return gdb.PatchHistoricalLocation(id, hl)
+}
+
+// PatchHistoricalLocation updates a HistoricalLocation in the database
+func (gdb *GostDatabase) PatchHistoricalLocation(id interface{}, hl *entities.HistoricalLocation) (*entities.HistoricalLocation, error) {
+	var err error
+	var ok bool
+	var intID int
+	updates := make(map[string]interface{})
+
+	if intID, ok = ToIntID(id); !ok || !gdb.HistoricalLocationExists(intID) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("HistoricalLocation does not exist"))
+	}
+
+	for _, l := range hl.Locations {
+		lid, ok := ToIntID(l.ID)
+		if !ok || !gdb.LocationExists(lid) {
+			return nil, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+		}
+	}
+
+	if len(hl.Time) > 0 {
+		updates["time"] = hl.Time
+		if t, err := time.Parse(time.RFC3339Nano, hl.Time); err == nil {
+			updates["time"] = t.UTC().Format(TimeFormat)
+		}
+	}
+
+	if err = gdb.updateEntityColumns("historicallocation", updates, intID); err != nil {
+		return nil, err
+	}
+
+	for _, l := range hl.Locations {
+		lid, _ := ToIntID(l.ID)
+		if _, err = gdb.statements(gdb.Db).Exec("INSERT OR IGNORE INTO location_to_historicallocation (location_id, historicallocation_id) VALUES (?1, ?2)", lid, intID); err != nil {
+			return nil, err
+		}
+	}
+
+	nhl, _ := gdb.GetHistoricalLocation(intID, nil)
+	return nhl, nil
+}
+
+// DeleteHistoricalLocation tries to delete a HistoricalLocation by the given id
+func (gdb *GostDatabase) DeleteHistoricalLocation(id interface{}) error {
+	return DeleteEntity(gdb, id, "historicallocation")
+}
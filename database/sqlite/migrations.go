@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"github.com/gost/server/database/migration"
+)
+
+// migrations are the ordered upgrades of the GOST database file, version 1 is the schema that was applied
+// when opening the file before migrations were introduced, it only creates missing tables
+var migrations = []migration.Migration{
+	{Version: 1, Description: "initial schema", SQL: createSchemaSQL},
+}
+
+// migrationDialect keeps track of the applied migrations in the gost_migrations table, SQLite has a single
+// writer so no lock is needed
+var migrationDialect = migration.Dialect{
+	CreateTable:   "CREATE TABLE IF NOT EXISTS gost_migrations (version INTEGER NOT NULL PRIMARY KEY, description TEXT, applied TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')))",
+	SelectVersion: "SELECT COALESCE(MAX(version), 0) FROM gost_migrations",
+	Insert:        "INSERT INTO gost_migrations (version, description) VALUES (?1, ?2)",
+}
+
+// SchemaVersion returns the version of the schema in the database and the latest version known by GOST
+func (gdb *GostDatabase) SchemaVersion() (int, int, error) {
+	return migration.Version(gdb.Db, migrationDialect, migrations)
+}
+
+// Migrate applies the pending migrations to the database and returns the number of applied migrations
+func (gdb *GostDatabase) Migrate() (int, error) {
+	applied, err := migration.Apply(gdb.Db, migrationDialect, migrations)
+	for _, m := range applied {
+		logger.Infof("Applied migration %d: %s", m.Version, m.Description)
+	}
+
+	return len(applied), err
+}
@@ -0,0 +1,530 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	log "github.com/sirupsen/logrus"
+)
+
+func observationParamFactory(values map[string]interface{}) (entities.Entity, error) {
+	o := &entities.Observation{}
+	for property, value := range values {
+		if property == "resultTime" {
+			rt, _ := value.(string)
+			o.ResultTime = &rt
+		}
+
+		if value == nil {
+			continue
+		}
+
+		switch property {
+		case "id":
+			o.ID = value
+		case "phenomenonTime":
+			o.PhenomenonTime, _ = value.(string)
+		case "result":
+			if result, err := json.Marshal(value); err == nil {
+				o.Result = result
+			}
+		case "validTime":
+			o.ValidTime, _ = value.(string)
+		case "resultQuality":
+			o.ResultQuality, _ = value.(string)
+		case "parameters":
+			o.Parameters, _ = value.(map[string]interface{})
+		}
+	}
+
+	return o, nil
+}
+
+// GetObservation retrieves an observation by id from the database
+func (gdb *GostDatabase) GetObservation(id interface{}, qo *odata.QueryOptions) (*entities.Observation, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Observation does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, nil, intID, qo)
+	return processObservation(gdb, query, args, qi)
+}
+
+// GetObservations retrieves all observations
+func (gdb *GostDatabase) GetObservations(qo *odata.QueryOptions) ([]*entities.Observation, int, bool, error) {
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, nil, nil, qo)
+	return processObservations(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetObservationsByFeatureOfInterest retrieves all observations by the given FeatureOfInterest id
+func (gdb *GostDatabase) GetObservationsByFeatureOfInterest(foiID interface{}, qo *odata.QueryOptions) ([]*entities.Observation, int, bool, error) {
+	intID, ok := ToIntID(foiID)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("FeatureOfInterest does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, &entities.FeatureOfInterest{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, &entities.FeatureOfInterest{}, intID, qo)
+	return processObservations(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetObservationsByDatastream retrieves all observations by the given datastream id
+func (gdb *GostDatabase) GetObservationsByDatastream(dataStreamID interface{}, qo *odata.QueryOptions) ([]*entities.Observation, int, bool, error) {
+	intID, ok := ToIntID(dataStreamID)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Observation{}, &entities.Datastream{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Observation{}, &entities.Datastream{}, intID, qo)
+	return processObservations(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetObservationAggregates retrieves the observations of the given datastream, or of all datastreams when the id is nil,
+// aggregated by the $apply of the QueryOptions
+func (gdb *GostDatabase) GetObservationAggregates(dataStreamID interface{}, qo *odata.QueryOptions) ([]*models.ObservationAggregate, int, bool, error) {
+	var id interface{}
+	if dataStreamID != nil {
+		intID, ok := ToIntID(dataStreamID)
+		if !ok {
+			return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+		}
+		id = intID
+	}
+
+	query, args := gdb.QueryBuilder.CreateAggregateQuery(id, qo)
+	rows, err := gdb.Db.Query(query, args...)
+	if err != nil {
+		return nil, 0, false, selectError(err)
+	}
+	defer rows.Close()
+
+	aggregates := make([]*models.ObservationAggregate, 0)
+	for rows.Next() {
+		var start int64
+		values := make([]sql.NullFloat64, len(qo.Aggregation.Aggregates))
+		dest := []interface{}{&start}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, 0, false, fmt.Errorf("Error reading aggregates %v", err)
+		}
+
+		aggregates = append(aggregates, newObservationAggregate(start, qo.Aggregation, values))
+	}
+
+	hasNext := false
+	if qo.Top != nil && int(*qo.Top) >= 0 && len(aggregates) > int(*qo.Top) {
+		hasNext = true
+		aggregates = aggregates[:int(*qo.Top)]
+	}
+
+	countSQL, countArgs := gdb.QueryBuilder.CreateAggregateCountQuery(id, qo)
+	count, err := ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+	if err != nil {
+		return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+	}
+
+	return aggregates, count, hasNext, nil
+}
+
+// newObservationAggregate creates the ObservationAggregate of the interval starting at the given seconds since
+// the Unix epoch, aggregates without numeric results are null and counts are returned as integer
+func newObservationAggregate(start int64, aggregation *odata.Aggregation, values []sql.NullFloat64) *models.ObservationAggregate {
+	a := &models.ObservationAggregate{
+		Start:  time.Unix(start, 0).UTC(),
+		Values: make(map[string]interface{}),
+	}
+	a.End = a.Start.Add(aggregation.Interval)
+
+	for i, agg := range aggregation.Aggregates {
+		switch {
+		case agg.Method == odata.AggregateMethodCount:
+			a.Values[agg.Alias] = int64(values[i].Float64)
+		case values[i].Valid:
+			a.Values[agg.Alias] = values[i].Float64
+		default:
+			a.Values[agg.Alias] = nil
+		}
+	}
+
+	return a
+}
+
+func processObservation(gdb *GostDatabase, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Observation, error) {
+	observations, _, _, err := processObservations(gdb, sql, args, nil, qi, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(observations) == 0 {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Observation not found"))
+	}
+
+	return observations[0], nil
+}
+
+func processObservations(gdb *GostDatabase, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Observation, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(gdb, qi, sql, args, qo)
+	if err != nil {
+		return nil, 0, false, selectError(err)
+	}
+
+	o := make([]*entities.Observation, 0)
+	for _, d := range data {
+		entity := d.(*entities.Observation)
+		o = append(o, entity)
+	}
+
+	var count int
+	if len(countSQL) > 0 {
+		count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+		if err != nil {
+			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return o, count, hasNext, nil
+}
+
+// PutObservation replaces an observation to the database
+func (gdb *GostDatabase) PutObservation(id interface{}, o *entities.Observation) (*entities.Observation, error) {
+	return gdb.PatchObservation(id, o)
+}
+
+// PostObservation adds an observation to the database
+func (gdb *GostDatabase) PostObservation(o *entities.Observation) (*entities.Observation, error) {
+	id, err := gdb.insertObservation(gdb.Db, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdObservation(o, id), nil
+}
+
+// PostObservations adds a batch of observations to the database using a single transaction, the returned
+// slice holds the created observations in the same order, the returned map holds the errors by index.
+// When atomic is true the transaction is rolled back as soon as an observation fails, otherwise every
+// observation is inserted inside its own savepoint so only the failing observations are rolled back. The
+// given observations are only altered when the transaction is committed
+func (gdb *GostDatabase) PostObservations(observations []*entities.Observation, atomic bool) ([]*entities.Observation, map[int]error) {
+	created := make([]*entities.Observation, len(observations))
+	errs := make(map[int]error)
+	ids := make([]int, len(observations))
+
+	tx, err := gdb.Db.Begin()
+	if err != nil {
+		for i := range observations {
+			errs[i] = err
+		}
+		return created, errs
+	}
+
+	for i, o := range observations {
+		if !atomic {
+			if _, err = gdb.statements(tx).Exec("SAVEPOINT observation"); err != nil {
+				return rollbackObservations(tx, len(observations), errs, err)
+			}
+		}
+
+		id, err := gdb.insertObservation(tx, o)
+		if err != nil {
+			errs[i] = err
+			if atomic {
+				break
+			}
+
+			if _, err = gdb.statements(tx).Exec("ROLLBACK TO SAVEPOINT observation"); err != nil {
+				return rollbackObservations(tx, len(observations), errs, err)
+			}
+			continue
+		}
+
+		ids[i] = id
+		if !atomic {
+			if _, err = gdb.statements(tx).Exec("RELEASE SAVEPOINT observation"); err != nil {
+				return rollbackObservations(tx, len(observations), errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 && atomic {
+		tx.Rollback()
+		return make([]*entities.Observation, len(observations)), errs
+	}
+
+	if err = tx.Commit(); err != nil {
+		for i := range observations {
+			errs[i] = err
+		}
+		return created, errs
+	}
+
+	for i, o := range observations {
+		if _, failed := errs[i]; !failed {
+			created[i] = createdObservation(o, ids[i])
+		}
+	}
+
+	return created, errs
+}
+
+// rollbackObservations rolls back the transaction of PostObservations when a savepoint cannot be set, rolled
+// back or released. None of the observations are created, every observation without an error gets the given error
+func rollbackObservations(tx *sql.Tx, count int, errs map[int]error, err error) ([]*entities.Observation, map[int]error) {
+	tx.Rollback()
+	for i := 0; i < count; i++ {
+		if _, ok := errs[i]; !ok {
+			errs[i] = err
+		}
+	}
+
+	return make([]*entities.Observation, count), errs
+}
+
+// PostObservationsBulk adds a batch of observations, possibly for multiple datastreams, to the database using
+// a single transaction and prepared insert. The batch is inserted all or nothing and the given observations are
+// only altered when the batch is committed, a failing batch can be retried using PostObservations to find the
+// failing observations
+func (gdb *GostDatabase) PostObservationsBulk(observations []*entities.Observation) ([]*entities.Observation, error) {
+	if len(observations) == 0 {
+		return observations, nil
+	}
+
+	if l := gdb.statementLogger(); l.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(l, time.Now(), "insert %v observations", len(observations))
+	}
+
+	tx, err := gdb.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO observation (data, stream_id, featureofinterest_id) VALUES (?1, ?2, ?3)")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	ids := make([]int, len(observations))
+	for i, o := range observations {
+		dID, fID, err := gdb.observationForeignKeys(tx, o)
+		if err == nil {
+			var r sql.Result
+			json, _ := o.MarshalPostgresJSON()
+			if r, err = stmt.Exec(string(json[:]), dID, fID); err == nil {
+				id, _ := r.LastInsertId()
+				ids[i] = int(id)
+			}
+		}
+
+		if err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	stmt.Close()
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i, o := range observations {
+		createdObservation(o, ids[i])
+	}
+
+	return observations, nil
+}
+
+// execQueryer is implemented by both sql.DB and sql.Tx so inserts can run with or without a transaction
+type execQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// observationForeignKeys returns the datastream and FeatureOfInterest id of an observation to insert, SQLite
+// does not report which foreign key failed so the existence of both is checked upfront
+func (gdb *GostDatabase) observationForeignKeys(db execQueryer, o *entities.Observation) (int, int, error) {
+	if o.Datastream == nil {
+		return 0, 0, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
+	}
+
+	var exists bool
+	dID, ok := ToIntID(o.Datastream.ID)
+	if ok {
+		db.QueryRow("SELECT EXISTS (SELECT 1 FROM datastream WHERE id = ?1)", dID).Scan(&exists)
+	}
+
+	if !exists {
+		return 0, 0, gostErrors.NewBadRequestError(errors.New("Datastream does not exist"))
+	}
+
+	if o.FeatureOfInterest == nil || len(fmt.Sprintf("%v", o.FeatureOfInterest.ID)) == 0 {
+		return 0, 0, gostErrors.NewBadRequestError(errors.New("No FeatureOfInterest supplied or Location found on linked thing"))
+	}
+
+	exists = false
+	fID, ok := ToIntID(o.FeatureOfInterest.ID)
+	if ok {
+		db.QueryRow("SELECT EXISTS (SELECT 1 FROM featureofinterest WHERE id = ?1)", fID).Scan(&exists)
+	}
+
+	if !exists {
+		return 0, 0, gostErrors.NewBadRequestError(errors.New("FeatureOfInterest does not exist"))
+	}
+
+	return dID, fID, nil
+}
+
+// insertObservation inserts an observation and returns its id, the observation is not altered so it stays
+// unchanged when the transaction of db is rolled back
+func (gdb *GostDatabase) insertObservation(db execQueryer, o *entities.Observation) (int, error) {
+	dID, fID, err := gdb.observationForeignKeys(db, o)
+	if err != nil {
+		return 0, err
+	}
+
+	json, _ := o.MarshalPostgresJSON()
+	r, err := gdb.statements(db).Exec("INSERT INTO observation (data, stream_id, featureofinterest_id) VALUES (?1, ?2, ?3)", string(json[:]), dID, fID)
+	if err != nil {
+		return 0, err
+	}
+
+	oID, _ := r.LastInsertId()
+	return int(oID), nil
+}
+
+// createdObservation sets the id of an inserted observation
+func createdObservation(o *entities.Observation, id int) *entities.Observation {
+	o.ID = id
+
+	// clear inner entities to serves links upon response
+	o.Datastream = nil
+	o.FeatureOfInterest = nil
+
+	return o
+}
+
+// ObservationExists checks if an Observation is present in the database based on a given id.
+func (gdb *GostDatabase) ObservationExists(id interface{}) bool {
+	return EntityExists(gdb, id, "observation")
+}
+
+// PatchObservation updates a Observation in the database
+func (gdb *GostDatabase) PatchObservation(id interface{}, o *entities.Observation) (*entities.Observation, error) {
+	var err error
+	var ok bool
+	var intID int
+	updates := make(map[string]interface{})
+
+	if intID, ok = ToIntID(id); !ok || !gdb.ObservationExists(intID) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Observation does not exist"))
+	}
+
+	observation, err := gdb.GetObservation(intID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(o.PhenomenonTime) > 0 {
+		observation.PhenomenonTime = o.PhenomenonTime
+	}
+
+	if o.Result != nil {
+		observation.Result = o.Result
+	}
+
+	if o.ResultTime != nil {
+		observation.ResultTime = o.ResultTime
+	}
+
+	if len(o.ResultQuality) > 0 {
+		observation.ResultQuality = o.ResultQuality
+	}
+
+	if len(o.ValidTime) > 0 {
+		observation.ValidTime = o.ValidTime
+	}
+
+	if len(o.Parameters) > 0 {
+		observation.Parameters = o.Parameters
+	}
+
+	json, _ := observation.MarshalPostgresJSON()
+	updates["data"] = string(json[:])
+
+	if err = gdb.updateEntityColumns("observation", updates, intID); err != nil {
+		return nil, err
+	}
+
+	return observation, nil
+}
+
+// DeleteObservation tries to delete a Observation by the given id
+func (gdb *GostDatabase) DeleteObservation(id interface{}) error {
+	return DeleteEntity(gdb, id, "observation")
+}
+
+// PruneObservations deletes the observations of the datastream with a phenomenonTime before the given time, when
+// rollupInterval is set the observations are first added to the rollups of that interval in the same transaction
+func (gdb *GostDatabase) PruneObservations(datastreamID interface{}, before time.Time, rollupInterval time.Duration) (int64, error) {
+	id, ok := ToIntID(datastreamID)
+	if !ok {
+		return 0, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	tx, err := gdb.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	if rollupInterval > 0 {
+		query, args := gdb.QueryBuilder.CreateRollupObservationsQuery(id, before, rollupInterval)
+		if _, err = gdb.statements(tx).Exec(query, args...); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("Error creating rollups %v", err)
+		}
+	}
+
+	query, args := gdb.QueryBuilder.CreatePruneObservationsQuery(id, before)
+	res, err := gdb.statements(tx).Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Error pruning observations %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// PruneObservationRollups deletes the rollups of the datastream of intervals starting before the given time
+func (gdb *GostDatabase) PruneObservationRollups(datastreamID interface{}, before time.Time) (int64, error) {
+	id, ok := ToIntID(datastreamID)
+	if !ok {
+		return 0, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	query, args := gdb.QueryBuilder.CreatePruneRollupsQuery(id, before)
+	res, err := gdb.statements(gdb.Db).Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("Error pruning rollups %v", err)
+	}
+
+	return res.RowsAffected()
+}
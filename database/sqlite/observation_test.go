@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObservationParamFactory(t *testing.T) {
+	// arrange
+	values := map[string]interface{}{
+		"id":             int64(4),
+		"phenomenonTime": "2015-03-06T00:00:00.000Z",
+		"result":         map[string]interface{}{"value": 20.5},
+	}
+
+	// act
+	entity, err := observationParamFactory(values)
+	observation := entity.(*entities.Observation)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), observation.ID)
+	assert.JSONEq(t, `{"value":20.5}`, string(observation.Result))
+}
@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+func observedPropertyParamFactory(values map[string]interface{}) (entities.Entity, error) {
+	op := &entities.ObservedProperty{}
+	for property, value := range values {
+		if value == nil {
+			continue
+		}
+
+		switch property {
+		case "id":
+			op.ID = value
+		case "name":
+			op.Name = value.(string)
+		case "description":
+			op.Description = value.(string)
+		case "definition":
+			op.Definition = value.(string)
+		}
+	}
+
+	return op, nil
+}
+
+// GetObservedProperty returns an ObservedProperty by id
+func (gdb *GostDatabase) GetObservedProperty(id interface{}, qo *odata.QueryOptions) (*entities.ObservedProperty, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("ObservedProperty does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, nil, intID, qo)
+	return processObservedProperty(gdb, query, args, qi)
+}
+
+// GetObservedPropertyByDatastream returns the ObservedProperty of a datastream
+func (gdb *GostDatabase) GetObservedPropertyByDatastream(id interface{}, qo *odata.QueryOptions) (*entities.ObservedProperty, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, &entities.Datastream{}, intID, qo)
+	return processObservedProperty(gdb, query, args, qi)
+}
+
+// GetObservedProperties returns all ObservedProperties
+func (gdb *GostDatabase) GetObservedProperties(qo *odata.QueryOptions) ([]*entities.ObservedProperty, int, bool, error) {
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.ObservedProperty{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.ObservedProperty{}, nil, nil, qo)
+	return processObservedProperties(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+func processObservedProperty(gdb *GostDatabase, sql string, args []interface{}, qi *QueryParseInfo) (*entities.ObservedProperty, error) {
+	obs, _, _, err := processObservedProperties(gdb, sql, args, nil, qi, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(obs) == 0 {
+		return nil, gostErrors.NewRequestNotFound(errors.New("ObservedProperty not found"))
+	}
+
+	return obs[0], nil
+}
+
+func processObservedProperties(gdb *GostDatabase, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.ObservedProperty, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(gdb, qi, sql, args, qo)
+	if err != nil {
+		return nil, 0, hasNext, selectError(err)
+	}
+
+	obs := make([]*entities.ObservedProperty, 0)
+	for _, d := range data {
+		entity := d.(*entities.ObservedProperty)
+		obs = append(obs, entity)
+	}
+
+	var count int
+	if len(countSQL) > 0 {
+		count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+		if err != nil {
+			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return obs, count, hasNext, nil
+}
+
+// PostObservedProperty adds an ObservedProperty to the database
+func (gdb *GostDatabase) PostObservedProperty(op *entities.ObservedProperty) (*entities.ObservedProperty, error) {
+	query := "INSERT INTO observedproperty (name, definition, description) VALUES (?1, ?2, ?3)"
+	r, err := gdb.statements(gdb.Db).Exec(query, op.Name, op.Definition, op.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	opID, _ := r.LastInsertId()
+	op.ID = int(opID)
+	return op, nil
+}
+
+// PutObservedProperty updates a ObservedProperty in the database
+func (gdb *GostDatabase) PutObservedProperty(id interface{}, op *entities.ObservedProperty) (*entities.ObservedProperty, error) {
+	return gdb.PatchObservedProperty(id, op)
+}
+
+// ObservedPropertyExists checks if a ObservedProperty is present in the database based on a given id.
+func (gdb *GostDatabase) ObservedPropertyExists(id interface{}) bool {
+	return EntityExists(gdb, id, "observedproperty")
+}
+
+// PatchObservedProperty updates a ObservedProperty in the database
+func (gdb *GostDatabase) PatchObservedProperty(id interface{}, op *entities.ObservedProperty) (*entities.ObservedProperty, error) {
+	var err error
+	var ok bool
+	var intID int
+	updates := make(map[string]interface{})
+
+	if intID, ok = ToIntID(id); !ok || !gdb.ObservedPropertyExists(intID) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("ObservedProperty does not exist"))
+	}
+
+	if len(op.Description) > 0 {
+		updates["description"] = op.Description
+	}
+
+	if len(op.Definition) > 0 {
+		updates["definition"] = op.Definition
+	}
+
+	if len(op.Name) > 0 {
+		updates["name"] = op.Name
+	}
+
+	if err = gdb.updateEntityColumns("observedproperty", updates, intID); err != nil {
+		return nil, err
+	}
+
+	ns, _ := gdb.GetObservedProperty(intID, nil)
+	return ns, nil
+}
+
+// DeleteObservedProperty tries to delete a ObservedProperty by the given id
+func (gdb *GostDatabase) DeleteObservedProperty(id interface{}) error {
+	return DeleteEntity(gdb, id, "observedproperty")
+}
@@ -0,0 +1,608 @@
+package sqlite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	"github.com/gost/server/metrics"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// QueryBuilder can construct SQLite queries based on entities and QueryOptions
+type QueryBuilder struct {
+	maxTop int
+	args   *queryArgs
+}
+
+// queryArgs holds the bind parameters of a query, values are never written into the
+// SQL text, only their placeholder (?1, ?2, ...) is
+type queryArgs struct {
+	values []interface{}
+}
+
+// QueryParseInfo describes the entities selected by a query, it is used to create the entities
+// from the returned rows and to expand their related entities
+type QueryParseInfo struct {
+	Entity     entities.Entity
+	Properties []string
+	IncludeID  bool
+	Expand     []*godata.ExpandItem
+}
+
+// CreateQueryBuilder instantiates a new queryBuilder, the queryBuilder is used to create
+// select queries based on the given entities, id en QueryOptions (ODATA)
+// maxTop is the maximum top the query should return
+func CreateQueryBuilder(maxTop int) *QueryBuilder {
+	return &QueryBuilder{
+		maxTop: maxTop,
+		args:   &queryArgs{},
+	}
+}
+
+// newQuery returns a copy of the QueryBuilder with its own bind parameters, the QueryBuilder
+// is shared by all requests so every query needs to collect its parameters separately
+func (qb *QueryBuilder) newQuery() *QueryBuilder {
+	query := *qb
+	query.args = &queryArgs{}
+	return &query
+}
+
+// addArg adds a bind parameter to the query and returns the placeholder to use in the SQL
+func (qb *QueryBuilder) addArg(value interface{}) string {
+	qb.args.values = append(qb.args.values, value)
+	return fmt.Sprintf("?%v", len(qb.args.values))
+}
+
+// getArg returns the value of the bind parameter behind the given placeholder, returns false
+// if the input is not a placeholder of the query
+func (qb *QueryBuilder) getArg(placeholder string) (interface{}, bool) {
+	if !strings.HasPrefix(placeholder, "?") {
+		return nil, false
+	}
+
+	i, err := strconv.Atoi(placeholder[1:])
+	if err != nil || i < 1 || i > len(qb.args.values) {
+		return nil, false
+	}
+
+	return qb.args.values[i-1], true
+}
+
+// setArg changes the value of the bind parameter behind the given placeholder, returns false
+// if the input is not a placeholder of the query
+func (qb *QueryBuilder) setArg(placeholder string, value interface{}) bool {
+	if _, ok := qb.getArg(placeholder); !ok {
+		return false
+	}
+
+	i, _ := strconv.Atoi(placeholder[1:])
+	qb.args.values[i-1] = value
+	return true
+}
+
+// setFilterValue sets the value of a filter operand, if the operand is a bind parameter the
+// parameter is changed else the operand is replaced by the given literal
+func (qb *QueryBuilder) setFilterValue(operand *string, value interface{}, literal string) {
+	if !qb.setArg(*operand, value) {
+		*operand = literal
+	}
+}
+
+// filterStringValue converts an OData string literal with escaped quotes to its value
+func filterStringValue(literal string) string {
+	if len(literal) > 1 && strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") {
+		literal = literal[1 : len(literal)-1]
+	}
+
+	return strings.Replace(literal, "''", "'", -1)
+}
+
+// getLimit returns the max entities to retrieve, this number is set by ODATA's
+// $top, if not provided use the global value, -1 means no limit
+// supply an int to extra to ad to the limit
+func (qb *QueryBuilder) getLimit(qo *odata.QueryOptions, extra int) int {
+	if qo != nil && qo.Top != nil {
+		if int(*qo.Top) < 0 {
+			return -1
+		}
+
+		return int(*qo.Top) + extra
+	}
+
+	return qb.maxTop + extra
+}
+
+// getOffset returns the offset, this number is set by ODATA's $skip, a $skiptoken
+// replaces the offset by the keyset condition of getKeyset
+func (qb *QueryBuilder) getOffset(qo *odata.QueryOptions) int {
+	if qo != nil && qo.Skip != nil && len(qo.SkipToken) == 0 {
+		return int(*qo.Skip)
+	}
+
+	return 0
+}
+
+// getOrderBy returns the string that needs to be placed after ORDER BY, this is set using
+// ODATA's $orderby if not given use the default ORDER BY "table".id DESC
+// when $orderby does not contain the id it is added as last key to get a unique order
+func (qb *QueryBuilder) getOrderBy(et entities.EntityType, qo *odata.QueryOptions) string {
+	orderBy := make([]string, 0)
+	hasID := false
+	if qo != nil && qo.OrderBy != nil {
+		for _, obi := range qo.OrderBy.OrderByItems {
+			c, ok := getColumn(et, obi.Field.Value)
+			if !ok {
+				continue
+			}
+
+			if c.property == idField {
+				hasID = true
+			}
+
+			order := "ASC"
+			if strings.ToLower(obi.Order) == "desc" {
+				order = "DESC"
+			}
+
+			orderBy = append(orderBy, fmt.Sprintf("%s %s", c.filter, order))
+		}
+	}
+
+	if !hasID {
+		orderBy = append(orderBy, fmt.Sprintf("%s.%s DESC", tableMappings[et], idField))
+	}
+
+	return strings.Join(orderBy, ", ")
+}
+
+// getKeyset returns the condition selecting the entities ordered after the entity of the $skiptoken, for the
+// sort keys a and b this is a > ?1 OR (a = ?2 AND b > ?3). SQLite places NULL values first when ordering
+// ascending and last when ordering descending, an empty string is returned without $skiptoken
+func (qb *QueryBuilder) getKeyset(et entities.EntityType, qo *odata.QueryOptions) string {
+	if qo == nil || len(qo.SkipToken) == 0 {
+		return ""
+	}
+
+	equal := make([]string, 0)
+	after := make([]string, 0)
+	keys := qo.SortKeys()
+	for i, key := range keys {
+		c, ok := getColumn(et, key.Field)
+		if !ok {
+			continue
+		}
+
+		// the id is never null, its condition needs no IS NULL check
+		id := strings.ToLower(key.Field) == "id"
+		value := qo.SkipToken[i]
+		condition := ""
+		switch {
+		case value == nil && !key.Desc:
+			condition = fmt.Sprintf("%s IS NOT NULL", c.filter)
+		case value != nil && (!key.Desc || id):
+			condition = fmt.Sprintf("%s %s %s", c.filter, keysetOperator(key.Desc), qb.addArg(value))
+		case value != nil:
+			condition = fmt.Sprintf("(%s < %s OR %s IS NULL)", c.filter, qb.addArg(value), c.filter)
+		}
+
+		if len(condition) > 0 {
+			after = append(after, strings.Join(append(append([]string{}, equal...), condition), " AND "))
+		}
+
+		// the last key is unique, there are no further keys needing its equality
+		if i == len(keys)-1 {
+			break
+		}
+
+		if value == nil {
+			equal = append(equal, fmt.Sprintf("%s IS NULL", c.filter))
+		} else {
+			equal = append(equal, fmt.Sprintf("%s = %s", c.filter, qb.addArg(value)))
+		}
+	}
+
+	if len(after) == 0 {
+		return "0 = 1"
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(after, " OR "))
+}
+
+func keysetOperator(desc bool) string {
+	if desc {
+		return "<"
+	}
+
+	return ">"
+}
+
+// getSelect returns the properties requested by $select, all properties when not set, and the SQL to select them.
+// The id is always selected to be able to expand related entities
+func (qb *QueryBuilder) getSelect(et entities.EntityType, qo *odata.QueryOptions) ([]string, bool, string) {
+	properties := make([]string, 0)
+	includeID := true
+	if qo != nil && qo.Select != nil && len(qo.Select.SelectItems) > 0 {
+		includeID = false
+		for _, si := range qo.Select.SelectItems {
+			if len(si.Segments) == 0 {
+				continue
+			}
+
+			if c, ok := getColumn(et, si.Segments[0].Value); ok {
+				if c.property == idField {
+					includeID = true
+				} else {
+					properties = append(properties, c.property)
+				}
+			}
+		}
+	} else {
+		for _, c := range columnMappings[et] {
+			if c.property != idField {
+				properties = append(properties, c.property)
+			}
+		}
+	}
+
+	fields := []string{fmt.Sprintf("%s.%s AS %s", tableMappings[et], idField, idField)}
+	added := map[string]bool{idField: true}
+	for _, p := range properties {
+		c, _ := getColumn(et, p)
+		alias := c.property
+		if c.kind == columnObservationData {
+			alias = "data"
+		}
+
+		if !added[alias] {
+			added[alias] = true
+			fields = append(fields, fmt.Sprintf("%s AS %s", c.field, alias))
+		}
+	}
+
+	return properties, includeID, strings.Join(fields, ", ")
+}
+
+// getWhere returns the WHERE clause for the relation with the entity e2 with the given id or the entity with the
+// given id when e2 is nil and the $filter
+func (qb *QueryBuilder) getWhere(e1 entities.Entity, e2 entities.Entity, id interface{}, qo *odata.QueryOptions) string {
+	et := e1.GetEntityType()
+	conditions := make([]string, 0)
+	if e2 != nil {
+		if relation, ok := relationFilters[et][e2.GetEntityType()]; ok {
+			conditions = append(conditions, fmt.Sprintf(relation, qb.addArg(id)))
+		} else {
+			conditions = append(conditions, "0 = 1")
+		}
+	} else if id != nil {
+		conditions = append(conditions, fmt.Sprintf("%s.%s = %s", tableMappings[et], idField, qb.addArg(id)))
+	}
+
+	if qo != nil && qo.Filter != nil {
+		if filter := qb.createFilter(et, qo.Filter.Tree); filter != "" {
+			conditions = append(conditions, fmt.Sprintf("(%s)", filter))
+		}
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("WHERE %s", strings.Join(conditions, " AND "))
+}
+
+// aggregateResult converts the result of an observation into a number for the aggregates of $apply,
+// results that are no number are NULL and ignored by AVG, MIN, MAX and SUM
+const aggregateResult = "CASE WHEN json_type(observation.data, '$.result') IN ('integer', 'real') THEN json_extract(observation.data, '$.result') END"
+
+// aggregatePhenomenonTime is the start of the phenomenonTime, the part before the / of a time interval
+const aggregatePhenomenonTime = "substr(json_extract(observation.data, '$.phenomenonTime'), 1, instr(json_extract(observation.data, '$.phenomenonTime') || '/', '/') - 1)"
+
+// aggregateInterval returns the start of the interval of the phenomenonTime of an observation as seconds since the Unix epoch
+func aggregateInterval(seconds int64) string {
+	return fmt.Sprintf("CAST(strftime('%%s', %s) AS INTEGER) / %d * %d", aggregatePhenomenonTime, seconds, seconds)
+}
+
+// getAggregateWhere returns the WHERE clause selecting the observations of the Datastream with the given id, or of
+// all Datastreams when the id is nil, matching the $filter
+func (qb *QueryBuilder) getAggregateWhere(datastreamID interface{}, qo *odata.QueryOptions) string {
+	var e2 entities.Entity
+	if datastreamID != nil {
+		e2 = &entities.Datastream{}
+	}
+
+	where := qb.getWhere(&entities.Observation{}, e2, datastreamID, qo)
+	if len(where) == 0 {
+		return "WHERE json_extract(observation.data, '$.phenomenonTime') IS NOT NULL"
+	}
+
+	return fmt.Sprintf("%s AND json_extract(observation.data, '$.phenomenonTime') IS NOT NULL", where)
+}
+
+// CreateAggregateQuery creates the query for the $apply of the QueryOptions, the observations of the Datastream with the
+// given id, or of all Datastreams when the id is nil, are grouped by the interval of their phenomenonTime. Every row
+// contains the start of the interval in seconds since the Unix epoch followed by the aggregates in the order of $apply
+func (qb *QueryBuilder) CreateAggregateQuery(datastreamID interface{}, qo *odata.QueryOptions) (string, []interface{}) {
+	query := qb.newQuery()
+	columns := []string{fmt.Sprintf("%s AS interval_start", aggregateInterval(qo.Aggregation.Seconds()))}
+	for _, a := range qo.Aggregation.Aggregates {
+		switch a.Method {
+		case odata.AggregateMethodAverage:
+			columns = append(columns, fmt.Sprintf("AVG(%s)", aggregateResult))
+		case odata.AggregateMethodMin:
+			columns = append(columns, fmt.Sprintf("MIN(%s)", aggregateResult))
+		case odata.AggregateMethodMax:
+			columns = append(columns, fmt.Sprintf("MAX(%s)", aggregateResult))
+		case odata.AggregateMethodSum:
+			columns = append(columns, fmt.Sprintf("SUM(%s)", aggregateResult))
+		default:
+			columns = append(columns, "COUNT(*)")
+		}
+	}
+
+	order := "DESC"
+	if !qo.IntervalsDescending() {
+		order = "ASC"
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s %s GROUP BY interval_start ORDER BY interval_start %s LIMIT %v OFFSET %v",
+		strings.Join(columns, ", "),
+		tableMappings[entities.EntityTypeObservation],
+		query.getAggregateWhere(datastreamID, qo),
+		order,
+		query.getLimit(qo, 1),
+		query.getOffset(qo))
+
+	return sql, query.args.values
+}
+
+// CreateAggregateCountQuery creates the query counting the intervals of the $apply of the QueryOptions
+func (qb *QueryBuilder) CreateAggregateCountQuery(datastreamID interface{}, qo *odata.QueryOptions) (string, []interface{}) {
+	query := qb.newQuery()
+	sql := fmt.Sprintf("SELECT COUNT(DISTINCT %s) FROM %s %s",
+		aggregateInterval(qo.Aggregation.Seconds()),
+		tableMappings[entities.EntityTypeObservation],
+		query.getAggregateWhere(datastreamID, qo))
+
+	return sql, query.args.values
+}
+
+// getPruneWhere returns the WHERE clause selecting the observations of the datastream with a phenomenonTime before
+// the given time, the start of an interval phenomenonTime is used
+func (qb *QueryBuilder) getPruneWhere(datastreamID interface{}, before time.Time) string {
+	return fmt.Sprintf("WHERE observation.stream_id = %s AND CAST(strftime('%%s', %s) AS INTEGER) < %s",
+		qb.addArg(datastreamID),
+		aggregatePhenomenonTime,
+		qb.addArg(before.Unix()))
+}
+
+// CreateRollupObservationsQuery creates the query adding the observations of the datastream with a phenomenonTime
+// before the given time to the rollups of the interval, existing rollups of the interval are updated
+func (qb *QueryBuilder) CreateRollupObservationsQuery(datastreamID interface{}, before time.Time, interval time.Duration) (string, []interface{}) {
+	query := qb.newQuery()
+	seconds := int64(interval / time.Second)
+	sql := fmt.Sprintf("INSERT INTO observation_rollup (stream_id, interval_start, interval_sec, observation_count, result_count, result_sum, result_min, result_max) "+
+		"SELECT observation.stream_id, %[1]s AS interval_start, %[2]d, COUNT(*), COUNT(%[3]s), SUM(%[3]s), MIN(%[3]s), MAX(%[3]s) FROM %[4]s %[5]s "+
+		"GROUP BY observation.stream_id, interval_start "+
+		"ON CONFLICT (stream_id, interval_sec, interval_start) DO UPDATE SET "+
+		"observation_count = observation_rollup.observation_count + excluded.observation_count, "+
+		"result_count = observation_rollup.result_count + excluded.result_count, "+
+		"result_sum = coalesce(observation_rollup.result_sum + excluded.result_sum, observation_rollup.result_sum, excluded.result_sum), "+
+		"result_min = coalesce(min(observation_rollup.result_min, excluded.result_min), observation_rollup.result_min, excluded.result_min), "+
+		"result_max = coalesce(max(observation_rollup.result_max, excluded.result_max), observation_rollup.result_max, excluded.result_max)",
+		aggregateInterval(seconds),
+		seconds,
+		aggregateResult,
+		tableMappings[entities.EntityTypeObservation],
+		query.getPruneWhere(datastreamID, before))
+
+	return sql, query.args.values
+}
+
+// CreatePruneObservationsQuery creates the query deleting the observations of the datastream with a phenomenonTime
+// before the given time
+func (qb *QueryBuilder) CreatePruneObservationsQuery(datastreamID interface{}, before time.Time) (string, []interface{}) {
+	query := qb.newQuery()
+	sql := fmt.Sprintf("DELETE FROM %s %s", tableMappings[entities.EntityTypeObservation], query.getPruneWhere(datastreamID, before))
+	return sql, query.args.values
+}
+
+// CreatePruneRollupsQuery creates the query deleting the rollups of the datastream of intervals starting before the given time
+func (qb *QueryBuilder) CreatePruneRollupsQuery(datastreamID interface{}, before time.Time) (string, []interface{}) {
+	query := qb.newQuery()
+	sql := fmt.Sprintf("DELETE FROM observation_rollup WHERE stream_id = %s AND interval_start < %s", query.addArg(datastreamID), query.addArg(before.Unix()))
+	return sql, query.args.values
+}
+
+// CreateCountQuery creates the correct count query based on the given info
+// e1 is the entity to get, when e2 is set e1 is selected by its relation with e2 where e2.id = id,
+// otherwise where e1.id = id
+// returns the query string and the bind parameters of the query
+func (qb *QueryBuilder) CreateCountQuery(e1 entities.Entity, e2 entities.Entity, id interface{}, queryOptions *odata.QueryOptions) (string, []interface{}) {
+	defer metrics.QueryBuildDuration.ObserveSince(time.Now(), "sqlite", "count")
+	query := qb.newQuery()
+	sql := fmt.Sprintf("SELECT COUNT(*) FROM %s %s", tableMappings[e1.GetEntityType()], query.getWhere(e1, e2, id, queryOptions))
+	return strings.TrimSpace(sql), query.args.values
+}
+
+// CreateQuery creates a new query based on given input
+// e1 is the entity to get, when e2 is set e1 is selected by its relation with e2 where e2.id = id,
+// otherwise where e1.id = id
+// returns the query string, the bind parameters and the QueryParseInfo to create the entities from the rows
+func (qb *QueryBuilder) CreateQuery(e1 entities.Entity, e2 entities.Entity, id interface{}, queryOptions *odata.QueryOptions) (string, []interface{}, *QueryParseInfo) {
+	defer metrics.QueryBuildDuration.ObserveSince(time.Now(), "sqlite", "select")
+	query := qb.newQuery()
+	et := e1.GetEntityType()
+	properties, includeID, selectString := query.getSelect(et, queryOptions)
+	qpi := &QueryParseInfo{
+		Entity:     e1,
+		Properties: properties,
+		IncludeID:  includeID,
+	}
+
+	if queryOptions != nil && queryOptions.Expand != nil {
+		qpi.Expand = queryOptions.Expand.ExpandItems
+	}
+
+	// continue after the entity of the $skiptoken
+	where := query.getWhere(e1, e2, id, queryOptions)
+	if keyset := query.getKeyset(et, queryOptions); len(keyset) > 0 {
+		if len(where) == 0 {
+			where = fmt.Sprintf("WHERE %s", keyset)
+		} else {
+			where = fmt.Sprintf("%s AND %s", where, keyset)
+		}
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s LIMIT %v OFFSET %v",
+		selectString,
+		tableMappings[et],
+		where,
+		query.getOrderBy(et, queryOptions),
+		query.getLimit(queryOptions, 1),
+		query.getOffset(queryOptions))
+
+	return strings.Replace(sql, "  ", " ", -1), query.args.values, qpi
+}
+
+func (qb *QueryBuilder) createFilter(et entities.EntityType, pn *godata.ParseNode) string {
+	if pn == nil || pn.Token == nil {
+		return ""
+	}
+
+	if convertFunction, ok := filterToStringMap[pn.Token.Type]; ok {
+		return convertFunction(qb, pn, et)
+	}
+
+	return ""
+}
+
+// prepareFilter converts the value compared with encodingType, observationType and time properties into the
+// value stored in the database
+func (qb *QueryBuilder) prepareFilter(originalLeft, left, originalRight, right string) (string, string) {
+	for i := 0; i < 2; i++ {
+		var oStr []*string
+		var str []*string
+		if i == 0 {
+			oStr = []*string{&originalLeft, &originalRight}
+			str = []*string{&left, &right}
+		} else {
+			oStr = []*string{&originalRight, &originalLeft}
+			str = []*string{&right, &left}
+		}
+
+		e := filterStringValue(*oStr[1])
+		property := strings.ToLower(*oStr[0])
+
+		if property == "encodingtype" {
+			et, err := entities.CreateEncodingType(e)
+			if err == nil {
+				qb.setFilterValue(str[1], et.Code, fmt.Sprintf("%v", et.Code))
+			}
+			return left, right
+		}
+
+		if property == "observationtype" {
+			et, err := entities.GetObservationTypeByValue(e)
+			if err == nil {
+				qb.setFilterValue(str[1], et.Code, fmt.Sprintf("%v", et.Code))
+			}
+			return left, right
+		}
+
+		if property == "phenomenontime" || property == "resulttime" || property == "time" {
+			if t, err := time.Parse(time.RFC3339Nano, e); err == nil {
+				formatted := t.UTC().Format(TimeFormat)
+				qb.setFilterValue(str[1], formatted, fmt.Sprintf("'%s'", formatted))
+			}
+
+			return left, right
+		}
+	}
+
+	return left, right
+}
+
+// odataLogicalOperatorToSQLite converts a logical operator to a SQLite string representation
+func (qb *QueryBuilder) odataLogicalOperatorToSQLite(o string) string {
+	switch o {
+	case "and":
+		return "AND"
+	case "or":
+		return "OR"
+	case "not":
+		return "NOT"
+	case "eq":
+		return "="
+	case "ne":
+		return "!="
+	case "gt":
+		return ">"
+	case "ge":
+		return ">="
+	case "lt":
+		return "<"
+	case "le":
+		return "<="
+	}
+
+	return ""
+}
+
+func (qb *QueryBuilder) createArithmetic(et entities.EntityType, pn *godata.ParseNode, operator, castTo string) string {
+	left := qb.createFilter(et, pn.Children[0])
+	right := qb.createFilter(et, pn.Children[1])
+	return fmt.Sprintf("CAST(%s AS %s) %s CAST(%s AS %s)", left, castTo, operator, right, castTo)
+}
+
+func (qb *QueryBuilder) createExtractDateQuery(pn *godata.ParseNode, et entities.EntityType, format string) string {
+	left := qb.createFilter(et, pn.Children[0])
+	return fmt.Sprintf("CAST(strftime('%s', %s) AS INTEGER)", format, left)
+}
+
+func (qb *QueryBuilder) createSpatialQuery(pn *godata.ParseNode, et entities.EntityType, function string, params int) string {
+	args := make([]interface{}, 0, params)
+	for i := 0; i < params && i < len(pn.Children); i++ {
+		input := qb.createFilter(et, pn.Children[i])
+		if i < 2 && pn.Children[i].Token.Type != godata.FilterTokenGeography {
+			input = fmt.Sprintf("SetSRID(GeomFromGeoJSON(%s), 4326)", input)
+		}
+
+		args = append(args, input)
+	}
+
+	return fmt.Sprintf(function, args...)
+}
+
+// LikeType describes the type of like
+type LikeType int
+
+// LikeType is a "enumeration" of the Like types, LikeStartsWith = startsWith input%, LikeEndsWith = endsWith %input, LikeContains = contains %input%
+const (
+	LikeStartsWith LikeType = 0
+	LikeEndsWith   LikeType = 1
+	LikeContains   LikeType = 2
+)
+
+// createLike adds the LIKE wildcards to a string bind parameter, other input is returned unchanged
+func (qb *QueryBuilder) createLike(input string, like LikeType) string {
+	value, _ := qb.getArg(input)
+	s, ok := value.(string)
+	if !ok {
+		return input
+	}
+
+	switch like {
+	case LikeStartsWith:
+		qb.setArg(input, fmt.Sprintf("%s%s", s, "%"))
+	case LikeEndsWith:
+		qb.setArg(input, fmt.Sprintf("%s%s", "%", s))
+	case LikeContains:
+		qb.setArg(input, fmt.Sprintf("%s%s%s", "%", s, "%"))
+	}
+
+	return input
+}
@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	"github.com/gost/server/sensorthings/odata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateQueryBuilder(t *testing.T) {
+	// act
+	qb := CreateQueryBuilder(1)
+	// assert
+	assert.NotNil(t, qb)
+}
+
+func TestGetLimit(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+	qo := &odata.QueryOptions{}
+	qo.Top, _ = godata.ParseTopString("2")
+
+	// assert
+	assert.Equal(t, 201, qb.getLimit(nil, 1))
+	assert.Equal(t, 3, qb.getLimit(qo, 1))
+}
+
+func TestCreateQueryById(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+
+	// act
+	query, args, qi := qb.CreateQuery(&entities.Thing{}, nil, 1, nil)
+
+	// assert
+	assert.True(t, strings.Contains(query, "FROM thing WHERE thing.id = ?1"))
+	assert.True(t, strings.HasSuffix(query, "ORDER BY thing.id DESC LIMIT 201 OFFSET 0"))
+	assert.Equal(t, []interface{}{1}, args)
+	assert.True(t, qi.IncludeID)
+}
+
+func TestCreateQueryRelation(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+
+	// act
+	query, args, _ := qb.CreateQuery(&entities.Datastream{}, &entities.Thing{}, 3, nil)
+
+	// assert
+	assert.True(t, strings.Contains(query, "WHERE datastream.thing_id = ?1"))
+	assert.Equal(t, []interface{}{3}, args)
+}
+
+func TestCreateQueryFilterUsesBindParameters(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+	qo := &odata.QueryOptions{}
+	qo.Filter, _ = godata.ParseFilterString("name eq 'x'' OR 1=1 --'")
+
+	// act
+	query, args, _ := qb.CreateQuery(&entities.Thing{}, nil, nil, qo)
+
+	// assert
+	assert.False(t, strings.Contains(query, "OR 1=1"))
+	assert.Equal(t, []interface{}{"x' OR 1=1 --"}, args)
+}
+
+func TestCreateCountQuery(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+
+	// act
+	query, args := qb.CreateCountQuery(&entities.Observation{}, &entities.Datastream{}, 5, nil)
+
+	// assert
+	assert.Equal(t, "SELECT COUNT(*) FROM observation WHERE observation.stream_id = ?1", query)
+	assert.Equal(t, []interface{}{5}, args)
+}
+
+func TestCreateQueryWithSkipToken(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+	qo := &odata.QueryOptions{}
+	qo.OrderBy, _ = godata.ParseOrderByString("name desc")
+	qo.SkipToken = odata.SkipToken{nil, int64(3)}
+
+	// act
+	query, args, _ := qb.CreateQuery(&entities.Datastream{}, &entities.Thing{}, 1, qo)
+
+	// assert
+	assert.True(t, strings.Contains(query, "WHERE datastream.thing_id = ?1 AND (datastream.name IS NULL AND datastream.id < ?2)"), query)
+	assert.True(t, strings.Contains(query, "ORDER BY datastream.name DESC, datastream.id DESC"))
+	assert.Equal(t, []interface{}{1, int64(3)}, args)
+}
+
+func TestCreateAggregateQuery(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+	qo := &odata.QueryOptions{}
+	qo.Aggregation, _ = odata.ParseApply("groupby((interval(phenomenonTime,P1D)),aggregate(result with max as max))")
+	qo.OrderBy, _ = godata.ParseOrderByString("phenomenonTime asc")
+
+	// act
+	query, args := qb.CreateAggregateQuery(2, qo)
+	countQuery, _ := qb.CreateAggregateCountQuery(nil, qo)
+
+	// assert
+	assert.True(t, strings.Contains(query, "/ 86400 * 86400 AS interval_start, MAX(CASE WHEN json_type(observation.data, '$.result') IN ('integer', 'real')"), query)
+	assert.True(t, strings.Contains(query, "WHERE observation.stream_id = ?1 AND json_extract(observation.data, '$.phenomenonTime') IS NOT NULL"), query)
+	assert.True(t, strings.HasSuffix(query, "GROUP BY interval_start ORDER BY interval_start ASC LIMIT 201 OFFSET 0"), query)
+	assert.Equal(t, []interface{}{2}, args)
+	assert.True(t, strings.HasSuffix(countQuery, "FROM observation WHERE json_extract(observation.data, '$.phenomenonTime') IS NOT NULL"), countQuery)
+}
+
+func TestCreateRollupObservationsQuery(t *testing.T) {
+	// arrange
+	qb := CreateQueryBuilder(200)
+	before := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// act
+	query, args := qb.CreateRollupObservationsQuery(2, before, 15*time.Minute)
+	pruneQuery, pruneArgs := qb.CreatePruneObservationsQuery(2, before)
+
+	// assert
+	assert.True(t, strings.HasPrefix(query, "INSERT INTO observation_rollup (stream_id, interval_start, interval_sec,"), query)
+	assert.True(t, strings.Contains(query, "/ 900 * 900 AS interval_start, 900, COUNT(*)"), query)
+	assert.True(t, strings.Contains(query, "ON CONFLICT (stream_id, interval_sec, interval_start) DO UPDATE SET"), query)
+	assert.Equal(t, []interface{}{2, before.Unix()}, args)
+	assert.True(t, strings.HasPrefix(pruneQuery, "DELETE FROM observation WHERE observation.stream_id = ?1 AND CAST(strftime('%s', "), pruneQuery)
+	assert.True(t, strings.HasSuffix(pruneQuery, "AS INTEGER) < ?2"), pruneQuery)
+	assert.Equal(t, args, pruneArgs)
+}
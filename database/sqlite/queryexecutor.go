@@ -0,0 +1,421 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	gostErrors "github.com/gost/server/errors"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/odata"
+	log "github.com/sirupsen/logrus"
+)
+
+// paramFactories create an entity from the selected values by property name
+var paramFactories map[entities.EntityType]func(values map[string]interface{}) (entities.Entity, error)
+
+// init is used to work around the initialization loop error (circular reference)
+func init() {
+	paramFactories = map[entities.EntityType]func(values map[string]interface{}) (entities.Entity, error){
+		entities.EntityTypeThing:              thingParamFactory,
+		entities.EntityTypeLocation:           locationParamFactory,
+		entities.EntityTypeHistoricalLocation: historicalLocationParamFactory,
+		entities.EntityTypeSensor:             sensorParamFactory,
+		entities.EntityTypeObservedProperty:   observedPropertyParamFactory,
+		entities.EntityTypeDatastream:         datastreamParamFactory,
+		entities.EntityTypeObservation:        observationParamFactory,
+		entities.EntityTypeFeatureOfInterest:  featureOfInterestParamFactory,
+	}
+}
+
+// queryLogger returns the logger for the queries of a request, the lines hold the id of the request
+func queryLogger(qo *odata.QueryOptions) *log.Entry {
+	if qo == nil {
+		return logger
+	}
+
+	return gostLog.WithRequestID(logger, qo.RequestID)
+}
+
+// statementExecutor logs the insert, update and delete statements it executes at debug level
+type statementExecutor struct {
+	execQueryer
+	logger *log.Entry
+}
+
+// Exec executes the statement on the wrapped database or transaction
+func (e statementExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if e.logger.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(e.logger, time.Now(), "execute statement: %s", query)
+	}
+
+	return e.execQueryer.Exec(query, args...)
+}
+
+// QueryRow executes a statement returning a row
+func (e statementExecutor) QueryRow(query string, args ...interface{}) *sql.Row {
+	if e.logger.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(e.logger, time.Now(), "execute statement: %s", query)
+	}
+
+	return e.execQueryer.QueryRow(query, args...)
+}
+
+// ExecuteSelectCount runs a given count query with its bind parameters and returns the value
+func ExecuteSelectCount(db *sql.DB, sql string, args []interface{}, qo *odata.QueryOptions) (int, error) {
+	if logger.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(queryLogger(qo), time.Now(), "execute count query: %s", sql)
+	}
+
+	var count int
+	err := db.QueryRow(sql, args...).Scan(&count)
+	return count, err
+}
+
+// ExecuteSelect executes the select query with its bind parameters and creates the retrieved entities,
+// the related entities requested by $expand are retrieved using a query per parent entity
+func ExecuteSelect(gdb *GostDatabase, q *QueryParseInfo, sql string, args []interface{}, qo *odata.QueryOptions) ([]entities.Entity, bool, error) {
+	hasNextPage := false
+	if logger.Logger.Level == log.DebugLevel {
+		defer gostLog.DebugfWithElapsedTime(queryLogger(qo), time.Now(), "execute select query: %s", sql)
+	}
+
+	parentEntities, err := queryEntities(gdb.Db, q, sql, args)
+	if err != nil {
+		return nil, hasNextPage, err
+	}
+
+	// if no parent entities are found return nil, nil
+	if len(parentEntities) == 0 {
+		return nil, hasNextPage, nil
+	}
+
+	// To check if there is a next page an additional entity is requested, check if more items
+	// returned than requested and remove the extra entity
+	if qo != nil && qo.Top != nil && int(*qo.Top) >= 0 && len(parentEntities) > int(*qo.Top) {
+		hasNextPage = true
+		parentEntities = parentEntities[:int(*qo.Top)]
+	}
+
+	if err = expand(gdb, parentEntities, q.Expand); err != nil {
+		return nil, hasNextPage, err
+	}
+
+	// Remove the id fields that weren't requested by the user
+	if !q.IncludeID {
+		for _, e := range parentEntities {
+			e.SetID(nil)
+		}
+	}
+
+	return parentEntities, hasNextPage, nil
+}
+
+// queryEntities runs the query and creates an entity per row, all rows are read before
+// returning so the single database connection is free for the expand queries
+func queryEntities(db *sql.DB, q *QueryParseInfo, sql string, args []interface{}) ([]entities.Entity, error) {
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	et := q.Entity.GetEntityType()
+	result := make([]entities.Entity, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valueP := make([]interface{}, len(columns))
+		for i := range values {
+			valueP[i] = &values[i]
+		}
+
+		if err = rows.Scan(valueP...); err != nil {
+			return nil, err
+		}
+
+		properties, err := rowToProperties(et, q.Properties, columns, values)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := paramFactories[et](properties)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
+
+// rowToProperties converts the values of a row into the values of the entity properties, JSON columns are parsed,
+// codes are converted into their value and the selected properties are taken from the observation data
+func rowToProperties(et entities.EntityType, selected []string, columns []string, values []interface{}) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	for i, name := range columns {
+		value := values[i]
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+
+		if name == "data" {
+			data, err := observationData(value)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, p := range selected {
+				properties[p] = data[p]
+			}
+			continue
+		}
+
+		c, _ := getColumn(et, name)
+		if value == nil {
+			properties[c.property] = nil
+			continue
+		}
+
+		switch c.kind {
+		case columnJSON:
+			var parsed interface{}
+			if err := json.Unmarshal([]byte(fmt.Sprintf("%v", value)), &parsed); err != nil {
+				return nil, err
+			}
+			properties[c.property] = parsed
+		case columnEncodingType:
+			if code, ok := value.(int64); ok && code != 0 {
+				properties[c.property] = entities.EncodingValues[code].Value
+			}
+		case columnObservationType:
+			if code, ok := value.(int64); ok {
+				obs, _ := entities.GetObservationTypeByID(code)
+				properties[c.property] = obs.Value
+			}
+		default:
+			properties[c.property] = value
+		}
+	}
+
+	return properties, nil
+}
+
+func observationData(value interface{}) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	s, ok := value.(string)
+	if !ok || len(s) == 0 {
+		return data, nil
+	}
+
+	err := json.Unmarshal([]byte(s), &data)
+	return data, err
+}
+
+// queryError converts errors caused by the requested filter into bad request errors
+func queryError(err error) error {
+	errString := err.Error()
+	if strings.Contains(errString, "no such function") || strings.Contains(errString, "no such column") {
+		return gostErrors.NewBadRequestError(fmt.Errorf("Unsupported filter: %v", errString))
+	}
+
+	return err
+}
+
+// selectError wraps a failed select, errors caused by the request are returned unchanged
+func selectError(err error) error {
+	if _, ok := err.(gostErrors.APIError); ok {
+		return err
+	}
+
+	return fmt.Errorf("Error executing query %v", err)
+}
+
+// expand retrieves the related entities requested by $expand for every parent and adds them to the parent,
+// expand items with the same path such as Datastreams/Sensor and Datastreams/Thing are retrieved once
+func expand(gdb *GostDatabase, parents []entities.Entity, items []*godata.ExpandItem) error {
+	if len(items) == 0 || len(parents) == 0 {
+		return nil
+	}
+
+	type expandNode struct {
+		item     *godata.ExpandItem
+		children []*godata.ExpandItem
+	}
+
+	order := make([]string, 0)
+	nodes := make(map[string]*expandNode)
+	for _, item := range items {
+		if len(item.Path) == 0 {
+			continue
+		}
+
+		name := strings.ToLower(item.Path[0].Value)
+		node, ok := nodes[name]
+		if !ok {
+			node = &expandNode{}
+			nodes[name] = node
+			order = append(order, name)
+		}
+
+		if len(item.Path) == 1 {
+			node.item = item
+			continue
+		}
+
+		child := *item
+		child.Path = item.Path[1:]
+		node.children = append(node.children, &child)
+	}
+
+	parentType := parents[0].GetEntityType()
+	for _, name := range order {
+		node := nodes[name]
+		child, err := entities.EntityFromString(name)
+		if err != nil {
+			return gostErrors.NewBadRequestError(fmt.Errorf("Unable to expand %s", name))
+		}
+
+		if _, ok := relationFilters[child.GetEntityType()][parentType]; !ok {
+			return gostErrors.NewBadRequestError(fmt.Errorf("%s has no relation with %s", parentType.ToString(), name))
+		}
+
+		var qo *odata.QueryOptions
+		if node.item != nil {
+			qo = odata.ExpandItemToQueryOptions(node.item)
+		}
+
+		for _, parent := range parents {
+			query, args, qi := gdb.QueryBuilder.CreateQuery(child, parent, parent.GetID(), qo)
+			qi.Expand = append(qi.Expand, node.children...)
+
+			related, _, err := ExecuteSelect(gdb, qi, query, args, qo)
+			if err != nil {
+				return err
+			}
+
+			addRelationToEntity(parent, related)
+		}
+	}
+
+	return nil
+}
+
+func addRelationToEntity(parent entities.Entity, subEntities []entities.Entity) {
+	switch parentEntity := parent.(type) {
+	case *entities.Thing:
+		addRelationToThing(parentEntity, subEntities)
+	case *entities.Location:
+		addRelationToLocation(parentEntity, subEntities)
+	case *entities.HistoricalLocation:
+		addRelationToHistoricalLocation(parentEntity, subEntities)
+	case *entities.Datastream:
+		addRelationToDatastream(parentEntity, subEntities)
+	case *entities.Sensor:
+		addRelationToSensor(parentEntity, subEntities)
+	case *entities.ObservedProperty:
+		addRelationToObservedProperty(parentEntity, subEntities)
+	case *entities.Observation:
+		addRelationToObservation(parentEntity, subEntities)
+	case *entities.FeatureOfInterest:
+		addRelationToFeatureOfInterest(parentEntity, subEntities)
+	}
+}
+
+func addRelationToThing(parentEntity *entities.Thing, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.HistoricalLocation:
+			parentEntity.HistoricalLocations = append(parentEntity.HistoricalLocations, subEntity)
+		case *entities.Location:
+			parentEntity.Locations = append(parentEntity.Locations, subEntity)
+		case *entities.Datastream:
+			parentEntity.Datastreams = append(parentEntity.Datastreams, subEntity)
+		}
+	}
+}
+
+func addRelationToLocation(parentEntity *entities.Location, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.HistoricalLocation:
+			parentEntity.HistoricalLocations = append(parentEntity.HistoricalLocations, subEntity)
+		case *entities.Thing:
+			parentEntity.Things = append(parentEntity.Things, subEntity)
+		}
+	}
+}
+
+func addRelationToHistoricalLocation(parentEntity *entities.HistoricalLocation, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Thing:
+			parentEntity.Thing = subEntity
+		case *entities.Location:
+			parentEntity.Locations = append(parentEntity.Locations, subEntity)
+		}
+	}
+}
+
+func addRelationToDatastream(parentEntity *entities.Datastream, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Observation:
+			parentEntity.Observations = append(parentEntity.Observations, subEntity)
+		case *entities.Thing:
+			parentEntity.Thing = subEntity
+		case *entities.Sensor:
+			parentEntity.Sensor = subEntity
+		case *entities.ObservedProperty:
+			parentEntity.ObservedProperty = subEntity
+		}
+	}
+}
+
+func addRelationToSensor(parentEntity *entities.Sensor, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Datastream:
+			parentEntity.Datastreams = append(parentEntity.Datastreams, subEntity)
+		}
+	}
+}
+
+func addRelationToObservedProperty(parentEntity *entities.ObservedProperty, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Datastream:
+			parentEntity.Datastreams = append(parentEntity.Datastreams, subEntity)
+		}
+	}
+}
+
+func addRelationToObservation(parentEntity *entities.Observation, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Datastream:
+			parentEntity.Datastream = subEntity
+		case *entities.FeatureOfInterest:
+			parentEntity.FeatureOfInterest = subEntity
+		}
+	}
+}
+
+func addRelationToFeatureOfInterest(parentEntity *entities.FeatureOfInterest, subEntities []entities.Entity) {
+	for _, se := range subEntities {
+		switch subEntity := se.(type) {
+		case *entities.Observation:
+			parentEntity.Observations = append(parentEntity.Observations, subEntity)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package sqlite
+
+// createSchemaSQL is the built-in schema used by CreateSchema, geometries are stored as GeoJSON text
+// and converted by SpatiaLite when filtering, times are stored as text in TimeFormat
+const createSchemaSQL = `
+CREATE TABLE IF NOT EXISTS thing (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	description TEXT,
+	properties TEXT
+);
+
+CREATE TABLE IF NOT EXISTS location (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	description TEXT,
+	encodingtype INTEGER,
+	location TEXT
+);
+
+CREATE TABLE IF NOT EXISTS thing_to_location (
+	thing_id INTEGER NOT NULL REFERENCES thing (id) ON DELETE CASCADE,
+	location_id INTEGER NOT NULL REFERENCES location (id) ON DELETE CASCADE,
+	PRIMARY KEY (thing_id, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS historicallocation (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time TEXT,
+	thing_id INTEGER NOT NULL REFERENCES thing (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS location_to_historicallocation (
+	location_id INTEGER NOT NULL REFERENCES location (id) ON DELETE CASCADE,
+	historicallocation_id INTEGER NOT NULL REFERENCES historicallocation (id) ON DELETE CASCADE,
+	PRIMARY KEY (location_id, historicallocation_id)
+);
+
+CREATE TABLE IF NOT EXISTS sensor (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	description TEXT,
+	encodingtype INTEGER,
+	metadata TEXT
+);
+
+CREATE TABLE IF NOT EXISTS observedproperty (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	definition TEXT,
+	description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS datastream (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	description TEXT,
+	unitofmeasurement TEXT,
+	observationtype INTEGER,
+	observedarea TEXT,
+	phenomenontime TEXT,
+	resulttime TEXT,
+	thing_id INTEGER NOT NULL REFERENCES thing (id) ON DELETE CASCADE,
+	sensor_id INTEGER NOT NULL REFERENCES sensor (id) ON DELETE CASCADE,
+	observedproperty_id INTEGER NOT NULL REFERENCES observedproperty (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS featureofinterest (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	description TEXT,
+	encodingtype INTEGER,
+	feature TEXT,
+	original_location_id INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS observation (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	data TEXT,
+	stream_id INTEGER NOT NULL REFERENCES datastream (id) ON DELETE CASCADE,
+	featureofinterest_id INTEGER NOT NULL REFERENCES featureofinterest (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS observation_rollup (
+	stream_id INTEGER NOT NULL REFERENCES datastream (id) ON DELETE CASCADE,
+	interval_start INTEGER NOT NULL,
+	interval_sec INTEGER NOT NULL,
+	observation_count INTEGER NOT NULL,
+	result_count INTEGER NOT NULL,
+	result_sum REAL,
+	result_min REAL,
+	result_max REAL,
+	PRIMARY KEY (stream_id, interval_sec, interval_start)
+);
+
+CREATE INDEX IF NOT EXISTS fki_thing_datastream ON datastream (thing_id);
+CREATE INDEX IF NOT EXISTS fki_thing_historicallocation ON historicallocation (thing_id);
+CREATE INDEX IF NOT EXISTS fki_datastream_observation ON observation (stream_id);
+CREATE INDEX IF NOT EXISTS fki_featureofinterest_observation ON observation (featureofinterest_id);
+CREATE INDEX IF NOT EXISTS fki_location_featureofinterest ON featureofinterest (original_location_id);
+`
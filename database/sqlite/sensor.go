@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+func sensorParamFactory(values map[string]interface{}) (entities.Entity, error) {
+	s := &entities.Sensor{}
+	for property, value := range values {
+		if value == nil {
+			continue
+		}
+
+		switch property {
+		case "id":
+			s.ID = value
+		case "name":
+			s.Name = value.(string)
+		case "description":
+			s.Description = value.(string)
+		case "encodingType":
+			s.EncodingType = value.(string)
+		case "metadata":
+			s.Metadata = value.(string)
+		}
+	}
+
+	return s, nil
+}
+
+// GetSensor return a sensor by id
+func (gdb *GostDatabase) GetSensor(id interface{}, qo *odata.QueryOptions) (*entities.Sensor, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Sensor does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, nil, intID, qo)
+	return processSensor(gdb, query, args, qi)
+}
+
+// GetSensorByDatastream retrieves a sensor by given datastream
+func (gdb *GostDatabase) GetSensorByDatastream(id interface{}, qo *odata.QueryOptions) (*entities.Sensor, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, &entities.Datastream{}, intID, qo)
+	return processSensor(gdb, query, args, qi)
+}
+
+// GetSensors retrieves all sensors based on the QueryOptions
+func (gdb *GostDatabase) GetSensors(qo *odata.QueryOptions) ([]*entities.Sensor, int, bool, error) {
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Sensor{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Sensor{}, nil, nil, qo)
+	return processSensors(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+func processSensor(gdb *GostDatabase, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Sensor, error) {
+	sensors, _, _, err := processSensors(gdb, sql, args, nil, qi, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sensors) == 0 {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Sensor not found"))
+	}
+
+	return sensors[0], nil
+}
+
+func processSensors(gdb *GostDatabase, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Sensor, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(gdb, qi, sql, args, qo)
+	if err != nil {
+		return nil, 0, hasNext, selectError(err)
+	}
+
+	sensors := make([]*entities.Sensor, 0)
+	for _, d := range data {
+		entity := d.(*entities.Sensor)
+		sensors = append(sensors, entity)
+	}
+
+	var count int
+	if len(countSQL) > 0 {
+		count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+		if err != nil {
+			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return sensors, count, hasNext, nil
+}
+
+// PostSensor posts a sensor to the database
+func (gdb *GostDatabase) PostSensor(sensor *entities.Sensor) (*entities.Sensor, error) {
+	encoding, err := entities.CreateEncodingType(sensor.EncodingType)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO sensor (name, description, encodingtype, metadata) VALUES (?1, ?2, ?3, ?4)"
+	r, err := gdb.statements(gdb.Db).Exec(query, sensor.Name, sensor.Description, encoding.Code, sensor.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	sensorID, _ := r.LastInsertId()
+	sensor.ID = int(sensorID)
+	return sensor, nil
+}
+
+// SensorExists checks if a sensor is present in the database based on a given id
+func (gdb *GostDatabase) SensorExists(id int) bool {
+	return EntityExists(gdb, id, "sensor")
+}
+
+// PatchSensor updates a sensor in the database
+func (gdb *GostDatabase) PatchSensor(id interface{}, s *entities.Sensor) (*entities.Sensor, error) {
+	var err error
+	var ok bool
+	var intID int
+	updates := make(map[string]interface{})
+
+	if intID, ok = ToIntID(id); !ok || !gdb.SensorExists(intID) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Sensor does not exist"))
+	}
+
+	if len(s.Name) > 0 {
+		updates["name"] = s.Name
+	}
+
+	if len(s.Description) > 0 {
+		updates["description"] = s.Description
+	}
+
+	if len(s.Metadata) > 0 {
+		updates["metadata"] = s.Metadata
+	}
+
+	if len(s.EncodingType) > 0 {
+		encoding, _ := entities.CreateEncodingType(s.EncodingType)
+		updates["encodingtype"] = encoding.Code
+	}
+
+	if err = gdb.updateEntityColumns("sensor", updates, intID); err != nil {
+		return nil, err
+	}
+
+	ns, _ := gdb.GetSensor(intID, nil)
+	return ns, nil
+}
+
+// PutSensor receives a Sensor entity and changes it in the database
+// returns the Sensor
+func (gdb *GostDatabase) PutSensor(id interface{}, sensor *entities.Sensor) (*entities.Sensor, error) {
+	return gdb.PatchSensor(id, sensor)
+}
+
+// DeleteSensor tries to delete a Sensor by the given id
+func (gdb *GostDatabase) DeleteSensor(id interface{}) error {
+	return DeleteEntity(gdb, id, "sensor")
+}
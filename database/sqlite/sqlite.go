@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	gostErrors "github.com/gost/server/errors"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/models"
+	sqlite3 "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// TimeFormat describes the format in which times are stored, stored times can be compared as text
+	TimeFormat = "2006-01-02T15:04:05.000Z"
+
+	// spatialiteDriver is the sqlite3 driver loading the SpatiaLite extension on every connection
+	spatialiteDriver = "sqlite3_spatialite"
+)
+
+var logger *log.Entry
+
+func init() {
+	sql.Register(spatialiteDriver, &sqlite3.SQLiteDriver{
+		Extensions: []string{"mod_spatialite"},
+	})
+}
+
+// GostDatabase implementation
+type GostDatabase struct {
+	Path         string
+	SpatiaLite   bool
+	Db           *sql.DB
+	QueryBuilder *QueryBuilder
+	once         *sync.Once
+	requestID    string
+}
+
+func setupLogger() {
+	l, err := gostLog.GetPackageLogger("gost.server.database.sqlite")
+	if err != nil {
+		log.Error(err)
+	}
+
+	logger = l
+}
+
+// NewDatabase initialises the SQLite database stored in the file at path, the file is created when it
+// does not exist, maxTop is the maximum number of entities returned by a query
+func NewDatabase(path string, maxTop int) models.Database {
+	setupLogger()
+	gdb := &GostDatabase{
+		Path:         path,
+		QueryBuilder: CreateQueryBuilder(maxTop),
+		once:         &sync.Once{},
+	}
+
+	// SQLite runs in process so the database is opened right away, Start is run in the background
+	gdb.open()
+	return gdb
+}
+
+// Start the database
+func (gdb *GostDatabase) Start() {
+	gdb.open()
+}
+
+// Ping checks if the database can be reached
+func (gdb *GostDatabase) Ping() error {
+	return gdb.Db.Ping()
+}
+
+// Close closes the connections to the database
+func (gdb *GostDatabase) Close() error {
+	if gdb.Db == nil {
+		return nil
+	}
+
+	return gdb.Db.Close()
+}
+
+// WithContext returns a copy of the database bound to the request of ctx, the statements executed by the
+// copy are logged with the id of the request
+func (gdb *GostDatabase) WithContext(ctx context.Context) models.Database {
+	c := *gdb
+	c.requestID = gostLog.RequestIDFromContext(ctx)
+	return &c
+}
+
+// statementLogger returns the logger for the statements of the request the database is bound to
+func (gdb *GostDatabase) statementLogger() *log.Entry {
+	return gostLog.WithRequestID(logger, gdb.requestID)
+}
+
+// statements returns db logging its statements with the id of the request the database is bound to
+func (gdb *GostDatabase) statements(db execQueryer) execQueryer {
+	return statementExecutor{execQueryer: db, logger: gdb.statementLogger()}
+}
+
+// open connects to the database file once, SpatiaLite is loaded when available. SQLite allows a single
+// writer so only one connection is used, this also keeps an in-memory database (:memory:) alive
+func (gdb *GostDatabase) open() {
+	gdb.once.Do(func() {
+		logger.Infof("Opening database %v", gdb.Path)
+		dsn := fmt.Sprintf("file:%s?_foreign_keys=1&_busy_timeout=5000", gdb.Path)
+
+		db, err := sql.Open(spatialiteDriver, dsn)
+		if err == nil {
+			err = db.QueryRow("SELECT spatialite_version()").Scan(new(string))
+		}
+
+		if err == nil {
+			gdb.SpatiaLite = true
+		} else {
+			logger.Warnf("SpatiaLite not available, spatial filters are not supported: %v", err)
+			if db != nil {
+				db.Close()
+			}
+
+			if db, err = sql.Open("sqlite3", dsn); err != nil {
+				logger.Fatal(err)
+			}
+		}
+
+		db.SetMaxOpenConns(1)
+		gdb.Db = db
+
+		// pending migrations are applied so a new database file is ready to use, a file migrated by
+		// a newer version of GOST is refused
+		if _, err = gdb.Migrate(); err != nil {
+			logger.Fatal(err)
+		}
+
+		logger.Infof("Connected to database")
+	})
+}
+
+// CreateSchema creates the needed tables in the database using the script at location, the
+// migrations are applied when location is empty
+func (gdb *GostDatabase) CreateSchema(location string) error {
+	if len(location) == 0 {
+		_, err := gdb.Migrate()
+		return err
+	}
+
+	create, err := GetCreateDatabaseQuery(location)
+	if err != nil {
+		return err
+	}
+
+	_, err = gdb.Db.Exec(*create)
+	return err
+}
+
+// GetCreateDatabaseQuery returns the database creation script for SQLite, read from location
+// or the built-in schema when location is empty
+func GetCreateDatabaseQuery(location string) (*string, error) {
+	if len(location) == 0 {
+		schema := createSchemaSQL
+		return &schema, nil
+	}
+
+	bytes, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(bytes[:])
+	return &content, nil
+}
+
+// EntityExists checks if entity exists in database
+func EntityExists(gdb *GostDatabase, id interface{}, entityName string) bool {
+	var result bool
+	sql := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = ?1 LIMIT 1)", entityName)
+	err := gdb.Db.QueryRow(sql, id).Scan(&result)
+	if err != nil {
+		return false
+	}
+
+	return result
+}
+
+// DeleteEntity deletes a record from database for entity
+func DeleteEntity(gdb *GostDatabase, id interface{}, entityName string) error {
+	intID, ok := ToIntID(id)
+	if !ok {
+		errorMessage := fmt.Sprintf("%s does not exist", entityName)
+		return gostErrors.NewRequestNotFound(errors.New(errorMessage))
+	}
+
+	r, err := gdb.statements(gdb.Db).Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?1", entityName), intID)
+	if err != nil {
+		return err
+	}
+
+	if c, _ := r.RowsAffected(); c == 0 {
+		errorMessage := fmt.Sprintf("%s not found", entityName)
+		return gostErrors.NewRequestNotFound(errors.New(errorMessage))
+	}
+	return nil
+}
+
+// JSONToMap converts a string of json into a map
+func JSONToMap(data *string) (map[string]interface{}, error) {
+	var p map[string]interface{}
+	if data == nil || len(*data) == 0 {
+		return p, nil
+	}
+
+	err := json.Unmarshal([]byte(*data), &p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ToIntID converts an interface to int id used for the id's in the database
+func ToIntID(id interface{}) (int, bool) {
+	switch t := id.(type) {
+	case string:
+		intID, err := strconv.Atoi(t)
+		if err != nil {
+			return 0, false
+		}
+		return intID, true
+	case float64:
+		return int(t), true
+	}
+
+	intID, err := strconv.Atoi(fmt.Sprintf("%v", id))
+	if err != nil {
+		return 0, false
+	}
+
+	return intID, true
+}
+
+// updateEntityColumns updates the given columns of an entity, all values are passed as bind parameters
+func (gdb *GostDatabase) updateEntityColumns(table string, updates map[string]interface{}, entityID int) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	columns := ""
+	prefix := ""
+	args := []interface{}{entityID}
+	for k, v := range updates {
+		args = append(args, v)
+		columns += fmt.Sprintf("%s%s = ?%v", prefix, k, len(args))
+		prefix = ", "
+	}
+
+	sql := fmt.Sprintf("UPDATE %s SET %s WHERE id = ?1", table, columns)
+	_, err := gdb.statements(gdb.Db).Exec(sql, args...)
+	return err
+}
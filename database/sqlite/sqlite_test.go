@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	entities "github.com/gost/core"
+	gostLog "github.com/gost/server/log"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithContextLogsStatementsWithRequestID(t *testing.T) {
+	// arrange
+	l, _ := gostLog.InitializeLogger(nil, "", new(log.JSONFormatter), true)
+	out := &bytes.Buffer{}
+	l.Out = out
+	db := NewDatabase(":memory:", 200)
+	db.Migrate()
+	ctx := gostLog.ContextWithRequestID(context.Background(), "test-id")
+
+	// act
+	thing, err := db.WithContext(ctx).PostThing(&entities.Thing{Name: "thing", Description: "test thing"})
+
+	// assert
+	assert.NoError(t, err)
+	assert.NotNil(t, thing)
+	assert.Contains(t, out.String(), `"requestId":"test-id"`)
+	assert.Contains(t, out.String(), "INSERT INTO thing")
+}
+
+func TestPostObservationsRollbackKeepsObservations(t *testing.T) {
+	// arrange
+	db := NewDatabase(":memory:", 200)
+	db.Migrate()
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test thing"})
+	sensor, _ := db.PostSensor(&entities.Sensor{Name: "sensor", Description: "sensor", EncodingType: "application/pdf", Metadata: "metadata"})
+	op, _ := db.PostObservedProperty(&entities.ObservedProperty{Name: "temperature", Definition: "definition", Description: "description"})
+	ds := &entities.Datastream{Name: "datastream", Description: "datastream", ObservationType: "http://www.opengis.net/def/observationType/OGC-OM/2.0/OM_Measurement", Thing: thing, Sensor: sensor, ObservedProperty: op}
+	ds, _ = db.PostDatastream(ds)
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi", Description: "foi", EncodingType: entities.EncodingGeoJSON.Value, Feature: map[string]interface{}{"type": "Point", "coordinates": []interface{}{5.0, 52.0}}})
+	valid := &entities.Observation{PhenomenonTime: "2017-09-20T12:00:00Z", Result: []byte("20.5"), Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+	valid.Datastream.ID = ds.ID
+	valid.FeatureOfInterest.ID = foi.ID
+	invalid := &entities.Observation{PhenomenonTime: "2017-09-20T12:00:00Z", Result: []byte("21.5"), Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+	invalid.Datastream.ID = 999
+	invalid.FeatureOfInterest.ID = foi.ID
+
+	// act
+	created, errs := db.PostObservations([]*entities.Observation{valid, invalid}, true)
+
+	// assert
+	assert.Nil(t, created[0])
+	assert.Len(t, errs, 1)
+	assert.Nil(t, valid.ID, "observation of a rolled back batch should not get an id")
+	assert.NotNil(t, valid.Datastream, "observation of a rolled back batch should keep its Datastream")
+	assert.NotNil(t, valid.FeatureOfInterest)
+}
@@ -0,0 +1,209 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+func thingParamFactory(values map[string]interface{}) (entities.Entity, error) {
+	t := &entities.Thing{}
+	for property, value := range values {
+		if value == nil {
+			continue
+		}
+
+		switch property {
+		case "id":
+			t.ID = value
+		case "name":
+			t.Name = value.(string)
+		case "description":
+			t.Description = value.(string)
+		case "properties":
+			t.Properties, _ = value.(map[string]interface{})
+		}
+	}
+
+	return t, nil
+}
+
+// GetThing returns a thing entity based on id and query
+func (gdb *GostDatabase) GetThing(id interface{}, qo *odata.QueryOptions) (*entities.Thing, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, nil, intID, qo)
+	return processThing(gdb, query, args, qi)
+}
+
+// GetThingByDatastream retrieves the thing linked to a datastream
+func (gdb *GostDatabase) GetThingByDatastream(id interface{}, qo *odata.QueryOptions) (*entities.Thing, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Datastream does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.Datastream{}, intID, qo)
+	return processThing(gdb, query, args, qi)
+}
+
+// GetThingsByLocation retrieves the things linked to a location
+func (gdb *GostDatabase) GetThingsByLocation(id interface{}, qo *odata.QueryOptions) ([]*entities.Thing, int, bool, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, 0, false, gostErrors.NewRequestNotFound(errors.New("Location does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.Location{}, intID, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Thing{}, &entities.Location{}, intID, qo)
+	return processThings(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+// GetThingByHistoricalLocation retrieves the thing linked to a HistoricalLocation
+func (gdb *GostDatabase) GetThingByHistoricalLocation(id interface{}, qo *odata.QueryOptions) (*entities.Thing, error) {
+	intID, ok := ToIntID(id)
+	if !ok {
+		return nil, gostErrors.NewRequestNotFound(errors.New("HistoricalLocation does not exist"))
+	}
+
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, &entities.HistoricalLocation{}, intID, qo)
+	return processThing(gdb, query, args, qi)
+}
+
+// GetThings returns an array of things
+func (gdb *GostDatabase) GetThings(qo *odata.QueryOptions) ([]*entities.Thing, int, bool, error) {
+	query, args, qi := gdb.QueryBuilder.CreateQuery(&entities.Thing{}, nil, nil, qo)
+	countSQL, countArgs := gdb.QueryBuilder.CreateCountQuery(&entities.Thing{}, nil, nil, qo)
+	return processThings(gdb, query, args, qo, qi, countSQL, countArgs)
+}
+
+func processThing(gdb *GostDatabase, sql string, args []interface{}, qi *QueryParseInfo) (*entities.Thing, error) {
+	things, _, _, err := processThings(gdb, sql, args, nil, qi, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(things) == 0 {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Thing not found"))
+	}
+
+	return things[0], nil
+}
+
+func processThings(gdb *GostDatabase, sql string, args []interface{}, qo *odata.QueryOptions, qi *QueryParseInfo, countSQL string, countArgs []interface{}) ([]*entities.Thing, int, bool, error) {
+	data, hasNext, err := ExecuteSelect(gdb, qi, sql, args, qo)
+	if err != nil {
+		return nil, 0, hasNext, selectError(err)
+	}
+
+	things := make([]*entities.Thing, 0)
+	for _, d := range data {
+		entity := d.(*entities.Thing)
+		things = append(things, entity)
+	}
+
+	var count int
+	if len(countSQL) > 0 {
+		count, err = ExecuteSelectCount(gdb.Db, countSQL, countArgs, qo)
+		if err != nil {
+			return nil, 0, hasNext, fmt.Errorf("Error executing count %v", err)
+		}
+	}
+
+	return things, count, hasNext, nil
+}
+
+// PostThing receives a posted thing entity and adds it to the database
+// returns the created Thing including the generated id
+func (gdb *GostDatabase) PostThing(thing *entities.Thing) (*entities.Thing, error) {
+	jsonProperties, _ := json.Marshal(thing.Properties)
+	query := "INSERT INTO thing (name, description, properties) VALUES (?1, ?2, ?3)"
+	r, err := gdb.statements(gdb.Db).Exec(query, thing.Name, thing.Description, string(jsonProperties[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	thingID, _ := r.LastInsertId()
+	thing.ID = int(thingID)
+	return thing, nil
+}
+
+// PutThing receives a Thing entity and changes it in the database
+// returns the adapted Thing
+func (gdb *GostDatabase) PutThing(id interface{}, thing *entities.Thing) (*entities.Thing, error) {
+	return gdb.PatchThing(id, thing)
+}
+
+// PatchThing receives a to be patched Thing entity and changes it in the database
+// returns the patched Thing
+func (gdb *GostDatabase) PatchThing(id interface{}, thing *entities.Thing) (*entities.Thing, error) {
+	var err error
+	var ok bool
+	var intID int
+	updates := make(map[string]interface{})
+
+	thing.ID = id
+	if intID, ok = ToIntID(id); !ok || !gdb.ThingExists(intID) {
+		return nil, gostErrors.NewRequestNotFound(errors.New("Thing does not exist"))
+	}
+
+	if len(thing.Name) > 0 {
+		updates["name"] = thing.Name
+	}
+
+	if len(thing.Description) > 0 {
+		updates["description"] = thing.Description
+	}
+
+	if len(thing.Properties) > 0 {
+		jsonProperties, _ := json.Marshal(thing.Properties)
+		updates["properties"] = string(jsonProperties[:])
+	}
+
+	for _, l := range thing.Locations {
+		location, _ := gdb.GetLocation(l.ID, nil)
+		if location == nil {
+			continue
+		}
+
+		if _, err = gdb.statements(gdb.Db).Exec("DELETE FROM thing_to_location WHERE thing_id = ?1", intID); err != nil {
+			return nil, err
+		}
+
+		if _, err = gdb.statements(gdb.Db).Exec("INSERT INTO thing_to_location (thing_id, location_id) VALUES (?1, ?2)", intID, location.ID); err != nil {
+			return nil, err
+		}
+
+		hl := &entities.HistoricalLocation{
+			Thing:     thing,
+			Locations: []*entities.Location{location},
+		}
+
+		hl.ContainsMandatoryParams()
+		gdb.PostHistoricalLocation(hl)
+	}
+
+	if err = gdb.updateEntityColumns("thing", updates, intID); err != nil {
+		return nil, err
+	}
+
+	nt, _ := gdb.GetThing(intID, nil)
+	return nt, nil
+}
+
+// ThingExists checks if a thing is present in the database based on a given id
+func (gdb *GostDatabase) ThingExists(id interface{}) bool {
+	return EntityExists(gdb, id, "thing")
+}
+
+// DeleteThing tries to delete a Thing by the given id
+func (gdb *GostDatabase) DeleteThing(id interface{}) error {
+	return DeleteEntity(gdb, id, "thing")
+}
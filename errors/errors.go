@@ -30,6 +30,16 @@ func NewBadRequestError(err error) error {
 	return NewErrorWithStatusCode(err, http.StatusBadRequest)
 }
 
+// NewUnauthorizedError creates an apiError with status code 401.
+func NewUnauthorizedError(err error) error {
+	return NewErrorWithStatusCode(err, http.StatusUnauthorized)
+}
+
+// NewForbiddenError creates an apiError with status code 403.
+func NewForbiddenError(err error) error {
+	return NewErrorWithStatusCode(err, http.StatusForbidden)
+}
+
 // NewConflictRequestError creates an apiError with status code 409.
 func NewConflictRequestError(err error) error {
 	return NewErrorWithStatusCode(err, http.StatusConflict)
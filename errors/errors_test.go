@@ -24,6 +24,8 @@ func TestRequestStatusCodes(t *testing.T) {
 	notimplementederror := NewRequestNotImplemented(errors.New("notimplemented"))
 	notallowederror := NewRequestMethodNotAllowed(errors.New("notallowed"))
 	internalservererror := NewRequestInternalServerError(errors.New("internalserver"))
+	unauthorizederror := NewUnauthorizedError(errors.New("unauthorized"))
+	forbiddenerror := NewForbiddenError(errors.New("forbidden"))
 
 	// assert
 	assert.Equal(t, "bad", badrequesterror.Error())
@@ -32,5 +34,7 @@ func TestRequestStatusCodes(t *testing.T) {
 	assert.Equal(t, "notimplemented", notimplementederror.Error())
 	assert.Equal(t, "notallowed", notallowederror.Error())
 	assert.Equal(t, "internalserver", internalservererror.Error())
+	assert.Equal(t, 401, unauthorizederror.(APIError).GetHTTPErrorStatusCode())
+	assert.Equal(t, 403, forbiddenerror.(APIError).GetHTTPErrorStatusCode())
 
 }
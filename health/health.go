@@ -0,0 +1,92 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Check returns an error when the component it checks is not available
+type Check func() error
+
+// Status values of a check and of the response
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Response is the body of /health and /ready, Checks holds the status or error of every check by name
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+var (
+	mutex  sync.RWMutex
+	checks []namedCheck
+)
+
+// Register adds a check to the health and readiness responses, a check with the same name is replaced
+func Register(name string, check Check) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, c := range checks {
+		if c.name == name {
+			checks[i].check = check
+			return
+		}
+	}
+
+	checks = append(checks, namedCheck{name: name, check: check})
+}
+
+// Run executes all checks, the status is StatusUnavailable when one of the checks fails
+func Run() *Response {
+	mutex.RLock()
+	list := append([]namedCheck(nil), checks...)
+	mutex.RUnlock()
+
+	response := &Response{Status: StatusOK, Checks: make(map[string]string)}
+	for _, c := range list {
+		if err := c.check(); err != nil {
+			response.Status = StatusUnavailable
+			response.Checks[c.name] = err.Error()
+			continue
+		}
+
+		response.Checks[c.name] = StatusOK
+	}
+
+	return response
+}
+
+// HealthHandler serves /health, it responds 200 OK as long as GOST is running and reports the state of
+// the checks so a failing database or MQTT connection does not get GOST restarted by a liveness probe
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	write(w, http.StatusOK, Run())
+}
+
+// ReadyHandler serves /ready, it responds 503 Service Unavailable when one of the checks fails so
+// no traffic is sent to GOST until the database and MQTT connection are available
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	response := Run()
+	status := http.StatusOK
+	if response.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	write(w, status, response)
+}
+
+func write(w http.ResponseWriter, status int, response *Response) {
+	b, _ := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	w.Write(b)
+}
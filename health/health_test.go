@@ -0,0 +1,45 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlers(t *testing.T) {
+	// arrange
+	connected := true
+	Register("database", func() error { return nil })
+	Register("mqtt", func() error {
+		if !connected {
+			return errors.New("not connected")
+		}
+		return nil
+	})
+
+	// act
+	healthy := httptest.NewRecorder()
+	HealthHandler(healthy, httptest.NewRequest("GET", "/health", nil))
+	ready := httptest.NewRecorder()
+	ReadyHandler(ready, httptest.NewRequest("GET", "/ready", nil))
+	connected = false
+	degraded := httptest.NewRecorder()
+	HealthHandler(degraded, httptest.NewRequest("GET", "/health", nil))
+	notReady := httptest.NewRecorder()
+	ReadyHandler(notReady, httptest.NewRequest("GET", "/ready", nil))
+	response := Response{}
+	json.Unmarshal(notReady.Body.Bytes(), &response)
+
+	// assert
+	assert.Equal(t, http.StatusOK, healthy.Code)
+	assert.Equal(t, http.StatusOK, ready.Code)
+	assert.Equal(t, http.StatusOK, degraded.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, notReady.Code)
+	assert.Equal(t, StatusUnavailable, response.Status)
+	assert.Equal(t, StatusOK, response.Checks["database"])
+	assert.Equal(t, "not connected", response.Checks["mqtt"])
+}
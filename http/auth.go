@@ -0,0 +1,41 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/auth"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/rest/writer"
+)
+
+// AuthHandler is a middleware function that authenticates the request and checks if the user is allowed
+// to execute the operation on the entity type before calling h, 401 is returned when the request is not
+// authenticated and 403 when the role of the user does not allow the operation
+func AuthHandler(h http.HandlerFunc, authenticator auth.Authenticator, operation models.HTTPOperation, entityType entities.EntityType, indentJSON bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := authenticator.Authenticate(r)
+		if err != nil {
+			// PostProcessHandler only copies the first value of a header so the challenges are combined
+			w.Header().Set("WWW-Authenticate", strings.Join(authenticator.Challenges(), ", "))
+			writer.SendError(w, []error{gostErrors.NewUnauthorizedError(err)}, indentJSON)
+			return
+		}
+
+		if !identity.IsAllowed(operation, entityType) {
+			if identity.Anonymous {
+				w.Header().Set("WWW-Authenticate", strings.Join(authenticator.Challenges(), ", "))
+				writer.SendError(w, []error{gostErrors.NewUnauthorizedError(auth.ErrNoCredentials)}, indentJSON)
+				return
+			}
+
+			writer.SendError(w, []error{gostErrors.NewForbiddenError(errors.New("Operation not allowed"))}, indentJSON)
+			return
+		}
+
+		h(w, r)
+	}
+}
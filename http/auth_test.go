@@ -0,0 +1,70 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/auth"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAuthenticator struct {
+	identity *auth.Identity
+}
+
+func (a *testAuthenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	if a.identity == nil {
+		return nil, auth.ErrNoCredentials
+	}
+
+	return a.identity, nil
+}
+
+func (a *testAuthenticator) AuthenticateCredentials(name, password string) (*auth.Identity, error) {
+	return a.Authenticate(nil)
+}
+
+func (a *testAuthenticator) Challenges() []string {
+	return []string{`Basic realm="GOST"`}
+}
+
+func serveAuthHandler(identity *auth.Identity, operation models.HTTPOperation) *httptest.ResponseRecorder {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(string(operation), "/v1.0/things", nil)
+	AuthHandler(h, &testAuthenticator{identity: identity}, operation, entities.EntityTypeThing, false)(rec, req)
+	return rec
+}
+
+func TestAuthHandlerAllowed(t *testing.T) {
+	// act
+	rec := serveAuthHandler(&auth.Identity{Name: "admin", Roles: []auth.Role{auth.RoleAdmin}}, models.HTTPOperationDelete)
+
+	// assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuthHandlerUnauthorized(t *testing.T) {
+	// act
+	rec := serveAuthHandler(nil, models.HTTPOperationGet)
+
+	// assert
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Basic realm="GOST"`, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestAuthHandlerForbidden(t *testing.T) {
+	// act
+	rec := serveAuthHandler(&auth.Identity{Name: "reader", Roles: []auth.Role{auth.RoleRead}}, models.HTTPOperationDelete)
+	anonymous := serveAuthHandler(&auth.Identity{Roles: []auth.Role{auth.RoleRead}, Anonymous: true}, models.HTTPOperationDelete)
+
+	// assert
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/gost/server/auth"
 	odata "github.com/gost/server/sensorthings/odata"
 
 	gostLog "github.com/gost/server/log"
@@ -19,19 +20,19 @@ import (
 var logger *log.Entry
 
 func setupLogger() {
-	l, err := gostLog.GetLoggerInstance()
+	l, err := gostLog.GetPackageLogger("gost.server.http")
 	if err != nil {
 		log.Error(err)
 	}
 
-	//Setting default fields for main logger
-	logger = l.WithFields(log.Fields{"package": "gost.server.http"})
+	logger = l
 }
 
 // Server interface for starting and stopping the HTTP server
 type Server interface {
 	Start()
 	Stop()
+	Shutdown(ctx context.Context) error
 }
 
 // GostServer is the type that contains all of the relevant information to set
@@ -46,11 +47,22 @@ type GostServer struct {
 	httpServer *http.Server
 }
 
-// CreateServer initialises a new GOST HTTPServer based on the given parameters
+// CreateServer initialises a new GOST HTTPServer based on the given parameters, requests are authenticated
+// when auth is enabled in the configuration of the api
 func CreateServer(host string, port int, api *models.API, https bool, httpsCert, httpsKey string) Server {
 	setupLogger()
 	a := *api
-	router := CreateRouter(api)
+	authenticator, err := auth.NewAuthenticator(a.GetConfig().Auth)
+	if err != nil {
+		logger.Fatalf("Unable to set up authentication: %v", err)
+	}
+
+	router := CreateRouter(api, authenticator)
+	handler := PostProcessHandler(RequestErrorHandler(LowerCaseURI(router)), a.GetConfig().Server.ExternalURI)
+	if a.GetConfig().Logger.AccessLog {
+		handler = AccessLogHandler(handler)
+	}
+
 	return &GostServer{
 		host:      host,
 		port:      port,
@@ -60,7 +72,7 @@ func CreateServer(host string, port int, api *models.API, https bool, httpsCert,
 		httpsKey:  httpsKey,
 		httpServer: &http.Server{
 			Addr:         fmt.Sprintf("%s:%s", host, strconv.Itoa(port)),
-			Handler:      PostProcessHandler(RequestErrorHandler(LowerCaseURI(router)), a.GetConfig().Server.ExternalURI),
+			Handler:      RequestIDHandler(handler),
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 30 * time.Second,
 		},
@@ -83,17 +95,26 @@ func (s *GostServer) Start() {
 		err = s.httpServer.ListenAndServe()
 	}
 
-	if err != nil {
+	// Start returns ErrServerClosed as soon as Shutdown is called
+	if err != nil && err != http.ErrServerClosed {
 		logger.Panicf("GOST server not properly stopped: %v", err)
 	}
 }
 
 // Stop command to stop the GOST HTTP server
 func (s *GostServer) Stop() {
-	if s.httpServer != nil {
-		logger.Info("Stopping HTTP(S) Server")
-		s.httpServer.Shutdown(context.Background())
+	s.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting new connections and waits until the requests being handled are finished, the
+// error of ctx is returned when ctx is done before all requests are finished
+func (s *GostServer) Shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
 	}
+
+	logger.Info("Stopping HTTP(S) Server")
+	return s.httpServer.Shutdown(ctx)
 }
 
 // RequestErrorHandler is a middleware function that lower cases the url path
@@ -157,61 +178,47 @@ func LowerCaseURI(h http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
-// PostProcessHandler runs after all the other handlers and can be used to modify
-// the response. In this case modify links (due to proxy running) or handle CORS functionality
-// Basically we catch all the response using httptest.NewRecorder (headers + body), modify and
-// write to response. Is this a right approach?
+// PostProcessHandler runs after all the other handlers, the response is written directly to w so large
+// responses are streamed to the client instead of being buffered. Links are created with the external uri
+// of the server when the entities are retrieved so the response body does not need to be modified, the
+// external uri of a request forwarded by a proxy is stored in the context of the request
 func PostProcessHandler(h http.Handler, externalURI string) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-
 		if logger.Logger.Level == log.DebugLevel {
-			logger.Debugf("%s start: %s", r.Method, r.URL.Path)
-			defer gostLog.DebugfWithElapsedTime(logger, time.Now(), "%s done: %s", r.Method, r.URL.Path)
+			l := gostLog.WithRequestID(logger, gostLog.RequestIDFromContext(r.Context()))
+			l.Debugf("%s start: %s", r.Method, r.URL.Path)
+			defer gostLog.DebugfWithElapsedTime(l, time.Now(), "%s done: %s", r.Method, r.URL.Path)
 		}
 
-		origURI := externalURI
-		forwardedURI := r.Header.Get("X-Forwarded-For")
-
-		rec := httptest.NewRecorder()
-
-		// first run the next handler and get results
-		h.ServeHTTP(rec, r)
-
-		// read response body and replace links
-		bytes := rec.Body.Bytes()
-		s := string(bytes)
-		if len(s) > 0 {
-			if len(forwardedURI) > 0 {
-				// if both are changed (X-Forwarded-For and External uri environment variabele) use the last one
-				if origURI == "http://localhost:8080/" {
-					s = strings.Replace(s, "localhost", forwardedURI, -1)
-				}
-			}
-
+		if uri := forwardedExternalURI(r, externalURI); len(uri) > 0 {
+			r = r.WithContext(models.ContextWithExternalURI(r.Context(), uri))
 		}
-		// handle headers too...
-		for k, v := range rec.HeaderMap {
-			val := v[0]
-
-			// if there is a location header and a proxy running, change
-			// the location url too
-			if k == "Location" && len(forwardedURI) > 0 {
-				if origURI == "http://localhost:8080/" {
-					logger.Debugf("proxy + location header detected. forwarded uri: %s", forwardedURI)
-					// idea: run net.LookupAddr(forwardeduri) to get hostname instead of ip address?
-					val = strings.Replace(val, "localhost", forwardedURI, -1)
-				}
-			}
 
-			// add the header to response
-			w.Header().Add(k, val)
-		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
 
-		// handle status code
-		w.WriteHeader(rec.Code)
+// forwardedExternalURI returns the external uri of a request forwarded by a proxy when the external uri of
+// the server is left on localhost, localhost is replaced by the X-Forwarded-Host header or the first address
+// in the X-Forwarded-For header and the scheme by X-Forwarded-Proto. An empty string is returned otherwise
+func forwardedExternalURI(r *http.Request, externalURI string) string {
+	u, err := url.Parse(externalURI)
+	if err != nil || u.Hostname() != "localhost" {
+		return ""
+	}
 
-		// write modified response
-		w.Write([]byte(s))
+	if host := r.Header.Get("X-Forwarded-Host"); len(host) > 0 {
+		u.Host = strings.TrimSpace(strings.Split(host, ",")[0])
+	} else if forwarded := r.Header.Get("X-Forwarded-For"); len(forwarded) > 0 {
+		u.Host = strings.Replace(u.Host, "localhost", strings.TrimSpace(strings.Split(forwarded, ",")[0]), 1)
+	} else {
+		return ""
 	}
-	return http.HandlerFunc(fn)
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		u.Scheme = proto
+	}
+
+	return u.String()
 }
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"bytes"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -8,8 +9,11 @@ import (
 
 	"github.com/gost/server/configuration"
 	"github.com/gost/server/database/postgis"
+	gostLog "github.com/gost/server/log"
 	"github.com/gost/server/mqtt"
 	"github.com/gost/server/sensorthings/api"
+	"github.com/gost/server/sensorthings/models"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,8 +62,8 @@ func TestLowerCaseURI(t *testing.T) {
 
 func TestPostProcessHandler(t *testing.T) {
 	n := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		rw.WriteHeader(http.StatusTeapot)
 		rw.Header().Add("Location", "tea location")
+		rw.WriteHeader(http.StatusTeapot)
 		rw.Write([]byte("hello teapot"))
 	})
 	ts := httptest.NewServer(PostProcessHandler(n, "http://localhost:8080/"))
@@ -107,3 +111,70 @@ func createTestServer(port int, https bool) Server {
 	stAPI := api.NewAPI(database, cfg, mqttServer)
 	return CreateServer("localhost", port, &stAPI, https, "", "")
 }
+
+func TestPostProcessHandlerForwardedExternalURI(t *testing.T) {
+	// arrange
+	uris := make([]string, 0)
+	n := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		uris = append(uris, models.ExternalURIFromContext(req.Context()))
+	})
+	forwardedFor := httptest.NewRequest("GET", "/v1.0", nil)
+	forwardedFor.Header.Set("X-Forwarded-For", "gost.example.com, 10.0.0.1")
+	forwardedHost := httptest.NewRequest("GET", "/v1.0", nil)
+	forwardedHost.Header.Set("X-Forwarded-Host", "gost.example.com")
+	forwardedHost.Header.Set("X-Forwarded-Proto", "https")
+
+	// act
+	PostProcessHandler(n, "http://localhost:8080/").ServeHTTP(httptest.NewRecorder(), forwardedFor)
+	PostProcessHandler(n, "http://localhost:8080/").ServeHTTP(httptest.NewRecorder(), forwardedHost)
+	PostProcessHandler(n, "https://gost.example.org").ServeHTTP(httptest.NewRecorder(), forwardedHost)
+	PostProcessHandler(n, "http://localhost:8080/").ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1.0", nil))
+
+	// assert
+	assert.Equal(t, []string{"http://gost.example.com:8080/", "https://gost.example.com/", "", ""}, uris)
+}
+
+func TestRequestIDHandler(t *testing.T) {
+	// arrange
+	requestID := ""
+	n := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requestID = gostLog.RequestIDFromContext(req.Context())
+	})
+	generated := httptest.NewRecorder()
+	given := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1.0", nil)
+	req.Header.Set(gostLog.RequestIDHeader, "proxy-id")
+
+	// act
+	RequestIDHandler(n).ServeHTTP(generated, httptest.NewRequest("GET", "/v1.0", nil))
+	generatedID := requestID
+	RequestIDHandler(n).ServeHTTP(given, req)
+
+	// assert
+	assert.NotEmpty(t, generatedID)
+	assert.Equal(t, generatedID, generated.Header().Get(gostLog.RequestIDHeader))
+	assert.Equal(t, "proxy-id", requestID)
+	assert.Equal(t, "proxy-id", given.Header().Get(gostLog.RequestIDHeader))
+}
+
+func TestAccessLogHandler(t *testing.T) {
+	// arrange
+	l, _ := gostLog.InitializeLogger(nil, "", new(log.JSONFormatter), false)
+	setupLogger()
+	out := &bytes.Buffer{}
+	l.Out = out
+	n := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+		rw.Write([]byte("hello teapot"))
+	})
+
+	// act
+	RequestIDHandler(AccessLogHandler(n)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1.0/things", nil))
+
+	// assert
+	assert.Contains(t, out.String(), `"method":"GET"`)
+	assert.Contains(t, out.String(), `"path":"/v1.0/things"`)
+	assert.Contains(t, out.String(), `"status":418`)
+	assert.Contains(t, out.String(), `"bytes":12`)
+	assert.Contains(t, out.String(), `"requestId"`)
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	gostLog "github.com/gost/server/log"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRequestIDLength is the maximum length of a request id set by a client or proxy, longer ids are replaced
+const maxRequestIDLength = 128
+
+// RequestIDHandler is a middleware function that gives every request an id, the id is returned in the
+// X-Request-ID header and stored in the context of the request so it can be added to the log lines
+// written while handling the request. An id set by the client or a proxy in the header is kept
+func RequestIDHandler(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(gostLog.RequestIDHeader)
+		if len(id) == 0 || len(id) > maxRequestIDLength {
+			id = gostLog.NewRequestID()
+		}
+
+		w.Header().Set(gostLog.RequestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(gostLog.ContextWithRequestID(r.Context(), id)))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// AccessLogHandler is a middleware function that logs a line for every handled request with the method,
+// path, status code, duration and number of bytes written
+func AccessLogHandler(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		path := r.URL.Path
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+
+		gostLog.WithRequestID(logger, gostLog.RequestIDFromContext(r.Context())).WithFields(log.Fields{
+			"method":   r.Method,
+			"path":     path,
+			"status":   sw.status,
+			"duration": time.Since(start).Seconds(),
+			"bytes":    sw.bytes,
+		}).Info("request handled")
+	}
+
+	return http.HandlerFunc(fn)
+}
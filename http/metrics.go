@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gost/server/metrics"
+)
+
+// statusWriter keeps the status code and number of bytes written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// MetricsHandler is a middleware function that counts the requests handled by h and the time it took to
+// handle them, requests are labelled with the path of the operation instead of the request path so
+// the number of series does not grow with the ids in the requests
+func MetricsHandler(h http.HandlerFunc, method, operation string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h(sw, r)
+
+		metrics.HTTPRequestDuration.ObserveSince(start, method, operation)
+		metrics.HTTPRequests.Inc(method, operation, strconv.Itoa(sw.status))
+	}
+}
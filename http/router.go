@@ -5,12 +5,18 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/gost/server/auth"
+	"github.com/gost/server/health"
+	"github.com/gost/server/metrics"
 	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/rest/endpoint"
 )
 
-// CreateRouter creates a new mux.Router and sets up all endpoints defined in the SensorThings api
-func CreateRouter(api *models.API) *mux.Router {
+// CreateRouter creates a new mux.Router and sets up all endpoints defined in the SensorThings api,
+// when authenticator is not nil every request is authenticated and authorized before it is handled.
+// Every request is handled by a copy of the api bound to the request so its log lines hold the request id.
+// The health, readiness and metrics endpoints are added without authentication for monitoring
+func CreateRouter(api *models.API, authenticator auth.Authenticator) *mux.Router {
 	// Note: tried julienschmidt/httprouter instead of gorilla/mux but had some
 	// problems with interfering endpoints cause of the wildcard used for the (id) in requests
 	a := *api
@@ -21,12 +27,23 @@ func CreateRouter(api *models.API) *mux.Router {
 		op := e
 		operation := op.Operation
 		method := fmt.Sprintf("%s", operation.OperationType)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			requestAPI := a.WithContext(r.Context())
+			operation.Handler(w, r, &op.Endpoint, &requestAPI)
+		}
+
+		if authenticator != nil {
+			handler = AuthHandler(handler, authenticator, operation.OperationType, op.Endpoint.GetEntityType(), a.GetConfig().Server.IndentedJSON)
+		}
+
 		router.Methods(method).
 			Path(operation.Path).
-			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				operation.Handler(w, r, &op.Endpoint, api)
-			})
+			HandlerFunc(MetricsHandler(handler, method, operation.Path))
 	}
 
+	router.Methods("GET").Path("/health").HandlerFunc(health.HealthHandler)
+	router.Methods("GET").Path("/ready").HandlerFunc(health.ReadyHandler)
+	router.Methods("GET").Path("/metrics").HandlerFunc(metrics.Handler())
+
 	return router
 }
@@ -22,7 +22,7 @@ func setup() {
 	mqttServer := mqtt.CreateMQTTClient(configuration.MQTTConfig{})
 	database := postgis.NewDatabase("", 123, "", "", "", "", false, 50, 100, 200)
 	a := api.NewAPI(database, cfg, mqttServer)
-	router = CreateRouter(&a)
+	router = CreateRouter(&a, nil)
 
 	//The response recorder used to record HTTP responses
 	respRec = httptest.NewRecorder()
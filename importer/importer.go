@@ -0,0 +1,387 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	entities "github.com/gost/core"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/metrics"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultBatchSize is the number of rows posted to the API at once and checkpointed afterwards
+const DefaultBatchSize = 1000
+
+// maxLineSize is the maximum length of a line in an import file
+const maxLineSize = 1024 * 1024
+
+var logger *log.Entry
+
+func setupLogger() {
+	l, err := gostLog.GetPackageLogger("gost.server.importer")
+	if err != nil {
+		log.Error(err)
+	}
+
+	logger = l
+}
+
+// Report holds the number of lines read from an import file, Skipped holds the lines that were already
+// imported by a previous run that was interrupted
+type Report struct {
+	Lines    int
+	Imported int
+	Rejected int
+	Skipped  int
+}
+
+// Importer loads historic Observations from a CSV or NDJSON file through the API, the last imported line is
+// stored in <file>.state after every batch so an interrupted import continues where it stopped. Rejected rows
+// are logged and written to <file>.rejected with their line number
+type Importer struct {
+	api         models.API
+	path        string
+	BatchSize   int
+	datastreams map[string]datastreamRef
+}
+
+// datastreamRef is the resolved id of a Datastream reference in an import file or the reason it could not be resolved
+type datastreamRef struct {
+	id  interface{}
+	err error
+}
+
+// pendingRow is a parsed Row that is waiting to be posted
+type pendingRow struct {
+	line        int
+	datastream  interface{}
+	observation *entities.Observation
+}
+
+// rejection is a line of the import file that could not be imported
+type rejection struct {
+	line int
+	err  string
+}
+
+// NewImporter creates an Importer for the CSV (.csv) or NDJSON (any other extension) file at path
+func NewImporter(api models.API, path string) *Importer {
+	setupLogger()
+	return &Importer{
+		api:         api,
+		path:        path,
+		BatchSize:   DefaultBatchSize,
+		datastreams: make(map[string]datastreamRef),
+	}
+}
+
+// StatePath returns the path of the file holding the last imported line
+func (i *Importer) StatePath() string {
+	return i.path + ".state"
+}
+
+// RejectedPath returns the path of the file holding the rejected lines
+func (i *Importer) RejectedPath() string {
+	return i.path + ".rejected"
+}
+
+// Run imports the file, lines imported by a previous run are skipped
+func (i *Importer) Run() (*Report, error) {
+	f, err := os.Open(i.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resumeAt, err := i.readState()
+	if err != nil {
+		return nil, err
+	}
+
+	if resumeAt > 0 {
+		logger.Infof("Resuming import of %s after line %d", i.path, resumeAt)
+	}
+
+	var p parser = &ndjsonParser{}
+	if strings.EqualFold(filepath.Ext(i.path), ".csv") {
+		p = &csvParser{}
+	}
+
+	report := &Report{}
+	batch := make([]pendingRow, 0, i.BatchSize)
+	rejected := make([]rejection, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+
+		// the header of a CSV file is parsed again when resuming
+		header := isHeader(p)
+		if line <= resumeAt && !header {
+			report.Skipped++
+			continue
+		}
+
+		row, err := p.parse(text)
+		if err != nil {
+			if header {
+				return nil, err
+			}
+
+			rejected = append(rejected, i.reject(line, err))
+			continue
+		}
+
+		if row == nil {
+			continue
+		}
+
+		report.Lines++
+		pending, err := i.pendingRow(line, row)
+		if err != nil {
+			rejected = append(rejected, i.reject(line, err))
+			continue
+		}
+
+		batch = append(batch, pending)
+		if len(batch) >= i.BatchSize {
+			rejected = append(rejected, i.post(batch, report)...)
+			if err = i.checkpoint(line, rejected, report); err != nil {
+				return nil, err
+			}
+
+			batch = batch[:0]
+			rejected = rejected[:0]
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	rejected = append(rejected, i.post(batch, report)...)
+	if line > resumeAt {
+		if err = i.checkpoint(line, rejected, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// isHeader returns true when the next line read by the parser is the header of a CSV file
+func isHeader(p parser) bool {
+	c, ok := p.(*csvParser)
+	return ok && c.columns == nil
+}
+
+// pendingRow validates a Row and converts it into an Observation for its Datastream
+func (i *Importer) pendingRow(line int, row *Row) (pendingRow, error) {
+	if len(row.Datastream) == 0 {
+		return pendingRow{}, errors.New("missing datastream")
+	}
+
+	if len(row.PhenomenonTime) == 0 {
+		return pendingRow{}, errors.New("missing phenomenonTime")
+	}
+
+	// a phenomenonTime can be an instant or an interval start/end
+	for _, t := range strings.Split(row.PhenomenonTime, "/") {
+		if _, err := time.Parse(time.RFC3339Nano, t); err != nil {
+			return pendingRow{}, fmt.Errorf("invalid phenomenonTime %s", row.PhenomenonTime)
+		}
+	}
+
+	ref := i.resolveDatastream(row.Datastream)
+	if ref.err != nil {
+		return pendingRow{}, ref.err
+	}
+
+	result, err := json.Marshal(row.Result)
+	if err != nil {
+		return pendingRow{}, fmt.Errorf("invalid result: %v", err)
+	}
+
+	o := &entities.Observation{PhenomenonTime: row.PhenomenonTime, Result: result}
+	if len(row.FeatureOfInterest) > 0 {
+		o.FeatureOfInterest = &entities.FeatureOfInterest{}
+		o.FeatureOfInterest.ID = row.FeatureOfInterest
+	}
+
+	return pendingRow{line: line, datastream: ref.id, observation: o}, nil
+}
+
+// resolveDatastream returns the id of the Datastream with the given id or name, the result is cached
+func (i *Importer) resolveDatastream(ref string) datastreamRef {
+	if resolved, ok := i.datastreams[ref]; ok {
+		return resolved
+	}
+
+	var resolved datastreamRef
+	if ds, err := i.api.GetDatastream(ref, nil, ""); err == nil {
+		resolved.id = ds.ID
+	} else {
+		resolved.id, resolved.err = i.datastreamByName(ref)
+	}
+
+	i.datastreams[ref] = resolved
+	return resolved
+}
+
+// datastreamByName returns the id of the only Datastream with the given name
+func (i *Importer) datastreamByName(name string) (interface{}, error) {
+	query := url.Values{}
+	query.Set("$filter", fmt.Sprintf("name eq '%s'", strings.Replace(name, "'", "''", -1)))
+	query.Set("$top", "2")
+	qo, err := odata.ParseURLQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("datastream %s not found", name)
+	}
+
+	ar, err := i.api.GetDatastreams(qo, "")
+	if err != nil {
+		return nil, fmt.Errorf("datastream %s not found: %v", name, err)
+	}
+
+	var datastreams []*entities.Datastream
+	if ar.Data != nil {
+		datastreams, _ = (*ar.Data).([]*entities.Datastream)
+	}
+
+	switch len(datastreams) {
+	case 0:
+		return nil, fmt.Errorf("datastream %s not found", name)
+	case 1:
+		return datastreams[0].ID, nil
+	}
+
+	return nil, fmt.Errorf("datastream name %s is not unique, use the id", name)
+}
+
+// post creates the Observations of the batch in a single CreateObservations request and returns the rows
+// that were rejected by the API
+func (i *Importer) post(batch []pendingRow, report *Report) []rejection {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// group the observations per datastream, lines holds the line of every posted observation in request order
+	data := &entities.CreateObservations{Datastreams: make([]*entities.Datastream, 0)}
+	byDatastream := make(map[string]*entities.Datastream)
+	rows := make(map[*entities.Datastream][]int)
+	for _, r := range batch {
+		key := fmt.Sprintf("%v", r.datastream)
+		d, ok := byDatastream[key]
+		if !ok {
+			d = &entities.Datastream{Observations: make([]*entities.Observation, 0)}
+			d.ID = r.datastream
+			byDatastream[key] = d
+			data.Datastreams = append(data.Datastreams, d)
+		}
+
+		d.Observations = append(d.Observations, r.observation)
+		rows[d] = append(rows[d], r.line)
+	}
+
+	lines := make([]int, 0, len(batch))
+	for _, d := range data.Datastreams {
+		lines = append(lines, rows[d]...)
+	}
+
+	rejected := make([]rejection, 0)
+	results, errs := i.api.PostCreateObservations(data, models.CreateObservationsModeBestEffort)
+	if len(errs) > 0 {
+		for _, line := range lines {
+			rejected = append(rejected, i.reject(line, errs[0]))
+		}
+
+		return rejected
+	}
+
+	prefix := i.api.GetConfig().GetExternalServerURI() + "/"
+	for n, result := range results {
+		if strings.HasPrefix(result, prefix) {
+			report.Imported++
+			metrics.ObservationsIngested.Inc(metrics.TransportImport)
+			continue
+		}
+
+		rejected = append(rejected, i.reject(lines[n], errors.New(result)))
+	}
+
+	return rejected
+}
+
+func (i *Importer) reject(line int, err error) rejection {
+	logger.Warnf("line %d: %v", line, err)
+	return rejection{line: line, err: err.Error()}
+}
+
+// checkpoint appends the rejected lines to the rejected file and stores line as last imported line
+func (i *Importer) checkpoint(line int, rejected []rejection, report *Report) error {
+	report.Rejected += len(rejected)
+	if len(rejected) > 0 {
+		f, err := os.OpenFile(i.RejectedPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		w := csv.NewWriter(f)
+		for _, r := range rejected {
+			w.Write([]string{strconv.Itoa(r.line), r.err})
+		}
+
+		w.Flush()
+		if err = w.Error(); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err = f.Close(); err != nil {
+			return err
+		}
+	}
+
+	// write to a temporary file first so an interruption never leaves a corrupt state file
+	tmp := i.StatePath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(line)), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, i.StatePath())
+}
+
+// readState returns the last imported line of a previous run or 0 when the file was not imported before
+func (i *Importer) readState() (int, error) {
+	b, err := ioutil.ReadFile(i.StatePath())
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	line, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid import state in %s: %v", i.StatePath(), err)
+	}
+
+	return line, nil
+}
@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/database/memory"
+	"github.com/gost/server/mqtt"
+	"github.com/gost/server/sensorthings/api"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestAPI() (models.API, models.Database, *entities.Datastream, *entities.FeatureOfInterest) {
+	db := memory.NewDatabase(200)
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test"})
+	sensor, _ := db.PostSensor(&entities.Sensor{Name: "sensor"})
+	op, _ := db.PostObservedProperty(&entities.ObservedProperty{Name: "temperature"})
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+	ds := &entities.Datastream{Name: "temperature", Thing: &entities.Thing{}, Sensor: &entities.Sensor{}, ObservedProperty: &entities.ObservedProperty{}}
+	ds.Thing.ID = thing.ID
+	ds.Sensor.ID = sensor.ID
+	ds.ObservedProperty.ID = op.ID
+	ds, _ = db.PostDatastream(ds)
+
+	cfg := configuration.Config{Server: configuration.ServerConfig{ExternalURI: "http://localhost:8080"}}
+	a := api.NewAPI(db, cfg, mqtt.CreateMQTTClient(configuration.MQTTConfig{}))
+	return a, db, ds, foi
+}
+
+func TestParseNDJSON(t *testing.T) {
+	// act
+	row, err := (&ndjsonParser{}).parse(`{"datastream": 1, "phenomenonTime": "2017-01-01T00:00:00Z", "result": 20.5}`)
+	_, missingErr := (&ndjsonParser{}).parse(`{"datastream": 1, "phenomenonTime": "2017-01-01T00:00:00Z"}`)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "1", row.Datastream)
+	assert.Equal(t, "2017-01-01T00:00:00Z", row.PhenomenonTime)
+	assert.Equal(t, "20.5", fmt.Sprintf("%v", row.Result))
+	assert.Equal(t, "", row.FeatureOfInterest)
+	assert.NotNil(t, missingErr)
+}
+
+func TestParseCSV(t *testing.T) {
+	// arrange
+	p := &csvParser{}
+
+	// act
+	header, headerErr := p.parse("Result,Datastream,phenomenonTime")
+	number, _ := p.parse("20.5,1,2017-01-01T00:00:00Z")
+	boolean, _ := p.parse("true,1,2017-01-01T00:00:00Z")
+	text, _ := p.parse(`"open, closed",1,2017-01-01T00:00:00Z`)
+	_, invalidHeaderErr := (&csvParser{}).parse("datastream,result")
+
+	// assert
+	assert.Nil(t, header)
+	assert.Nil(t, headerErr)
+	assert.Equal(t, 20.5, number.Result)
+	assert.Equal(t, "1", number.Datastream)
+	assert.Equal(t, true, boolean.Result)
+	assert.Equal(t, "open, closed", text.Result)
+	assert.NotNil(t, invalidHeaderErr)
+}
+
+func TestPendingRowResult(t *testing.T) {
+	// arrange
+	a, _, ds, _ := createTestAPI()
+	importer := NewImporter(a, "observations.csv")
+	p := &csvParser{}
+	p.parse("datastream,phenomenonTime,result")
+	number, _ := p.parse(fmt.Sprintf("%v,2017-01-01T00:00:00Z,20.5", ds.ID))
+	text, _ := (&ndjsonParser{}).parse(fmt.Sprintf(`{"datastream": %v, "phenomenonTime": "2017-01-01T00:00:00Z", "result": {"state": "open"}}`, ds.ID))
+
+	// act
+	numberRow, err := importer.pendingRow(2, number)
+	textRow, err2 := importer.pendingRow(1, text)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Nil(t, err2)
+	assert.Equal(t, "20.5", string(numberRow.observation.Result))
+	assert.JSONEq(t, `{"state": "open"}`, string(textRow.observation.Result))
+}
+
+func TestRun(t *testing.T) {
+	// arrange
+	a, db, ds, foi := createTestAPI()
+	dir, _ := ioutil.TempDir("", "gost-import")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "observations.csv")
+	lines := []string{
+		"datastream,phenomenonTime,result,featureOfInterest",
+		fmt.Sprintf("%v,2017-01-01T00:00:00Z,20.5,%v", ds.ID, foi.ID),
+		fmt.Sprintf("temperature,2017-01-01T01:00:00Z,21,%v", foi.ID),
+		fmt.Sprintf("unknown,2017-01-01T02:00:00Z,22,%v", foi.ID),
+		fmt.Sprintf("%v,yesterday,23,%v", ds.ID, foi.ID),
+	}
+	ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	importer := NewImporter(a, path)
+	importer.BatchSize = 2
+
+	// act
+	report, err := importer.Run()
+	rerun, rerunErr := NewImporter(a, path).Run()
+	state, _ := ioutil.ReadFile(importer.StatePath())
+	rejected, _ := ioutil.ReadFile(importer.RejectedPath())
+	_, count, _, _ := db.GetObservationsByDatastream(ds.ID, &odata.QueryOptions{})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, &Report{Lines: 4, Imported: 2, Rejected: 2}, report)
+	assert.Equal(t, "5", string(state))
+	assert.Equal(t, "4,datastream unknown not found\n5,invalid phenomenonTime yesterday\n", string(rejected))
+	assert.Nil(t, rerunErr)
+	assert.Equal(t, &Report{Skipped: 4}, rerun)
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Row is an Observation read from an import file, Datastream is the id or name of its Datastream and
+// FeatureOfInterest the optional id of its FeatureOfInterest
+type Row struct {
+	Datastream        string
+	PhenomenonTime    string
+	Result            interface{}
+	FeatureOfInterest string
+}
+
+// parser converts a line of an import file into a Row, a nil Row without error is returned for lines
+// that hold no Observation such as the header of a CSV file
+type parser interface {
+	parse(line string) (*Row, error)
+}
+
+// ndjsonParser parses files with a JSON object per line such as
+// {"datastream": 1, "phenomenonTime": "2017-01-01T00:00:00Z", "result": 20.5, "featureOfInterest": 3}
+type ndjsonParser struct{}
+
+func (p *ndjsonParser) parse(line string) (*Row, error) {
+	var values map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader([]byte(line)))
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	row := &Row{Result: values["result"]}
+	row.Datastream = toString(values["datastream"])
+	row.PhenomenonTime = toString(values["phenomenonTime"])
+	row.FeatureOfInterest = toString(values["featureOfInterest"])
+	if _, ok := values["result"]; !ok {
+		return nil, errors.New("missing result")
+	}
+
+	return row, nil
+}
+
+// csvParser parses CSV files with a header naming the columns datastream, phenomenonTime, result and the
+// optional featureOfInterest in any order, results are converted to a number or boolean when possible
+type csvParser struct {
+	columns map[string]int
+}
+
+func (p *csvParser) parse(line string) (*Row, error) {
+	r := csv.NewReader(strings.NewReader(line))
+	r.FieldsPerRecord = -1
+	fields, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+
+	if p.columns == nil {
+		return nil, p.parseHeader(fields)
+	}
+
+	field := func(column string) string {
+		if i, ok := p.columns[column]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+
+		return ""
+	}
+
+	if _, ok := p.columns["result"]; !ok || p.columns["result"] >= len(fields) {
+		return nil, errors.New("missing result")
+	}
+
+	return &Row{
+		Datastream:        field("datastream"),
+		PhenomenonTime:    field("phenomenontime"),
+		Result:            csvResult(field("result")),
+		FeatureOfInterest: field("featureofinterest"),
+	}, nil
+}
+
+// parseHeader reads the positions of the columns from the header of the file
+func (p *csvParser) parseHeader(fields []string) error {
+	columns := make(map[string]int)
+	for i, f := range fields {
+		columns[strings.ToLower(strings.TrimSpace(f))] = i
+	}
+
+	for _, required := range []string{"datastream", "phenomenontime", "result"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("CSV header misses column %s, expected datastream,phenomenonTime,result[,featureOfInterest]", required)
+		}
+	}
+
+	p.columns = columns
+	return nil
+}
+
+// csvResult converts a CSV field into a number, boolean or string result
+func csvResult(field string) interface{} {
+	if f, err := strconv.ParseFloat(field, 64); err == nil {
+		return f
+	}
+
+	if b, err := strconv.ParseBool(field); err == nil {
+		return b
+	}
+
+	return field
+}
+
+func toString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%v", value))
+}
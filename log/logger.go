@@ -3,7 +3,10 @@ package log
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -11,7 +14,11 @@ import (
 
 var (
 	gostLogger *log.Logger
-	gostFile   *os.File
+	gostFile   io.WriteCloser
+	// packageLevels holds the levels set per package field, packageLoggers the loggers created for them
+	packageLevels  map[string]log.Level
+	packageLoggers map[string]*log.Logger
+	packageMutex   sync.Mutex
 )
 
 var (
@@ -19,6 +26,15 @@ var (
 	ErrLoggerNotInitialized = errors.New("LoggerNotInitialized")
 )
 
+// Options configures the logger, FileName is written to stdout when empty
+type Options struct {
+	FileName      string
+	Formatter     log.Formatter
+	Level         log.Level
+	PackageLevels map[string]log.Level
+	Rotation      Rotation
+}
+
 // GetLoggerInstance returns singleton instance of the logger
 func GetLoggerInstance() (*log.Logger, error) {
 	if gostLogger == nil {
@@ -28,36 +44,114 @@ func GetLoggerInstance() (*log.Logger, error) {
 	return gostLogger, nil
 }
 
+// NewFormatter returns the formatter for a log format, text (default) writes human readable lines and
+// json writes a JSON object per line with the fields of the entry for log collectors
+func NewFormatter(format string) (log.Formatter, error) {
+	switch strings.ToLower(format) {
+	case "", "text":
+		return &log.TextFormatter{FullTimestamp: true}, nil
+	case "json":
+		return &log.JSONFormatter{}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown log format %s, supported: text, json", format)
+}
+
+// ParseLevel parses a log level, an empty level is info or debug when verbose is set
+func ParseLevel(level string, verbose bool) (log.Level, error) {
+	if len(level) == 0 {
+		if verbose {
+			return log.DebugLevel, nil
+		}
+
+		return log.InfoLevel, nil
+	}
+
+	return log.ParseLevel(level)
+}
+
+// ParsePackageLevels parses the log levels by package field such as gost.server.http
+func ParsePackageLevels(levels map[string]string) (map[string]log.Level, error) {
+	parsed := make(map[string]log.Level)
+	for pkg, level := range levels {
+		l, err := log.ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid log level for %s: %v", pkg, err)
+		}
+
+		parsed[pkg] = l
+	}
+
+	return parsed, nil
+}
+
 // InitializeLogger with various properties
 func InitializeLogger(file *os.File, logFileName string, format log.Formatter, verboseFlag bool) (*log.Logger, error) {
+	level, _ := ParseLevel("", verboseFlag)
+	return Initialize(Options{FileName: logFileName, Formatter: format, Level: level})
+}
+
+// Initialize creates the logger, the log is written to <FileName>.log and rotated by the Rotation options
+// or to stdout when no file name is set or the file cannot be opened
+func Initialize(o Options) (*log.Logger, error) {
 	var err error
+	var out io.Writer = os.Stdout
 	gostLogger = log.New()
-	gostFile = file
+	gostFile = nil
 
-	if logFileName != "" {
-		gostFile, err = os.OpenFile(logFileName+".log", os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+	if o.FileName != "" {
+		var f *RotatingFile
+		f, err = OpenRotatingFile(o.FileName+".log", o.Rotation)
 		if err != nil {
 			fmt.Println("Log file cannot be opened")
-			gostFile.Close()
+		} else {
+			gostFile = f
+			out = f
 		}
 	}
 
-	if logFileName == "" || err != nil {
-		gostFile = os.Stdout
+	gostLogger.Out = out
+	if o.Formatter != nil {
+		gostLogger.Formatter = o.Formatter
+	}
+
+	gostLogger.Level = o.Level
+
+	packageMutex.Lock()
+	packageLevels = o.PackageLevels
+	packageLoggers = make(map[string]*log.Logger)
+	packageMutex.Unlock()
+
+	return gostLogger, err
+}
+
+// GetPackageLogger returns the logger entry of a package with the package field set, for example
+// gost.server.http. When a level is configured for the package the entry logs with that level
+func GetPackageLogger(pkg string) (*log.Entry, error) {
+	l, err := GetLoggerInstance()
+	fields := log.Fields{"package": pkg}
+	if err != nil {
+		return l.WithFields(fields), err
 	}
 
-	gostLogger.Out = gostFile
-	if format != nil {
-		gostLogger.Formatter = format
+	packageMutex.Lock()
+	defer packageMutex.Unlock()
+	level, ok := packageLevels[pkg]
+	if !ok {
+		return l.WithFields(fields), nil
 	}
 
-	if verboseFlag {
-		gostLogger.Level = log.DebugLevel
-	} else {
-		gostLogger.Level = log.InfoLevel
+	pl, ok := packageLoggers[pkg]
+	if !ok {
+		pl = log.New()
+		pl.Out = l.Out
+		pl.Formatter = l.Formatter
+		pl.Hooks = l.Hooks
+		pl.Level = level
+		packageLoggers[pkg] = pl
 	}
 
-	return gostLogger, err
+	return pl.WithFields(fields), nil
 }
 
 // DebugWithElapsedTime writes a new debug line, including a field with elapsed time
@@ -2,6 +2,7 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"testing"
@@ -93,3 +94,51 @@ func captureStdout(f func()) string {
 	io.Copy(&buf, r)
 	return buf.String()
 }
+
+func TestNewFormatter(t *testing.T) {
+	// act
+	text, textErr := NewFormatter("")
+	json, jsonErr := NewFormatter("JSON")
+	_, unknownErr := NewFormatter("xml")
+
+	// assert
+	assert.Nil(t, textErr)
+	assert.IsType(t, &log.TextFormatter{}, text)
+	assert.Nil(t, jsonErr)
+	assert.IsType(t, &log.JSONFormatter{}, json)
+	assert.NotNil(t, unknownErr)
+}
+
+func TestWithRequestID(t *testing.T) {
+	// arrange
+	testLogger, err = InitializeLogger(testFile, "", new(log.JSONFormatter), true)
+	entry := testLogger.WithFields(log.Fields{"package": "gost.server.log"})
+	ctx := ContextWithRequestID(context.Background(), "abc")
+
+	// act
+	message := captureStdout(func() { WithRequestID(entry, RequestIDFromContext(ctx)).Info("test") })
+
+	// assert
+	assert.Contains(t, message, `"requestId":"abc"`)
+	assert.Equal(t, entry, WithRequestID(entry, RequestIDFromContext(context.Background())))
+	assert.Equal(t, 16, len(NewRequestID()))
+}
+
+func TestGetPackageLogger(t *testing.T) {
+	// arrange
+	levels, err := ParsePackageLevels(map[string]string{"gost.server.http": "warn"})
+	Initialize(Options{Level: log.InfoLevel, PackageLevels: levels})
+
+	// act
+	httpLogger, _ := GetPackageLogger("gost.server.http")
+	mqttLogger, _ := GetPackageLogger("gost.server.mqtt")
+	_, invalidErr := ParsePackageLevels(map[string]string{"gost.server.http": "loud"})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, log.WarnLevel, httpLogger.Logger.Level)
+	assert.Equal(t, "gost.server.http", httpLogger.Data["package"])
+	assert.Equal(t, log.InfoLevel, mqttLogger.Logger.Level)
+	assert.Equal(t, "gost.server.mqtt", mqttLogger.Data["package"])
+	assert.NotNil(t, invalidErr)
+}
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header holding the id of a request, an id set by a proxy in front of GOST is kept
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDField is the name of the log field holding the request id
+const RequestIDField = "requestId"
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// NewRequestID generates a random id for a request
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ContextWithRequestID returns a copy of ctx holding the request id
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request id in ctx or an empty string when ctx holds no request id
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithRequestID adds the request id as field to the log entry so the lines logged while handling a request
+// can be found by the id in the response header, entry is returned as is when id is empty
+func WithRequestID(entry *log.Entry, id string) *log.Entry {
+	if len(id) == 0 {
+		return entry
+	}
+
+	return entry.WithField(RequestIDField, id)
+}
@@ -0,0 +1,202 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the time format in the name of a rotated file, it sorts in order of rotation
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// Rotation configures when a log file is rotated and how many rotated files are kept, a zero value
+// disables the rotation by size, age or the removal of rotated files
+type Rotation struct {
+	MaxSizeMB   int
+	MaxAgeHours int
+	MaxBackups  int
+	Compress    bool
+}
+
+// RotatingFile is a log file that is rotated when it reaches the maximum size or age, the rotated file is
+// renamed to <name>-<time><ext>, compressed with gzip when Compress is set and the oldest rotated files
+// above MaxBackups are removed
+type RotatingFile struct {
+	sync.Mutex
+	path     string
+	rotation Rotation
+	file     *os.File
+	size     int64
+	opened   time.Time
+	// cleanup is done when the compression and removal of rotated files in the background is finished
+	cleanup sync.WaitGroup
+}
+
+// OpenRotatingFile opens or creates the log file at path, new lines are appended to an existing file
+func OpenRotatingFile(path string, rotation Rotation) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+// Write writes p to the log file, the file is rotated first when p does not fit or the file is too old
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.shouldRotate(len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) shouldRotate(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+
+	if f.rotation.MaxSizeMB > 0 && f.size+int64(n) > int64(f.rotation.MaxSizeMB)*1024*1024 {
+		return true
+	}
+
+	return f.rotation.MaxAgeHours > 0 && time.Since(f.opened) >= time.Duration(f.rotation.MaxAgeHours)*time.Hour
+}
+
+// rotate renames the current file and opens a new one, the caller should hold the lock
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	rotated := f.rotatedName(time.Now())
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.cleanup.Add(1)
+	go func() {
+		defer f.cleanup.Done()
+		if f.rotation.Compress {
+			compress(rotated)
+		}
+
+		f.removeBackups()
+	}()
+
+	return nil
+}
+
+// rotatedName returns the name of the file rotated at t: gost.log becomes gost-2017-01-01T00-00-00.000.log
+func (f *RotatingFile) rotatedName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + t.Format(rotatedTimeFormat) + ext
+}
+
+// Backups returns the rotated files ordered from old to new
+func (f *RotatingFile) Backups() []string {
+	ext := filepath.Ext(f.path)
+	pattern := strings.TrimSuffix(f.path, ext) + "-*" + ext
+	plain, _ := filepath.Glob(pattern)
+	compressed, _ := filepath.Glob(pattern + ".gz")
+	backups := append(plain, compressed...)
+
+	// sort on the time in the name, the .gz extension follows it
+	sort.Slice(backups, func(i, j int) bool { return backups[i] < backups[j] })
+	return backups
+}
+
+func (f *RotatingFile) removeBackups() {
+	if f.rotation.MaxBackups <= 0 {
+		return
+	}
+
+	backups := f.Backups()
+	for i := 0; i < len(backups)-f.rotation.MaxBackups; i++ {
+		os.Remove(backups[i])
+	}
+}
+
+// compress writes the file at path to path.gz and removes it
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	in.Close()
+	return os.Remove(path)
+}
+
+// Close closes the log file after the compression and removal of rotated files is finished
+func (f *RotatingFile) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	f.cleanup.Wait()
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
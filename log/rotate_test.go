@@ -0,0 +1,73 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile(t *testing.T) {
+	// arrange
+	dir, _ := ioutil.TempDir("", "gostlog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gost.log")
+	f, err := OpenRotatingFile(path, Rotation{MaxSizeMB: 1, MaxBackups: 2})
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+
+	// act
+	for i := 0; i < 4*1024+1; i++ {
+		f.Write(line)
+	}
+	f.Close()
+	info, _ := os.Stat(path)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(f.Backups()))
+	assert.Equal(t, int64(1024), info.Size())
+}
+
+func TestRotatingFileCompress(t *testing.T) {
+	// arrange
+	dir, _ := ioutil.TempDir("", "gostlog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gost.log")
+	f, _ := OpenRotatingFile(path, Rotation{MaxSizeMB: 1, Compress: true})
+
+	// act
+	f.Write([]byte(strings.Repeat("x", 1024*1024)))
+	_, err := f.Write([]byte("rotated\n"))
+	f.Close()
+	backups := f.Backups()
+	content, _ := ioutil.ReadFile(path)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(backups))
+	assert.True(t, strings.HasSuffix(backups[0], ".log.gz"))
+	assert.Equal(t, "rotated\n", string(content))
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	// arrange
+	dir, _ := ioutil.TempDir("", "gostlog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gost.log")
+	f, _ := OpenRotatingFile(path, Rotation{MaxAgeHours: 1})
+	f.Write([]byte("old\n"))
+	f.opened = f.opened.Add(-2 * time.Hour)
+
+	// act
+	f.Write([]byte("new\n"))
+	f.Close()
+	content, _ := ioutil.ReadFile(path)
+
+	// assert
+	assert.Equal(t, 1, len(f.Backups()))
+	assert.Equal(t, "new\n", string(content))
+}
@@ -1,33 +1,51 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/gost/server/archive"
 	"github.com/gost/server/configuration"
+	"github.com/gost/server/database/memory"
 	"github.com/gost/server/database/postgis"
+	"github.com/gost/server/database/sqlite"
+	"github.com/gost/server/health"
 	"github.com/gost/server/http"
+	"github.com/gost/server/importer"
 	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/metrics"
 	"github.com/gost/server/mqtt"
+	"github.com/gost/server/retention"
 	"github.com/gost/server/sensorthings/api"
 	"github.com/gost/server/sensorthings/models"
+	stmqtt "github.com/gost/server/sensorthings/mqtt"
 )
 
+// defaultShutdownTimeout is used when server.shutdownTimeoutSec is not set
+const defaultShutdownTimeout = 30 * time.Second
+
 var (
-	stAPI       models.API
-	gostServer  http.Server
-	mqttClient  models.MQTTClient
-	file        *os.File
-	logger      *log.Logger
-	mainLogger  *log.Entry
-	conf        configuration.Config
-	cfgFlag     = flag.String("config", "config.yaml", "path of the config file")
-	installFlag = flag.String("install", "", "path to the database creation file")
+	stAPI        models.API
+	gostDatabase models.Database
+	gostServer   http.Server
+	mqttClient   models.MQTTClient
+	retentionJob *retention.Job
+	logger       *log.Logger
+	mainLogger   *log.Entry
+	conf         configuration.Config
+	cfgFlag      = flag.String("config", "config.yaml", "path of the config file")
+	installFlag  = flag.String("install", "", "path to the database creation file")
+	importFlag   = flag.String("import", "", "path of a CSV or NDJSON file with observations or a .tar.gz archive created by -export to import")
+	exportFlag   = flag.String("export", "", "path of the .tar.gz archive to export all entities to")
+	migrateFlag  = flag.Bool("migrate", false, "apply the pending database migrations and exit")
 )
 
 func initialize() {
@@ -41,7 +59,13 @@ func initialize() {
 	}
 
 	configuration.SetEnvironmentVariables(&conf)
-	logger, err := gostLog.InitializeLogger(file, conf.Logger.FileName, &log.TextFormatter{FullTimestamp: true}, conf.Logger.Verbose)
+	options, err := loggerOptions(conf.Logger)
+	if err != nil {
+		log.Fatal("config read error: ", err)
+		return
+	}
+
+	logger, err := gostLog.Initialize(options)
 	if err != nil {
 		log.Println("Error initializing logger, defaulting to stdout. Error: " + err.Error())
 	}
@@ -50,45 +74,152 @@ func initialize() {
 	mainLogger = logger.WithFields(log.Fields{"package": "main"})
 }
 
+// loggerOptions converts the logger section of the config to the options of the logger
+func loggerOptions(c configuration.LoggerConfig) (gostLog.Options, error) {
+	formatter, err := gostLog.NewFormatter(c.Format)
+	if err != nil {
+		return gostLog.Options{}, err
+	}
+
+	level, err := gostLog.ParseLevel(c.Level, c.Verbose)
+	if err != nil {
+		return gostLog.Options{}, err
+	}
+
+	packageLevels, err := gostLog.ParsePackageLevels(c.Levels)
+	if err != nil {
+		return gostLog.Options{}, err
+	}
+
+	return gostLog.Options{
+		FileName:      c.FileName,
+		Formatter:     formatter,
+		Level:         level,
+		PackageLevels: packageLevels,
+		Rotation: gostLog.Rotation{
+			MaxSizeMB:   c.MaxSizeMB,
+			MaxAgeHours: c.MaxAgeHours,
+			MaxBackups:  c.MaxBackups,
+			Compress:    c.Compress,
+		},
+	}, nil
+}
+
 func main() {
 	initialize()
-	stop := make(chan os.Signal, 2)
-	signal.Notify(stop, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	go func() {
-		<-stop
-		// mainLogger.Info("GOST stopped gracefully")
-		cleanup()
-		os.Exit(1)
-	}()
-
 	mainLogger.Info("Starting GOST")
 
-	database := postgis.NewDatabase(
-		conf.Database.Host,
-		conf.Database.Port,
-		conf.Database.User,
-		conf.Database.Password,
-		conf.Database.Database,
-		conf.Database.Schema,
-		conf.Database.SSL,
-		conf.Database.MaxIdleConns,
-		conf.Database.MaxOpenConns,
-		conf.Server.MaxEntityResponse)
-	go database.Start()
-
-	// if install is supplied create database and close, if not start server
-	sqlFile := *installFlag
-	if len(sqlFile) != 0 {
+	database := newDatabase()
+	database.Start()
+	gostDatabase = database
+
+	// if migrate is supplied upgrade the schema and close
+	if *migrateFlag {
+		migrateDatabase(database)
+		return
+	}
+
+	// if install is supplied create database and close
+	if sqlFile := *installFlag; len(sqlFile) != 0 {
 		createDatabase(database, sqlFile)
+		return
+	}
+
+	checkSchema(database)
+
+	// if import or export is supplied load or write the file and close
+	if importFile := *importFlag; len(importFile) != 0 {
+		if archive.IsArchive(importFile) {
+			restoreArchive(database, importFile)
+		} else {
+			importObservations(database, importFile)
+		}
+		return
+	}
+
+	if exportFile := *exportFlag; len(exportFile) != 0 {
+		exportArchive(database, exportFile)
+		return
+	}
+
+	if conf.MQTT.Broker.Enabled {
+		mqttClient = mqtt.CreateMQTTBroker(conf.MQTT)
 	} else {
 		mqttClient = mqtt.CreateMQTTClient(conf.MQTT)
-		stAPI = api.NewAPI(database, conf, mqttClient)
+	}
+
+	stAPI = api.NewAPI(database, conf, mqttClient)
+
+	if conf.MQTT.Enabled {
+		mqttClient.Start(&stAPI)
+	}
 
-		if conf.MQTT.Enabled {
-			mqttClient.Start(&stAPI)
+	if conf.Retention.Enabled {
+		retentionJob = retention.NewJob(database, conf.Retention)
+		retentionJob.Start()
+	}
+
+	registerMonitoring(database)
+	createAndStartServer(&stAPI)
+}
+
+// newDatabase creates the database set by database.type, PostGIS is used when no type is set
+func newDatabase() models.Database {
+	switch conf.Database.Type {
+	case configuration.DatabaseTypeMemory:
+		return memory.NewDatabase(conf.Server.MaxEntityResponse)
+	case configuration.DatabaseTypeSQLite:
+		path := conf.Database.Path
+		if path == "" {
+			path = configuration.DefaultSQLitePath
 		}
 
-		createAndStartServer(&stAPI)
+		return sqlite.NewDatabase(path, conf.Server.MaxEntityResponse)
+	case "", configuration.DatabaseTypePostGIS:
+		return postgis.NewDatabase(
+			conf.Database.Host,
+			conf.Database.Port,
+			conf.Database.User,
+			conf.Database.Password,
+			conf.Database.Database,
+			conf.Database.Schema,
+			conf.Database.SSL,
+			conf.Database.MaxIdleConns,
+			conf.Database.MaxOpenConns,
+			conf.Server.MaxEntityResponse)
+	}
+
+	mainLogger.Fatalf("Unknown database type %s, supported: %s, %s, %s", conf.Database.Type, configuration.DatabaseTypePostGIS, configuration.DatabaseTypeMemory, configuration.DatabaseTypeSQLite)
+	return nil
+}
+
+// checkSchema applies the pending migrations when database.autoMigrate is set, GOST refuses to start
+// when the schema has been migrated by a newer version
+func checkSchema(db models.Database) {
+	if conf.Database.AutoMigrate {
+		migrateDatabase(db)
+		return
+	}
+
+	current, latest, err := db.SchemaVersion()
+	if err != nil {
+		mainLogger.Fatal(err)
+	}
+
+	if current < latest {
+		mainLogger.Warnf("Database schema version %d is behind version %d, run GOST with -migrate or set database.autoMigrate", current, latest)
+	}
+}
+
+// migrateDatabase applies the pending migrations
+func migrateDatabase(db models.Database) {
+	applied, err := db.Migrate()
+	if err != nil {
+		mainLogger.Fatal(err)
+	}
+
+	if applied > 0 {
+		mainLogger.Infof("Applied %d database migrations", applied)
 	}
 }
 
@@ -103,6 +234,64 @@ func createDatabase(db models.Database, sqlFile string) {
 	mainLogger.Info("Database created successfully, you can start your server now")
 }
 
+// importObservations loads the observations in file through the API without publishing them over MQTT
+func importObservations(db models.Database, file string) {
+	mainLogger.Infof("Importing observations from %s", file)
+
+	importConf := conf
+	importConf.MQTT.Enabled = false
+	a := api.NewAPI(db, importConf, mqtt.CreateMQTTClient(importConf.MQTT))
+	report, err := importer.NewImporter(a, file).Run()
+	if err != nil {
+		mainLogger.Fatal(err)
+	}
+
+	mainLogger.Infof("Import finished: %d lines, %d imported, %d rejected, %d skipped", report.Lines, report.Imported, report.Rejected, report.Skipped)
+	if report.Rejected > 0 {
+		mainLogger.Warnf("Rejected lines are written to %s", file+".rejected")
+	}
+}
+
+// exportArchive writes all entities in the database to an archive that can be imported on another GOST instance
+func exportArchive(db models.Database, file string) {
+	mainLogger.Infof("Exporting entities to %s", file)
+	manifest, err := archive.Export(db, file)
+	if err != nil {
+		mainLogger.Fatal(err)
+	}
+
+	mainLogger.Infof("Export finished: %v", manifest.Counts)
+}
+
+// restoreArchive creates the entities of an archive created by -export, the entities get new ids
+func restoreArchive(db models.Database, file string) {
+	mainLogger.Infof("Importing archive %s", file)
+	manifest, err := archive.Restore(db, file)
+	if err != nil {
+		mainLogger.Fatal(err)
+	}
+
+	mainLogger.Infof("Import finished: %v", manifest.Counts)
+}
+
+// registerMonitoring adds the checks of /health and /ready and the metrics kept outside the metrics package
+func registerMonitoring(db models.Database) {
+	health.Register("database", db.Ping)
+	if conf.MQTT.Enabled {
+		health.Register("mqtt", func() error {
+			if !mqttClient.IsConnected() {
+				return errors.New("not connected to the MQTT broker")
+			}
+
+			return nil
+		})
+	}
+
+	metrics.NewCounterFunc("gost_mqtt_rejected_messages_total", "Number of MQTT messages rejected by the ACL.", func() float64 {
+		return float64(stmqtt.RejectedMessages())
+	})
+}
+
 // createAndStartServer creates the GOST HTTPServer and starts it
 func createAndStartServer(api *models.API) {
 	a := *api
@@ -116,13 +305,75 @@ func createAndStartServer(api *models.API) {
 		config.Server.HTTPS,
 		config.Server.HTTPSCert,
 		config.Server.HTTPSKey)
+	handleSignals(mqttClient, gostServer, retentionJob, gostDatabase)
 	gostServer.Start()
+
+	// the server returns when it is shut down, wait until the shutdown exits
+	select {}
+}
+
+// handleSignals shuts down GOST on SIGHUP, SIGINT, SIGTERM or SIGQUIT, it is called when all components
+// are started and gets them passed so the signal handler does not read them while they are set. Until
+// then a signal stops GOST right away
+func handleSignals(client models.MQTTClient, server http.Server, job *retention.Job, db models.Database) {
+	stop := make(chan os.Signal, 2)
+	signal.Notify(stop, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-stop
+		os.Exit(shutdown(client, server, job, db))
+	}()
 }
 
-func cleanup() {
-	if gostServer != nil {
-		gostServer.Stop()
+// shutdown stops GOST within server.shutdownTimeoutSec: the MQTT client stops receiving messages and
+// finishes the messages being handled, the HTTP server finishes the requests being handled and the
+// database connections are closed. The exit code is 1 when the timeout passed before GOST was stopped
+func shutdown(client models.MQTTClient, server http.Server, job *retention.Job, db models.Database) int {
+	mainLogger.Info("Stopping GOST")
+	timeout := time.Duration(conf.Server.ShutdownTimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	code := 0
+	if client != nil && conf.MQTT.Enabled {
+		if err := client.Shutdown(ctx); err != nil {
+			mainLogger.Warnf("MQTT messages not handled before the shutdown timeout: %v", err)
+			code = 1
+		}
+	}
+
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			mainLogger.Warnf("HTTP requests not handled before the shutdown timeout: %v", err)
+			code = 1
+		}
+	}
+
+	if job != nil {
+		stopped := make(chan bool)
+		go func() {
+			job.Stop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			mainLogger.Warnf("Retention job not finished before the shutdown timeout: %v", ctx.Err())
+			code = 1
+		}
+	}
+
+	if db != nil {
+		if err := db.Close(); err != nil {
+			mainLogger.Warnf("Unable to close the database: %v", err)
+		}
 	}
 
+	mainLogger.Info("GOST stopped")
 	gostLog.CleanUp()
+	return code
 }
@@ -0,0 +1,27 @@
+package metrics
+
+// Transports on which Observations are ingested
+const (
+	TransportHTTP   = "http"
+	TransportMQTT   = "mqtt"
+	TransportImport = "import"
+)
+
+// QueryBuckets are the upper bounds in seconds of the buckets of the query builder histogram
+var QueryBuckets = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01}
+
+// The metrics of GOST exposed on /metrics
+var (
+	// HTTPRequests counts the handled requests by method, operation path and status code
+	HTTPRequests = NewCounter("gost_http_requests_total", "Number of handled HTTP requests per endpoint operation.", "method", "operation", "code")
+	// HTTPRequestDuration holds the time to handle requests by method and operation path
+	HTTPRequestDuration = NewHistogram("gost_http_request_duration_seconds", "Time to handle HTTP requests per endpoint operation.", DefaultBuckets, "method", "operation")
+	// ObservationsIngested counts the created Observations by transport
+	ObservationsIngested = NewCounter("gost_observations_ingested_total", "Number of created Observations per transport.", "transport")
+	// QueryBuildDuration holds the time to construct database queries by database type and query type
+	QueryBuildDuration = NewHistogram("gost_query_build_duration_seconds", "Time to construct database queries.", QueryBuckets, "database", "query")
+	// MQTTIngestDropped counts the MQTT messages dropped because the ingest queue was full
+	MQTTIngestDropped = NewCounter("gost_mqtt_ingest_dropped_total", "Number of MQTT messages dropped because the ingest queue was full.")
+	// MQTTReconnects counts the reconnects of the MQTT client after losing its connection
+	MQTTReconnects = NewCounter("gost_mqtt_reconnects_total", "Number of times the MQTT client reconnected after losing the connection to the broker.")
+)
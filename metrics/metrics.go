@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of request duration histograms
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metric is written in the Prometheus text format by a Registry
+type Metric interface {
+	Name() string
+	write(w io.Writer)
+}
+
+// Registry holds the metrics exposed on /metrics
+type Registry struct {
+	sync.RWMutex
+	metrics map[string]Metric
+}
+
+// DefaultRegistry holds the metrics created by NewCounter, NewHistogram and NewCounterFunc
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]Metric)}
+}
+
+// Register adds a metric to the registry, a metric with the same name is replaced
+func (r *Registry) Register(m Metric) {
+	r.Lock()
+	defer r.Unlock()
+	r.metrics[m.Name()] = m
+}
+
+// Write writes all metrics ordered by name in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	metrics := make([]Metric, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+
+	bw.Flush()
+}
+
+// Handler returns the handler serving the metrics of the DefaultRegistry
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		DefaultRegistry.Write(w)
+	}
+}
+
+// series is the value of a metric for a combination of label values
+type series struct {
+	labels  []string
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+// vector holds the series of a metric by their label values
+type vector struct {
+	sync.Mutex
+	name       string
+	help       string
+	labelNames []string
+	series     map[string]*series
+}
+
+func newVector(name, help string, labelNames []string) *vector {
+	return &vector{name: name, help: help, labelNames: labelNames, series: make(map[string]*series)}
+}
+
+// Name returns the name of the metric
+func (v *vector) Name() string {
+	return v.name
+}
+
+// get returns the series of the label values, the caller should hold the lock
+func (v *vector) get(labels []string, buckets int) *series {
+	if len(labels) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(labels)))
+	}
+
+	key := strings.Join(labels, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), labels...), buckets: make([]uint64, buckets)}
+		v.series[key] = s
+	}
+
+	return s
+}
+
+// sorted returns a copy of the series ordered by their label values
+func (v *vector) sorted() []series {
+	v.Lock()
+	defer v.Unlock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	list := make([]series, len(keys))
+	for i, k := range keys {
+		s := *v.series[k]
+		s.buckets = append([]uint64(nil), s.buckets...)
+		list[i] = s
+	}
+
+	return list
+}
+
+func (v *vector) writeHeader(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, metricType)
+}
+
+// Counter is a metric that only goes up, such as the number of handled requests
+type Counter struct {
+	*vector
+}
+
+// NewCounter creates a Counter with the given label names and adds it to the DefaultRegistry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newVector(name, help, labelNames)}
+	DefaultRegistry.Register(c)
+	return c
+}
+
+// Inc increases the counter of the label values by one
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add increases the counter of the label values by value
+func (c *Counter) Add(value float64, labels ...string) {
+	c.Lock()
+	defer c.Unlock()
+	c.get(labels, 0).value += value
+}
+
+// Value returns the current value of the counter of the label values
+func (c *Counter) Value(labels ...string) float64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.get(labels, 0).value
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w, "counter")
+	for _, s := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labelNames, s.labels), formatValue(s.value))
+	}
+}
+
+// Histogram counts observations, such as durations, in buckets with an upper bound
+type Histogram struct {
+	*vector
+	upperBounds []float64
+}
+
+// NewHistogram creates a Histogram with the given buckets and label names and adds it to the DefaultRegistry
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{vector: newVector(name, help, labelNames), upperBounds: buckets}
+	DefaultRegistry.Register(h)
+	return h
+}
+
+// Observe adds a value to the histogram of the label values
+func (h *Histogram) Observe(value float64, labels ...string) {
+	h.Lock()
+	defer h.Unlock()
+	s := h.get(labels, len(h.upperBounds))
+	for i, bound := range h.upperBounds {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+
+	s.count++
+	s.value += value
+}
+
+// ObserveSince adds the seconds elapsed since start, call with defer: defer h.ObserveSince(time.Now(), "label")
+func (h *Histogram) ObserveSince(start time.Time, labels ...string) {
+	h.Observe(time.Since(start).Seconds(), labels...)
+}
+
+// Count returns the number of observations of the label values
+func (h *Histogram) Count(labels ...string) uint64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.get(labels, len(h.upperBounds)).count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+	names := append(append([]string(nil), h.labelNames...), "le")
+	for _, s := range h.sorted() {
+		for i, bound := range h.upperBounds {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, withLabel(s.labels, formatValue(bound))), s.buckets[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, withLabel(s.labels, "+Inf")), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labelNames, s.labels), formatValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labelNames, s.labels), s.count)
+	}
+}
+
+// funcMetric reads its value from a function when the metrics are written
+type funcMetric struct {
+	name       string
+	help       string
+	metricType string
+	value      func() float64
+}
+
+// NewCounterFunc adds a counter to the DefaultRegistry that reads its value from a function, for
+// counters kept elsewhere such as the number of MQTT messages rejected by the ACL
+func NewCounterFunc(name, help string, value func() float64) {
+	DefaultRegistry.Register(&funcMetric{name: name, help: help, metricType: "counter", value: value})
+}
+
+// NewGaugeFunc adds a gauge to the DefaultRegistry that reads its value from a function
+func NewGaugeFunc(name, help string, value func() float64) {
+	DefaultRegistry.Register(&funcMetric{name: name, help: help, metricType: "gauge", value: value})
+}
+
+func (f *funcMetric) Name() string {
+	return f.name
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", f.name, f.help, f.name, f.metricType, f.name, formatValue(f.value()))
+}
+
+// withLabel returns a copy of the label values with value appended
+func withLabel(labels []string, value string) []string {
+	return append(append(make([]string, 0, len(labels)+1), labels...), value)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
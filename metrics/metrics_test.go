@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	// arrange
+	registry := NewRegistry()
+	counter := NewCounter("test_requests_total", "Number of requests.", "method", "code")
+	registry.Register(counter)
+	buffer := &bytes.Buffer{}
+
+	// act
+	counter.Inc("GET", "200")
+	counter.Inc("GET", "200")
+	counter.Add(3, "POST", "201")
+	registry.Write(buffer)
+
+	// assert
+	assert.Equal(t, float64(2), counter.Value("GET", "200"))
+	assert.Equal(t, "# HELP test_requests_total Number of requests.\n"+
+		"# TYPE test_requests_total counter\n"+
+		"test_requests_total{method=\"GET\",code=\"200\"} 2\n"+
+		"test_requests_total{method=\"POST\",code=\"201\"} 3\n", buffer.String())
+}
+
+func TestCounterLabelCount(t *testing.T) {
+	// arrange
+	counter := NewCounter("test_labels_total", "Number of labels.", "method")
+
+	// assert
+	assert.Panics(t, func() { counter.Inc() })
+}
+
+func TestHistogram(t *testing.T) {
+	// arrange
+	registry := NewRegistry()
+	histogram := NewHistogram("test_duration_seconds", "Duration.", []float64{0.1, 1}, "operation")
+	registry.Register(histogram)
+	buffer := &bytes.Buffer{}
+
+	// act
+	histogram.Observe(0.05, "a\"b")
+	histogram.Observe(0.5, "a\"b")
+	histogram.Observe(2, "a\"b")
+	registry.Write(buffer)
+
+	// assert
+	assert.Equal(t, uint64(3), histogram.Count("a\"b"))
+	assert.Equal(t, "# HELP test_duration_seconds Duration.\n"+
+		"# TYPE test_duration_seconds histogram\n"+
+		"test_duration_seconds_bucket{operation=\"a\\\"b\",le=\"0.1\"} 1\n"+
+		"test_duration_seconds_bucket{operation=\"a\\\"b\",le=\"1\"} 2\n"+
+		"test_duration_seconds_bucket{operation=\"a\\\"b\",le=\"+Inf\"} 3\n"+
+		"test_duration_seconds_sum{operation=\"a\\\"b\"} 2.55\n"+
+		"test_duration_seconds_count{operation=\"a\\\"b\"} 3\n", buffer.String())
+}
+
+func TestGaugeFunc(t *testing.T) {
+	// arrange
+	buffer := &bytes.Buffer{}
+
+	// act
+	NewGaugeFunc("test_gauge", "Gauge.", func() float64 { return 42 })
+	DefaultRegistry.Write(buffer)
+
+	// assert
+	assert.Contains(t, buffer.String(), "# TYPE test_gauge gauge\ntest_gauge 42\n")
+}
@@ -0,0 +1,831 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/auth"
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/metrics"
+	"github.com/gost/server/sensorthings/models"
+)
+
+const (
+	// connectTimeout is the time a client has to send CONNECT after opening the connection
+	connectTimeout = 10 * time.Second
+	// sessionQueueSize is the number of packets waiting to be written to a client, packets are dropped
+	// when a client does not read them fast enough
+	sessionQueueSize = 1000
+	// maxQoS is the highest QoS granted to subscriptions, messages are not stored for redelivery
+	maxQoS byte = 1
+
+	subscribeLogTopic   = "$SYS/broker/log/M/subscribe"
+	unsubscribeLogTopic = "$SYS/broker/log/M/unsubscribe"
+)
+
+// Broker is a MQTT 3.1.1 broker embedded in GOST, it replaces the client connecting to an external broker.
+// Messages published by clients are routed to the topics of the API and to the subscribed clients, the
+// entity changes published by the API are sent to the subscribed clients. Subscriptions are reported to
+// the API the same way mosquitto does on $SYS/broker/log/M/subscribe. Sessions are not persisted, a
+// session ends when the client disconnects. When auth is enabled clients are authenticated with the
+// username and password of CONNECT the same way as HTTP requests
+type Broker struct {
+	clientCount   uint64
+	host          string
+	port          int
+	websocketPort int
+	websocketPath string
+	prefix        string
+	acl           bool
+	authenticator auth.Authenticator
+	api           *models.API
+	topics        []models.Topic
+	pool          *ingestPool
+	listener      net.Listener
+	websocket     *http.Server
+	mutex         sync.RWMutex
+	sessions      map[string]*session
+	retained      map[string]*publishPacket
+	running       bool
+	stopping      bool
+	connections   sync.WaitGroup
+}
+
+// session is the connection of a client, subscriptions holds the granted QoS by topic filter and is
+// guarded by the mutex of the broker. The identity is nil when auth is disabled
+type session struct {
+	clientID      string
+	identity      *auth.Identity
+	conn          net.Conn
+	out           chan []byte
+	done          chan struct{}
+	closeOnce     sync.Once
+	subscriptions map[string]byte
+	idMutex       sync.Mutex
+	nextID        uint16
+	// received holds the ids of QoS 2 messages waiting for PUBREL, they are handled only once
+	received map[uint16]bool
+	will     *publishPacket
+}
+
+// CreateMQTTBroker creates the embedded MQTT broker
+func CreateMQTTBroker(config configuration.MQTTConfig) models.MQTTClient {
+	setupLogger(config.Verbose)
+
+	b := &Broker{
+		host:          config.Broker.Host,
+		port:          config.Broker.Port,
+		websocketPort: config.Broker.WebsocketPort,
+		websocketPath: config.Broker.WebsocketPath,
+		prefix:        config.Prefix,
+		acl:           config.ACL.Enabled,
+		pool:          newConfiguredIngestPool(config),
+		sessions:      make(map[string]*session),
+		retained:      make(map[string]*publishPacket),
+	}
+
+	if b.port == 0 {
+		b.port = configuration.DefaultMQTTBrokerPort
+	}
+
+	if len(b.websocketPath) == 0 {
+		b.websocketPath = configuration.DefaultMQTTBrokerWebsocketPath
+	}
+
+	return b
+}
+
+// Start starts the listeners of the broker
+func (b *Broker) Start(api *models.API) {
+	b.api = api
+	a := *api
+	authenticator, err := auth.NewAuthenticator(a.GetConfig().Auth)
+	if err != nil {
+		logger.Errorf("unable to start MQTT broker: %v", err)
+		return
+	}
+
+	b.authenticator = authenticator
+	b.topics = *a.GetTopics(b.prefix)
+	b.pool.start(api, b.prefix)
+	metrics.NewGaugeFunc("gost_mqtt_ingest_queue_depth", "Number of MQTT messages waiting to be handled.", func() float64 {
+		return float64(b.pool.depth())
+	})
+	metrics.NewGaugeFunc("gost_mqtt_broker_clients", "Number of clients connected to the embedded MQTT broker.", func() float64 {
+		return float64(b.clients())
+	})
+
+	if err := b.listen(); err != nil {
+		logger.Errorf("unable to start MQTT broker: %v", err)
+	}
+}
+
+// listen opens the TCP listener and the websocket listener when a websocket port is set
+func (b *Broker) listen() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", b.host, b.port))
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Starting MQTT broker on %s with Prefix:%v", listener.Addr(), b.prefix)
+	b.mutex.Lock()
+	b.listener = listener
+	b.running = true
+	b.mutex.Unlock()
+	go b.accept(listener)
+
+	if b.websocketPort <= 0 {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(b.websocketPath, b.handleWebsocket)
+	b.websocket = &http.Server{Addr: fmt.Sprintf("%s:%d", b.host, b.websocketPort), Handler: mux}
+	logger.Infof("Starting MQTT broker websocket listener on %s%s", b.websocket.Addr, b.websocketPath)
+	go func() {
+		if err := b.websocket.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("MQTT broker websocket listener stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (b *Broker) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			if !b.isStopping() {
+				logger.Errorf("MQTT broker stopped accepting connections: %v", err)
+			}
+			return
+		}
+
+		b.serveConn(conn)
+	}
+}
+
+func (b *Broker) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebsocket(w, r)
+	if err != nil {
+		logger.Debugf("MQTT websocket connection from %s refused: %v", r.RemoteAddr, err)
+		return
+	}
+
+	b.serveConn(conn)
+}
+
+// serveConn serves a connection in the background unless the broker is stopping
+func (b *Broker) serveConn(conn net.Conn) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.stopping {
+		conn.Close()
+		return
+	}
+
+	b.connections.Add(1)
+	go b.serve(conn)
+}
+
+// serve reads the packets of a client until it disconnects
+func (b *Broker) serve(conn net.Conn) {
+	defer b.connections.Done()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(connectTimeout))
+	s, keepAlive, err := b.connect(conn, r)
+	if err != nil {
+		logger.Debugf("MQTT connection from %s refused: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	defer b.closeSession(s)
+	for {
+		// the client is disconnected when nothing is received within one and a half times the keep alive
+		deadline := time.Time{}
+		if keepAlive > 0 {
+			deadline = time.Now().Add(keepAlive * 3 / 2)
+		}
+		conn.SetReadDeadline(deadline)
+
+		p, err := readPacket(r)
+		if err != nil {
+			logger.Debugf("MQTT client %s disconnected: %v", s.clientID, err)
+			return
+		}
+
+		if p.kind == packetDisconnect {
+			s.will = nil
+			return
+		}
+
+		if err := b.handle(s, p); err != nil {
+			logger.Debugf("MQTT client %s disconnected: %v", s.clientID, err)
+			return
+		}
+	}
+}
+
+// connect reads the CONNECT packet and starts the session of the client
+func (b *Broker) connect(conn net.Conn, r *bufio.Reader) (*session, time.Duration, error) {
+	p, err := readPacket(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if p.kind != packetConnect {
+		return nil, 0, fmt.Errorf("expected CONNECT, received packet type %d", p.kind)
+	}
+
+	c, err := parseConnect(p)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if c.protocol != protocolName || c.level != protocolLevel {
+		conn.Write(encodeConnack(connackBadProtocolVersion))
+		return nil, 0, fmt.Errorf("unsupported protocol %s level %d", c.protocol, c.level)
+	}
+
+	if len(c.clientID) == 0 {
+		if !c.cleanSession {
+			conn.Write(encodeConnack(connackIdentifierRejected))
+			return nil, 0, fmt.Errorf("empty client id without clean session")
+		}
+
+		c.clientID = fmt.Sprintf("gost-%d", atomic.AddUint64(&b.clientCount, 1))
+	}
+
+	identity, err := b.authenticate(c)
+	if err != nil {
+		if err == auth.ErrNoCredentials {
+			conn.Write(encodeConnack(connackNotAuthorized))
+		} else {
+			conn.Write(encodeConnack(connackBadCredentials))
+		}
+		return nil, 0, err
+	}
+
+	s := &session{
+		clientID:      c.clientID,
+		identity:      identity,
+		conn:          conn,
+		out:           make(chan []byte, sessionQueueSize),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]byte),
+		received:      make(map[uint16]bool),
+		will:          c.will,
+	}
+
+	if c.will != nil {
+		if err := b.authorize(s, c.will.topic); err != nil {
+			conn.Write(encodeConnack(connackNotAuthorized))
+			return nil, 0, fmt.Errorf("will on %s not allowed: %v", c.will.topic, err)
+		}
+	}
+
+	// a client connecting with the id of a connected client of the same user takes over its session
+	b.mutex.Lock()
+	existing := b.sessions[s.clientID]
+	if existing != nil && !sameUser(existing.identity, s.identity) {
+		b.mutex.Unlock()
+		conn.Write(encodeConnack(connackIdentifierRejected))
+		return nil, 0, fmt.Errorf("client id %s is in use by another user", s.clientID)
+	}
+
+	b.sessions[s.clientID] = s
+	b.mutex.Unlock()
+	if existing != nil {
+		logger.Debugf("MQTT client %s connected again, closing the previous connection", s.clientID)
+		b.closeSession(existing)
+	}
+
+	go s.write()
+	s.send(encodeConnack(connackAccepted))
+	logger.Debugf("MQTT client %s connected from %s", s.clientID, conn.RemoteAddr())
+	return s, time.Duration(c.keepAlive) * time.Second, nil
+}
+
+// authenticate returns the identity of the username and password of a client, a nil identity is returned
+// when auth is disabled
+func (b *Broker) authenticate(c *connectPacket) (*auth.Identity, error) {
+	if b.authenticator == nil {
+		return nil, nil
+	}
+
+	return b.authenticator.AuthenticateCredentials(c.username, c.password)
+}
+
+// sameUser returns true when both identities are the same authenticated user or auth is disabled, a
+// session can only be taken over by a client of the same user
+func sameUser(a, b *auth.Identity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return !a.Anonymous && !b.Anonymous && a.Name == b.Name
+}
+
+// handle handles a packet received from a client, an error closes the connection
+func (b *Broker) handle(s *session, p *packet) error {
+	switch p.kind {
+	case packetPublish:
+		pub, err := parsePublish(p)
+		if err != nil {
+			return err
+		}
+
+		return b.publish(s, pub)
+	case packetPubrel:
+		id, err := parsePacketID(p)
+		if err != nil {
+			return err
+		}
+
+		delete(s.received, id)
+		s.send(encodeAck(packetPubcomp, id))
+	case packetPuback, packetPubrec, packetPubcomp:
+		// messages are sent with at most QoS 1 and not stored, nothing to release
+	case packetSubscribe:
+		id, subscriptions, err := parseSubscribe(p, false)
+		if err != nil {
+			return err
+		}
+
+		b.subscribe(s, id, subscriptions)
+	case packetUnsubscribe:
+		id, subscriptions, err := parseSubscribe(p, true)
+		if err != nil {
+			return err
+		}
+
+		b.unsubscribe(s, id, subscriptions)
+	case packetPingreq:
+		s.send(encodePingresp())
+	default:
+		return fmt.Errorf("unexpected packet type %d", p.kind)
+	}
+
+	return nil
+}
+
+// publish acknowledges and routes a message published by a client
+func (b *Broker) publish(s *session, pub *publishPacket) error {
+	if !validTopic(pub.topic) {
+		return fmt.Errorf("invalid topic %s", pub.topic)
+	}
+
+	switch pub.qos {
+	case 1:
+		s.send(encodeAck(packetPuback, pub.packetID))
+	case 2:
+		s.send(encodeAck(packetPubrec, pub.packetID))
+		if s.received[pub.packetID] {
+			return nil
+		}
+
+		s.received[pub.packetID] = true
+	}
+
+	if strings.HasPrefix(pub.topic, "$") {
+		logger.Warnf("MQTT message of %s on %s ignored, clients cannot publish on $ topics", s.clientID, pub.topic)
+		return nil
+	}
+
+	if err := b.authorize(s, pub.topic); err != nil {
+		logger.Warnf("MQTT message of %s on %s rejected: %v", s.clientID, pub.topic, err)
+		return nil
+	}
+
+	b.route(pub)
+	return nil
+}
+
+// authorize returns an error when a client is not allowed to publish on topic. Messages below the prefix
+// are handled by the API and need a role allowed to post observations when auth is enabled. When the ACL
+// is enabled the level below the prefix identifies the publisher and has to be the name of the authenticated
+// user, anonymous clients cannot publish below the prefix
+func (b *Broker) authorize(s *session, topic string) error {
+	if !strings.HasPrefix(topic, b.prefix+"/") {
+		return nil
+	}
+
+	if s.identity != nil && !s.identity.IsAllowed(models.HTTPOperationPost, entities.EntityTypeObservation) {
+		return errors.New("the role of the client is not allowed to post observations")
+	}
+
+	if !b.acl {
+		return nil
+	}
+
+	if s.identity == nil || s.identity.Anonymous {
+		return errors.New("anonymous clients cannot publish below the prefix when the ACL is enabled")
+	}
+
+	publisher := strings.TrimPrefix(topic, b.prefix+"/")
+	if i := strings.Index(publisher, "/"); i >= 0 {
+		publisher = publisher[:i]
+	}
+
+	if publisher != s.identity.Name {
+		return fmt.Errorf("clients can only publish on the level of their username below %s", b.prefix)
+	}
+
+	return nil
+}
+
+// authorizeSubscription returns an error when a client is not allowed to subscribe to filter. The $ topics
+// such as the broker log are only used by GOST. When auth or the ACL is enabled a wildcard in the first level
+// is refused, SensorThings topics below v1.0 need permission to read the entity sets they match and filters
+// below the prefix need the same permission as publishing on them
+func (b *Broker) authorizeSubscription(s *session, filter string) error {
+	if strings.HasPrefix(filter, "$") {
+		return errors.New("clients cannot subscribe to $ topics")
+	}
+
+	if s.identity == nil && !b.acl {
+		return nil
+	}
+
+	levels := strings.Split(filter, "/")
+	if levels[0] == "#" || levels[0] == "+" {
+		return errors.New("a wildcard in the first level is not allowed")
+	}
+
+	if levels[0] == models.APIPrefix && s.identity != nil {
+		for _, entityType := range subscribedEntityTypes(levels[1:]) {
+			if !s.identity.IsAllowed(models.HTTPOperationGet, entityType) {
+				return fmt.Errorf("the role of the client is not allowed to read %s", entityType)
+			}
+		}
+	}
+
+	return b.authorize(s, filter)
+}
+
+// subscribedEntityTypes returns the types of the entities published on a SensorThings filter given by its
+// levels below v1.0, the type of the last entity set or all types when a wildcard can match any entity set
+func subscribedEntityTypes(levels []string) []entities.EntityType {
+	entityType := entities.EntityTypeUnknown
+	for _, level := range levels {
+		if level == "+" || level == "#" {
+			return entities.EntityTypeList
+		}
+
+		if i := strings.IndexAny(level, "(?"); i != -1 {
+			level = level[:i]
+		}
+
+		if t, err := entities.EntityTypeFromString(level); err == nil {
+			entityType = t
+		}
+	}
+
+	if entityType == entities.EntityTypeUnknown {
+		return entities.EntityTypeList
+	}
+
+	return []entities.EntityType{entityType}
+}
+
+// route sends a message to the subscribed clients and the topics of the API, a retained message
+// is kept for clients subscribing later and removed by a retained message without payload
+func (b *Broker) route(pub *publishPacket) {
+	if pub.retain {
+		b.mutex.Lock()
+		if len(pub.payload) == 0 {
+			delete(b.retained, pub.topic)
+		} else {
+			b.retained[pub.topic] = pub
+		}
+		b.mutex.Unlock()
+	}
+
+	b.mutex.RLock()
+	for _, s := range b.sessions {
+		if qos, ok := s.match(pub.topic); ok {
+			s.deliver(pub, qos, false)
+		}
+	}
+	b.mutex.RUnlock()
+
+	for i := range b.topics {
+		t := &b.topics[i]
+		if !matchTopic(t.Path, pub.topic) {
+			continue
+		}
+
+		if !b.pool.enqueue(message{subscription: t, topic: pub.topic, payload: pub.payload}) && !b.isStopping() {
+			// log the first drop and every thousandth after it, the drops are counted in the metrics
+			if dropped := b.pool.droppedCount(); dropped%1000 == 1 {
+				logger.Warnf("MQTT ingest queue full, message on %s dropped (%d messages dropped)", pub.topic, dropped)
+			}
+		}
+	}
+}
+
+// subscribe adds the subscriptions of a client, sends the retained messages matching them and reports
+// them to the API
+func (b *Broker) subscribe(s *session, id uint16, subscriptions []subscription) {
+	granted := make([]byte, len(subscriptions))
+	retained := make([]*publishPacket, 0)
+	b.mutex.Lock()
+	for i, sub := range subscriptions {
+		if !validFilter(sub.filter) || sub.qos > 2 {
+			granted[i] = subackFailure
+			continue
+		}
+
+		if err := b.authorizeSubscription(s, sub.filter); err != nil {
+			logger.Warnf("MQTT subscription of %s on %s refused: %v", s.clientID, sub.filter, err)
+			granted[i] = subackFailure
+			continue
+		}
+
+		granted[i] = sub.qos
+		if granted[i] > maxQoS {
+			granted[i] = maxQoS
+		}
+
+		s.subscriptions[sub.filter] = granted[i]
+		for topic, pub := range b.retained {
+			if matchTopic(sub.filter, topic) {
+				retained = append(retained, pub)
+			}
+		}
+	}
+	b.mutex.Unlock()
+
+	s.send(encodeSuback(id, granted))
+	for _, pub := range retained {
+		b.mutex.RLock()
+		qos, _ := s.match(pub.topic)
+		b.mutex.RUnlock()
+		s.deliver(pub, qos, true)
+	}
+
+	for i, sub := range subscriptions {
+		if granted[i] != subackFailure {
+			b.route(&publishPacket{topic: subscribeLogTopic, payload: []byte(fmt.Sprintf("%d: %s %d %s", time.Now().Unix(), s.clientID, granted[i], sub.filter))})
+		}
+	}
+}
+
+// unsubscribe removes the subscriptions of a client and reports them to the API
+func (b *Broker) unsubscribe(s *session, id uint16, subscriptions []subscription) {
+	b.mutex.Lock()
+	for _, sub := range subscriptions {
+		delete(s.subscriptions, sub.filter)
+	}
+	b.mutex.Unlock()
+
+	s.send(encodeAck(packetUnsuback, id))
+	for _, sub := range subscriptions {
+		b.routeUnsubscribed(s.clientID, sub.filter)
+	}
+}
+
+func (b *Broker) routeUnsubscribed(clientID, filter string) {
+	b.route(&publishPacket{topic: unsubscribeLogTopic, payload: []byte(fmt.Sprintf("%d: %s %s", time.Now().Unix(), clientID, filter))})
+}
+
+// closeSession closes the connection of a client, publishes its will when it did not disconnect and
+// removes its subscriptions from the API
+func (b *Broker) closeSession(s *session) {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.conn.Close()
+
+		b.mutex.Lock()
+		if b.sessions[s.clientID] == s {
+			delete(b.sessions, s.clientID)
+		}
+
+		filters := make([]string, 0, len(s.subscriptions))
+		for filter := range s.subscriptions {
+			filters = append(filters, filter)
+		}
+		stopping := b.stopping
+		b.mutex.Unlock()
+
+		if stopping {
+			return
+		}
+
+		if s.will != nil {
+			b.route(s.will)
+		}
+
+		for _, filter := range filters {
+			b.routeUnsubscribed(s.clientID, filter)
+		}
+	})
+}
+
+// Stop the broker
+func (b *Broker) Stop() {
+	b.Shutdown(context.Background())
+}
+
+// Shutdown closes the listeners and the connections of the clients and waits until the queued messages
+// are handled, the error of ctx is returned when ctx is done before they are handled
+func (b *Broker) Shutdown(ctx context.Context) error {
+	logger.Infof("Stopping MQTT broker")
+
+	b.mutex.Lock()
+	b.stopping = true
+	sessions := make([]*session, 0, len(b.sessions))
+	for _, s := range b.sessions {
+		sessions = append(sessions, s)
+	}
+	b.mutex.Unlock()
+
+	if b.listener != nil {
+		b.listener.Close()
+	}
+
+	if b.websocket != nil {
+		b.websocket.Close()
+	}
+
+	for _, s := range sessions {
+		b.closeSession(s)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.connections.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return b.pool.close(ctx)
+}
+
+func (b *Broker) isStopping() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.stopping
+}
+
+// Publish sends a message to the subscribed clients and the topics of the API
+func (b *Broker) Publish(topic string, message string, qos byte) {
+	b.route(&publishPacket{topic: topic, qos: qos, payload: []byte(message)})
+}
+
+// IsConnected returns true when the broker accepts connections
+func (b *Broker) IsConnected() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.running && !b.stopping
+}
+
+func (b *Broker) clients() int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.sessions)
+}
+
+// match returns the highest QoS of the subscriptions matching topic, the caller should hold the
+// lock of the broker
+func (s *session) match(topic string) (byte, bool) {
+	matched := false
+	var qos byte
+	for filter, q := range s.subscriptions {
+		if matchTopic(filter, topic) {
+			matched = true
+			if q > qos {
+				qos = q
+			}
+		}
+	}
+
+	return qos, matched
+}
+
+// deliver sends a message to the client with the lowest of the QoS of the message and the subscription
+func (s *session) deliver(pub *publishPacket, subscriptionQoS byte, retain bool) {
+	d := &publishPacket{topic: pub.topic, qos: pub.qos, retain: retain, payload: pub.payload}
+	if subscriptionQoS < d.qos {
+		d.qos = subscriptionQoS
+	}
+
+	if d.qos > 0 {
+		d.packetID = s.packetID()
+	}
+
+	if !s.send(encodePublish(d)) {
+		logger.Debugf("MQTT message on %s not sent to %s, the client is not reading fast enough", pub.topic, s.clientID)
+	}
+}
+
+// packetID returns the next id for a message sent to the client, 0 is not a valid id
+func (s *session) packetID() uint16 {
+	s.idMutex.Lock()
+	defer s.idMutex.Unlock()
+	s.nextID++
+	if s.nextID == 0 {
+		s.nextID = 1
+	}
+
+	return s.nextID
+}
+
+// send queues a packet for the client, false is returned when the queue is full or the session is closed
+func (s *session) send(b []byte) bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+
+	select {
+	case s.out <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+// write writes the queued packets to the client until the session is closed
+func (s *session) write() {
+	for {
+		select {
+		case b := <-s.out:
+			if _, err := s.conn.Write(b); err != nil {
+				s.conn.Close()
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// matchTopic returns true when topic matches filter, + matches a single level and # all remaining levels.
+// A wildcard in the first level does not match topics starting with $ such as $SYS/broker/log/M/subscribe
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+
+	return len(f) == len(t)
+}
+
+// validFilter returns false when a wildcard is not a complete level or # is not the last level
+func validFilter(filter string) bool {
+	if len(filter) == 0 {
+		return false
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return false
+		}
+
+		if strings.Contains(level, "+") && level != "+" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validTopic returns false for empty topics and topics containing a wildcard
+func validTopic(topic string) bool {
+	return len(topic) > 0 && !strings.ContainsAny(topic, "+#")
+}
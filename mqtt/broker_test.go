@@ -0,0 +1,371 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gost/server/auth"
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// received is a message handled by a topic of the test broker
+type received struct {
+	topic   string
+	payload string
+}
+
+// testAuthenticator accepts the password secret for the users by role, clients without credentials are refused
+type testAuthenticator struct {
+	roles map[string]auth.Role
+}
+
+func (a *testAuthenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	return nil, auth.ErrNoCredentials
+}
+
+func (a *testAuthenticator) AuthenticateCredentials(name, password string) (*auth.Identity, error) {
+	if len(name) == 0 {
+		return nil, auth.ErrNoCredentials
+	}
+
+	role, ok := a.roles[name]
+	if !ok || password != "secret" {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	return &auth.Identity{Name: name, Roles: []auth.Role{role}}, nil
+}
+
+func (a *testAuthenticator) Challenges() []string {
+	return nil
+}
+
+// startTestBroker starts a broker on a random port with topics passing the handled messages to the returned
+// channel, clients are authenticated by authenticator when it is not nil
+func startTestBroker(t *testing.T, acl bool, authenticator auth.Authenticator) (*Broker, chan received) {
+	handled := make(chan received, 10)
+	handler := func(a *models.API, prefix, topic string, message []byte) {
+		handled <- received{topic: topic, payload: string(message)}
+	}
+
+	b := CreateMQTTBroker(configuration.MQTTConfig{Prefix: "GOST", ACL: configuration.MQTTACLConfig{Enabled: acl}}).(*Broker)
+	b.host = "127.0.0.1"
+	b.port = 0
+	b.authenticator = authenticator
+	b.topics = []models.Topic{
+		{Path: "GOST/#", Handler: handler},
+		{Path: subscribeLogTopic, Handler: handler},
+		{Path: unsubscribeLogTopic, Handler: handler},
+	}
+	b.pool.start(nil, "GOST")
+	if err := b.listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b, handled
+}
+
+// testClient is a raw MQTT connection to the test broker
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func connectTestClient(t *testing.T, b *Broker, clientID string) *testClient {
+	return connectTestUser(t, b, clientID, "")
+}
+
+// connectTestUser connects with the password secret when username is not empty
+func connectTestUser(t *testing.T, b *Broker, clientID, username string) *testClient {
+	c, returnCode := dialTestClient(t, b, clientID, username, "secret")
+	if returnCode != connackAccepted {
+		t.Fatalf("connection of %s refused", clientID)
+	}
+
+	return c
+}
+
+// dialTestClient sends CONNECT with the credentials when username is not empty and returns the CONNACK return code
+func dialTestClient(t *testing.T, b *Broker, clientID, username, password string) (*testClient, byte) {
+	conn, err := net.Dial("tcp", b.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var flags byte = 0x02
+	if len(username) > 0 {
+		flags |= 0x80 | 0x40
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = appendUint16(body, 0)
+	body = appendString(body, clientID)
+	if len(username) > 0 {
+		body = appendString(appendString(body, username), password)
+	}
+
+	c := &testClient{conn: conn, r: bufio.NewReader(conn)}
+	conn.Write((&packet{kind: packetConnect, body: body}).encode())
+	p := c.read(t)
+	if p.kind != packetConnack {
+		t.Fatalf("expected CONNACK for %s, received packet type %d", clientID, p.kind)
+	}
+
+	return c, p.body[1]
+}
+
+func (c *testClient) read(t *testing.T) *packet {
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	p, err := readPacket(c.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func (c *testClient) subscribe(t *testing.T, filter string, qos byte) *packet {
+	body := append(appendString(appendUint16(nil, 1), filter), qos)
+	c.conn.Write((&packet{kind: packetSubscribe, flags: 0x02, body: body}).encode())
+	return c.read(t)
+}
+
+func (c *testClient) publish(pub *publishPacket) {
+	c.conn.Write(encodePublish(pub))
+}
+
+func waitHandled(t *testing.T, handled chan received) received {
+	select {
+	case r := <-handled:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not handled")
+	}
+
+	return received{}
+}
+
+func TestMatchTopic(t *testing.T) {
+	assert.True(t, matchTopic("GOST/#", "GOST/Datastreams(1)/Observations"))
+	assert.True(t, matchTopic("GOST/#", "GOST"))
+	assert.True(t, matchTopic("GOST/+/Observations", "GOST/Datastreams(1)/Observations"))
+	assert.True(t, matchTopic("v1.0/Things", "v1.0/Things"))
+	assert.False(t, matchTopic("GOST/+", "GOST/Datastreams(1)/Observations"))
+	assert.False(t, matchTopic("v1.0/Things", "v1.0/Things/name"))
+	assert.False(t, matchTopic("#", "$SYS/broker/log/M/subscribe"), "wildcards should not match $ topics")
+	assert.True(t, matchTopic("$SYS/#", "$SYS/broker/log/M/subscribe"))
+}
+
+func TestValidFilter(t *testing.T) {
+	assert.True(t, validFilter("GOST/#"))
+	assert.True(t, validFilter("+/+/Observations"))
+	assert.False(t, validFilter(""))
+	assert.False(t, validFilter("GOST/#/Observations"))
+	assert.False(t, validFilter("GOST/Data+"))
+}
+
+func TestBrokerRoutesMessages(t *testing.T) {
+	// arrange
+	b, handled := startTestBroker(t, false, nil)
+	defer b.Shutdown(context.Background())
+	subscriber := connectTestClient(t, b, "app")
+	publisher := connectTestClient(t, b, "sensor1")
+
+	// act
+	suback := subscriber.subscribe(t, "v1.0/Things", 2)
+	subscribed := waitHandled(t, handled)
+	b.Publish("v1.0/Things", `{"name":"thing"}`, 0)
+	published := subscriber.read(t)
+	publisher.publish(&publishPacket{topic: "GOST/Datastreams(1)/Observations", qos: 1, packetID: 5, payload: []byte(`{"result":1}`)})
+	puback := publisher.read(t)
+	observation := waitHandled(t, handled)
+
+	// assert
+	assert.Equal(t, packetSuback, suback.kind)
+	assert.Equal(t, byte(1), suback.body[2], "QoS 2 subscriptions should be granted QoS 1")
+	assert.Equal(t, subscribeLogTopic, subscribed.topic)
+	assert.Contains(t, subscribed.payload, "app 1 v1.0/Things", "subscriptions should be reported in the mosquitto log format")
+	pub, _ := parsePublish(published)
+	assert.Equal(t, "v1.0/Things", pub.topic)
+	assert.Equal(t, []byte(`{"name":"thing"}`), pub.payload)
+	assert.Equal(t, encodeAck(packetPuback, 5), puback.encode())
+	assert.Equal(t, received{topic: "GOST/Datastreams(1)/Observations", payload: `{"result":1}`}, observation)
+}
+
+func TestBrokerRetainedMessage(t *testing.T) {
+	// arrange
+	b, _ := startTestBroker(t, false, nil)
+	defer b.Shutdown(context.Background())
+	publisher := connectTestClient(t, b, "sensor1")
+	subscriber := connectTestClient(t, b, "app")
+
+	// act
+	publisher.publish(&publishPacket{topic: "status/sensor1", retain: true, payload: []byte("online")})
+	// the packets of a client are handled in order, the PINGRESP follows the handled PUBLISH
+	publisher.conn.Write((&packet{kind: packetPingreq}).encode())
+	publisher.read(t)
+	subscriber.subscribe(t, "status/+", 0)
+	retained, err := parsePublish(subscriber.read(t))
+
+	// assert
+	assert.Nil(t, err)
+	assert.True(t, retained.retain)
+	assert.Equal(t, []byte("online"), retained.payload)
+}
+
+func TestBrokerACL(t *testing.T) {
+	// arrange
+	b, handled := startTestBroker(t, true, &testAuthenticator{roles: map[string]auth.Role{"sensor1": auth.RoleIngest}})
+	defer b.Shutdown(context.Background())
+	publisher := connectTestUser(t, b, "client1", "sensor1")
+
+	// act
+	publisher.publish(&publishPacket{topic: "GOST/sensor2/Datastreams(1)/Observations", payload: []byte("rejected")})
+	publisher.publish(&publishPacket{topic: "GOST/client1/Datastreams(1)/Observations", payload: []byte("rejected")})
+	publisher.publish(&publishPacket{topic: "GOST/sensor1/Datastreams(1)/Observations", payload: []byte("allowed")})
+	observation := waitHandled(t, handled)
+
+	// assert
+	assert.Equal(t, "allowed", observation.payload, "clients should only publish on the level of their username")
+}
+
+func TestBrokerACLRejectsAnonymousClients(t *testing.T) {
+	// arrange
+	b, handled := startTestBroker(t, true, nil)
+	defer b.Shutdown(context.Background())
+	publisher := connectTestClient(t, b, "sensor1")
+
+	// act
+	publisher.publish(&publishPacket{topic: "GOST/sensor1/Datastreams(1)/Observations", payload: []byte("rejected")})
+	publisher.subscribe(t, "status/sensor1", 0)
+	handledMessage := waitHandled(t, handled)
+
+	// assert
+	assert.Equal(t, subscribeLogTopic, handledMessage.topic, "anonymous clients should not publish below the prefix")
+}
+
+func TestBrokerAuthentication(t *testing.T) {
+	// arrange
+	b, handled := startTestBroker(t, false, &testAuthenticator{roles: map[string]auth.Role{"sensor1": auth.RoleIngest, "reader": auth.RoleRead}})
+	defer b.Shutdown(context.Background())
+
+	// act
+	_, wrongPassword := dialTestClient(t, b, "sensor1", "sensor1", "wrong")
+	_, anonymous := dialTestClient(t, b, "anonymous", "", "")
+	reader := connectTestUser(t, b, "reader", "reader")
+	reader.publish(&publishPacket{topic: "GOST/Datastreams(1)/Observations", payload: []byte("rejected")})
+	publisher := connectTestUser(t, b, "sensor1", "sensor1")
+	publisher.publish(&publishPacket{topic: "GOST/Datastreams(1)/Observations", payload: []byte("allowed")})
+	observation := waitHandled(t, handled)
+
+	// assert
+	assert.Equal(t, connackBadCredentials, wrongPassword)
+	assert.Equal(t, connackNotAuthorized, anonymous)
+	assert.Equal(t, "allowed", observation.payload, "a reader should not post observations")
+}
+
+func TestBrokerSessionTakeover(t *testing.T) {
+	// arrange
+	b, _ := startTestBroker(t, false, &testAuthenticator{roles: map[string]auth.Role{"sensor1": auth.RoleIngest, "sensor2": auth.RoleIngest}})
+	defer b.Shutdown(context.Background())
+	connectTestUser(t, b, "client1", "sensor1")
+
+	// act
+	_, otherUser := dialTestClient(t, b, "client1", "sensor2", "secret")
+	_, sameUser := dialTestClient(t, b, "client1", "sensor1", "secret")
+
+	// assert
+	assert.Equal(t, connackIdentifierRejected, otherUser, "another user should not take over the session")
+	assert.Equal(t, connackAccepted, sameUser)
+}
+
+func TestBrokerShutdown(t *testing.T) {
+	// arrange
+	b, _ := startTestBroker(t, false, nil)
+	client := connectTestClient(t, b, "app")
+	connected := b.IsConnected()
+
+	// act
+	err := b.Shutdown(context.Background())
+	client.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, readErr := readPacket(client.r)
+
+	// assert
+	assert.True(t, connected)
+	assert.Nil(t, err)
+	assert.False(t, b.IsConnected())
+	assert.NotNil(t, readErr, "connections should be closed on shutdown")
+	assert.Equal(t, 0, b.clients())
+}
+
+func TestBrokerRefusesSystemSubscriptions(t *testing.T) {
+	// arrange
+	b, _ := startTestBroker(t, false, nil)
+	defer b.Shutdown(context.Background())
+	subscriber := connectTestClient(t, b, "app")
+
+	// act
+	suback := subscriber.subscribe(t, "$SYS/broker/log/M/#", 0)
+
+	// assert
+	assert.Equal(t, subackFailure, suback.body[2], "clients should not subscribe to $ topics")
+}
+
+func TestBrokerRefusesFirstLevelWildcardSubscriptions(t *testing.T) {
+	// arrange
+	b, _ := startTestBroker(t, false, &testAuthenticator{roles: map[string]auth.Role{"admin": auth.RoleAdmin}})
+	defer b.Shutdown(context.Background())
+	subscriber := connectTestUser(t, b, "app", "admin")
+
+	// act
+	all := subscriber.subscribe(t, "#", 0)
+	level := subscriber.subscribe(t, "+/sensor1/#", 0)
+
+	// assert
+	assert.Equal(t, subackFailure, all.body[2])
+	assert.Equal(t, subackFailure, level.body[2])
+}
+
+func TestBrokerSensorThingsSubscriptionsNeedReadRole(t *testing.T) {
+	// arrange
+	b, _ := startTestBroker(t, false, &testAuthenticator{roles: map[string]auth.Role{"sensor1": auth.RoleIngest, "reader": auth.RoleRead}})
+	defer b.Shutdown(context.Background())
+	publisher := connectTestUser(t, b, "sensor1", "sensor1")
+	reader := connectTestUser(t, b, "reader", "reader")
+
+	// act
+	publisherThings := publisher.subscribe(t, "v1.0/Things", 0)
+	publisherAll := publisher.subscribe(t, "v1.0/#", 0)
+	readerThings := reader.subscribe(t, "v1.0/Things(1)/Datastreams", 0)
+	readerAll := reader.subscribe(t, "v1.0/#", 0)
+
+	// assert
+	assert.Equal(t, subackFailure, publisherThings.body[2], "the ingest role should not read entities")
+	assert.Equal(t, subackFailure, publisherAll.body[2])
+	assert.Equal(t, byte(0), readerThings.body[2])
+	assert.Equal(t, byte(0), readerAll.body[2])
+}
+
+func TestBrokerACLRefusesSubscriptionsOfOtherPublishers(t *testing.T) {
+	// arrange
+	b, _ := startTestBroker(t, true, &testAuthenticator{roles: map[string]auth.Role{"sensor1": auth.RoleIngest}})
+	defer b.Shutdown(context.Background())
+	publisher := connectTestUser(t, b, "client1", "sensor1")
+
+	// act
+	other := publisher.subscribe(t, "GOST/sensor2/#", 0)
+	wildcard := publisher.subscribe(t, "GOST/+/Datastreams(1)/Observations/errors", 0)
+	own := publisher.subscribe(t, "GOST/sensor1/#", 0)
+
+	// assert
+	assert.Equal(t, subackFailure, other.body[2])
+	assert.Equal(t, subackFailure, wildcard.body[2])
+	assert.Equal(t, byte(0), own.body[2], "a publisher should subscribe to its own level")
+}
@@ -1,7 +1,10 @@
 package mqtt
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"crypto/tls"
@@ -9,6 +12,7 @@ import (
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gost/server/configuration"
 	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/metrics"
 	"github.com/gost/server/sensorthings/models"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
@@ -16,6 +20,15 @@ import (
 
 var logger *log.Entry
 
+const (
+	// brokerLogTopic is the topic mosquitto reports subscriptions on when configured with log_dest topic
+	// and log_type subscribe, GOST tracks the subscriptions on SensorThings topics with it
+	brokerLogTopic = "$SYS/broker/log/M/subscribe"
+
+	// brokerLogTimeout is the time the broker has to report the subscriptions of GOST itself on brokerLogTopic
+	brokerLogTimeout = 10 * time.Second
+)
+
 // MQTT is the implementation of the MQTT client
 type MQTT struct {
 	host            string
@@ -39,15 +52,21 @@ type MQTT struct {
 	verbose         bool
 	api             *models.API
 	connectToken 	*paho.ConnectToken
+	// pool handles the received messages, stopping is set on shutdown
+	pool     *ingestPool
+	mutex    sync.Mutex
+	stopping bool
+	// brokerLog is set to 1 when a message is received on brokerLogTopic
+	brokerLog int32
 }
 
 func setupLogger(verbose bool) {
-	l, err := gostLog.GetLoggerInstance()
+	l, err := gostLog.GetPackageLogger("gost.server.mqtt")
 	if err != nil {
 		log.Error(err)
 	}
 
-	logger = l.WithFields(log.Fields{"package": "gost.server.mqtt"})
+	logger = l
 
 	if verbose {
 		paho.ERROR = logger
@@ -139,6 +158,8 @@ func CreateMQTTClient(config configuration.MQTTConfig) models.MQTTClient {
 		pingTimeoutSec:	 config.PingTimeoutSec,
 	}
 
+	mqttClient.pool = newConfiguredIngestPool(config)
+
 	opts, err := initMQTTClientOptions(mqttClient)
 	if err != nil {
 		logger.Errorf("unable to configure MQTT client: %s", err)
@@ -153,6 +174,10 @@ func CreateMQTTClient(config configuration.MQTTConfig) models.MQTTClient {
 // Start running the MQTT client
 func (m *MQTT) Start(api *models.API) {
 	m.api = api
+	m.pool.start(api, m.prefix)
+	metrics.NewGaugeFunc("gost_mqtt_ingest_queue_depth", "Number of MQTT messages waiting to be handled.", func() float64 {
+		return float64(m.pool.depth())
+	})
 	logger.Infof("Starting MQTT client on %s://%s:%v with Prefix:%v, Persistence:%v, OrderMatters:%v, KeepAlive:%v, PingTimeout:%v, QOS:%v",
 		m.getProtocol(), m.host, m.port,m.prefix,m.persistent,m.order,m.keepAliveSec,m.pingTimeoutSec,m.subscriptionQos )
 	m.connect()
@@ -163,6 +188,27 @@ func (m *MQTT) Stop() {
 	m.client.Disconnect(500)
 }
 
+// Shutdown disconnects from the broker so no new messages are received and waits until the queued
+// messages are handled, the error of ctx is returned when ctx is done before they are handled
+func (m *MQTT) Shutdown(ctx context.Context) error {
+	logger.Infof("Stopping MQTT client")
+
+	// disconnect before stopping so messages received until the disconnect are still handled, the
+	// broker keeps the messages sent after it for a persistent session
+	m.client.Disconnect(250)
+	m.mutex.Lock()
+	m.stopping = true
+	m.mutex.Unlock()
+
+	return m.pool.close(ctx)
+}
+
+func (m *MQTT) isStopping() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stopping
+}
+
 func (m *MQTT) subscribe() {
 	a := *m.api
 	topics := *a.GetTopics(m.prefix)
@@ -172,11 +218,41 @@ func (m *MQTT) subscribe() {
 		logger.Infof("MQTT client subscribing to %s", topic.Path)
 
 		if token := m.client.Subscribe(topic.Path, m.subscriptionQos, func(client paho.Client, msg paho.Message) {
-			go topic.Handler(m.api, m.prefix, msg.Topic(), msg.Payload())
+			if msg.Topic() == brokerLogTopic {
+				atomic.StoreInt32(&m.brokerLog, 1)
+			}
+
+			// enqueue blocks while the queue is full so the broker stops sending until the workers catch up
+			if !m.pool.enqueue(message{subscription: &topic, topic: msg.Topic(), payload: msg.Payload()}) {
+				if m.isStopping() {
+					logger.Warnf("MQTT client stopping, message on %s dropped", msg.Topic())
+				} else {
+					logger.Warnf("MQTT ingest queue full, message on %s dropped (%d messages dropped)", msg.Topic(), m.pool.droppedCount())
+				}
+			}
 		}); token.Wait() && token.Error() != nil {
 			logger.Error(token.Error())
 		}
 	}
+
+	m.checkBrokerLog(topics)
+}
+
+// checkBrokerLog warns when the broker does not report subscriptions on brokerLogTopic, the topics
+// subscribed after it are reported by the broker when its log is published
+func (m *MQTT) checkBrokerLog(topics []models.Topic) {
+	for _, topic := range topics {
+		if topic.Path != brokerLogTopic {
+			continue
+		}
+
+		time.AfterFunc(brokerLogTimeout, func() {
+			if atomic.LoadInt32(&m.brokerLog) == 0 && !m.isStopping() {
+				logger.Warnf("MQTT broker does not publish subscriptions on %s, subscriptions on SensorThings topics are not tracked. For mosquitto add log_dest topic and log_type subscribe unsubscribe notice to mosquitto.conf", brokerLogTopic)
+			}
+		})
+		return
+	}
 }
 
 // Publish a message on a topic
@@ -185,6 +261,11 @@ func (m *MQTT) Publish(topic string, message string, qos byte) {
 	token.Wait()
 }
 
+// IsConnected returns true when the client is connected to the broker
+func (m *MQTT) IsConnected() bool {
+	return m.client.IsConnected()
+}
+
 func (m *MQTT) connect() {
 	m.connectToken = m.client.Connect().(*paho.ConnectToken)
 	if m.connectToken.Wait() && m.connectToken.Error() != nil {
@@ -204,6 +285,11 @@ func (m *MQTT) retryConnect() {
 	ticker := time.NewTicker(time.Second * 5)
 	go func() {
 		for range ticker.C {
+			if m.isStopping() {
+				ticker.Stop()
+				return
+			}
+
 			m.connect()
 			if m.client.IsConnected() {
 				ticker.Stop()
@@ -215,6 +301,10 @@ func (m *MQTT) retryConnect() {
 
 func (m *MQTT) connectHandler(c paho.Client) {
 	logger.Infof("MQTT client connected")
+	if m.disconnected {
+		metrics.MQTTReconnects.Inc()
+	}
+
 	hasSession := m.connectToken.SessionPresent()
 	logger.Infof("MQTT Session present: %v", hasSession);
 
@@ -229,6 +319,10 @@ func (m *MQTT) connectHandler(c paho.Client) {
 //ToDo: bubble up and call retryConnect?
 func (m *MQTT) connectionLostHandler(c paho.Client, err error) {
 	logger.Warnf("MQTT client lost connection: %v", err)
+	if m.isStopping() {
+		return
+	}
+
 	m.disconnected = true
 	m.retryConnect()
 }
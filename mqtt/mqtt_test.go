@@ -1,7 +1,11 @@
 package mqtt
 
 import (
+	"context"
+	"time"
+
 	"github.com/gost/server/configuration"
+	"github.com/gost/server/sensorthings/models"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -18,3 +22,25 @@ func TestMqtt(t *testing.T) {
 	// assert
 	assert.NotNil(t, mqttClient, "function should return MqqtClient")
 }
+
+func TestShutdown(t *testing.T) {
+	// arrange
+	m := CreateMQTTClient(configuration.MQTTConfig{}).(*MQTT)
+	release := make(chan struct{})
+	topic := &models.Topic{Handler: func(a *models.API, prefix, topic string, message []byte) { <-release }}
+	m.pool.start(nil, "GOST")
+	queued := m.pool.enqueue(message{subscription: topic, topic: "GOST/Datastreams(1)/Observations", payload: []byte("{}")})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// act
+	timeoutErr := m.Shutdown(ctx)
+	close(release)
+	err := m.Shutdown(context.Background())
+
+	// assert
+	assert.True(t, queued)
+	assert.Equal(t, context.DeadlineExceeded, timeoutErr)
+	assert.Nil(t, err)
+	assert.False(t, m.pool.enqueue(message{subscription: topic}), "no messages should be queued after shutdown")
+}
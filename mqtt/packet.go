@@ -0,0 +1,323 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetPubrec      byte = 5
+	packetPubrel      byte = 6
+	packetPubcomp     byte = 7
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetUnsubscribe byte = 10
+	packetUnsuback    byte = 11
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+)
+
+// CONNACK return codes and the SUBACK return code of a refused subscription
+const (
+	connackAccepted           byte = 0
+	connackBadProtocolVersion byte = 1
+	connackIdentifierRejected byte = 2
+	connackBadCredentials     byte = 4
+	connackNotAuthorized      byte = 5
+	subackFailure             byte = 0x80
+)
+
+const (
+	// protocolName and protocolLevel identify MQTT 3.1.1 in the CONNECT packet
+	protocolName       = "MQTT"
+	protocolLevel byte = 4
+	// maxPacketSize is the largest packet accepted, a dataArray of many observations fits in it
+	maxPacketSize = 16 * 1024 * 1024
+	// maxRemainingLengthBytes is the maximum number of bytes encoding the remaining length
+	maxRemainingLengthBytes = 4
+)
+
+var errMalformedPacket = errors.New("malformed MQTT packet")
+
+// packet is a MQTT control packet, flags are the lower 4 bits of the fixed header
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// connectPacket holds the fields of a CONNECT packet used by the broker
+type connectPacket struct {
+	protocol     string
+	level        byte
+	cleanSession bool
+	keepAlive    uint16
+	clientID     string
+	username     string
+	password     string
+	will         *publishPacket
+}
+
+// publishPacket holds the fields of a PUBLISH packet
+type publishPacket struct {
+	topic    string
+	qos      byte
+	retain   bool
+	dup      bool
+	packetID uint16
+	payload  []byte
+}
+
+// subscription is a topic filter with the requested QoS of a SUBSCRIBE packet
+type subscription struct {
+	filter string
+	qos    byte
+}
+
+// readPacket reads the next control packet, packets larger than maxPacketSize are rejected
+func readPacket(r *bufio.Reader) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	length := 0
+	multiplier := 1
+	for i := 0; ; i++ {
+		if i == maxRemainingLengthBytes {
+			return nil, errMalformedPacket
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		length += int(b&127) * multiplier
+		multiplier *= 128
+		if b&128 == 0 {
+			break
+		}
+	}
+
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("MQTT packet of %d bytes exceeds the maximum of %d bytes", length, maxPacketSize)
+	}
+
+	p := &packet{kind: header >> 4, flags: header & 0x0f, body: make([]byte, length)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// encode returns the bytes of the packet including the fixed header
+func (p *packet) encode() []byte {
+	b := []byte{p.kind<<4 | p.flags}
+	length := len(p.body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 128
+		}
+
+		b = append(b, digit)
+		if length == 0 {
+			break
+		}
+	}
+
+	return append(b, p.body...)
+}
+
+// reader reads the fields of a packet body
+type reader struct {
+	body []byte
+	err  error
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil || len(r.body) < 1 {
+		r.err = errMalformedPacket
+		return 0
+	}
+
+	b := r.body[0]
+	r.body = r.body[1:]
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	if r.err != nil || len(r.body) < 2 {
+		r.err = errMalformedPacket
+		return 0
+	}
+
+	v := binary.BigEndian.Uint16(r.body)
+	r.body = r.body[2:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := int(r.uint16())
+	if r.err != nil || len(r.body) < n {
+		r.err = errMalformedPacket
+		return nil
+	}
+
+	b := r.body[:n]
+	r.body = r.body[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// parseConnect parses the body of a CONNECT packet, a password is only allowed after a username
+func parseConnect(p *packet) (*connectPacket, error) {
+	r := &reader{body: p.body}
+	c := &connectPacket{protocol: r.string(), level: r.byte()}
+	flags := r.byte()
+	c.keepAlive = r.uint16()
+	c.clientID = r.string()
+	if flags&0x01 != 0 || (flags&0x40 != 0 && flags&0x80 == 0) {
+		return nil, errMalformedPacket
+	}
+
+	c.cleanSession = flags&0x02 != 0
+	if flags&0x04 != 0 {
+		c.will = &publishPacket{qos: (flags >> 3) & 0x03, retain: flags&0x20 != 0}
+		c.will.topic = r.string()
+		c.will.payload = r.bytes()
+	}
+
+	if flags&0x80 != 0 {
+		c.username = r.string()
+	}
+
+	if flags&0x40 != 0 {
+		c.password = r.string()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return c, nil
+}
+
+// parsePublish parses a PUBLISH packet, the packet id is only present for QoS 1 and 2
+func parsePublish(p *packet) (*publishPacket, error) {
+	r := &reader{body: p.body}
+	pub := &publishPacket{
+		dup:    p.flags&0x08 != 0,
+		qos:    (p.flags >> 1) & 0x03,
+		retain: p.flags&0x01 != 0,
+		topic:  r.string(),
+	}
+
+	if pub.qos > 2 {
+		return nil, errMalformedPacket
+	}
+
+	if pub.qos > 0 {
+		pub.packetID = r.uint16()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	pub.payload = r.body
+	return pub, nil
+}
+
+// parseSubscribe parses a SUBSCRIBE packet or an UNSUBSCRIBE packet when unsubscribe is set, which has
+// no QoS after the topic filters
+func parseSubscribe(p *packet, unsubscribe bool) (uint16, []subscription, error) {
+	r := &reader{body: p.body}
+	id := r.uint16()
+	subscriptions := make([]subscription, 0)
+	for r.err == nil && len(r.body) > 0 {
+		s := subscription{filter: r.string()}
+		if !unsubscribe {
+			s.qos = r.byte()
+		}
+
+		subscriptions = append(subscriptions, s)
+	}
+
+	if r.err != nil || len(subscriptions) == 0 {
+		return 0, nil, errMalformedPacket
+	}
+
+	return id, subscriptions, nil
+}
+
+// parsePacketID parses the packet id of a PUBACK, PUBREC, PUBREL or PUBCOMP packet
+func parsePacketID(p *packet) (uint16, error) {
+	r := &reader{body: p.body}
+	id := r.uint16()
+	return id, r.err
+}
+
+func encodeConnack(returnCode byte) []byte {
+	return (&packet{kind: packetConnack, body: []byte{0, returnCode}}).encode()
+}
+
+func encodePublish(pub *publishPacket) []byte {
+	flags := pub.qos << 1
+	if pub.retain {
+		flags |= 0x01
+	}
+
+	if pub.dup {
+		flags |= 0x08
+	}
+
+	body := appendString(make([]byte, 0, len(pub.topic)+len(pub.payload)+4), pub.topic)
+	if pub.qos > 0 {
+		body = appendUint16(body, pub.packetID)
+	}
+
+	return (&packet{kind: packetPublish, flags: flags, body: append(body, pub.payload...)}).encode()
+}
+
+// encodeAck encodes a packet holding only a packet id: PUBACK, PUBREC, PUBREL, PUBCOMP or UNSUBACK
+func encodeAck(kind byte, packetID uint16) []byte {
+	var flags byte
+	if kind == packetPubrel {
+		flags = 0x02
+	}
+
+	return (&packet{kind: kind, flags: flags, body: appendUint16(nil, packetID)}).encode()
+}
+
+func encodeSuback(packetID uint16, granted []byte) []byte {
+	return (&packet{kind: packetSuback, body: append(appendUint16(nil, packetID), granted...)}).encode()
+}
+
+func encodePingresp() []byte {
+	return (&packet{kind: packetPingresp}).encode()
+}
@@ -0,0 +1,106 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacketRemainingLength(t *testing.T) {
+	// arrange
+	p := &packet{kind: packetPublish, flags: 0x02, body: make([]byte, 321)}
+
+	// act
+	encoded := p.encode()
+	decoded, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x32, 0xc1, 0x02}, encoded[:3], "remaining length should be encoded in two bytes")
+	assert.Equal(t, packetPublish, decoded.kind)
+	assert.Equal(t, byte(0x02), decoded.flags)
+	assert.Equal(t, 321, len(decoded.body))
+}
+
+func TestReadPacketMalformedLength(t *testing.T) {
+	// act
+	_, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01})))
+
+	// assert
+	assert.Equal(t, errMalformedPacket, err)
+}
+
+func TestParseConnect(t *testing.T) {
+	// arrange
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, 0x02|0x04|0x08|0x80|0x40)
+	body = appendUint16(body, 60)
+	body = appendString(body, "sensor1")
+	body = appendString(body, "GOST/sensor1/status")
+	body = appendString(body, "offline")
+	body = appendString(body, "user")
+	body = appendString(body, "secret")
+
+	// act
+	c, err := parseConnect(&packet{kind: packetConnect, body: body})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "MQTT", c.protocol)
+	assert.Equal(t, byte(4), c.level)
+	assert.True(t, c.cleanSession)
+	assert.Equal(t, uint16(60), c.keepAlive)
+	assert.Equal(t, "sensor1", c.clientID)
+	assert.Equal(t, "user", c.username)
+	assert.Equal(t, "secret", c.password)
+	assert.Equal(t, "GOST/sensor1/status", c.will.topic)
+	assert.Equal(t, []byte("offline"), c.will.payload)
+	assert.Equal(t, byte(1), c.will.qos)
+}
+
+func TestParseConnectPasswordWithoutUsername(t *testing.T) {
+	// arrange
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, 0x02|0x40)
+	body = appendUint16(body, 60)
+	body = appendString(body, "sensor1")
+	body = appendString(body, "secret")
+
+	// act
+	_, err := parseConnect(&packet{kind: packetConnect, body: body})
+
+	// assert
+	assert.Equal(t, errMalformedPacket, err)
+}
+
+func TestPublishRoundTrip(t *testing.T) {
+	// arrange
+	pub := &publishPacket{topic: "GOST/Datastreams(1)/Observations", qos: 1, retain: true, packetID: 7, payload: []byte(`{"result":1}`)}
+
+	// act
+	p, _ := readPacket(bufio.NewReader(bytes.NewReader(encodePublish(pub))))
+	parsed, err := parsePublish(p)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, pub, parsed)
+}
+
+func TestParseSubscribe(t *testing.T) {
+	// arrange
+	body := appendUint16(nil, 3)
+	body = append(appendString(body, "v1.0/Things"), 1)
+	body = append(appendString(body, "GOST/#"), 0)
+
+	// act
+	id, subscriptions, err := parseSubscribe(&packet{kind: packetSubscribe, body: body}, false)
+	_, _, emptyErr := parseSubscribe(&packet{kind: packetSubscribe, body: appendUint16(nil, 3)}, false)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(3), id)
+	assert.Equal(t, []subscription{{filter: "v1.0/Things", qos: 1}, {filter: "GOST/#", qos: 0}}, subscriptions)
+	assert.NotNil(t, emptyErr, "a SUBSCRIBE without topic filters is malformed")
+}
@@ -0,0 +1,248 @@
+package mqtt
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/metrics"
+	"github.com/gost/server/sensorthings/models"
+)
+
+// message is a received MQTT message waiting to be handled
+type message struct {
+	subscription *models.Topic
+	topic        string
+	payload      []byte
+}
+
+// ingestPool handles the received messages with a fixed number of workers so a burst of messages, for
+// example the messages queued by the broker while GOST was offline, does not open a database connection
+// per message. A message received while the queue is full blocks the caller until there is a place, so
+// the broker stops sending, and is dropped when there is no place within the timeout. When ordered is set
+// the messages of a topic are always handled by the same worker so they are inserted in order of arrival,
+// a message then waits until shutdown instead of the timeout
+type ingestPool struct {
+	// dropped is the first field so it is 64-bit aligned for atomic operations on 32-bit platforms
+	dropped   uint64
+	api       *models.API
+	prefix    string
+	queues    []chan message
+	batchSize int
+	workers   int
+	timeout   time.Duration
+	ordered   bool
+	mutex     sync.RWMutex
+	closed    bool
+	abort     chan struct{}
+	abortOnce sync.Once
+	done      sync.WaitGroup
+}
+
+// newIngestPool creates a pool with queueSize places divided over the queues of the workers when ordered
+// is set or shared by the workers otherwise
+func newIngestPool(workers, queueSize, batchSize int, timeout time.Duration, ordered bool) *ingestPool {
+	p := &ingestPool{batchSize: batchSize, workers: workers, timeout: timeout, ordered: ordered, abort: make(chan struct{})}
+	if !ordered {
+		p.queues = []chan message{make(chan message, queueSize)}
+		return p
+	}
+
+	size := (queueSize + workers - 1) / workers
+	for i := 0; i < workers; i++ {
+		p.queues = append(p.queues, make(chan message, size))
+	}
+
+	return p
+}
+
+// newConfiguredIngestPool creates a pool with the workers, queue size, queue timeout and batch size of the
+// configuration or their defaults when not set
+func newConfiguredIngestPool(config configuration.MQTTConfig) *ingestPool {
+	workers, queueSize, queueTimeoutSec, batchSize := config.Workers, config.QueueSize, config.QueueTimeoutSec, config.BatchSize
+	if workers <= 0 {
+		workers = configuration.DefaultMQTTWorkers
+	}
+
+	if queueSize <= 0 {
+		queueSize = configuration.DefaultMQTTQueueSize
+	}
+
+	if queueTimeoutSec <= 0 {
+		queueTimeoutSec = configuration.DefaultMQTTQueueTimeoutSec
+	}
+
+	if batchSize <= 0 {
+		batchSize = configuration.DefaultMQTTBatchSize
+	}
+
+	return newIngestPool(workers, queueSize, batchSize, time.Duration(queueTimeoutSec)*time.Second, config.Order)
+}
+
+// start starts the workers
+func (p *ingestPool) start(api *models.API, prefix string) {
+	p.api = api
+	p.prefix = prefix
+	for i := 0; i < p.workers; i++ {
+		p.done.Add(1)
+		go p.work(p.queues[i%len(p.queues)])
+	}
+}
+
+// enqueue adds a message to the queue of its topic, when the queue is full it waits for a place up to the
+// timeout, or until the context of close is done when ordered is set. False is returned when the message
+// is dropped because there was no place in time or the pool is closed
+func (p *ingestPool) enqueue(m message) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed {
+		return false
+	}
+
+	queue := p.queue(m.topic)
+	select {
+	case queue <- m:
+		return true
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if !p.ordered {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case queue <- m:
+		return true
+	case <-timeout:
+	case <-p.abort:
+	}
+
+	atomic.AddUint64(&p.dropped, 1)
+	metrics.MQTTIngestDropped.Inc()
+	return false
+}
+
+// droppedCount returns the number of messages dropped because the queue was full
+func (p *ingestPool) droppedCount() uint64 {
+	return atomic.LoadUint64(&p.dropped)
+}
+
+func (p *ingestPool) queue(topic string) chan message {
+	if len(p.queues) == 1 {
+		return p.queues[0]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(topic))
+	return p.queues[h.Sum32()%uint32(len(p.queues))]
+}
+
+// depth returns the number of messages waiting to be handled
+func (p *ingestPool) depth() int {
+	depth := 0
+	for _, q := range p.queues {
+		depth += len(q)
+	}
+
+	return depth
+}
+
+// close stops accepting messages and waits until the workers handled the queued messages, the error of
+// ctx is returned when ctx is done before the queues are empty. Messages waiting for a place in a full
+// queue are still added until ctx is done, after that they are dropped
+func (p *ingestPool) close(ctx context.Context) error {
+	locked := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.abortOnce.Do(func() { close(p.abort) })
+		case <-locked:
+		}
+	}()
+
+	p.mutex.Lock()
+	close(locked)
+	if !p.closed {
+		p.closed = true
+		for _, q := range p.queues {
+			close(q)
+		}
+	}
+	p.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.done.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work handles the messages of a queue, the messages waiting in the queue are taken up to the batch size
+// and handled at once
+func (p *ingestPool) work(queue chan message) {
+	defer p.done.Done()
+	for m := range queue {
+		batch := []message{m}
+	fill:
+		for len(batch) < p.batchSize {
+			select {
+			case next, ok := <-queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+
+		p.handle(batch)
+	}
+}
+
+// handle groups the messages of a batch by topic, a group is passed to the BatchHandler of the
+// subscription when it has one, otherwise every message is passed to the Handler
+func (p *ingestPool) handle(batch []message) {
+	groups := make([][]message, 0)
+	index := make(map[string]int)
+	for _, m := range batch {
+		i, ok := index[m.topic]
+		if !ok {
+			i = len(groups)
+			index[m.topic] = i
+			groups = append(groups, nil)
+		}
+
+		groups[i] = append(groups[i], m)
+	}
+
+	for _, group := range groups {
+		subscription := group[0].subscription
+		if len(group) > 1 && subscription.BatchHandler != nil {
+			payloads := make([][]byte, len(group))
+			for i, m := range group {
+				payloads[i] = m.payload
+			}
+
+			subscription.BatchHandler(p.api, p.prefix, group[0].topic, payloads)
+			continue
+		}
+
+		for _, m := range group {
+			subscription.Handler(p.api, p.prefix, m.topic, m.payload)
+		}
+	}
+}
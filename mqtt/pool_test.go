@@ -0,0 +1,132 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gost/server/sensorthings/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	// arrange
+	p := newIngestPool(1, 1, 1, 10*time.Millisecond, false)
+	m := message{subscription: &models.Topic{}, topic: "GOST/Datastreams(1)/Observations"}
+
+	// act
+	first := p.enqueue(m)
+	second := p.enqueue(m)
+
+	// assert
+	assert.True(t, first)
+	assert.False(t, second, "message should be dropped when the queue stays full")
+	assert.Equal(t, uint64(1), p.droppedCount())
+	assert.Equal(t, 1, p.depth())
+}
+
+func TestEnqueueWaitsForPlace(t *testing.T) {
+	// arrange
+	p := newIngestPool(1, 1, 1, time.Second, false)
+	m := message{subscription: &models.Topic{}, topic: "GOST/Datastreams(1)/Observations"}
+	p.enqueue(m)
+	enqueued := make(chan bool)
+
+	// act
+	go func() { enqueued <- p.enqueue(m) }()
+	<-p.queues[0]
+
+	// assert
+	assert.True(t, <-enqueued, "message should be added when a place comes free")
+	assert.Equal(t, uint64(0), p.droppedCount())
+	assert.Equal(t, 1, p.depth())
+}
+
+func TestOrderedEnqueueWaitsUntilShutdown(t *testing.T) {
+	// arrange
+	p := newIngestPool(1, 1, 1, time.Millisecond, true)
+	m := message{subscription: &models.Topic{}, topic: "GOST/Datastreams(1)/Observations"}
+	p.enqueue(m)
+	enqueued := make(chan bool)
+	go func() { enqueued <- p.enqueue(m) }()
+
+	// act
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-enqueued:
+		t.Fatal("ordered message should not be dropped after the timeout")
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	p.close(ctx)
+
+	// assert
+	assert.False(t, <-enqueued, "message should be dropped when the shutdown context is done")
+	assert.Equal(t, uint64(1), p.droppedCount())
+}
+
+func TestOrderedQueues(t *testing.T) {
+	// arrange
+	p := newIngestPool(4, 10, 1, time.Second, true)
+
+	// act
+	q1 := p.queue("GOST/Datastreams(1)/Observations")
+	q2 := p.queue("GOST/Datastreams(1)/Observations")
+
+	// assert
+	assert.Equal(t, 4, len(p.queues))
+	assert.Equal(t, 3, cap(p.queues[0]), "queue size should be divided over the workers")
+	assert.Equal(t, q1, q2, "messages of a topic should be handled by the same worker")
+}
+
+func TestHandleGroupsByTopic(t *testing.T) {
+	// arrange
+	handled := make([]string, 0)
+	batches := make(map[string]int)
+	topic := &models.Topic{
+		Handler: func(a *models.API, prefix, topic string, message []byte) {
+			handled = append(handled, topic)
+		},
+		BatchHandler: func(a *models.API, prefix, topic string, messages [][]byte) {
+			batches[topic] = len(messages)
+		},
+	}
+	p := newIngestPool(1, 10, 10, time.Second, false)
+
+	// act
+	p.handle([]message{
+		{subscription: topic, topic: "GOST/Datastreams(1)/Observations"},
+		{subscription: topic, topic: "GOST/Datastreams(2)/Observations"},
+		{subscription: topic, topic: "GOST/Datastreams(1)/Observations"},
+	})
+
+	// assert
+	assert.Equal(t, 2, batches["GOST/Datastreams(1)/Observations"])
+	assert.Equal(t, []string{"GOST/Datastreams(2)/Observations"}, handled, "single message should be passed to the Handler")
+}
+
+func TestCloseHandlesQueuedMessages(t *testing.T) {
+	// arrange
+	var mutex sync.Mutex
+	count := 0
+	topic := &models.Topic{Handler: func(a *models.API, prefix, topic string, message []byte) {
+		mutex.Lock()
+		count++
+		mutex.Unlock()
+	}}
+	p := newIngestPool(2, 10, 1, time.Second, true)
+	p.start(nil, "GOST")
+
+	// act
+	p.enqueue(message{subscription: topic, topic: "GOST/Datastreams(1)/Observations"})
+	p.enqueue(message{subscription: topic, topic: "GOST/Datastreams(2)/Observations"})
+	p.enqueue(message{subscription: topic, topic: "GOST/Datastreams(3)/Observations"})
+	err := p.close(context.Background())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 0, p.depth())
+}
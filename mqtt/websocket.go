@@ -0,0 +1,230 @@
+package mqtt
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the key of the client to compute the accept header (RFC 6455)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// websocket frame opcodes
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xa
+)
+
+var errTextFrame = errors.New("MQTT over websockets requires binary frames")
+
+// wsConn carries MQTT packets in binary websocket frames, a packet can be split over frames so the
+// payloads are read as a stream. Control frames are answered while reading
+type wsConn struct {
+	net.Conn
+	r          *bufio.Reader
+	remaining  uint64
+	mask       [4]byte
+	maskOffset int
+	writeMutex sync.Mutex
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept header value for the Sec-WebSocket-Key of a client
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// upgradeWebsocket upgrades a HTTP request to a websocket connection using the mqtt subprotocol
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, errors.New("no websocket upgrade requested")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != "GET" || r.Header.Get("Sec-WebSocket-Version") != "13" || len(key) == 0 {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket request", http.StatusBadRequest)
+		return nil, errors.New("unsupported websocket request")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response cannot be hijacked")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + websocketAccept(key) + "\r\n"
+	for _, protocol := range strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",") {
+		if p := strings.TrimSpace(protocol); p == "mqtt" || p == "mqttv3.1" {
+			response += "Sec-WebSocket-Protocol: " + p + "\r\n"
+			break
+		}
+	}
+
+	if _, err := rw.WriteString(response + "\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{Conn: conn, r: rw.Reader}, nil
+}
+
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range strings.Split(h.Get(name), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read reads the payload of binary frames
+func (c *wsConn) Read(b []byte) (int, error) {
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	if uint64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+
+	n, err := c.r.Read(b)
+	for i := 0; i < n; i++ {
+		b[i] ^= c.mask[c.maskOffset%4]
+		c.maskOffset++
+	}
+
+	c.remaining -= uint64(n)
+	return n, err
+}
+
+// nextFrame reads the header of the next data frame, control frames are handled until a data frame is read
+func (c *wsConn) nextFrame() error {
+	for {
+		opcode, length, err := c.readHeader()
+		if err != nil {
+			return err
+		}
+
+		switch opcode {
+		case opBinary, opContinuation:
+			c.remaining = length
+			return nil
+		case opText:
+			c.writeFrame(opClose, []byte{0x03, 0xeb})
+			return errTextFrame
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return io.EOF
+		}
+
+		// control frames have at most 125 bytes of payload
+		if length > 125 {
+			return errMalformedPacket
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return err
+		}
+
+		for i := range payload {
+			payload[i] ^= c.mask[i%4]
+		}
+
+		if opcode == opPing {
+			c.writeFrame(opPong, payload)
+		}
+	}
+}
+
+// readHeader reads the header of a frame, frames of a client must be masked
+func (c *wsConn) readHeader() (byte, uint64, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, 0, err
+	}
+
+	if header[1]&0x80 == 0 {
+		return 0, 0, errors.New("websocket frame of client not masked")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return 0, 0, err
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return 0, 0, err
+		}
+		length = binary.BigEndian.Uint64(b[:])
+	}
+
+	if _, err := io.ReadFull(c.r, c.mask[:]); err != nil {
+		return 0, 0, err
+	}
+
+	c.maskOffset = 0
+	return header[0] & 0x0f, length, nil
+}
+
+// Write writes b in a binary frame
+func (c *wsConn) Write(b []byte) (int, error) {
+	if err := c.writeFrame(opBinary, b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// writeFrame writes a final unmasked frame, frames of the server are not masked
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126, byte(len(payload)>>8), byte(len(payload)))
+	default:
+		header = append(header, 127)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(len(payload)))
+		header = append(header, b[:]...)
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_, err := c.Conn.Write(append(header, payload...))
+	return err
+}
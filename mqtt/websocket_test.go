@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebsocketAccept(t *testing.T) {
+	// example of RFC 6455
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+// maskedFrame returns a frame as sent by a client
+func maskedFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+func TestWebsocketConn(t *testing.T) {
+	// arrange
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgradeWebsocket(w, r)
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+		r2 := bufio.NewReader(conn)
+		line, _ := r2.ReadString('!')
+		received <- line
+		conn.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	conn, _ := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	defer conn.Close()
+	conn.Write([]byte("GET /mqtt HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Protocol: mqtt\r\n\r\n"))
+	r := bufio.NewReader(conn)
+	response, _ := http.ReadResponse(r, nil)
+
+	// act
+	conn.Write(maskedFrame(opBinary, []byte("hello ")))
+	conn.Write(maskedFrame(opPing, []byte("ping")))
+	conn.Write(maskedFrame(opContinuation, []byte("world!")))
+	pong := make([]byte, 6)
+	io.ReadFull(r, pong)
+	frame := make([]byte, 6)
+	io.ReadFull(r, frame)
+
+	// assert
+	assert.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+	assert.Equal(t, "mqtt", response.Header.Get("Sec-WebSocket-Protocol"))
+	assert.Equal(t, "hello world!", <-received, "payload of frames should be read as a stream")
+	assert.Equal(t, []byte{0x80 | opPong, 4, 'p', 'i', 'n', 'g'}, pong)
+	assert.Equal(t, []byte{0x80 | opBinary, 4, 'p', 'o', 'n', 'g'}, frame)
+}
@@ -0,0 +1,232 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gost/godata"
+	"github.com/gost/server/configuration"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger *log.Entry
+
+func setupLogger() {
+	l, err := gostLog.GetPackageLogger("gost.server.retention")
+	if err != nil {
+		log.Error(err)
+	}
+
+	logger = l
+}
+
+// Policy describes how long the Observations of a Datastream are kept, a zero duration keeps the data forever.
+// Raw Observations older than Raw are deleted after being added to the rollups of RollupInterval, when
+// RollupInterval is zero they are deleted without rollups
+type Policy struct {
+	Raw             time.Duration
+	RollupInterval  time.Duration
+	RollupRetention time.Duration
+}
+
+// NewPolicy converts a configured retention policy into a Policy
+func NewPolicy(conf configuration.RetentionPolicy) Policy {
+	day := 24 * time.Hour
+	return Policy{
+		Raw:             time.Duration(conf.RawDays) * day,
+		RollupInterval:  time.Duration(conf.RollupIntervalSec) * time.Second,
+		RollupRetention: time.Duration(conf.RollupDays) * day,
+	}
+}
+
+// Report describes the outcome of a run of the retention Job
+type Report struct {
+	Started            time.Time `json:"started"`
+	Finished           time.Time `json:"finished"`
+	Datastreams        int       `json:"datastreams"`
+	PrunedObservations int64     `json:"prunedObservations"`
+	PrunedRollups      int64     `json:"prunedRollups"`
+	Errors             int       `json:"errors"`
+}
+
+// Job enforces the retention policies of all Datastreams at a fixed interval, the policy of a Datastream is
+// read from the configuration by id, from the properties of its Thing or else the default policy is used
+type Job struct {
+	db            models.Database
+	interval      time.Duration
+	thingProperty string
+	defaultPolicy Policy
+	datastreams   map[string]Policy
+	stop          chan bool
+	done          chan bool
+	mutex         sync.Mutex
+	lastReport    *Report
+}
+
+// NewJob creates a retention Job from the retention configuration
+func NewJob(db models.Database, conf configuration.RetentionConfig) *Job {
+	setupLogger()
+	j := &Job{
+		db:            db,
+		interval:      time.Duration(conf.IntervalSec) * time.Second,
+		thingProperty: conf.ThingProperty,
+		defaultPolicy: NewPolicy(conf.RetentionPolicy),
+		datastreams:   make(map[string]Policy),
+	}
+
+	if conf.IntervalSec <= 0 {
+		j.interval = time.Duration(configuration.DefaultRetentionIntervalSec) * time.Second
+	}
+
+	if len(j.thingProperty) == 0 {
+		j.thingProperty = configuration.DefaultRetentionThingProperty
+	}
+
+	for id, p := range conf.Datastreams {
+		j.datastreams[id] = NewPolicy(p)
+	}
+
+	return j
+}
+
+// Start runs the Job directly and after every interval until Stop is called
+func (j *Job) Start() {
+	stop := make(chan bool)
+	done := make(chan bool)
+	j.stop = stop
+	j.done = done
+	logger.Infof("Retention job started, running every %v", j.interval)
+
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		defer close(done)
+
+		for {
+			j.Run()
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops running the Job, a run in progress is finished first
+func (j *Job) Stop() {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+		j.stop = nil
+	}
+}
+
+// LastReport returns the Report of the last finished run or nil when the Job did not run yet
+func (j *Job) LastReport() *Report {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.lastReport
+}
+
+// Run enforces the retention policies of all Datastreams once and returns what was pruned
+func (j *Job) Run() *Report {
+	report := &Report{Started: time.Now().UTC()}
+
+	qo := &odata.QueryOptions{}
+	top := godata.GoDataTopQuery(-1)
+	qo.Top = &top
+
+	datastreams, _, _, err := j.db.GetDatastreams(qo)
+	if err != nil {
+		logger.Errorf("Retention job unable to get Datastreams: %v", err)
+		report.Errors++
+	}
+
+	for _, ds := range datastreams {
+		report.Datastreams++
+		observations, rollups, err := j.prune(ds.ID, j.policy(ds.ID), report.Started)
+		report.PrunedObservations += observations
+		report.PrunedRollups += rollups
+		if err != nil {
+			logger.Errorf("Retention job unable to prune Datastream %v: %v", ds.ID, err)
+			report.Errors++
+			continue
+		}
+
+		if observations > 0 || rollups > 0 {
+			logger.Infof("Retention job pruned %d Observations and %d rollups of Datastream %v", observations, rollups, ds.ID)
+		}
+	}
+
+	report.Finished = time.Now().UTC()
+	logger.Infof("Retention job checked %d Datastreams, pruned %d Observations and %d rollups with %d errors in %v",
+		report.Datastreams, report.PrunedObservations, report.PrunedRollups, report.Errors, report.Finished.Sub(report.Started))
+
+	j.mutex.Lock()
+	j.lastReport = report
+	j.mutex.Unlock()
+
+	return report
+}
+
+// prune applies the policy to the Datastream with the given id at time now, the cutoff of the raw Observations is
+// aligned to the start of a rollup interval, which start at a multiple of their length since the Unix epoch, so a
+// rollup is never created from a part of its interval
+func (j *Job) prune(id interface{}, p Policy, now time.Time) (int64, int64, error) {
+	var observations, rollups int64
+	var err error
+	if p.Raw > 0 {
+		before := now.Add(-p.Raw)
+		if p.RollupInterval > 0 {
+			seconds := int64(p.RollupInterval / time.Second)
+			before = time.Unix(before.Unix()/seconds*seconds, 0).UTC()
+		}
+
+		if observations, err = j.db.PruneObservations(id, before, p.RollupInterval); err != nil {
+			return observations, 0, err
+		}
+	}
+
+	if p.RollupRetention > 0 {
+		if rollups, err = j.db.PruneObservationRollups(id, now.Add(-p.RollupRetention)); err != nil {
+			return observations, rollups, err
+		}
+	}
+
+	return observations, rollups, nil
+}
+
+// policy returns the Policy of the Datastream with the given id, a policy in the configuration overrules the
+// policy set on the Thing of the Datastream
+func (j *Job) policy(id interface{}) Policy {
+	if p, ok := j.datastreams[fmt.Sprintf("%v", id)]; ok {
+		return p
+	}
+
+	thing, err := j.db.GetThingByDatastream(id, nil)
+	if err != nil || thing == nil {
+		return j.defaultPolicy
+	}
+
+	property, ok := thing.Properties[j.thingProperty]
+	if !ok {
+		return j.defaultPolicy
+	}
+
+	var conf configuration.RetentionPolicy
+	b, _ := json.Marshal(property)
+	if err = json.Unmarshal(b, &conf); err != nil {
+		logger.Warnf("Invalid %s property on Thing of Datastream %v, using the default retention policy: %v", j.thingProperty, id, err)
+		return j.defaultPolicy
+	}
+
+	return NewPolicy(conf)
+}
@@ -0,0 +1,106 @@
+package retention
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/database/memory"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestDatastream(db models.Database, properties map[string]interface{}, phenomenonTimes ...time.Time) *entities.Datastream {
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test", Properties: properties})
+	sensor, _ := db.PostSensor(&entities.Sensor{Name: "sensor"})
+	op, _ := db.PostObservedProperty(&entities.ObservedProperty{Name: "temperature"})
+	foi, _ := db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+	ds := &entities.Datastream{Name: "datastream", Thing: &entities.Thing{}, Sensor: &entities.Sensor{}, ObservedProperty: &entities.ObservedProperty{}}
+	ds.Thing.ID = thing.ID
+	ds.Sensor.ID = sensor.ID
+	ds.ObservedProperty.ID = op.ID
+	ds, _ = db.PostDatastream(ds)
+
+	for i, t := range phenomenonTimes {
+		o := &entities.Observation{Result: json.RawMessage(strconv.Itoa(i)), PhenomenonTime: t.Format(time.RFC3339), Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+		o.Datastream.ID = ds.ID
+		o.FeatureOfInterest.ID = foi.ID
+		db.PostObservation(o)
+	}
+
+	return ds
+}
+
+func TestNewJob(t *testing.T) {
+	// arrange
+	conf := configuration.RetentionConfig{
+		RetentionPolicy: configuration.RetentionPolicy{RawDays: 90, RollupIntervalSec: 3600, RollupDays: 1825},
+		Datastreams:     map[string]configuration.RetentionPolicy{"1": {RawDays: 7}},
+	}
+
+	// act
+	job := NewJob(memory.NewDatabase(200), conf)
+
+	// assert
+	assert.Equal(t, time.Hour, job.interval)
+	assert.Equal(t, configuration.DefaultRetentionThingProperty, job.thingProperty)
+	assert.Equal(t, Policy{Raw: 90 * 24 * time.Hour, RollupInterval: time.Hour, RollupRetention: 1825 * 24 * time.Hour}, job.defaultPolicy)
+	assert.Equal(t, Policy{Raw: 7 * 24 * time.Hour}, job.datastreams["1"])
+	assert.Nil(t, job.LastReport())
+}
+
+func TestRun(t *testing.T) {
+	// arrange
+	now := time.Now().UTC()
+	db := memory.NewDatabase(200)
+	defaultDatastream := createTestDatastream(db, nil, now.Add(-48*time.Hour), now.Add(-time.Minute))
+	thingDatastream := createTestDatastream(db, map[string]interface{}{"retention": map[string]interface{}{"rawDays": 3}}, now.Add(-48*time.Hour), now.Add(-96*time.Hour))
+	job := NewJob(db, configuration.RetentionConfig{RetentionPolicy: configuration.RetentionPolicy{RawDays: 1}})
+
+	// act
+	report := job.Run()
+	_, defaultCount, _, _ := db.GetObservationsByDatastream(defaultDatastream.ID, &odata.QueryOptions{})
+	_, thingCount, _, _ := db.GetObservationsByDatastream(thingDatastream.ID, &odata.QueryOptions{})
+
+	// assert
+	assert.Equal(t, 2, report.Datastreams)
+	assert.Equal(t, int64(2), report.PrunedObservations)
+	assert.Equal(t, 0, report.Errors)
+	assert.Equal(t, 1, defaultCount)
+	assert.Equal(t, 1, thingCount)
+	assert.Equal(t, report, job.LastReport())
+}
+
+func TestPolicyFromConfigurationOverrulesThing(t *testing.T) {
+	// arrange
+	db := memory.NewDatabase(200)
+	ds := createTestDatastream(db, map[string]interface{}{"retention": map[string]interface{}{"rawDays": 3}})
+	job := NewJob(db, configuration.RetentionConfig{
+		Datastreams: map[string]configuration.RetentionPolicy{"1": {RawDays: 10, RollupIntervalSec: 60}},
+	})
+
+	// act
+	p := job.policy(ds.ID)
+
+	// assert
+	assert.Equal(t, Policy{Raw: 10 * 24 * time.Hour, RollupInterval: time.Minute}, p)
+}
+
+func TestStopWaitsForRun(t *testing.T) {
+	// arrange
+	db := memory.NewDatabase(200)
+	createTestDatastream(db, nil, time.Now().UTC().Add(-48*time.Hour))
+	job := NewJob(db, configuration.RetentionConfig{RetentionPolicy: configuration.RetentionPolicy{RawDays: 1}, IntervalSec: 3600})
+
+	// act
+	job.Start()
+	job.Stop()
+
+	// assert
+	assert.NotNil(t, job.LastReport(), "the first run should be finished when Stop returns")
+	assert.NotPanics(t, func() { job.Stop() })
+}
@@ -1,20 +1,26 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"reflect"
 	"strings"
 
 	entities "github.com/gost/core"
 	"github.com/gost/server/configuration"
 	gostErrors "github.com/gost/server/errors"
+	gostLog "github.com/gost/server/log"
 	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/mqtt"
 	"github.com/gost/server/sensorthings/odata"
 	"github.com/gost/server/sensorthings/rest/config"
+	log "github.com/sirupsen/logrus"
 )
 
+var logger *log.Entry
+
 // APIv1 is the default implementation of SensorThingsApi, API needs a database
 // provider, config, endpoint information to setup te needed services
 type APIv1 struct {
@@ -24,14 +30,28 @@ type APIv1 struct {
 	topics        []models.Topic
 	mqtt          models.MQTTClient
 	acceptedPaths []string
+	subscriptions *subscriptions
+	logger        *log.Entry
+}
+
+func setupLogger() {
+	l, err := gostLog.GetPackageLogger("gost.server.sensorthings.api")
+	if err != nil {
+		log.Error(err)
+	}
+
+	logger = l
 }
 
 // NewAPI Initialise a new SensorThings API
 func NewAPI(database models.Database, config configuration.Config, mqtt models.MQTTClient) models.API {
+	setupLogger()
 	api := &APIv1{
-		db:     database,
-		mqtt:   mqtt,
-		config: config,
+		logger:        logger,
+		db:            database,
+		mqtt:          mqtt,
+		config:        config,
+		subscriptions: newSubscriptions(),
 		acceptedPaths: []string{
 			"v1.0",
 			"thing",
@@ -78,6 +98,19 @@ func (a *APIv1) GetConfig() *configuration.Config {
 	return &a.config
 }
 
+// WithContext returns a copy of the API bound to the request of ctx, the log lines and database statements
+// of the copy hold the id of the request and links are created with the external uri of a forwarded request
+func (a *APIv1) WithContext(ctx context.Context) models.API {
+	c := *a
+	c.db = a.db.WithContext(ctx)
+	c.logger = gostLog.WithRequestID(logger, gostLog.RequestIDFromContext(ctx))
+	if uri := models.ExternalURIFromContext(ctx); len(uri) > 0 {
+		c.config.Server.ExternalURI = uri
+	}
+
+	return &c
+}
+
 // GetAcceptedPaths returns an array of accepted endpoint paths
 func (a *APIv1) GetAcceptedPaths() []string {
 	return a.acceptedPaths
@@ -129,7 +162,7 @@ func (a *APIv1) initRest() {
 // GetTopics returns all configured topics for the MQTT client
 func (a *APIv1) GetTopics(prefix string) *[]models.Topic {
 	if a.topics == nil {
-		a.topics = mqtt.CreateTopics(prefix)
+		a.topics = mqtt.CreateTopics(prefix, a.config.MQTT.ACL, a.config.MQTT.ErrorTopic)
 	}
 
 	return &a.topics
@@ -155,10 +188,53 @@ func (a *APIv1) CreateNextLink(incomingURL string, qo *odata.QueryOptions) strin
 		return ""
 	}
 
+	queryString := nextLinkQuery(qo)
+	if qo.Skip != nil {
+		queryString = appendQueryPart(queryString, fmt.Sprintf("$skip=%v", url.QueryEscape(fmt.Sprintf("%v", int(*qo.Skip)+int(*qo.Top)))))
+	}
+
+	return fmt.Sprintf("%s%s", incomingURL, queryString)
+}
+
+// createNextLink creates the link to the next page continuing after the last entity in data using a $skiptoken,
+// the $skip based link of CreateNextLink is used when the sort key values of the last entity are not available
+func (a *APIv1) createNextLink(incomingURL string, qo *odata.QueryOptions, data interface{}) string {
+	if qo == nil || qo.Top == nil || int(*qo.Top) <= 0 {
+		return a.CreateNextLink(incomingURL, qo)
+	}
+
+	token := createSkipToken(qo, data)
+	if token == nil {
+		return a.CreateNextLink(incomingURL, qo)
+	}
+
+	return fmt.Sprintf("%s%s", incomingURL, appendQueryPart(nextLinkQuery(qo), fmt.Sprintf("$skiptoken=%s", token)))
+}
+
+// createSkipNextLink creates a $skip based link to the next page for results that are not entities such as
+// aggregates, the link is also created when the request has no $skip
+func createSkipNextLink(incomingURL string, qo *odata.QueryOptions) string {
+	if qo == nil || qo.Top == nil || int(*qo.Top) <= 0 {
+		return ""
+	}
+
+	skip := 0
+	if qo.Skip != nil {
+		skip = int(*qo.Skip)
+	}
+
+	return fmt.Sprintf("%s%s", incomingURL, appendQueryPart(nextLinkQuery(qo), fmt.Sprintf("$skip=%v", skip+int(*qo.Top))))
+}
+
+// nextLinkQuery returns the query options of the request to repeat in a next link
+func nextLinkQuery(qo *odata.QueryOptions) string {
 	queryString := ""
 	if qo.Filter != nil {
 		queryString = appendQueryPart(queryString, fmt.Sprintf("$filter=%s", url.QueryEscape(qo.RawFilter)))
 	}
+	if qo.Aggregation != nil {
+		queryString = appendQueryPart(queryString, fmt.Sprintf("$apply=%s", url.QueryEscape(qo.RawApply)))
+	}
 	if qo.Count != nil {
 		queryString = appendQueryPart(queryString, fmt.Sprintf("$count=%v", url.QueryEscape(fmt.Sprintf("%v", *qo.Count))))
 	}
@@ -171,14 +247,86 @@ func (a *APIv1) CreateNextLink(incomingURL string, qo *odata.QueryOptions) strin
 	if qo.Format != nil {
 		queryString = appendQueryPart(queryString, fmt.Sprintf("$format=%s", url.QueryEscape(fmt.Sprintf("%v", qo.Format))))
 	}
+	if len(qo.ResultFormat) > 0 {
+		queryString = appendQueryPart(queryString, fmt.Sprintf("$resultFormat=%s", url.QueryEscape(string(qo.ResultFormat))))
+	}
 	if qo.Top != nil {
 		queryString = appendQueryPart(queryString, fmt.Sprintf("$top=%v", url.QueryEscape(fmt.Sprintf("%v", *qo.Top))))
 	}
-	if qo.Skip != nil {
-		queryString = appendQueryPart(queryString, fmt.Sprintf("$skip=%v", url.QueryEscape(fmt.Sprintf("%v", int(*qo.Skip)+int(*qo.Top)))))
+
+	return queryString
+}
+
+// createSkipToken returns the SkipToken holding the sort key values of the last entity in data, nil is returned
+// when a value is missing in the entity, for example when it is not selected or when ordered by a related entity
+func createSkipToken(qo *odata.QueryOptions, data interface{}) odata.SkipToken {
+	list := reflect.ValueOf(data)
+	if list.Kind() != reflect.Slice || list.Len() == 0 {
+		return nil
 	}
 
-	return fmt.Sprintf("%s%s", incomingURL, queryString)
+	entity, ok := list.Index(list.Len() - 1).Interface().(entities.Entity)
+	if !ok || entity.GetID() == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(entity)
+	if err != nil {
+		return nil
+	}
+
+	var properties map[string]interface{}
+	if err = json.Unmarshal(b, &properties); err != nil {
+		return nil
+	}
+
+	token := make(odata.SkipToken, 0)
+	for _, key := range qo.SortKeys() {
+		if strings.ToLower(key.Field) == "id" {
+			token = append(token, entity.GetID())
+			continue
+		}
+
+		value, ok := lookupProperty(properties, strings.Split(key.Field, "/"))
+		if !ok {
+			return nil
+		}
+
+		token = append(token, value)
+	}
+
+	return token
+}
+
+// lookupProperty returns the value of a property path by case insensitive name, only values
+// that can be compared in a query such as strings and numbers are returned
+func lookupProperty(properties map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = properties
+	for _, segment := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		found := false
+		for k, v := range m {
+			if strings.EqualFold(k, segment) {
+				value, found = v, true
+				break
+			}
+		}
+
+		if !found {
+			return nil, false
+		}
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil, false
+	}
+
+	return value, true
 }
 
 func containsMandatoryParams(entity interface{}) (bool, []error) {
@@ -223,7 +371,7 @@ func (a *APIv1) createArrayResponse(count int, hasNext bool, path string, qo *od
 	}
 
 	if hasNext {
-		ar.NextLink = a.CreateNextLink(path, qo)
+		ar.NextLink = a.createNextLink(path, qo, data)
 	}
 
 	if qo != nil && qo.Count != nil && bool(*qo.Count) == true {
@@ -5,14 +5,20 @@ import (
 	"github.com/gost/server/configuration"
 	"github.com/gost/server/database/postgis"
 	gostErrors "github.com/gost/server/errors"
+	gostLog "github.com/gost/server/log"
 	"github.com/gost/server/mqtt"
+	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/odata"
 
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/gost/godata"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -146,6 +152,28 @@ func TestCreateNextLink(t *testing.T) {
 	assert.True(t, strings.Contains(result1, "name+eq+%27test%27"))
 }
 
+func TestCreateArrayResponseWithSkipToken(t *testing.T) {
+	// arrange
+	testAPI := &APIv1{}
+	qo := &odata.QueryOptions{}
+	qo.Top, _ = godata.ParseTopString("2")
+	qo.Skip, _ = godata.ParseSkipString("2")
+	qo.OrderBy, _ = godata.ParseOrderByString("name")
+	qo.RawOrderBy = "name"
+	thing := &entities.Thing{Name: "a"}
+	thing.ID = 4
+	data := []*entities.Thing{thing}
+	unsorted := []*entities.Thing{{Name: "b"}}
+
+	// act
+	arrayResponse := testAPI.createArrayResponse(10, true, "http://www.nu.nl", qo, data)
+	fallback := testAPI.createArrayResponse(10, true, "http://www.nu.nl", qo, unsorted)
+
+	// assert
+	assert.Equal(t, "http://www.nu.nl?$orderby=name&$top=2&$skiptoken="+odata.SkipToken{"a", 4}.String(), arrayResponse.NextLink)
+	assert.Equal(t, "http://www.nu.nl?$orderby=name&$top=2&$skip=4", fallback.NextLink, "the $skip link should be used without id")
+}
+
 func TestCreateArrayResponseWithCount(t *testing.T) {
 	// arrange
 	testAPI := &APIv1{}
@@ -210,6 +238,39 @@ func TestContainsMandatoryParamsReturningBadRequest(t *testing.T) {
 	assert.Equal(t, 400, getStatusCode(fErr))
 }
 
+func TestPostCreateObservationsWithUnsupportedMode(t *testing.T) {
+	// arrange
+	cfg := configuration.Config{}
+	mqttServer := mqtt.CreateMQTTClient(configuration.MQTTConfig{})
+	database := postgis.NewDatabase("", 123, "", "", "", "", false, 50, 100, 200)
+	stAPI := NewAPI(database, cfg, mqttServer)
+
+	// act
+	result, err := stAPI.PostCreateObservations(&entities.CreateObservations{}, models.CreateObservationsMode("sometimes"))
+
+	// assert
+	assert.Nil(t, result)
+	assert.Equal(t, 400, getStatusCode(err))
+}
+
+func TestCreateObservationsErrors(t *testing.T) {
+	// arrange
+	failed := map[int]error{
+		3: gostErrors.NewConflictRequestError(errors.New("conflict")),
+		1: errors.New("no datastream"),
+	}
+
+	// act
+	result := createObservationsErrors(failed)
+
+	// assert
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "Observation 1: no datastream", result[0].Error())
+	assert.Equal(t, 400, getStatusCode(result[0:1]))
+	assert.Equal(t, "Observation 3: conflict", result[1].Error())
+	assert.Equal(t, 409, getStatusCode(result[1:2]))
+}
+
 func getStatusCode(error []error) int {
 	switch e := error[0].(type) {
 	case gostErrors.APIError:
@@ -218,3 +279,38 @@ func getStatusCode(error []error) int {
 
 	return 0
 }
+
+func TestWithContextLogsRequestID(t *testing.T) {
+	// arrange
+	l, _ := gostLog.InitializeLogger(nil, "", new(log.JSONFormatter), false)
+	out := &bytes.Buffer{}
+	l.Out = out
+	a, _ := createTestAPI()
+	ctx := gostLog.ContextWithRequestID(context.Background(), "test-id")
+
+	// act
+	requestAPI := a.WithContext(ctx).(*APIv1)
+	requestAPI.deleteFeaturesOfInterest(999)
+
+	// assert
+	assert.Contains(t, out.String(), `"requestId":"test-id"`)
+	assert.NotEqual(t, a, requestAPI, "the api itself should not be bound to the request")
+	assert.Equal(t, logger, a.logger)
+}
+
+func TestWithContextForwardedExternalURI(t *testing.T) {
+	// arrange
+	a, _ := createTestAPI()
+	a.config.Server.ExternalURI = "http://localhost:8080/"
+	ctx := models.ContextWithExternalURI(context.Background(), "https://gost.example.com/")
+
+	// act
+	requestAPI := a.WithContext(ctx)
+	thing := &entities.Thing{}
+	thing.ID = 1
+	requestAPI.SetLinks(thing, nil)
+
+	// assert
+	assert.Equal(t, "https://gost.example.com/v1.0/Things(1)", thing.NavSelf)
+	assert.Equal(t, "http://localhost:8080", a.GetConfig().GetExternalServerURI(), "the api itself should keep the configured uri")
+}
@@ -4,35 +4,160 @@ import (
 	"fmt"
 
 	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/models"
 )
 
-// PostCreateObservations checks for correctness of the datastreams and observations and calls PostcreateObservations on the database
-// ToDo: use transactions
-func (a *APIv1) PostCreateObservations(data *entities.CreateObservations) ([]string, []error) {
+// PostCreateObservations checks for correctness of the datastreams and observations and inserts all observations
+// using a single database bulk insert. The given mode decides what happens when one of the observations fails:
+// CreateObservationsModeAtomic creates no observations at all and returns the errors, CreateObservationsModeBestEffort
+// (default) creates all valid observations. Deep inserted FeaturesOfInterest are created before the observations,
+// the FeaturesOfInterest of observations that are not created are deleted again. The returned list contains the self link of every created observation
+// or the error string of the failed observation, in the order of the posted dataArray
+func (a *APIv1) PostCreateObservations(data *entities.CreateObservations, mode models.CreateObservationsMode) ([]string, []error) {
+	if len(mode) == 0 {
+		mode = models.CreateObservationsModeBestEffort
+	}
+
+	if mode != models.CreateObservationsModeAtomic && mode != models.CreateObservationsModeBestEffort {
+		err := fmt.Errorf("Mode %v not supported, supported modes: %v, %v", mode, models.CreateObservationsModeAtomic, models.CreateObservationsModeBestEffort)
+		return nil, []error{gostErrors.NewBadRequestError(err)}
+	}
+
 	_, err := containsMandatoryParams(data)
 	if err != nil {
 		return nil, err
 	}
 
+	atomic := mode == models.CreateObservationsModeAtomic
 	returnList := make([]string, 0)
+	failed := make(map[int]error)
+	observations := make([]*entities.Observation, 0)
+	datastreamIDs := make([]interface{}, 0)
+	positions := make([]int, 0)
+	relations := make([][]string, 0)
+	fois := make(map[string]string)
+	deepInserted := make(map[int]interface{})
+
 	for i := 0; i < len(data.Datastreams); i++ {
 		for j := 0; j < len(data.Datastreams[i].Observations); j++ {
-			obs, errors := a.PostObservationByDatastream(data.Datastreams[i].ID, data.Datastreams[i].Observations[j])
-			if errors == nil || len(errors) == 0 {
-				returnList = append(returnList, obs.GetSelfLink())
-			} else {
-				errorString := ""
-				for k := 0; k < len(errors); k++ {
-					if len(errorString) > 0 {
-						errorString += ", "
-					}
-
-					errorString += fmt.Sprintf("%v", errors[k].Error())
-				}
-				returnList = append(returnList, errorString)
+			position := len(returnList)
+			returnList = append(returnList, "")
+
+			observation := data.Datastreams[i].Observations[j]
+			observation.Datastream = &entities.Datastream{}
+			observation.Datastream.ID = data.Datastreams[i].ID
+
+			if _, errors := containsMandatoryParams(observation); len(errors) > 0 {
+				failed[position] = gostErrors.NewBadRequestError(fmt.Errorf("%v", errorsToString(errors)))
+				continue
+			}
+
+			created, err := a.setObservationFeatureOfInterest(observation, fois)
+			if err != nil {
+				failed[position] = err
+				continue
 			}
+
+			if created {
+				deepInserted[position] = observation.FeatureOfInterest.ID
+			}
+
+			observations = append(observations, observation)
+			datastreamIDs = append(datastreamIDs, data.Datastreams[i].ID)
+			positions = append(positions, position)
+			relations = append(relations, entityRelations(observation))
 		}
 	}
 
+	if atomic && len(failed) > 0 {
+		a.deleteDeepInserted(deepInserted, nil)
+		return nil, createObservationsErrors(failed)
+	}
+
+	created, dbErrors := a.insertObservations(observations, atomic)
+	for i, err := range dbErrors {
+		failed[positions[i]] = err
+	}
+
+	if atomic && len(failed) > 0 {
+		a.deleteDeepInserted(deepInserted, nil)
+		return nil, createObservationsErrors(failed)
+	}
+
+	a.deleteDeepInserted(deepInserted, failed)
+
+	for i, o := range created {
+		if o == nil {
+			continue
+		}
+
+		o.SetAllLinks(a.config.GetExternalServerURI())
+		returnList[positions[i]] = o.GetSelfLink()
+		a.publishObservation(o, datastreamIDs[i], relations[i]...)
+	}
+
+	for position, err := range failed {
+		returnList[position] = err.Error()
+	}
+
 	return returnList, nil
 }
+
+// insertObservations inserts the observations using the fast bulk insert of the database, when the bulk insert
+// fails the observations are inserted one by one in a transaction to find out which observations are failing
+func (a *APIv1) insertObservations(observations []*entities.Observation, atomic bool) ([]*entities.Observation, map[int]error) {
+	created, err := a.db.PostObservationsBulk(observations)
+	if err == nil {
+		return created, map[int]error{}
+	}
+
+	return a.db.PostObservations(observations, atomic)
+}
+
+// deleteDeepInserted deletes the deep inserted FeaturesOfInterest by position of the observations that failed,
+// a nil failed map deletes all of them
+func (a *APIv1) deleteDeepInserted(deepInserted map[int]interface{}, failed map[int]error) {
+	ids := make([]interface{}, 0)
+	for position, id := range deepInserted {
+		if _, ok := failed[position]; ok || failed == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	a.deleteFeaturesOfInterest(ids...)
+}
+
+// createObservationsErrors converts the errors of failed observations into a list of errors containing
+// the position of the observation in the posted dataArray
+func createObservationsErrors(failed map[int]error) []error {
+	errors := make([]error, 0)
+	for position := 0; len(errors) < len(failed); position++ {
+		err, ok := failed[position]
+		if !ok {
+			continue
+		}
+
+		message := fmt.Errorf("Observation %v: %v", position, err.Error())
+		if apiErr, ok := err.(gostErrors.APIError); ok {
+			errors = append(errors, gostErrors.NewErrorWithStatusCode(message, apiErr.GetHTTPErrorStatusCode()))
+		} else {
+			errors = append(errors, gostErrors.NewBadRequestError(message))
+		}
+	}
+
+	return errors
+}
+
+func errorsToString(errors []error) string {
+	errorString := ""
+	for k := 0; k < len(errors); k++ {
+		if len(errorString) > 0 {
+			errorString += ", "
+		}
+
+		errorString += fmt.Sprintf("%v", errors[k].Error())
+	}
+
+	return errorString
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/database/memory"
+	"github.com/gost/server/mqtt"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestAPI() (*APIv1, *entities.Datastream) {
+	db := memory.NewDatabase(200)
+	thing, _ := db.PostThing(&entities.Thing{Name: "thing", Description: "test thing"})
+	sensor, _ := db.PostSensor(&entities.Sensor{Name: "sensor"})
+	op, _ := db.PostObservedProperty(&entities.ObservedProperty{Name: "temperature"})
+	ds := &entities.Datastream{Name: "datastream", Thing: &entities.Thing{}, Sensor: &entities.Sensor{}, ObservedProperty: &entities.ObservedProperty{}}
+	ds.Thing.ID = thing.ID
+	ds.Sensor.ID = sensor.ID
+	ds.ObservedProperty.ID = op.ID
+	ds, _ = db.PostDatastream(ds)
+
+	a := NewAPI(db, configuration.Config{}, mqtt.CreateMQTTClient(configuration.MQTTConfig{})).(*APIv1)
+	return a, ds
+}
+
+func deepInsertObservation() *entities.Observation {
+	foi := &entities.FeatureOfInterest{
+		Name:         "foi",
+		Description:  "deep inserted",
+		EncodingType: entities.EncodingGeoJSON.Value,
+		Feature:      map[string]interface{}{"type": "Point", "coordinates": []interface{}{5.0, 52.0}},
+	}
+
+	return &entities.Observation{Result: json.RawMessage("20.5"), FeatureOfInterest: foi}
+}
+
+func TestPostCreateObservationsAtomicDeletesDeepInsertedFeaturesOfInterest(t *testing.T) {
+	// arrange
+	a, ds := createTestAPI()
+	valid := deepInsertObservation()
+	invalid := deepInsertObservation()
+	unknown := &entities.Datastream{Observations: []*entities.Observation{invalid}}
+	unknown.ID = 999
+	data := &entities.CreateObservations{Datastreams: []*entities.Datastream{{Observations: []*entities.Observation{valid}}, unknown}}
+	data.Datastreams[0].ID = ds.ID
+
+	// act
+	result, errs := a.PostCreateObservations(data, models.CreateObservationsModeAtomic)
+	_, count, _, err := a.db.GetFeatureOfInterests(nil)
+
+	// assert
+	assert.Nil(t, result)
+	assert.Len(t, errs, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count, "deep inserted FeaturesOfInterest of a rolled back batch should be deleted")
+}
+
+func TestPostCreateObservationsBestEffortDeletesFailedFeaturesOfInterest(t *testing.T) {
+	// arrange
+	a, ds := createTestAPI()
+	valid := deepInsertObservation()
+	invalid := deepInsertObservation()
+	unknown := &entities.Datastream{Observations: []*entities.Observation{invalid}}
+	unknown.ID = 999
+	data := &entities.CreateObservations{Datastreams: []*entities.Datastream{{Observations: []*entities.Observation{valid}}, unknown}}
+	data.Datastreams[0].ID = ds.ID
+
+	// act
+	result, errs := a.PostCreateObservations(data, models.CreateObservationsModeBestEffort)
+	fois, _, _, err := a.db.GetFeatureOfInterests(nil)
+
+	// assert
+	assert.Nil(t, errs)
+	assert.Len(t, result, 2)
+	assert.NoError(t, err)
+	assert.Len(t, fois, 1, "only the FeatureOfInterest of the failed observation should be deleted")
+}
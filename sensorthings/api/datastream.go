@@ -107,6 +107,7 @@ func (a *APIv1) PostDatastream(datastream *entities.Datastream) (*entities.Datas
 		postedSensor = s
 	}
 
+	relations := entityRelations(datastream)
 	ns, err := a.db.PostDatastream(datastream)
 	if err != nil {
 		a.revertPostDatastream(postedObservedProperty, postedSensor, postedObservations)
@@ -131,6 +132,14 @@ func (a *APIv1) PostDatastream(datastream *entities.Datastream) (*entities.Datas
 	}
 
 	ns.SetAllLinks(a.config.GetExternalServerURI())
+	if postedObservedProperty != nil {
+		a.notifySubscriptions(postedObservedProperty)
+	}
+	if postedSensor != nil {
+		a.notifySubscriptions(postedSensor)
+	}
+	a.notifySubscriptions(ns, relations...)
+
 	return ns, nil
 }
 
@@ -162,7 +171,14 @@ func (a *APIv1) PatchDatastream(id interface{}, datastream *entities.Datastream)
 		return nil, gostErrors.NewBadRequestError(errors.New("Deep patch datastream not supported."))
 	}
 
-	return a.db.PatchDatastream(id, datastream)
+	pd, err := a.db.PatchDatastream(id, datastream)
+	if err != nil {
+		return nil, err
+	}
+
+	pd.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(pd, a.storedRelations(pd)...)
+	return pd, nil
 }
 
 // PutDatastream updates the given thing in the database
@@ -174,10 +190,20 @@ func (a *APIv1) PutDatastream(id interface{}, datastream *entities.Datastream) (
 	}
 
 	// putdatastream.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(putdatastream, a.storedRelations(putdatastream)...)
 	return putdatastream, nil
 }
 
 // DeleteDatastream deletes a datastream from the database
 func (a *APIv1) DeleteDatastream(id interface{}) error {
-	return a.db.DeleteDatastream(id)
+	deleted := &entities.Datastream{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteDatastream(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
@@ -65,6 +65,8 @@ func (a *APIv1) PostFeatureOfInterest(foi *entities.FeatureOfInterest) (*entitie
 	}
 
 	l.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifySubscriptions(l)
+
 	return l, nil
 }
 
@@ -81,6 +83,8 @@ func (a *APIv1) PutFeatureOfInterest(id interface{}, foi *entities.FeatureOfInte
 	}
 
 	l.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(l)
+
 	return l, nil
 }
 
@@ -102,10 +106,26 @@ func (a *APIv1) PatchFeatureOfInterest(id interface{}, foi *entities.FeatureOfIn
 		}
 	}
 
-	return a.db.PatchFeatureOfInterest(id, foi)
+	pf, err := a.db.PatchFeatureOfInterest(id, foi)
+	if err != nil {
+		return nil, err
+	}
+
+	pf.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(pf, a.storedRelations(pf)...)
+	return pf, nil
 }
 
 // DeleteFeatureOfInterest deletes a given FeatureOfInterest from the database
 func (a *APIv1) DeleteFeatureOfInterest(id interface{}) error {
-	return a.db.DeleteFeatureOfInterest(id)
+	deleted := &entities.FeatureOfInterest{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteFeatureOfInterest(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
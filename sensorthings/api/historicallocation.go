@@ -56,11 +56,14 @@ func (a *APIv1) PostHistoricalLocation(hl *entities.HistoricalLocation) (*entiti
 		return nil, err
 	}
 
+	relations := entityRelations(hl)
 	l, err2 := a.db.PostHistoricalLocation(hl)
 	if err2 != nil {
 		return nil, []error{err2}
 	}
 	l.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifySubscriptions(l, relations...)
+
 	return l, nil
 }
 
@@ -71,6 +74,8 @@ func (a *APIv1) PutHistoricalLocation(id interface{}, hl *entities.HistoricalLoc
 		return nil, []error{err2}
 	}
 	l.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(l, a.storedRelations(l)...)
+
 	return l, nil
 }
 
@@ -80,10 +85,26 @@ func (a *APIv1) PatchHistoricalLocation(id interface{}, hl *entities.HistoricalL
 		return nil, gostErrors.NewBadRequestError(errors.New("Unable to deep patch HistoricalLocation"))
 	}
 
-	return a.db.PatchHistoricalLocation(id, hl)
+	phl, err := a.db.PatchHistoricalLocation(id, hl)
+	if err != nil {
+		return nil, err
+	}
+
+	phl.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(phl, a.storedRelations(phl)...)
+	return phl, nil
 }
 
 // DeleteHistoricalLocation deletes a given HistoricalLocation from the database
 func (a *APIv1) DeleteHistoricalLocation(id interface{}) error {
-	return a.db.DeleteHistoricalLocation(id)
+	deleted := &entities.HistoricalLocation{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteHistoricalLocation(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
@@ -3,7 +3,6 @@ package api
 import (
 	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	entities "github.com/gost/core"
@@ -13,6 +12,19 @@ import (
 
 // PostLocation tries to add a new location
 func (a *APIv1) PostLocation(location *entities.Location) (*entities.Location, []error) {
+	l, err := a.postLocation(location)
+	if len(err) > 0 {
+		return nil, err
+	}
+
+	a.sendOverMQTT(l, "Locations")
+	a.notifySubscriptions(l)
+	return l, nil
+}
+
+// postLocation adds a new location without publishing it, PostLocationByThing publishes it after
+// linking the location to the thing
+func (a *APIv1) postLocation(location *entities.Location) (*entities.Location, []error) {
 	_, err := containsMandatoryParams(location)
 	if err != nil {
 		return nil, err
@@ -28,8 +40,6 @@ func (a *APIv1) PostLocation(location *entities.Location) (*entities.Location, [
 		return nil, []error{err2}
 	}
 	l.SetAllLinks(a.config.GetExternalServerURI())
-	a.sendOverMQTT(l, "Locations")
-
 	return l, nil
 }
 
@@ -38,7 +48,7 @@ func (a *APIv1) PostLocation(location *entities.Location) (*entities.Location, [
 func (a *APIv1) PostLocationByThing(thingID interface{}, location *entities.Location) (*entities.Location, []error) {
 	var err []error
 	var err2 error
-	l, err := a.PostLocation(location)
+	l, err := a.postLocation(location)
 	if len(err) > 0 {
 		return nil, err
 	}
@@ -46,9 +56,10 @@ func (a *APIv1) PostLocationByThing(thingID interface{}, location *entities.Loca
 	if thingID != nil {
 		err2 = a.LinkLocation(thingID, l.ID)
 		if err2 != nil {
-			err3 := a.DeleteLocation(l.ID)
+			// the location is not published yet, delete it without notifying the subscriptions
+			err3 := a.db.DeleteLocation(l.ID)
 			if err3 != nil {
-				log.Printf("Error rolling back location %v", err2)
+				a.logger.Errorf("Error rolling back location %v", err3)
 			}
 
 			return nil, []error{err2}
@@ -65,18 +76,26 @@ func (a *APIv1) PostLocationByThing(thingID interface{}, location *entities.Loca
 
 		_, err = a.PostHistoricalLocation(hl)
 		if len(err) > 0 {
-			err2 := a.DeleteHistoricalLocation(l.ID)
-			if err2 != nil {
-				log.Printf("Error rolling back location %v", err2)
+			if err2 = a.db.DeleteLocation(l.ID); err2 != nil {
+				a.logger.Errorf("Error rolling back location %v", err2)
 			}
 
-			return nil, []error{err2}
+			return nil, err
 		}
 	}
 
 	l.SetAllLinks(a.config.GetExternalServerURI())
 
-	a.sendOverMQTT(l, fmt.Sprintf("Things(%v)/Locations", thingID))
+	a.sendOverMQTT(l, "Locations")
+	if thingID != nil {
+		a.sendOverMQTT(l, fmt.Sprintf("Things(%v)/Locations", thingID))
+	}
+
+	if thingID != nil {
+		a.notifySubscriptions(l, fmt.Sprintf("Things(%v)/Locations", thingID))
+	} else {
+		a.notifySubscriptions(l)
+	}
 
 	return l, nil
 }
@@ -134,7 +153,14 @@ func (a *APIv1) PatchLocation(id interface{}, location *entities.Location) (*ent
 		}
 	}
 
-	return a.db.PatchLocation(id, location)
+	pl, err := a.db.PatchLocation(id, location)
+	if err != nil {
+		return nil, err
+	}
+
+	pl.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(pl, a.storedRelations(pl)...)
+	return pl, nil
 }
 
 // PutLocation updates the given thing in the database
@@ -146,12 +172,23 @@ func (a *APIv1) PutLocation(id interface{}, location *entities.Location) (*entit
 	}
 
 	putlocation.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(putlocation, a.storedRelations(putlocation)...)
+
 	return putlocation, nil
 }
 
 // DeleteLocation deletes a given Location from the database
 func (a *APIv1) DeleteLocation(id interface{}) error {
-	return a.db.DeleteLocation(id)
+	deleted := &entities.Location{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteLocation(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
 
 // LinkLocation links a thing with a location in the database
@@ -40,6 +40,23 @@ func (a *APIv1) GetObservationsByDatastream(datastreamID interface{}, qo *odata.
 	return processObservations(a, observations, qo, path, count, hasNext, err)
 }
 
+// GetObservationAggregates returns the Observations aggregated by the $apply of the QueryOptions, when datastreamID
+// is nil the Observations of all Datastreams are aggregated
+func (a *APIv1) GetObservationAggregates(datastreamID interface{}, qo *odata.QueryOptions, path string) (*entities.ArrayResponse, error) {
+	aggregates, count, hasNext, err := a.db.GetObservationAggregates(datastreamID, qo)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{} = aggregates
+	ar := a.createArrayResponse(count, false, path, qo, data)
+	if hasNext {
+		ar.NextLink = createSkipNextLink(path, qo)
+	}
+
+	return ar, nil
+}
+
 func processObservations(a *APIv1, observations []*entities.Observation, qo *odata.QueryOptions, path string, count int, hasNext bool, err error) (*entities.ArrayResponse, error) {
 	if err != nil {
 		return nil, err
@@ -104,35 +121,79 @@ func (a *APIv1) PostObservation(observation *entities.Observation) (*entities.Ob
 		return nil, err
 	}
 
+	datastreamID := observation.Datastream.ID
+	deepInserted, err2 := a.setObservationFeatureOfInterest(observation, nil)
+	if err2 != nil {
+		return nil, []error{err2}
+	}
+
+	relations := entityRelations(observation)
+	no, err2 := a.db.PostObservation(observation)
+	if err2 != nil {
+		if deepInserted {
+			a.deleteFeaturesOfInterest(observation.FeatureOfInterest.ID)
+		}
+		return nil, []error{err2}
+	}
+
+	no.SetAllLinks(a.config.GetExternalServerURI())
+	a.publishObservation(no, datastreamID, relations...)
+
+	return no, nil
+}
+
+// setObservationFeatureOfInterest makes sure the given observation is linked to a FeatureOfInterest, when no
+// FeatureOfInterest is posted it is copied from the location of the thing, a deep inserted FeatureOfInterest
+// is created. fois can be used to cache the FeatureOfInterest id per datastream id, nil disables caching.
+// The returned bool is true when a deep inserted FeatureOfInterest was created for the observation
+func (a *APIv1) setObservationFeatureOfInterest(observation *entities.Observation, fois map[string]string) (bool, error) {
 	datastreamID := observation.Datastream.ID
 
 	// there is no foi posted: try to copy it from thing.location...
 	if observation.FeatureOfInterest == nil {
-		foiID, err := CopyLocationToFoi(&a.db, datastreamID)
-
-		if err != nil {
-			errorMessage := "Missing Observation.FeatureOfInterest. Unable to create it from the Location: "
-			return nil, []error{gostErrors.NewBadRequestError(errors.New(errorMessage + err.Error()))}
+		foiID, ok := fois[toStringID(datastreamID)]
+		if !ok {
+			var err error
+			foiID, err = CopyLocationToFoi(&a.db, datastreamID)
+			if err != nil {
+				errorMessage := "Missing Observation.FeatureOfInterest. Unable to create it from the Location: "
+				return false, gostErrors.NewBadRequestError(errors.New(errorMessage + err.Error()))
+			}
+
+			if fois != nil {
+				fois[toStringID(datastreamID)] = foiID
+			}
 		}
 
 		observation.FeatureOfInterest = &entities.FeatureOfInterest{}
 		observation.FeatureOfInterest.ID = foiID
 	} else if observation.FeatureOfInterest != nil && observation.FeatureOfInterest.ID == nil {
-		var foi *entities.FeatureOfInterest
-		if foi, err = a.PostFeatureOfInterest(observation.FeatureOfInterest); err != nil {
-			return nil, []error{gostErrors.NewConflictRequestError(errors.New("Unable to create deep inserted FeatureOfInterest"))}
+		foi, err := a.PostFeatureOfInterest(observation.FeatureOfInterest)
+		if err != nil {
+			return false, gostErrors.NewConflictRequestError(errors.New("Unable to create deep inserted FeatureOfInterest"))
 		}
 		observation.FeatureOfInterest = foi
+		return true, nil
 	}
 
-	no, err2 := a.db.PostObservation(observation)
-	if err2 != nil {
-		return nil, []error{err2}
-	}
+	return false, nil
+}
 
-	no.SetAllLinks(a.config.GetExternalServerURI())
+// deleteFeaturesOfInterest removes deep inserted FeaturesOfInterest of observations that were not created, the
+// FeaturesOfInterest are created before the observations and outside of their transaction. A FeatureOfInterest
+// copied from the location of a thing is kept since it is shared by the observations of the datastream
+func (a *APIv1) deleteFeaturesOfInterest(ids ...interface{}) {
+	for _, id := range ids {
+		if err := a.db.DeleteFeatureOfInterest(id); err != nil {
+			a.logger.Errorf("Error deleting FeatureOfInterest %v of failed observation %v", id, err)
+		}
+	}
+}
 
-	json, _ := json.Marshal(no)
+// publishObservation sends a created observation to the MQTT topics of its datastream and to the
+// subscriptions matching the observation or one of its relations
+func (a *APIv1) publishObservation(observation *entities.Observation, datastreamID interface{}, relations ...string) {
+	json, _ := json.Marshal(observation)
 	s := string(json)
 
 	//ToDo: MQTT TEST
@@ -144,7 +205,7 @@ func (a *APIv1) PostObservation(observation *entities.Observation) (*entities.Ob
 		go a.MQTTPublish(topics, s, 0)
 	}
 
-	return no, nil
+	a.notifySubscriptions(observation, relations...)
 }
 
 // MQTTPublish publishes a message to a set of given topics
@@ -172,7 +233,14 @@ func (a *APIv1) PatchObservation(id interface{}, observation *entities.Observati
 		return nil, gostErrors.NewBadRequestError(errors.New("Unable to deep patch Observation"))
 	}
 
-	return a.db.PatchObservation(id, observation)
+	po, err := a.db.PatchObservation(id, observation)
+	if err != nil {
+		return nil, err
+	}
+
+	po.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(po, a.storedRelations(po)...)
+	return po, nil
 }
 
 // PutObservation updates the given observation in the database
@@ -181,10 +249,21 @@ func (a *APIv1) PutObservation(id interface{}, observation *entities.Observation
 	if err2 != nil {
 		return nil, []error{err2}
 	}
+
+	a.notifyChanged(obs, a.storedRelations(obs)...)
 	return obs, nil
 }
 
 // DeleteObservation deletes a given Observation from the database
 func (a *APIv1) DeleteObservation(id interface{}) error {
-	return a.db.DeleteObservation(id)
+	deleted := &entities.Observation{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteObservation(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/sensorthings/odata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetObservationAggregatesNextLink(t *testing.T) {
+	// arrange
+	a, ds := createTestAPI()
+	foi, _ := a.db.PostFeatureOfInterest(&entities.FeatureOfInterest{Name: "foi"})
+	for _, phenomenonTime := range []string{"2017-01-01T00:10:00.000Z", "2017-01-01T01:10:00.000Z", "2017-01-01T02:10:00.000Z"} {
+		o := &entities.Observation{Result: json.RawMessage("1"), PhenomenonTime: phenomenonTime, Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+		o.Datastream.ID = ds.ID
+		o.FeatureOfInterest.ID = foi.ID
+		a.db.PostObservation(o)
+	}
+
+	query := url.Values{}
+	query.Set("$apply", "groupby((interval(phenomenonTime,PT1H)),aggregate($count as count))")
+	query.Set("$top", "2")
+	qo, _ := odata.ParseURLQuery(query)
+	query.Set("$skip", "1")
+	qoSkip, _ := odata.ParseURLQuery(query)
+
+	// act
+	ar, err := a.GetObservationAggregates(ds.ID, qo, "http://localhost/v1.0/Datastreams(1)/Observations")
+	arSkip, errSkip := a.GetObservationAggregates(ds.ID, qoSkip, "http://localhost/v1.0/Datastreams(1)/Observations")
+
+	// assert
+	assert.NoError(t, err)
+	assert.NoError(t, errSkip)
+	assert.Contains(t, ar.NextLink, "$top=2&$skip=2", "aggregates should get a $skip link without $skip in the request")
+	assert.Empty(t, arSkip.NextLink, "the last page should have no next link")
+}
@@ -60,6 +60,7 @@ func (a *APIv1) PostObservedProperty(op *entities.ObservedProperty) (*entities.O
 	}
 
 	nop.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifySubscriptions(nop)
 
 	return nop, nil
 }
@@ -70,7 +71,14 @@ func (a *APIv1) PatchObservedProperty(id interface{}, op *entities.ObservedPrope
 		return nil, gostErrors.NewBadRequestError(errors.New("Unable to deep patch ObservedProperty"))
 	}
 
-	return a.db.PatchObservedProperty(id, op)
+	pop, err := a.db.PatchObservedProperty(id, op)
+	if err != nil {
+		return nil, err
+	}
+
+	pop.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(pop, a.storedRelations(pop)...)
+	return pop, nil
 }
 
 // PutObservedProperty patches a given ObservedProperty
@@ -81,11 +89,21 @@ func (a *APIv1) PutObservedProperty(id interface{}, op *entities.ObservedPropert
 	}
 
 	nop.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(nop)
 
 	return nop, nil
 }
 
 // DeleteObservedProperty deletes a given ObservedProperty from the database
 func (a *APIv1) DeleteObservedProperty(id interface{}) error {
-	return a.db.DeleteObservedProperty(id)
+	deleted := &entities.ObservedProperty{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteObservedProperty(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
@@ -66,6 +66,7 @@ func (a *APIv1) PostSensor(sensor *entities.Sensor) (*entities.Sensor, []error)
 	}
 
 	ns.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifySubscriptions(ns)
 
 	return ns, nil
 }
@@ -83,7 +84,14 @@ func (a *APIv1) PatchSensor(id interface{}, sensor *entities.Sensor) (*entities.
 		}
 	}
 
-	return a.db.PatchSensor(id, sensor)
+	ps, err := a.db.PatchSensor(id, sensor)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(ps, a.storedRelations(ps)...)
+	return ps, nil
 }
 
 // PutSensor updates the given thing in the database
@@ -95,10 +103,21 @@ func (a *APIv1) PutSensor(id interface{}, sensor *entities.Sensor) (*entities.Se
 	}
 
 	putsensor.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(putsensor)
+
 	return putsensor, nil
 }
 
 // DeleteSensor deletes a sensor from the database by given sensor id
 func (a *APIv1) DeleteSensor(id interface{}) error {
-	return a.db.DeleteSensor(id)
+	deleted := &entities.Sensor{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteSensor(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
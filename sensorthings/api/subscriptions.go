@@ -0,0 +1,489 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// subscription is a SensorThings MQTT topic such as v1.0/Things(1)/Datastreams?$select=name where clients
+// are subscribed to, path is used to match changed entities, filter is the $filter a changed entity has to
+// match and the query options shape the published message
+type subscription struct {
+	topic   string
+	path    string
+	filter  string
+	qo      *odata.QueryOptions
+	clients map[string]bool
+}
+
+// subscriptions keeps track of all SensorThings topics with at least one subscribed client, persistent holds
+// the clients connected with a persistent session which keep their subscriptions when disconnecting
+type subscriptions struct {
+	sync.RWMutex
+	topics     map[string]*subscription
+	persistent map[string]bool
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{topics: make(map[string]*subscription), persistent: make(map[string]bool)}
+}
+
+// AddSubscription registers the subscription of a client on a SensorThings MQTT topic, entity changes
+// matching the topic will be published on it. Topics not starting with v1.0/ are ignored
+func (a *APIv1) AddSubscription(clientID, topic string) error {
+	s, err := parseSubscription(topic)
+	if err != nil {
+		return err
+	}
+
+	a.subscriptions.Lock()
+	defer a.subscriptions.Unlock()
+
+	if existing, ok := a.subscriptions.topics[topic]; ok {
+		s = existing
+	} else {
+		a.subscriptions.topics[topic] = s
+	}
+
+	s.clients[clientID] = true
+	return nil
+}
+
+// RemoveSubscription removes the subscription of a client, the topic is no longer published when
+// no clients are left
+func (a *APIv1) RemoveSubscription(clientID, topic string) {
+	a.subscriptions.Lock()
+	defer a.subscriptions.Unlock()
+
+	a.subscriptions.remove(clientID, topic)
+}
+
+// ClientConnected registers the connection of a client, a clean session starts without subscriptions so
+// subscriptions left by an earlier connection of the client are removed. The subscriptions of a client
+// connected with a persistent session are kept when it disconnects
+func (a *APIv1) ClientConnected(clientID string, cleanSession bool) {
+	a.subscriptions.Lock()
+	defer a.subscriptions.Unlock()
+
+	if !cleanSession {
+		a.subscriptions.persistent[clientID] = true
+		return
+	}
+
+	delete(a.subscriptions.persistent, clientID)
+	a.subscriptions.removeClient(clientID)
+}
+
+// ClientDisconnected removes the subscriptions of a disconnected client unless it is connected with a
+// persistent session, clients connected before GOST are handled as clean sessions
+func (a *APIv1) ClientDisconnected(clientID string) {
+	a.subscriptions.Lock()
+	defer a.subscriptions.Unlock()
+
+	if !a.subscriptions.persistent[clientID] {
+		a.subscriptions.removeClient(clientID)
+	}
+}
+
+func (s *subscriptions) remove(clientID, topic string) {
+	sub, ok := s.topics[topic]
+	if !ok {
+		return
+	}
+
+	delete(sub.clients, clientID)
+	if len(sub.clients) == 0 {
+		delete(s.topics, topic)
+	}
+}
+
+func (s *subscriptions) removeClient(clientID string) {
+	for topic := range s.topics {
+		s.remove(clientID, topic)
+	}
+}
+
+// parseSubscription parses a topic into a subscription, only $select and $filter are supported as query
+// options since the message is created from the changed entity
+func parseSubscription(topic string) (*subscription, error) {
+	prefix := fmt.Sprintf("%s/", models.APIPrefix)
+	if !strings.HasPrefix(topic, prefix) {
+		return nil, gostErrors.NewBadRequestError(fmt.Errorf("Topic %s is not a SensorThings topic", topic))
+	}
+
+	path := strings.TrimPrefix(topic, prefix)
+	rawQuery := ""
+	if i := strings.Index(path, "?"); i != -1 {
+		rawQuery = path[i+1:]
+		path = path[:i]
+	}
+
+	if len(path) == 0 || strings.ContainsAny(path, "#+") {
+		return nil, gostErrors.NewBadRequestError(fmt.Errorf("Topic %s is not a SensorThings topic", topic))
+	}
+
+	segments := strings.Split(path, "/")
+	first := segments[0]
+	if i := strings.Index(first, "("); i != -1 {
+		first = first[:i]
+	}
+
+	if _, err := entities.EntityFromString(strings.ToLower(first)); err != nil {
+		return nil, gostErrors.NewBadRequestError(fmt.Errorf("Topic %s does not start with an entity", topic))
+	}
+
+	s := &subscription{topic: topic, path: path, clients: make(map[string]bool)}
+	if len(rawQuery) == 0 {
+		return s, nil
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, gostErrors.NewBadRequestError(err)
+	}
+
+	for key := range query {
+		if key != "$select" && key != "$filter" {
+			return nil, gostErrors.NewBadRequestError(fmt.Errorf("Query option %s is not supported for MQTT subscriptions, supported: $select, $filter", key))
+		}
+	}
+
+	if s.qo, err = odata.ParseURLQuery(query); err != nil {
+		return nil, gostErrors.NewBadRequestError(err)
+	}
+
+	s.filter = query.Get("$filter")
+	return s, nil
+}
+
+// matchFilter returns true when the subscription has no $filter or the changed entity matches it, the
+// filter is evaluated by the database by querying the entity with its id and the filter
+func (a *APIv1) matchFilter(entity entities.Entity, s *subscription) bool {
+	if len(s.filter) == 0 {
+		return true
+	}
+
+	id := fmt.Sprintf("%v", entity.GetID())
+	if str, ok := entity.GetID().(string); ok {
+		id = fmt.Sprintf("'%s'", strings.Replace(str, "'", "''", -1))
+	}
+
+	qo, err := odata.ParseURLQuery(url.Values{"$filter": []string{fmt.Sprintf("id eq %s and (%s)", id, s.filter)}})
+	if err != nil {
+		return false
+	}
+
+	count := 0
+	switch entity.(type) {
+	case *entities.Thing:
+		var found []*entities.Thing
+		found, _, _, err = a.db.GetThings(qo)
+		count = len(found)
+	case *entities.Location:
+		var found []*entities.Location
+		found, _, _, err = a.db.GetLocations(qo)
+		count = len(found)
+	case *entities.HistoricalLocation:
+		var found []*entities.HistoricalLocation
+		found, _, _, err = a.db.GetHistoricalLocations(qo)
+		count = len(found)
+	case *entities.Datastream:
+		var found []*entities.Datastream
+		found, _, _, err = a.db.GetDatastreams(qo)
+		count = len(found)
+	case *entities.Sensor:
+		var found []*entities.Sensor
+		found, _, _, err = a.db.GetSensors(qo)
+		count = len(found)
+	case *entities.ObservedProperty:
+		var found []*entities.ObservedProperty
+		found, _, _, err = a.db.GetObservedProperties(qo)
+		count = len(found)
+	case *entities.Observation:
+		var found []*entities.Observation
+		found, _, _, err = a.db.GetObservations(qo)
+		count = len(found)
+	case *entities.FeatureOfInterest:
+		var found []*entities.FeatureOfInterest
+		found, _, _, err = a.db.GetFeatureOfInterests(qo)
+		count = len(found)
+	}
+
+	if err != nil {
+		a.logger.Debugf("Unable to apply the filter of MQTT subscription %s: %v", s.topic, err)
+		return false
+	}
+
+	return count > 0
+}
+
+// message creates the message to publish for the given entity, if property is set only the property is
+// send else the $select of the subscription is applied
+func (s *subscription) message(entity entities.Entity, property string) (string, error) {
+	b, err := json.Marshal(entity)
+	if err != nil {
+		return "", err
+	}
+
+	values := make(map[string]interface{})
+	if err = json.Unmarshal(b, &values); err != nil {
+		return "", err
+	}
+
+	selected := make(map[string]interface{})
+	if len(property) > 0 {
+		value, ok := values[property]
+		if !ok {
+			return "", errors.New("Property not found")
+		}
+
+		selected[property] = value
+	} else if s.qo != nil && s.qo.Select != nil && len(s.qo.Select.SelectItems) > 0 {
+		for _, si := range s.qo.Select.SelectItems {
+			name := si.Segments[0].Value
+			if strings.ToLower(name) == "id" {
+				name = "@iot.id"
+			}
+
+			for k, v := range values {
+				if strings.EqualFold(k, name) || strings.EqualFold(k, name+"@iot.navigationLink") {
+					selected[k] = v
+				}
+			}
+		}
+	} else {
+		selected = values
+	}
+
+	b, err = json.Marshal(selected)
+	return string(b), err
+}
+
+// notifySubscriptions publishes a created or updated entity on all subscribed topics it belongs to, the entity
+// can be found on its entity set, by its id, by its properties and by the given relations such as
+// Datastreams(1)/Observations
+func (a *APIv1) notifySubscriptions(entity entities.Entity, relations ...string) {
+	a.publishSubscriptions(entity, false, relations)
+}
+
+// notifyChanged publishes a patched or replaced entity on the topic of its entity set and the topics of the
+// given relations, the same way created entities are published, and on all subscribed topics it belongs to
+func (a *APIv1) notifyChanged(entity entities.Entity, relations ...string) {
+	a.publishChange(entity, false, relations)
+	a.publishSubscriptions(entity, false, relations)
+}
+
+// notifyDeleted publishes the id of a deleted entity with @iot.deleted set to true on the topic of its entity
+// set and the topics of the given relations, and on the subscribed topics of its entity set, its id and the
+// given relations
+func (a *APIv1) notifyDeleted(entity entities.Entity, relations ...string) {
+	a.publishChange(entity, true, relations)
+	a.publishSubscriptions(entity, true, relations)
+}
+
+// publishChange publishes a changed entity on the plain topics such as Things and Datastreams(1)/Observations
+func (a *APIv1) publishChange(entity entities.Entity, deleted bool, relations []string) {
+	if !a.config.MQTT.Enabled || entity == nil {
+		return
+	}
+
+	ep, ok := (*a.GetEndpoints())[entity.GetEntityType()]
+	if !ok {
+		return
+	}
+
+	var msg string
+	if deleted {
+		msg, _ = deletedMessage(entity)
+	} else {
+		b, _ := json.Marshal(entity)
+		msg = string(b)
+	}
+
+	go a.MQTTPublish(append([]string{ep.GetName()}, relations...), msg, 0)
+}
+
+// publishSubscriptions publishes an entity on the subscribed topics it belongs to, a created or updated
+// entity is only published on a topic with a $filter when it matches the filter, deleted entities are
+// published on every topic since they can no longer be filtered
+func (a *APIv1) publishSubscriptions(entity entities.Entity, deleted bool, relations []string) {
+	if !a.hasSubscriptions() || entity == nil {
+		return
+	}
+
+	ep, ok := (*a.GetEndpoints())[entity.GetEntityType()]
+	if !ok {
+		return
+	}
+
+	entityPath := fmt.Sprintf("%s(%v)", ep.GetName(), entity.GetID())
+	paths := append([]string{ep.GetName(), entityPath}, relations...)
+
+	// the filters query the database, collect the matching subscriptions first so adding and removing
+	// subscriptions does not wait for the database
+	matched := make([]*subscription, 0)
+	properties := make(map[*subscription]string)
+	a.subscriptions.RLock()
+	for _, s := range a.subscriptions.topics {
+		if !containsPath(paths, s.path) {
+			if deleted || !strings.HasPrefix(strings.ToLower(s.path), strings.ToLower(entityPath+"/")) {
+				continue
+			}
+
+			properties[s] = s.path[len(entityPath)+1:]
+		}
+
+		matched = append(matched, s)
+	}
+	a.subscriptions.RUnlock()
+
+	for _, s := range matched {
+		var msg string
+		var err error
+		if deleted {
+			msg, err = deletedMessage(entity)
+		} else if a.matchFilter(entity, s) {
+			msg, err = s.message(entity, properties[s])
+		} else {
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		go a.MQTTPublish([]string{s.topic}, msg, 0)
+	}
+}
+
+// hasSubscriptions returns true when MQTT is enabled and at least one client is subscribed
+func (a *APIv1) hasSubscriptions() bool {
+	if !a.config.MQTT.Enabled || a.subscriptions == nil {
+		return false
+	}
+
+	a.subscriptions.RLock()
+	defer a.subscriptions.RUnlock()
+	return len(a.subscriptions.topics) > 0
+}
+
+func deletedMessage(entity entities.Entity) (string, error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"@iot.id":      entity.GetID(),
+		"@iot.deleted": true,
+	})
+
+	return string(b), err
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.EqualFold(p, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// entityRelations returns the paths of the related entities where the given entity can be found on, for example
+// Datastreams(1)/Observations for an observation of datastream 1. Call before inserting since inserting clears
+// the related entities
+func entityRelations(entity entities.Entity) []string {
+	relations := make([]string, 0)
+	add := func(format string, id interface{}) {
+		if id != nil {
+			relations = append(relations, fmt.Sprintf(format, id))
+		}
+	}
+
+	switch e := entity.(type) {
+	case *entities.Observation:
+		if e.Datastream != nil {
+			add("Datastreams(%v)/Observations", e.Datastream.ID)
+		}
+		if e.FeatureOfInterest != nil {
+			add("FeaturesOfInterest(%v)/Observations", e.FeatureOfInterest.ID)
+		}
+	case *entities.Datastream:
+		if e.Thing != nil {
+			add("Things(%v)/Datastreams", e.Thing.ID)
+		}
+		if e.Sensor != nil {
+			add("Sensors(%v)/Datastreams", e.Sensor.ID)
+		}
+		if e.ObservedProperty != nil {
+			add("ObservedProperties(%v)/Datastreams", e.ObservedProperty.ID)
+		}
+	case *entities.HistoricalLocation:
+		if e.Thing != nil {
+			add("Things(%v)/HistoricalLocations", e.Thing.ID)
+		}
+		for _, l := range e.Locations {
+			if l != nil {
+				add("Locations(%v)/HistoricalLocations", l.ID)
+			}
+		}
+	case *entities.Thing:
+		for _, l := range e.Locations {
+			if l != nil {
+				add("Locations(%v)/Things", l.ID)
+			}
+		}
+	case *entities.Location:
+		for _, t := range e.Things {
+			if t != nil {
+				add("Things(%v)/Locations", t.ID)
+			}
+		}
+	}
+
+	return relations
+}
+
+// storedRelations loads the related entities of a stored entity from the database and returns the
+// paths they can be found on, see entityRelations. The database is only queried when MQTT is enabled
+func (a *APIv1) storedRelations(entity entities.Entity) []string {
+	if !a.config.MQTT.Enabled {
+		return nil
+	}
+
+	id := entity.GetID()
+	switch entity.(type) {
+	case *entities.Observation:
+		o := &entities.Observation{}
+		o.Datastream, _ = a.db.GetDatastreamByObservation(id, nil)
+		o.FeatureOfInterest, _ = a.db.GetFeatureOfInterestByObservation(id, nil)
+		return entityRelations(o)
+	case *entities.Datastream:
+		d := &entities.Datastream{}
+		d.Thing, _ = a.db.GetThingByDatastream(id, nil)
+		d.Sensor, _ = a.db.GetSensorByDatastream(id, nil)
+		d.ObservedProperty, _ = a.db.GetObservedPropertyByDatastream(id, nil)
+		return entityRelations(d)
+	case *entities.HistoricalLocation:
+		hl := &entities.HistoricalLocation{}
+		hl.Thing, _ = a.db.GetThingByHistoricalLocation(id, nil)
+		hl.Locations, _, _, _ = a.db.GetLocationsByHistoricalLocation(id, nil)
+		return entityRelations(hl)
+	case *entities.Thing:
+		t := &entities.Thing{}
+		t.Locations, _, _, _ = a.db.GetLocationsByThing(id, nil)
+		return entityRelations(t)
+	case *entities.Location:
+		l := &entities.Location{}
+		l.Things, _, _, _ = a.db.GetThingsByLocation(id, nil)
+		return entityRelations(l)
+	}
+
+	return nil
+}
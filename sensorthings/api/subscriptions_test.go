@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubscription(t *testing.T) {
+	// arrange
+	// act
+	s, err := parseSubscription("v1.0/Observations?$select=result,phenomenonTime")
+	_, errPrefix := parseSubscription("GOST/Observations")
+	_, errWildcard := parseSubscription("v1.0/Things(1)/#")
+	filtered, errFilter := parseSubscription("v1.0/Observations?$filter=result gt 1")
+	_, errInvalidFilter := parseSubscription("v1.0/Observations?$filter=(result gt 1")
+	_, errQuery := parseSubscription("v1.0/Observations?$orderby=result")
+	_, errEntity := parseSubscription("v1.0/Nothing(1)/name")
+
+	// assert
+	assert.NoError(t, err)
+	assert.Equal(t, "Observations", s.path)
+	assert.NotNil(t, s.qo)
+	assert.NoError(t, errFilter)
+	assert.Equal(t, "result gt 1", filtered.filter)
+	assert.Error(t, errInvalidFilter)
+	assert.Error(t, errPrefix)
+	assert.Error(t, errWildcard)
+	assert.Error(t, errQuery)
+	assert.Error(t, errEntity)
+}
+
+func TestAddRemoveSubscription(t *testing.T) {
+	// arrange
+	a := &APIv1{subscriptions: newSubscriptions()}
+	topic := "v1.0/Things(1)/Datastreams"
+
+	// act
+	a.AddSubscription("client1", topic)
+	a.AddSubscription("client2", topic)
+	a.RemoveSubscription("client1", topic)
+	_, afterFirst := a.subscriptions.topics[topic]
+	a.RemoveSubscription("client2", topic)
+	_, afterSecond := a.subscriptions.topics[topic]
+
+	// assert
+	assert.True(t, afterFirst, "topic should be kept while a client is subscribed")
+	assert.False(t, afterSecond, "topic should be removed when no clients are subscribed")
+}
+
+func TestClientDisconnectedRemovesSubscriptions(t *testing.T) {
+	// arrange
+	a := &APIv1{subscriptions: newSubscriptions()}
+	topic := "v1.0/Things(1)/Datastreams"
+	a.AddSubscription("client1", topic)
+	a.AddSubscription("persistent", topic)
+	a.AddSubscription("persistent", "v1.0/Observations")
+	a.ClientConnected("persistent", false)
+
+	// act
+	a.ClientDisconnected("client1")
+	clientsAfterDisconnect := len(a.subscriptions.topics[topic].clients)
+	a.ClientDisconnected("persistent")
+	topicsAfterPersistent := len(a.subscriptions.topics)
+	a.ClientConnected("persistent", true)
+
+	// assert
+	assert.Equal(t, 1, clientsAfterDisconnect, "subscriptions of a disconnected client should be removed")
+	assert.Equal(t, 2, topicsAfterPersistent, "subscriptions of a persistent session should be kept")
+	assert.Len(t, a.subscriptions.topics, 0, "a clean session should remove earlier subscriptions")
+	assert.Len(t, a.subscriptions.persistent, 0)
+}
+
+func TestSubscriptionMessage(t *testing.T) {
+	// arrange
+	thing := &entities.Thing{Name: "thing", Description: "description"}
+	thing.ID = 1
+	s, _ := parseSubscription("v1.0/Things?$select=id,name")
+
+	// act
+	selected, err := s.message(thing, "")
+	property, err2 := s.message(thing, "description")
+
+	// assert
+	assert.NoError(t, err)
+	assert.NoError(t, err2)
+	assert.JSONEq(t, `{"@iot.id":1,"name":"thing"}`, selected)
+	assert.JSONEq(t, `{"description":"description"}`, property)
+}
+
+func TestMatchFilter(t *testing.T) {
+	// arrange
+	a, _ := createTestAPI()
+	things, _, _, _ := a.db.GetThings(nil)
+	s, _ := parseSubscription("v1.0/Things?$filter=name eq 'thing'")
+	other, _ := parseSubscription("v1.0/Things?$filter=name eq 'other'")
+	all, _ := parseSubscription("v1.0/Things")
+
+	// act
+	match := a.matchFilter(things[0], s)
+	otherMatch := a.matchFilter(things[0], other)
+	allMatch := a.matchFilter(things[0], all)
+
+	// assert
+	assert.True(t, match)
+	assert.False(t, otherMatch, "entity not matching the filter should not be published")
+	assert.True(t, allMatch, "subscription without filter should match every entity")
+}
+
+func TestEntityRelations(t *testing.T) {
+	// arrange
+	observation := &entities.Observation{Datastream: &entities.Datastream{}, FeatureOfInterest: &entities.FeatureOfInterest{}}
+	observation.Datastream.ID = 5
+	observation.FeatureOfInterest.ID = 7
+
+	// act
+	relations := entityRelations(observation)
+
+	// assert
+	assert.Equal(t, []string{"Datastreams(5)/Observations", "FeaturesOfInterest(7)/Observations"}, relations)
+}
+
+func TestDeletedMessage(t *testing.T) {
+	// arrange
+	thing := &entities.Thing{}
+	thing.ID = 1
+
+	// act
+	msg, err := deletedMessage(thing)
+
+	// assert
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"@iot.id":1,"@iot.deleted":true}`, msg)
+}
+
+func TestStoredRelationsWithoutMQTT(t *testing.T) {
+	// arrange
+	a := &APIv1{subscriptions: newSubscriptions()}
+	thing := &entities.Thing{}
+	thing.ID = 1
+
+	// act
+	relations := a.storedRelations(thing)
+
+	// assert
+	assert.Nil(t, relations, "database should not be queried when MQTT is disabled")
+}
+
+type publishRecorder struct {
+	published chan string
+}
+
+func (r *publishRecorder) Start(a *models.API)                 {}
+func (r *publishRecorder) Stop()                               {}
+func (r *publishRecorder) Shutdown(ctx context.Context) error  { return nil }
+func (r *publishRecorder) IsConnected() bool                   { return true }
+func (r *publishRecorder) Publish(topic, msg string, qos byte) { r.published <- topic + " " + msg }
+
+func TestNotifyChangedAndDeleted(t *testing.T) {
+	// arrange
+	recorder := &publishRecorder{published: make(chan string, 10)}
+	a := &APIv1{subscriptions: newSubscriptions(), mqtt: recorder}
+	a.config.MQTT.Enabled = true
+	a.AddSubscription("client1", "v1.0/Observations(1)")
+	observation := &entities.Observation{Result: json.RawMessage("20.5")}
+	observation.ID = 1
+
+	// act
+	a.notifyChanged(observation, "Datastreams(5)/Observations")
+	changed := []string{<-recorder.published, <-recorder.published, <-recorder.published}
+	a.notifyDeleted(observation, "Datastreams(5)/Observations")
+	deleted := []string{<-recorder.published, <-recorder.published, <-recorder.published}
+	sort.Strings(changed)
+	sort.Strings(deleted)
+
+	// assert
+	assert.True(t, strings.HasPrefix(changed[0], "Datastreams(5)/Observations {"))
+	assert.True(t, strings.HasPrefix(changed[1], "Observations {"))
+	assert.True(t, strings.HasPrefix(changed[2], "v1.0/Observations(1) {"))
+	assert.Equal(t, []string{
+		`Datastreams(5)/Observations {"@iot.deleted":true,"@iot.id":1}`,
+		`Observations {"@iot.deleted":true,"@iot.id":1}`,
+		`v1.0/Observations(1) {"@iot.deleted":true,"@iot.id":1}`,
+	}, deleted)
+}
+
+func TestPatchPublishesLinks(t *testing.T) {
+	// arrange
+	a, _ := createTestAPI()
+	recorder := &publishRecorder{published: make(chan string, 10)}
+	a.mqtt = recorder
+	a.config.MQTT.Enabled = true
+	things, _, _, _ := a.db.GetThings(nil)
+
+	// act
+	_, err := a.PatchThing(things[0].ID, &entities.Thing{Name: "patched"})
+	published := <-recorder.published
+
+	// assert
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(published, "Things {"))
+	assert.Contains(t, published, "@iot.selfLink", "links should be set before the change is published")
+}
+
+func TestPostLocationByThingRollbackDoesNotPublish(t *testing.T) {
+	// arrange
+	a, _ := createTestAPI()
+	recorder := &publishRecorder{published: make(chan string, 10)}
+	a.mqtt = recorder
+	a.config.MQTT.Enabled = true
+	a.AddSubscription("client1", "v1.0/Locations")
+	location := &entities.Location{Name: "location", Description: "test location", EncodingType: entities.EncodingGeoJSON.Value}
+	location.Location = map[string]interface{}{"type": "Point", "coordinates": []interface{}{5.0, 52.0}}
+
+	// act
+	l, errs := a.PostLocationByThing(999, location)
+	_, count, _, _ := a.db.GetLocations(nil)
+
+	// assert
+	assert.Nil(t, l)
+	assert.NotEmpty(t, errs)
+	assert.Equal(t, 0, count, "location should be rolled back")
+	select {
+	case published := <-recorder.published:
+		t.Errorf("rolled back location should not be published, got %s", published)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -88,6 +88,7 @@ func (a *APIv1) PostThing(thing *entities.Thing) (*entities.Thing, []error) {
 
 	var postedLocations []*entities.Location
 	var postedDatastreams []*entities.Datastream
+	var linkedLocations []*entities.Location
 
 	// Handle deep insert locations
 	if thing.Locations != nil {
@@ -121,6 +122,8 @@ func (a *APIv1) PostThing(thing *entities.Thing) (*entities.Thing, []error) {
 					err = append(err, gostErrors.NewConflictRequestError(errors.New("Creating Historical Location went wrong")))
 					return nil, err
 				}
+
+				linkedLocations = append(linkedLocations, l)
 			}
 		}
 	}
@@ -149,6 +152,7 @@ func (a *APIv1) PostThing(thing *entities.Thing) (*entities.Thing, []error) {
 
 	//push to mqtt
 	a.sendOverMQTT(nt, "Things")
+	a.notifySubscriptions(nt, entityRelations(&entities.Thing{Locations: append(postedLocations, linkedLocations...)})...)
 
 	return nt, nil
 }
@@ -167,7 +171,16 @@ func (a *APIv1) reverseInserts(thing *entities.Thing, locations []*entities.Loca
 
 // DeleteThing deletes a given Thing from the database
 func (a *APIv1) DeleteThing(id interface{}) error {
-	return a.db.DeleteThing(id)
+	deleted := &entities.Thing{}
+	deleted.ID = id
+	relations := a.storedRelations(deleted)
+
+	if err := a.db.DeleteThing(id); err != nil {
+		return err
+	}
+
+	a.notifyDeleted(deleted, relations...)
+	return nil
 }
 
 // PatchThing updates the given thing in the database
@@ -176,7 +189,14 @@ func (a *APIv1) PatchThing(id interface{}, thing *entities.Thing) (*entities.Thi
 		return nil, gostErrors.NewBadRequestError(errors.New("Unable to deep patch Thing"))
 	}
 
-	return a.db.PatchThing(id, thing)
+	pt, err := a.db.PatchThing(id, thing)
+	if err != nil {
+		return nil, err
+	}
+
+	pt.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(pt, a.storedRelations(pt)...)
+	return pt, nil
 }
 
 // PutThing updates the given thing in the database
@@ -188,6 +208,8 @@ func (a *APIv1) PutThing(id interface{}, thing *entities.Thing) (*entities.Thing
 	}
 
 	putthing.SetAllLinks(a.config.GetExternalServerURI())
+	a.notifyChanged(putthing, a.storedRelations(putthing)...)
+
 	return putthing, nil
 }
 
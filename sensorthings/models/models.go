@@ -1,7 +1,12 @@
 package models
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	entities "github.com/gost/core"
 	"github.com/gost/server/configuration"
@@ -17,12 +22,18 @@ const (
 type API interface {
 	Start()
 	GetConfig() *configuration.Config
+	WithContext(ctx context.Context) API
 
 	GetAcceptedPaths() []string
 	GetVersionInfo() *VersionInfo
 	GetBasePathInfo() *entities.ArrayResponse
 	GetEndpoints() *map[entities.EntityType]Endpoint
 	GetTopics(prefix string) *[]Topic
+	AddSubscription(clientID, topic string) error
+	RemoveSubscription(clientID, topic string)
+	ClientConnected(clientID string, cleanSession bool)
+	ClientDisconnected(clientID string)
+	MQTTPublish(topics []string, msg string, qos byte)
 
 	GetThing(id interface{}, qo *odata.QueryOptions, path string) (*entities.Thing, error)
 	GetThingByDatastream(id interface{}, qo *odata.QueryOptions, path string) (*entities.Thing, error)
@@ -77,6 +88,7 @@ type API interface {
 	GetObservations(qo *odata.QueryOptions, path string) (*entities.ArrayResponse, error)
 	GetObservationsByDatastream(datastreamID interface{}, qo *odata.QueryOptions, path string) (*entities.ArrayResponse, error)
 	GetObservationsByFeatureOfInterest(foiID interface{}, qo *odata.QueryOptions, path string) (*entities.ArrayResponse, error)
+	GetObservationAggregates(datastreamID interface{}, qo *odata.QueryOptions, path string) (*entities.ArrayResponse, error)
 	PostObservation(observation *entities.Observation) (*entities.Observation, []error)
 	PostObservationByDatastream(datastreamID interface{}, observation *entities.Observation) (*entities.Observation, []error)
 	PatchObservation(id interface{}, observation *entities.Observation) (*entities.Observation, error)
@@ -99,7 +111,7 @@ type API interface {
 	DeleteSensor(id interface{}) error
 	PutSensor(id interface{}, sensor *entities.Sensor) (*entities.Sensor, []error)
 
-	PostCreateObservations(co *entities.CreateObservations) ([]string, []error)
+	PostCreateObservations(co *entities.CreateObservations, mode CreateObservationsMode) ([]string, []error)
 
 	LinkLocation(thingID interface{}, locationID interface{}) error
 	SetLinks(entity entities.Entity, qo *odata.QueryOptions)
@@ -109,7 +121,12 @@ type API interface {
 // Database specifies the operations that the database provider needs to support
 type Database interface {
 	Start()
+	Ping() error
+	Close() error
 	CreateSchema(location string) error
+	SchemaVersion() (current int, latest int, err error)
+	Migrate() (applied int, err error)
+	WithContext(ctx context.Context) Database
 
 	GetThing(id interface{}, qo *odata.QueryOptions) (*entities.Thing, error)
 	GetThingByDatastream(id interface{}, qo *odata.QueryOptions) (t *entities.Thing, e error)
@@ -173,10 +190,15 @@ type Database interface {
 	GetObservations(qo *odata.QueryOptions) (o []*entities.Observation, count int, hasNext bool, e error)
 	GetObservationsByDatastream(id interface{}, qo *odata.QueryOptions) (o []*entities.Observation, count int, hasNext bool, e error)
 	GetObservationsByFeatureOfInterest(id interface{}, qo *odata.QueryOptions) (o []*entities.Observation, count int, hasNext bool, e error)
+	GetObservationAggregates(datastreamID interface{}, qo *odata.QueryOptions) (a []*ObservationAggregate, count int, hasNext bool, e error)
 	PostObservation(*entities.Observation) (*entities.Observation, error)
+	PostObservations(observations []*entities.Observation, atomic bool) ([]*entities.Observation, map[int]error)
+	PostObservationsBulk(observations []*entities.Observation) ([]*entities.Observation, error)
 	PatchObservation(interface{}, *entities.Observation) (*entities.Observation, error)
 	PutObservation(interface{}, *entities.Observation) (*entities.Observation, error)
 	DeleteObservation(id interface{}) error
+	PruneObservations(datastreamID interface{}, before time.Time, rollupInterval time.Duration) (int64, error)
+	PruneObservationRollups(datastreamID interface{}, before time.Time) (int64, error)
 
 	GetHistoricalLocation(id interface{}, qo *odata.QueryOptions) (*entities.HistoricalLocation, error)
 	GetHistoricalLocations(qo *odata.QueryOptions) (h []*entities.HistoricalLocation, count int, hasNext bool, e error)
@@ -195,7 +217,9 @@ type Database interface {
 type MQTTClient interface {
 	Start(*API)
 	Stop()
+	Shutdown(ctx context.Context) error
 	Publish(string, string, byte) //topic, message, qos
+	IsConnected() bool
 }
 
 // Endpoint defines the rest endpoint options
@@ -206,6 +230,7 @@ type Endpoint interface {
 	GetSupportedExpandParams() []string
 	GetSupportedSelectParams() []string
 	ShowOutputInfo() bool
+	GetEntityType() entities.EntityType
 }
 
 // HTTPHandler func defines the format of the handler to process the incoming request
@@ -230,17 +255,69 @@ const (
 	HTTPOperationDelete HTTPOperation = "DELETE"
 )
 
-// Topic defines the MQTT PUBLISH topics
+// CreateObservationsMode describes how a CreateObservations request handles observations that cannot be created
+type CreateObservationsMode string
+
+// CreateObservationsMode is a "enumeration" of the supported CreateObservations modes, CreateObservationsModeAtomic creates
+// all observations or none, CreateObservationsModeBestEffort creates all valid observations and reports the failing ones
+const (
+	CreateObservationsModeAtomic     CreateObservationsMode = "atomic"
+	CreateObservationsModeBestEffort CreateObservationsMode = "besteffort"
+)
+
+// CountCreated returns the number of created Observations in the results of PostCreateObservations, a
+// result is the self link of the created Observation or the error of an Observation that could not be created
+func CountCreated(results []string, externalURI string) int {
+	count := 0
+	for _, result := range results {
+		if strings.HasPrefix(result, externalURI+"/") {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ObservationAggregate holds the aggregates requested by $apply of the Observations with a phenomenonTime in
+// the interval from Start until End, Values contains the aggregates by their alias
+type ObservationAggregate struct {
+	Start  time.Time
+	End    time.Time
+	Values map[string]interface{}
+}
+
+// MarshalJSON writes the aggregates and the interval as phenomenonTime, for example
+// {"phenomenonTime":"2017-01-01T00:00:00.000Z/2017-01-01T01:00:00.000Z","avg":20.5,"count":60}
+func (a *ObservationAggregate) MarshalJSON() ([]byte, error) {
+	values := make(map[string]interface{}, len(a.Values)+1)
+	for alias, value := range a.Values {
+		values[alias] = value
+	}
+
+	values[odata.AggregatePhenomenonTime] = fmt.Sprintf("%s/%s", a.Start.UTC().Format(aggregateTimeFormat), a.End.UTC().Format(aggregateTimeFormat))
+	return json.Marshal(values)
+}
+
+const aggregateTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// Topic defines the MQTT PUBLISH topics, BatchHandler is optional and handles multiple messages received
+// on the same topic at once
 type Topic struct {
-	Path    string
-	Handler MQTTHandler
+	Path         string
+	Handler      MQTTHandler
+	BatchHandler MQTTBatchHandler
 }
 
 // MQTTHandler func defines the format of the handler to process the incoming MQTT publish message
 type MQTTHandler func(a *API, prefix, topic string, message []byte)
 
+// MQTTBatchHandler func defines the format of the handler to process multiple MQTT publish messages
+// received on the same topic, the messages are in order of arrival
+type MQTTBatchHandler func(a *API, prefix, topic string, messages [][]byte)
+
 // MQTTInternalHandler func defines the format of the handler to process the incoming MQTT publish message
-type MQTTInternalHandler func(a *API, message []byte, id string)
+// and returns the errors to report to the publisher
+type MQTTInternalHandler func(a *API, message []byte, id string) []error
 
 // VersionInfo describes the version info for the GOST server version and supported SensorThings API version
 type VersionInfo struct {
@@ -269,3 +346,19 @@ type ErrorContent struct {
 	StatusCode int      `json:"code"`
 	Messages   []string `json:"message"`
 }
+
+type contextKey int
+
+const externalURIKey contextKey = 0
+
+// ContextWithExternalURI returns a copy of ctx holding the external uri of a request forwarded by a proxy,
+// the links in the response to the request are created with it instead of the configured external uri
+func ContextWithExternalURI(ctx context.Context, uri string) context.Context {
+	return context.WithValue(ctx, externalURIKey, uri)
+}
+
+// ExternalURIFromContext returns the external uri in ctx or an empty string when ctx holds no external uri
+func ExternalURIFromContext(ctx context.Context) string {
+	uri, _ := ctx.Value(externalURIKey).(string)
+	return uri
+}
@@ -0,0 +1,316 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	entities "github.com/gost/core"
+	"github.com/gost/server/configuration"
+	gostLog "github.com/gost/server/log"
+	"github.com/gost/server/sensorthings/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	logger *log.Entry
+
+	// rejectedMessages is the number of messages rejected by the ACL
+	rejectedMessages uint64
+
+	// datastreamTopic matches the topics posting to a datastream, the first group is the datastream id
+	datastreamTopic = regexp.MustCompile(`^Datastreams\(([^)]+)\)/Observations$`)
+
+	// thingLocationsTopic matches the topics posting a location of a thing, the first group is the thing id
+	thingLocationsTopic = regexp.MustCompile(`^Things\(([^)]+)\)/Locations$`)
+)
+
+func setupLogger() {
+	l, err := gostLog.GetPackageLogger("gost.server.sensorthings.mqtt")
+	if err != nil {
+		log.Error(err)
+	}
+
+	logger = l
+}
+
+// RejectedMessages returns the number of MQTT messages rejected by the ACL since GOST started
+func RejectedMessages() uint64 {
+	return atomic.LoadUint64(&rejectedMessages)
+}
+
+// ACL checks if the publisher of a message is allowed to post to the Datastreams or Thing of the message. The
+// publisher is the first topic level after the prefix, for example sensor1 in GOST/sensor1/Datastreams(1)/Observations, the
+// broker has to make sure a client can only publish on its own level (mosquitto: pattern write GOST/%u/#)
+type ACL struct {
+	datastreams   map[string]map[string]bool
+	thingProperty string
+	cacheDuration time.Duration
+	mutex         sync.Mutex
+	cache         map[string]*cachedPublishers
+}
+
+// cachedPublishers holds the publishers read from the Thing of a Datastream, publishers is
+// nil when the Thing does not have the publishers property
+type cachedPublishers struct {
+	publishers map[string]bool
+	expires    time.Time
+}
+
+// NewACL creates an ACL from the mqtt.acl configuration
+func NewACL(conf configuration.MQTTACLConfig) *ACL {
+	setupLogger()
+	acl := &ACL{
+		datastreams:   make(map[string]map[string]bool),
+		thingProperty: conf.ThingProperty,
+		cacheDuration: time.Duration(conf.CacheSec) * time.Second,
+		cache:         make(map[string]*cachedPublishers),
+	}
+
+	if len(acl.thingProperty) == 0 {
+		acl.thingProperty = configuration.DefaultMQTTACLThingProperty
+	}
+
+	if conf.CacheSec == 0 {
+		acl.cacheDuration = time.Duration(configuration.DefaultMQTTACLCacheSec) * time.Second
+	}
+
+	for id, publishers := range conf.Datastreams {
+		acl.datastreams[id] = toPublisherSet(publishers)
+	}
+
+	return acl
+}
+
+// Handler returns a MQTTHandler passing the messages of allowed publishers to h, rejected messages are
+// logged and counted
+func (acl *ACL) Handler(h models.MQTTHandler) models.MQTTHandler {
+	return func(a *models.API, prefix, topic string, message []byte) {
+		publisher, path := splitPublisher(prefix, topic)
+		if err := acl.check(a, publisher, path, message); err != nil {
+			atomic.AddUint64(&rejectedMessages, 1)
+			logger.Warnf("MQTT message on %s rejected: %v", topic, err)
+			return
+		}
+
+		h(a, prefix, topic, message)
+	}
+}
+
+// BatchHandler returns a MQTTBatchHandler passing the messages of allowed publishers to h, the messages
+// are received on the same topic but can post to different Datastreams so every message is checked
+func (acl *ACL) BatchHandler(h models.MQTTBatchHandler) models.MQTTBatchHandler {
+	return func(a *models.API, prefix, topic string, messages [][]byte) {
+		publisher, path := splitPublisher(prefix, topic)
+		allowed := make([][]byte, 0, len(messages))
+		var rejectErr error
+		for _, message := range messages {
+			if err := acl.check(a, publisher, path, message); err != nil {
+				rejectErr = err
+				continue
+			}
+
+			allowed = append(allowed, message)
+		}
+
+		if rejected := len(messages) - len(allowed); rejected > 0 {
+			atomic.AddUint64(&rejectedMessages, uint64(rejected))
+			logger.Warnf("%d MQTT messages on %s rejected: %v", rejected, topic, rejectErr)
+		}
+
+		if len(allowed) > 0 {
+			h(a, prefix, topic, allowed)
+		}
+	}
+}
+
+// check returns an error when publisher is not allowed to post message to path, the publisher has to
+// be allowed by every Datastream the message posts Observations to or by the Thing it posts a Location
+// of. Publishers set in the configuration overrule the publishers set on the Thing
+func (acl *ACL) check(a *models.API, publisher, path string, message []byte) error {
+	if len(publisher) == 0 {
+		return fmt.Errorf("no publisher in topic")
+	}
+
+	if match := thingLocationsTopic.FindStringSubmatch(path); match != nil {
+		return acl.checkThing(a, publisher, match[1])
+	}
+
+	ids, err := datastreamIDs(path, message)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := acl.checkDatastream(a, publisher, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkDatastream returns an error when publisher is not allowed to post to the Datastream with the given id
+func (acl *ACL) checkDatastream(a *models.API, publisher, id string) error {
+	publishers, ok := acl.datastreams[id]
+	if !ok {
+		var err error
+		if publishers, err = acl.datastreamPublishers(a, id); err != nil {
+			return err
+		}
+	}
+
+	if publishers == nil {
+		return fmt.Errorf("no publishers configured for Datastream %s", id)
+	}
+
+	if !publishers[publisher] && !publishers["*"] {
+		return fmt.Errorf("%s is not allowed to publish to Datastream %s", publisher, id)
+	}
+
+	return nil
+}
+
+// checkThing returns an error when publisher is not allowed to post a Location of the Thing with the given id
+func (acl *ACL) checkThing(a *models.API, publisher, id string) error {
+	publishers, err := acl.thingPublishers("Things("+id+")", func() (*entities.Thing, error) {
+		api := *a
+		thing, err := api.GetThing(id, nil, "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to get Thing %s: %v", id, err)
+		}
+
+		return thing, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if publishers == nil {
+		return fmt.Errorf("no publishers configured for Thing %s", id)
+	}
+
+	if !publishers[publisher] && !publishers["*"] {
+		return fmt.Errorf("%s is not allowed to publish to Thing %s", publisher, id)
+	}
+
+	return nil
+}
+
+// datastreamPublishers returns the publishers set in the properties of the Thing of the Datastream with the given id
+func (acl *ACL) datastreamPublishers(a *models.API, id string) (map[string]bool, error) {
+	return acl.thingPublishers("Datastreams("+id+")", func() (*entities.Thing, error) {
+		api := *a
+		thing, err := api.GetThingByDatastream(id, nil, "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to get Thing of Datastream %s: %v", id, err)
+		}
+
+		return thing, nil
+	})
+}
+
+// thingPublishers returns the publishers set in the properties of the Thing returned by getThing, the
+// publishers are cached by key
+func (acl *ACL) thingPublishers(key string, getThing func() (*entities.Thing, error)) (map[string]bool, error) {
+	acl.mutex.Lock()
+	cached, ok := acl.cache[key]
+	acl.mutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.publishers, nil
+	}
+
+	thing, err := getThing()
+	if err != nil {
+		return nil, err
+	}
+
+	var publishers map[string]bool
+	switch p := thing.Properties[acl.thingProperty].(type) {
+	case string:
+		publishers = toPublisherSet(strings.Split(p, ","))
+	case []interface{}:
+		list := make([]string, 0, len(p))
+		for _, v := range p {
+			list = append(list, fmt.Sprintf("%v", v))
+		}
+		publishers = toPublisherSet(list)
+	}
+
+	acl.mutex.Lock()
+	acl.cache[key] = &cachedPublishers{publishers: publishers, expires: time.Now().Add(acl.cacheDuration)}
+	acl.mutex.Unlock()
+
+	return publishers, nil
+}
+
+// datastreamRef reads the id of the Datastream an Observation or dataArray is posted to
+type datastreamRef struct {
+	Datastream *struct {
+		ID interface{} `json:"@iot.id"`
+	} `json:"Datastream"`
+}
+
+// datastreamIDs returns the ids of the Datastreams a message posts Observations to, read from the topic
+// for Datastreams(id)/Observations or from the message for Observations and CreateObservations
+func datastreamIDs(path string, message []byte) ([]string, error) {
+	if match := datastreamTopic.FindStringSubmatch(path); match != nil {
+		return []string{match[1]}, nil
+	}
+
+	refs := make([]datastreamRef, 0)
+	d := json.NewDecoder(bytes.NewReader(message))
+	d.UseNumber()
+	switch path {
+	case "Observations":
+		ref := datastreamRef{}
+		if err := d.Decode(&ref); err != nil {
+			return nil, fmt.Errorf("unable to read Datastream from message: %v", err)
+		}
+		refs = append(refs, ref)
+	case "CreateObservations":
+		if err := d.Decode(&refs); err != nil {
+			return nil, fmt.Errorf("unable to read Datastreams from message: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("topic %s does not post to a Datastream or Thing", path)
+	}
+
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Datastream == nil || ref.Datastream.ID == nil {
+			return nil, fmt.Errorf("no Datastream id in message")
+		}
+
+		ids = append(ids, fmt.Sprintf("%v", ref.Datastream.ID))
+	}
+
+	return ids, nil
+}
+
+// splitPublisher returns the publisher and the remaining path of a topic such as GOST/sensor1/Datastreams(1)/Observations
+func splitPublisher(prefix, topic string) (string, string) {
+	topic = strings.TrimPrefix(topic, fmt.Sprintf("%s/", prefix))
+	i := strings.Index(topic, "/")
+	if i < 0 {
+		return "", topic
+	}
+
+	return topic[:i], topic[i+1:]
+}
+
+func toPublisherSet(publishers []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, p := range publishers {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			set[p] = true
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,91 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/gost/server/configuration"
+	"github.com/gost/server/sensorthings/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPublisher(t *testing.T) {
+	// act
+	publisher, path := splitPublisher("GOST", "GOST/sensor1/Datastreams(1)/Observations")
+	noPublisher, _ := splitPublisher("GOST", "GOST/Datastreams(1)")
+
+	// assert
+	assert.Equal(t, "sensor1", publisher)
+	assert.Equal(t, "Datastreams(1)/Observations", path)
+	assert.Equal(t, "", noPublisher)
+}
+
+func TestACLCheckConfiguredDatastreams(t *testing.T) {
+	// arrange
+	acl := NewACL(configuration.MQTTACLConfig{
+		Enabled: true,
+		Datastreams: map[string][]string{
+			"1": {"sensor1", "gateway"},
+			"2": {"*"},
+		},
+	})
+
+	// assert
+	assert.Nil(t, acl.check(nil, "sensor1", "Datastreams(1)/Observations", nil))
+	assert.NotNil(t, acl.check(nil, "sensor2", "Datastreams(1)/Observations", nil))
+	assert.Nil(t, acl.check(nil, "sensor2", "Datastreams(2)/Observations", nil))
+	assert.NotNil(t, acl.check(nil, "", "Datastreams(2)/Observations", nil))
+	assert.NotNil(t, acl.check(nil, "sensor1", "Things(1)", nil))
+}
+
+func TestACLHandler(t *testing.T) {
+	// arrange
+	acl := NewACL(configuration.MQTTACLConfig{
+		Enabled:     true,
+		Datastreams: map[string][]string{"1": {"sensor1"}},
+	})
+
+	handled := ""
+	h := acl.Handler(func(a *models.API, prefix, topic string, message []byte) {
+		handled = topic
+	})
+	rejected := RejectedMessages()
+
+	// act
+	h(nil, "GOST", "GOST/sensor2/Datastreams(1)/Observations", []byte("{}"))
+	rejectedTopic := handled
+	h(nil, "GOST", "GOST/sensor1/Datastreams(1)/Observations", []byte("{}"))
+
+	// assert
+	assert.Equal(t, "", rejectedTopic)
+	assert.Equal(t, rejected+1, RejectedMessages())
+	assert.Equal(t, "GOST/sensor1/Datastreams(1)/Observations", handled)
+}
+
+func TestACLCheckMessageDatastreams(t *testing.T) {
+	// arrange
+	acl := NewACL(configuration.MQTTACLConfig{
+		Enabled:     true,
+		Datastreams: map[string][]string{"1": {"sensor1"}, "2": {"sensor2"}},
+	})
+
+	// assert
+	assert.Nil(t, acl.check(nil, "sensor1", "Observations", []byte(`{"result":1,"Datastream":{"@iot.id":1}}`)))
+	assert.NotNil(t, acl.check(nil, "sensor1", "Observations", []byte(`{"result":1,"Datastream":{"@iot.id":2}}`)))
+	assert.NotNil(t, acl.check(nil, "sensor1", "Observations", []byte(`{"result":1}`)))
+	assert.Nil(t, acl.check(nil, "sensor1", "CreateObservations", []byte(`[{"Datastream":{"@iot.id":1},"dataArray":[]}]`)))
+	assert.NotNil(t, acl.check(nil, "sensor1", "CreateObservations", []byte(`[{"Datastream":{"@iot.id":1}},{"Datastream":{"@iot.id":2}}]`)))
+}
+
+func TestDatastreamIDs(t *testing.T) {
+	// act
+	topicIDs, _ := datastreamIDs("Datastreams(5)/Observations", nil)
+	observationIDs, _ := datastreamIDs("Observations", []byte(`{"Datastream":{"@iot.id":12345678901234567890}}`))
+	createIDs, _ := datastreamIDs("CreateObservations", []byte(`[{"Datastream":{"@iot.id":"a"}},{"Datastream":{"@iot.id":2}}]`))
+	_, err := datastreamIDs("Things(1)", nil)
+
+	// assert
+	assert.Equal(t, []string{"5"}, topicIDs)
+	assert.Equal(t, []string{"12345678901234567890"}, observationIDs)
+	assert.Equal(t, []string{"a", "2"}, createIDs)
+	assert.NotNil(t, err)
+}
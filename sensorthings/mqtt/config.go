@@ -3,15 +3,45 @@ package mqtt
 import (
 	"fmt"
 
+	"github.com/gost/server/configuration"
 	"github.com/gost/server/sensorthings/models"
 )
 
-// CreateTopics creates the pre-defined MQTT Topics
-func CreateTopics(prefix string) []models.Topic {
+// CreateTopics creates the pre-defined MQTT Topics, when the ACL is enabled only messages
+// of allowed publishers are handled by the MainMqttHandler. Messages on the errorTopic are
+// published by GOST and not handled
+func CreateTopics(prefix string, acl configuration.MQTTACLConfig, errorTopic string) []models.Topic {
+	var mainHandler models.MQTTHandler = MainMqttHandler
+	var mainBatchHandler models.MQTTBatchHandler = MainMqttBatchHandler
+	if acl.Enabled {
+		a := NewACL(acl)
+		mainHandler = a.Handler(MainMqttHandler)
+		mainBatchHandler = a.BatchHandler(MainMqttBatchHandler)
+	}
+
+	if len(errorTopic) > 0 {
+		pattern := errorTopicPattern(errorTopic, prefix)
+		mainHandler = ignoreErrorTopic(pattern, mainHandler)
+		mainBatchHandler = ignoreErrorTopicBatch(pattern, mainBatchHandler)
+	}
+
 	topics := []models.Topic{
 		{
-			Path:    fmt.Sprintf("%s/#", prefix),
-			Handler: MainMqttHandler,
+			Path:         fmt.Sprintf("%s/#", prefix),
+			Handler:      mainHandler,
+			BatchHandler: mainBatchHandler,
+		},
+		{
+			Path:    "$SYS/broker/log/M/subscribe",
+			Handler: SubscribeHandler,
+		},
+		{
+			Path:    "$SYS/broker/log/M/unsubscribe",
+			Handler: UnsubscribeHandler,
+		},
+		{
+			Path:    "$SYS/broker/log/N",
+			Handler: NoticeHandler,
 		},
 	}
 
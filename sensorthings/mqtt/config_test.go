@@ -3,13 +3,14 @@ package mqtt
 import (
 	"testing"
 
+	"github.com/gost/server/configuration"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateTopics(t *testing.T) {
 	// arrange
 	// act
-	topics := CreateTopics("GOST")
+	topics := CreateTopics("GOST", configuration.MQTTACLConfig{}, "{topic}/errors")
 	// assert
 	assert.True(t, len(topics) > 0, "Must have more than zero topics")
 }
@@ -0,0 +1,102 @@
+package mqtt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gost/server/sensorthings/models"
+)
+
+// ErrorMessage is published on the error topic when a message cannot be handled so the publisher learns
+// its data was rejected, the payload is identified by its SHA-256 hash
+type ErrorMessage struct {
+	Topic       string   `json:"topic"`
+	ClientID    string   `json:"clientId,omitempty"`
+	PayloadHash string   `json:"payloadHash"`
+	Errors      []string `json:"errors"`
+	Time        string   `json:"time"`
+}
+
+// newErrorMessage creates the error message for a payload received on topic, nil errors are skipped
+func newErrorMessage(topic, clientID string, payload []byte, errs []error) *ErrorMessage {
+	hash := sha256.Sum256(payload)
+	e := &ErrorMessage{
+		Topic:       topic,
+		ClientID:    clientID,
+		PayloadHash: "sha256:" + hex.EncodeToString(hash[:]),
+		Errors:      make([]string, 0, len(errs)),
+		Time:        time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		e.Errors = append(e.Errors, err.Error())
+	}
+
+	return e
+}
+
+// publishErrors publishes the errors of a message received on topic on the error topic, nothing is
+// published when no error topic is configured or errs holds no errors
+func publishErrors(a *models.API, prefix, topic string, payload []byte, errs []error) {
+	api := *a
+	template := api.GetConfig().MQTT.ErrorTopic
+	if len(template) == 0 {
+		return
+	}
+
+	clientID, path := splitTopic(a, prefix, topic)
+	e := newErrorMessage(topic, clientID, payload, errs)
+	if len(e.Errors) == 0 {
+		return
+	}
+
+	message, err := json.Marshal(e)
+	if err != nil {
+		logger.Errorf("unable to create MQTT error message: %v", err)
+		return
+	}
+
+	errorTopic := formatErrorTopic(template, prefix, clientID, path)
+	logger.Debugf("MQTT message on %s not handled, errors published on %s", topic, errorTopic)
+	api.MQTTPublish([]string{errorTopic}, string(message), 0)
+}
+
+// formatErrorTopic fills in the placeholders of the error topic: {prefix}, {clientId} (the publisher in
+// the topic when the ACL is enabled) and {topic} (the topic below the prefix and publisher), for example
+// {topic}/errors becomes Datastreams(5)/Observations/errors
+func formatErrorTopic(template, prefix, clientID, path string) string {
+	return strings.NewReplacer("{prefix}", prefix, "{clientId}", clientID, "{topic}", path).Replace(template)
+}
+
+// errorTopicPattern returns a pattern matching the topics of the error messages, they are published by
+// GOST so they are not handled when they are received on a subscribed topic
+func errorTopicPattern(template, prefix string) *regexp.Regexp {
+	r := strings.NewReplacer(`\{prefix\}`, regexp.QuoteMeta(prefix), `\{clientId\}`, `[^/]*`, `\{topic\}`, `.+`)
+	return regexp.MustCompile("^" + r.Replace(regexp.QuoteMeta(template)) + "$")
+}
+
+// ignoreErrorTopic returns a MQTTHandler passing the messages not published on an error topic to h
+func ignoreErrorTopic(pattern *regexp.Regexp, h models.MQTTHandler) models.MQTTHandler {
+	return func(a *models.API, prefix, topic string, message []byte) {
+		if !pattern.MatchString(topic) {
+			h(a, prefix, topic, message)
+		}
+	}
+}
+
+// ignoreErrorTopicBatch returns a MQTTBatchHandler passing the messages not published on an error topic to h
+func ignoreErrorTopicBatch(pattern *regexp.Regexp, h models.MQTTBatchHandler) models.MQTTBatchHandler {
+	return func(a *models.API, prefix, topic string, messages [][]byte) {
+		if !pattern.MatchString(topic) {
+			h(a, prefix, topic, messages)
+		}
+	}
+}
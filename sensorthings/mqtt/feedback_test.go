@@ -0,0 +1,40 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewErrorMessage(t *testing.T) {
+	// act
+	e := newErrorMessage("GOST/Datastreams(5)/Observations", "", []byte("test"), []error{errors.New("invalid result"), nil})
+
+	// assert
+	assert.Equal(t, "GOST/Datastreams(5)/Observations", e.Topic)
+	assert.Equal(t, "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", e.PayloadHash)
+	assert.Equal(t, []string{"invalid result"}, e.Errors)
+}
+
+func TestFormatErrorTopic(t *testing.T) {
+	// act
+	topic := formatErrorTopic("{topic}/errors", "GOST", "", "Datastreams(5)/Observations")
+	clientTopic := formatErrorTopic("{prefix}/errors/{clientId}", "GOST", "sensor1", "Datastreams(5)/Observations")
+
+	// assert
+	assert.Equal(t, "Datastreams(5)/Observations/errors", topic)
+	assert.Equal(t, "GOST/errors/sensor1", clientTopic)
+}
+
+func TestErrorTopicPattern(t *testing.T) {
+	// arrange
+	pattern := errorTopicPattern("{prefix}/errors/{clientId}", "GOST")
+	topicPattern := errorTopicPattern("{prefix}/{topic}/errors", "GOST")
+
+	// assert
+	assert.True(t, pattern.MatchString("GOST/errors/sensor1"))
+	assert.False(t, pattern.MatchString("GOST/sensor1/Datastreams(5)/Observations"))
+	assert.True(t, topicPattern.MatchString("GOST/Datastreams(5)/Observations/errors"))
+	assert.False(t, topicPattern.MatchString("GOST/Datastreams(5)/Observations"))
+}
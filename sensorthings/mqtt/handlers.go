@@ -1,45 +1,349 @@
 package mqtt
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	entities "github.com/gost/core"
+	"github.com/gost/server/metrics"
 	"github.com/gost/server/sensorthings/models"
 )
 
 var topics = map[string]models.MQTTInternalHandler{
 	"Datastreams()/Observations": observationsByDatastream,
+	"Observations":               observation,
+	"CreateObservations":         createObservations,
+	"Things()/Locations":         locationByThing,
 }
 
 // MainMqttHandler handles all messages on GOST/# and maps them to the appropriate
 // handler. Mapping is needed because of the ODATA (id) format
 func MainMqttHandler(a *models.API, prefix, topic string, message []byte) {
-	topic = strings.Replace(topic, fmt.Sprintf("%s/", prefix), "", 1)
-	topicMapName := ""
-	id := ""
-	if strings.Contains(topic, "(") {
-		i := strings.Index(topic, "(")
-		i2 := strings.Index(topic, ")")
-		first := topic[0 : i+1]
-		id = topic[i+1 : i2]
-		last := topic[i2:]
-		topicMapName = first + last
+	_, path := splitTopic(a, prefix, topic)
+	topicMapName, id, err := mapTopic(path)
+	if err != nil {
+		publishErrors(a, prefix, topic, message, []error{err})
+		return
 	}
 
 	h := topics[topicMapName]
-	if h != nil {
-		h(a, message, id)
+	if h == nil {
+		return
+	}
+
+	if errs := h(a, message, id); len(errs) > 0 {
+		publishErrors(a, prefix, topic, message, errs)
+	}
+}
+
+// MainMqttBatchHandler handles multiple messages received on the same topic, the observations of all
+// messages on a Datastream topic are inserted at once, other messages are handled by MainMqttHandler
+func MainMqttBatchHandler(a *models.API, prefix, topic string, messages [][]byte) {
+	_, path := splitTopic(a, prefix, topic)
+	topicMapName, id, err := mapTopic(path)
+	if err != nil || topicMapName != "Datastreams()/Observations" {
+		for _, message := range messages {
+			MainMqttHandler(a, prefix, topic, message)
+		}
+		return
+	}
+
+	observations := make([]*entities.Observation, 0, len(messages))
+	counts := make([]int, len(messages))
+	for i, message := range messages {
+		o, err := parseObservations(message)
+		if err != nil {
+			publishErrors(a, prefix, topic, message, []error{err})
+			continue
+		}
+
+		counts[i] = len(o)
+		observations = append(observations, o...)
+	}
+
+	// the results are in order of the observations, the observations of a message follow each other
+	results, errs := postObservations(a, id, observations)
+	position := 0
+	for i, message := range messages {
+		if counts[i] == 0 {
+			continue
+		}
+
+		failed := errs
+		if len(errs) == 0 {
+			failed = failedResults(a, results[position:position+counts[i]])
+		}
+
+		position += counts[i]
+		if len(failed) > 0 {
+			publishErrors(a, prefix, topic, message, failed)
+		}
+	}
+}
+
+// splitTopic returns the client id and the path below the prefix of a topic, the client id is the
+// first level after the prefix when the ACL is enabled
+func splitTopic(a *models.API, prefix, topic string) (string, string) {
+	api := *a
+	if api.GetConfig().MQTT.ACL.Enabled {
+		return splitPublisher(prefix, topic)
 	}
+
+	return "", strings.TrimPrefix(topic, fmt.Sprintf("%s/", prefix))
+}
+
+// mapTopic returns the name of the path in the topics map and the id in the path, for example
+// Datastreams()/Observations and 1 for Datastreams(1)/Observations. An error is returned when the id
+// is not closed by a )
+func mapTopic(path string) (string, string, error) {
+	topicMapName := path
+	id := ""
+	if strings.Contains(path, "(") {
+		i := strings.Index(path, "(")
+		i2 := strings.Index(path, ")")
+		if i2 <= i {
+			return "", "", fmt.Errorf("invalid id in topic %s", path)
+		}
+
+		first := path[0 : i+1]
+		id = path[i+1 : i2]
+		last := path[i2:]
+		topicMapName = first + last
+	}
+
+	return topicMapName, id, nil
 }
 
-func observationsByDatastream(a *models.API, message []byte, id string) {
+func observationsByDatastream(a *models.API, message []byte, id string) []error {
+	// an array of observations is inserted as a batch, for example when a device sends its backlog
+	if bytes.HasPrefix(bytes.TrimSpace(message), []byte("[")) {
+		return observationBatchByDatastream(a, message, id)
+	}
+
 	o := entities.Observation{}
 	err := o.ParseEntity(message)
 	if err != nil {
+		return []error{err}
+	}
+
+	api := *a
+	_, errs := api.PostObservationByDatastream(id, &o)
+	if len(errs) == 0 {
+		metrics.ObservationsIngested.Inc(metrics.TransportMQTT)
+	}
+
+	return errs
+}
+
+func observationBatchByDatastream(a *models.API, message []byte, id string) []error {
+	observations, err := parseObservations(message)
+	if err != nil {
+		return []error{err}
+	}
+
+	results, errs := postObservations(a, id, observations)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return failedResults(a, results)
+}
+
+// observation creates an Observation posted on Observations, the Datastream is set in the message
+func observation(a *models.API, message []byte, id string) []error {
+	o := entities.Observation{}
+	if err := o.ParseEntity(message); err != nil {
+		return []error{err}
+	}
+
+	api := *a
+	_, errs := api.PostObservation(&o)
+	if len(errs) == 0 {
+		metrics.ObservationsIngested.Inc(metrics.TransportMQTT)
+	}
+
+	return errs
+}
+
+// createObservations creates the Observations of a dataArray posted on CreateObservations, the
+// valid observations are created when others fail
+func createObservations(a *models.API, message []byte, id string) []error {
+	c := &entities.CreateObservations{}
+	if err := c.ParseEntity(message); err != nil {
+		return []error{err}
+	}
+
+	results, errs := postCreateObservations(a, c)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return failedResults(a, results)
+}
+
+// locationByThing creates a Location posted on Things(id)/Locations and links it to the Thing, a
+// HistoricalLocation is created the same way as a Location posted to the Thing over HTTP
+func locationByThing(a *models.API, message []byte, id string) []error {
+	l := &entities.Location{}
+	if err := l.ParseEntity(message); err != nil {
+		return []error{err}
+	}
+
+	api := *a
+	_, errs := api.PostLocationByThing(id, l)
+	return errs
+}
+
+// parseObservations parses a message holding an observation or an array of observations
+func parseObservations(message []byte) ([]*entities.Observation, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(message), []byte("[")) {
+		o := &entities.Observation{}
+		if err := o.ParseEntity(message); err != nil {
+			return nil, err
+		}
+
+		return []*entities.Observation{o}, nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(message, &raw); err != nil {
+		return nil, err
+	}
+
+	observations := make([]*entities.Observation, 0, len(raw))
+	for _, r := range raw {
+		o := &entities.Observation{}
+		if err := o.ParseEntity(r); err != nil {
+			return nil, err
+		}
+		observations = append(observations, o)
+	}
+
+	return observations, nil
+}
+
+// postObservations inserts the observations of the Datastream with the given id as a batch, the results
+// hold the self link or the error of every observation
+func postObservations(a *models.API, id string, observations []*entities.Observation) ([]string, []error) {
+	if len(observations) == 0 {
+		return nil, nil
+	}
+
+	d := &entities.Datastream{}
+	d.ID = id
+	d.Observations = observations
+
+	return postCreateObservations(a, &entities.CreateObservations{Datastreams: []*entities.Datastream{d}})
+}
+
+// postCreateObservations creates the observations in best effort mode and counts the created observations
+func postCreateObservations(a *models.API, c *entities.CreateObservations) ([]string, []error) {
+	api := *a
+	results, errs := api.PostCreateObservations(c, models.CreateObservationsModeBestEffort)
+	metrics.ObservationsIngested.Add(float64(models.CountCreated(results, api.GetConfig().GetExternalServerURI())), metrics.TransportMQTT)
+	return results, errs
+}
+
+// failedResults returns the errors in the results of PostCreateObservations, a created observation
+// has its self link as result
+func failedResults(a *models.API, results []string) []error {
+	api := *a
+	prefix := api.GetConfig().GetExternalServerURI() + "/"
+	errs := make([]error, 0)
+	for _, r := range results {
+		if !strings.HasPrefix(r, prefix) {
+			errs = append(errs, errors.New(r))
+		}
+	}
+
+	return errs
+}
+
+// SubscribeHandler registers client subscriptions on SensorThings topics, the broker reports them
+// on $SYS/broker/log/M/subscribe (mosquitto: log_dest topic, log_type subscribe) as
+// "<timestamp>: <clientId> <qos> <topic>"
+func SubscribeHandler(a *models.API, prefix, topic string, message []byte) {
+	clientID, subscribed, ok := parseBrokerLog(message, 2)
+	if !ok {
+		return
+	}
+
+	api := *a
+	api.AddSubscription(clientID, subscribed)
+}
+
+// UnsubscribeHandler removes client subscriptions, the broker reports them on
+// $SYS/broker/log/M/unsubscribe as "<timestamp>: <clientId> <topic>"
+func UnsubscribeHandler(a *models.API, prefix, topic string, message []byte) {
+	clientID, unsubscribed, ok := parseBrokerLog(message, 1)
+	if !ok {
 		return
 	}
 
 	api := *a
-	api.PostObservationByDatastream(id, &o)
+	api.RemoveSubscription(clientID, unsubscribed)
+}
+
+// NoticeHandler removes the subscriptions of disconnected clients, the broker reports connecting and
+// disconnecting clients on $SYS/broker/log/N (mosquitto: log_dest topic, log_type notice)
+func NoticeHandler(a *models.API, prefix, topic string, message []byte) {
+	api := *a
+	if clientID, cleanSession, ok := parseConnectedNotice(message); ok {
+		api.ClientConnected(clientID, cleanSession)
+	} else if clientID, ok := parseDisconnectedNotice(message); ok {
+		api.ClientDisconnected(clientID)
+	}
+}
+
+var (
+	connectedNotice    = regexp.MustCompile(`^New client connected from \S+ as (\S+) \((?:p\d+, )?c([01])`)
+	disconnectedNotice = regexp.MustCompile(`^(?:Client (\S+) (?:disconnected|closed its connection|has exceeded timeout)|Socket error on client (\S+), disconnecting)`)
+)
+
+// parseConnectedNotice returns the client id and the clean session flag of a notice such as
+// "<timestamp>: New client connected from 127.0.0.1 as sensor1 (p2, c1, k60)."
+func parseConnectedNotice(message []byte) (string, bool, bool) {
+	m := connectedNotice.FindStringSubmatch(stripTimestamp(string(message)))
+	if m == nil {
+		return "", false, false
+	}
+
+	return m[1], m[2] == "1", true
+}
+
+// parseDisconnectedNotice returns the client id of a notice such as "<timestamp>: Client sensor1 disconnected."
+func parseDisconnectedNotice(message []byte) (string, bool) {
+	m := disconnectedNotice.FindStringSubmatch(stripTimestamp(string(message)))
+	if m == nil {
+		return "", false
+	}
+
+	return strings.TrimSuffix(m[1]+m[2], "."), true
+}
+
+func stripTimestamp(line string) string {
+	if i := strings.Index(line, ": "); i != -1 && !strings.Contains(line[:i], " ") {
+		return line[i+2:]
+	}
+
+	return line
+}
+
+// parseBrokerLog returns the client id and topic from a broker log line, topicField is the
+// index of the topic after the optional timestamp
+func parseBrokerLog(message []byte, topicField int) (string, string, bool) {
+	fields := strings.Fields(string(message))
+	if len(fields) > 0 && strings.HasSuffix(fields[0], ":") {
+		fields = fields[1:]
+	}
+
+	if len(fields) <= topicField {
+		return "", "", false
+	}
+
+	return fields[0], strings.Join(fields[topicField:], " "), true
 }
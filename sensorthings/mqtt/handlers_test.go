@@ -0,0 +1,75 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBrokerLog(t *testing.T) {
+	// arrange
+	// act
+	clientID, topic, ok := parseBrokerLog([]byte("1505908624: client1 0 v1.0/Observations?$select=result"), 2)
+	clientID2, topic2, ok2 := parseBrokerLog([]byte("client2 v1.0/Things(1)/name"), 1)
+	_, _, ok3 := parseBrokerLog([]byte("1505908624: client3"), 1)
+
+	// assert
+	assert.True(t, ok)
+	assert.Equal(t, "client1", clientID)
+	assert.Equal(t, "v1.0/Observations?$select=result", topic)
+	assert.True(t, ok2)
+	assert.Equal(t, "client2", clientID2)
+	assert.Equal(t, "v1.0/Things(1)/name", topic2)
+	assert.False(t, ok3)
+}
+
+func TestParseNotices(t *testing.T) {
+	// arrange
+	// act
+	clientID, clean, ok := parseConnectedNotice([]byte("1505908624: New client connected from 127.0.0.1 as sensor1 (p2, c1, k60)."))
+	clientID2, clean2, ok2 := parseConnectedNotice([]byte("New client connected from ::1 as sensor2 (c0, k60, u'user')."))
+	_, _, ok3 := parseConnectedNotice([]byte("1505908624: Client sensor1 disconnected."))
+	disconnected, ok4 := parseDisconnectedNotice([]byte("1505908624: Client sensor1 disconnected."))
+	closed, ok5 := parseDisconnectedNotice([]byte("1505908624: Client sensor2 closed its connection."))
+	socketError, ok6 := parseDisconnectedNotice([]byte("1505908624: Socket error on client sensor3, disconnecting."))
+	_, ok7 := parseDisconnectedNotice([]byte("1505908624: Client sensor1 already connected, closing old connection."))
+
+	// assert
+	assert.True(t, ok)
+	assert.Equal(t, "sensor1", clientID)
+	assert.True(t, clean)
+	assert.True(t, ok2)
+	assert.Equal(t, "sensor2", clientID2)
+	assert.False(t, clean2)
+	assert.False(t, ok3)
+	assert.True(t, ok4)
+	assert.Equal(t, "sensor1", disconnected)
+	assert.True(t, ok5)
+	assert.Equal(t, "sensor2", closed)
+	assert.True(t, ok6)
+	assert.Equal(t, "sensor3", socketError)
+	assert.False(t, ok7)
+}
+
+func TestMapTopic(t *testing.T) {
+	// arrange
+	// act
+	name, id, err := mapTopic("Datastreams(1)/Observations")
+	name2, id2, err2 := mapTopic("Observations")
+	name3, id3, err3 := mapTopic("Things(5)/Locations")
+	_, _, err4 := mapTopic("Datastreams(1/Observations")
+	_, _, err5 := mapTopic("Datastreams)1(/Observations")
+
+	// assert
+	assert.Equal(t, "Datastreams()/Observations", name)
+	assert.Equal(t, "1", id)
+	assert.Equal(t, "Observations", name2)
+	assert.Equal(t, "", id2)
+	assert.Equal(t, "Things()/Locations", name3)
+	assert.Equal(t, "5", id3)
+	assert.Nil(t, err)
+	assert.Nil(t, err2)
+	assert.Nil(t, err3)
+	assert.NotNil(t, err4, "id without ) should return an error")
+	assert.NotNil(t, err5, ") before ( should return an error")
+}
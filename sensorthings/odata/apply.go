@@ -0,0 +1,151 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gostErrors "github.com/gost/server/errors"
+)
+
+// AggregateMethod is the aggregation applied to the results of the Observations in an interval
+type AggregateMethod string
+
+// AggregateMethod is a "enumeration" of the supported aggregations, AggregateMethodCount is the number of
+// Observations in the interval, the other methods only use numeric results
+const (
+	AggregateMethodAverage AggregateMethod = "average"
+	AggregateMethodMin     AggregateMethod = "min"
+	AggregateMethodMax     AggregateMethod = "max"
+	AggregateMethodSum     AggregateMethod = "sum"
+	AggregateMethodCount   AggregateMethod = "count"
+)
+
+// AggregatePhenomenonTime is the name of the interval in an aggregated Observation
+const AggregatePhenomenonTime = "phenomenonTime"
+
+var (
+	applyRegex     = regexp.MustCompile(`(?i)^groupby\(\(\s*interval\(\s*phenomenonTime\s*,\s*([^)\s]+)\s*\)\s*\)\s*,\s*aggregate\((.+)\)\)$`)
+	aggregateRegex = regexp.MustCompile(`(?i)^(?:result\s+with\s+(average|min|max|sum)|\$count)\s+as\s+([a-z_][a-z0-9_]*)$`)
+	durationRegex  = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+)
+
+// Aggregation is the $apply of an Observations request, the Observations are grouped by an interval of their
+// phenomenonTime and the aggregates of their results are returned per interval. Supported is
+// $apply=groupby((interval(phenomenonTime,PT1H)),aggregate(result with average as avg,$count as count))
+type Aggregation struct {
+	Interval   time.Duration
+	Aggregates []Aggregate
+}
+
+// Aggregate is an aggregation of the results returned under the name Alias
+type Aggregate struct {
+	Method AggregateMethod
+	Alias  string
+}
+
+// ParseApply parses the value of $apply into an Aggregation
+func ParseApply(apply string) (*Aggregation, error) {
+	match := applyRegex.FindStringSubmatch(strings.TrimSpace(apply))
+	if match == nil {
+		return nil, applyError("expected groupby((interval(phenomenonTime,<duration>)),aggregate(<aggregates>))")
+	}
+
+	interval, err := parseInterval(match[1])
+	if err != nil {
+		return nil, err
+	}
+
+	aggregation := &Aggregation{Interval: interval, Aggregates: make([]Aggregate, 0)}
+	aliases := map[string]bool{strings.ToLower(AggregatePhenomenonTime): true}
+	for _, a := range strings.Split(match[2], ",") {
+		m := aggregateRegex.FindStringSubmatch(strings.TrimSpace(a))
+		if m == nil {
+			return nil, applyError(fmt.Sprintf("unsupported aggregate %s, expected result with average|min|max|sum as <alias> or $count as <alias>", strings.TrimSpace(a)))
+		}
+
+		if aliases[strings.ToLower(m[2])] {
+			return nil, applyError(fmt.Sprintf("alias %s is used more than once", m[2]))
+		}
+		aliases[strings.ToLower(m[2])] = true
+
+		method := AggregateMethodCount
+		if len(m[1]) > 0 {
+			method = AggregateMethod(strings.ToLower(m[1]))
+		}
+
+		aggregation.Aggregates = append(aggregation.Aggregates, Aggregate{Method: method, Alias: m[2]})
+	}
+
+	return aggregation, nil
+}
+
+// Seconds returns the length of the intervals in seconds, the intervals start at a multiple of
+// this length since the Unix epoch so an interval of P1D starts at midnight UTC
+func (a *Aggregation) Seconds() int64 {
+	return int64(a.Interval / time.Second)
+}
+
+// parseInterval parses an ISO 8601 duration such as PT15M, PT1H or P1D, months and years are
+// not supported because they do not have a fixed length
+func parseInterval(duration string) (time.Duration, error) {
+	match := durationRegex.FindStringSubmatch(strings.ToUpper(duration))
+	if match == nil {
+		return 0, applyError(fmt.Sprintf("unsupported interval %s, expected an ISO 8601 duration such as PT1H or P1D", duration))
+	}
+
+	interval := time.Duration(0)
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if len(match[i+1]) == 0 {
+			continue
+		}
+
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, applyError(fmt.Sprintf("unsupported interval %s", duration))
+		}
+
+		interval += time.Duration(n) * unit
+	}
+
+	if interval < time.Second {
+		return 0, applyError(fmt.Sprintf("interval %s has to be at least one second", duration))
+	}
+
+	return interval, nil
+}
+
+// IntervalsDescending returns true when the intervals of an aggregation are ordered descending, they can
+// only be ordered by phenomenonTime and are ordered descending when no $orderby is given
+func (q *QueryOptions) IntervalsDescending() bool {
+	if q == nil || q.OrderBy == nil || len(q.OrderBy.OrderByItems) == 0 {
+		return true
+	}
+
+	return strings.ToLower(q.OrderBy.OrderByItems[0].Order) == "desc"
+}
+
+// validateAggregation checks the query options that cannot be combined with $apply
+func (q *QueryOptions) validateAggregation() error {
+	if q.Select != nil || q.Expand != nil || len(q.SkipToken) > 0 {
+		return applyError("$select, $expand and $skiptoken cannot be combined with $apply")
+	}
+
+	if q.OrderBy != nil {
+		for _, obi := range q.OrderBy.OrderByItems {
+			if obi.Field == nil || !strings.EqualFold(obi.Field.Value, AggregatePhenomenonTime) {
+				return applyError("aggregated Observations can only be ordered by phenomenonTime")
+			}
+		}
+	}
+
+	return nil
+}
+
+func applyError(message string) error {
+	return gostErrors.NewBadRequestError(errors.New("Invalid $apply: " + message))
+}
@@ -0,0 +1,61 @@
+package odata
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseApply(t *testing.T) {
+	// act
+	a, err := ParseApply("groupby((interval(phenomenonTime, P1DT12H)), aggregate(result with average as avg, result with MAX as max, $count as n))")
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 36*time.Hour, a.Interval)
+	assert.Equal(t, int64(129600), a.Seconds())
+	assert.Equal(t, []Aggregate{
+		{Method: AggregateMethodAverage, Alias: "avg"},
+		{Method: AggregateMethodMax, Alias: "max"},
+		{Method: AggregateMethodCount, Alias: "n"},
+	}, a.Aggregates)
+}
+
+func TestParseApplyInvalid(t *testing.T) {
+	for _, apply := range []string{
+		"aggregate(result with average as avg)",
+		"groupby((interval(phenomenonTime,P1M)),aggregate(result with average as avg))",
+		"groupby((interval(phenomenonTime,PT0S)),aggregate(result with average as avg))",
+		"groupby((interval(phenomenonTime,PT1H)),aggregate(result with median as median))",
+		"groupby((interval(phenomenonTime,PT1H)),aggregate(result with min as v,result with max as v))",
+		"groupby((interval(phenomenonTime,PT1H)),aggregate($count as phenomenonTime))",
+	} {
+		// act
+		_, err := ParseApply(apply)
+
+		// assert
+		assert.NotNil(t, err, apply)
+	}
+}
+
+func TestParseURLQueryApply(t *testing.T) {
+	// arrange
+	apply := url.QueryEscape("groupby((interval(phenomenonTime,PT15M)),aggregate(result with min as min))")
+	valid, _ := url.Parse("localhost/v1.0/observations?$orderby=phenomenonTime%20asc&$apply=" + apply)
+	selected, _ := url.Parse("localhost/v1.0/observations?$select=result&$apply=" + apply)
+	ordered, _ := url.Parse("localhost/v1.0/observations?$orderby=result&$apply=" + apply)
+
+	// act
+	query, err := ParseURLQuery(valid.Query())
+	_, selectErr := ParseURLQuery(selected.Query())
+	_, orderErr := ParseURLQuery(ordered.Query())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 15*time.Minute, query.Aggregation.Interval)
+	assert.False(t, query.IntervalsDescending())
+	assert.NotNil(t, selectErr)
+	assert.NotNil(t, orderErr)
+}
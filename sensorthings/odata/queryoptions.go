@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gost/godata"
+	gostLog "github.com/gost/server/log"
 )
 
 // SupportedExpandParameters contains a list of endpoints with their supported expand parameters
@@ -23,9 +24,16 @@ type QueryOptions struct {
 	Value           *GoDataValueQuery
 	Ref             *GoDataRefQuery
 	CollectionCount *GoDataCollectionCountQuery
+	SkipToken       SkipToken
+	Aggregation     *Aggregation
+	ResultFormat    ResultFormat
 	RawExpand       string
 	RawFilter       string
 	RawOrderBy      string
+	RawApply        string
+	// RequestID is the id of the HTTP request the QueryOptions are parsed from, it is added to the log lines
+	// of the queries so they can be traced to the request
+	RequestID string
 }
 
 // ExpandParametersSupported returns if the QueryOptions expand request is supported by the endpoints
@@ -113,10 +121,41 @@ func ParseURLQuery(query url.Values) (*QueryOptions, error) {
 	}
 	result.CollectionCount = &cc
 
+	if value = query.Get("$skiptoken"); value != "" {
+		if result.SkipToken, err = ParseSkipToken(value); err != nil {
+			return nil, err
+		}
+
+		if len(result.SkipToken) != len(result.SortKeys()) {
+			return nil, errInvalidSkipToken
+		}
+	}
+
+	if value = query.Get("$apply"); value != "" {
+		if result.Aggregation, err = ParseApply(value); err != nil {
+			return nil, err
+		}
+
+		if err = result.validateAggregation(); err != nil {
+			return nil, err
+		}
+	}
+
+	if value = query.Get("$resultFormat"); value != "" {
+		if result.ResultFormat, err = ParseResultFormat(value); err != nil {
+			return nil, err
+		}
+
+		if err = result.validateResultFormat(); err != nil {
+			return nil, err
+		}
+	}
+
 	//store raw queries
 	result.RawExpand = query.Get("$expand")
 	result.RawFilter = query.Get("$filter")
 	result.RawOrderBy = query.Get("$orderby")
+	result.RawApply = query.Get("$apply")
 
 	return result, err
 }
@@ -166,10 +205,21 @@ func GetQueryOptions(r *http.Request, maxEntities int) (*QueryOptions, []error)
 		values["$skip"] = []string{"0"}
 	}
 
+	// the Accept header can ask for CSV or GeoJSON when no $resultFormat is given, it is ignored on $value, $ref and /$count
+	if values.Get("$resultFormat") == "" && values.Get("$value") == "" && values.Get("$ref") == "" && values.Get("$collectioncount") == "" {
+		if format := AcceptResultFormat(r.Header.Get("Accept")); len(format) > 0 {
+			values["$resultFormat"] = []string{string(format)}
+		}
+	}
+
 	qo, e := ParseURLQuery(values)
 	if e != nil {
 		return nil, []error{e}
 	}
 
+	if qo != nil {
+		qo.RequestID = gostLog.RequestIDFromContext(r.Context())
+	}
+
 	return qo, nil
 }
@@ -4,7 +4,10 @@ import (
 	"strings"
 )
 
-var keywords = []string{"$filter", "$select", "$expand", "$orderby", "$top", "$skip", "$count"}
+var keywords = []string{"$filter", "$select", "$expand", "$orderby", "$top", "$skip", "$skiptoken", "$count", "$apply", "$resultformat"}
+
+// customOptions are the accepted non OData query options such as mode on CreateObservations
+var customOptions = []string{"mode"}
 
 func partHasKeyword(part string) bool {
 	part1 := strings.ToLower(strings.Split(part, "=")[0])
@@ -15,6 +18,12 @@ func partHasKeyword(part string) bool {
 			return true
 		}
 	}
+
+	for _, o := range customOptions {
+		if decoded == o {
+			return true
+		}
+	}
 	return false
 }
 
@@ -12,4 +12,5 @@ func TestQueryValidator(t *testing.T) {
 	assert.Equal(t, true, IsValidOdataQuery("$filter=name eq 'ho'"))
 	assert.Equal(t, true, IsValidOdataQuery(fmt.Sprintf("%sfilter=name eq 'ho'", "%24")))
 	assert.Equal(t, false, IsValidOdataQuery("$notexisting=name eq 'ho'"))
+	assert.Equal(t, true, IsValidOdataQuery("mode=atomic"))
 }
@@ -0,0 +1,76 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	gostErrors "github.com/gost/server/errors"
+)
+
+// ResultFormat is the format of a response requested by $resultFormat or the Accept header
+type ResultFormat string
+
+// ResultFormat is a "enumeration" of the supported formats, ResultFormatDataArray is the SensorThings dataArray
+// format for Observations, ResultFormatCSV writes Observations and Datastreams as CSV and ResultFormatGeoJSON
+// writes Locations and FeaturesOfInterest as GeoJSON FeatureCollection
+const (
+	ResultFormatJSON      ResultFormat = "json"
+	ResultFormatDataArray ResultFormat = "dataArray"
+	ResultFormatCSV       ResultFormat = "csv"
+	ResultFormatGeoJSON   ResultFormat = "GeoJSON"
+)
+
+var resultFormats = []ResultFormat{ResultFormatJSON, ResultFormatDataArray, ResultFormatCSV, ResultFormatGeoJSON}
+
+// acceptFormats maps the media types of the Accept header to the ResultFormat they request
+var acceptFormats = map[string]ResultFormat{
+	"text/csv":                 ResultFormatCSV,
+	"application/geo+json":     ResultFormatGeoJSON,
+	"application/vnd.geo+json": ResultFormatGeoJSON,
+}
+
+// ParseResultFormat parses the value of $resultFormat, the value is case insensitive
+func ParseResultFormat(format string) (ResultFormat, error) {
+	for _, f := range resultFormats {
+		if strings.EqualFold(format, string(f)) {
+			return f, nil
+		}
+	}
+
+	return "", gostErrors.NewBadRequestError(fmt.Errorf("Invalid $resultFormat %s, supported: %s, %s, %s, %s", format, ResultFormatJSON, ResultFormatDataArray, ResultFormatCSV, ResultFormatGeoJSON))
+}
+
+// AcceptResultFormat returns the ResultFormat of the first media type in the Accept header that has one,
+// an empty string is returned when the header does not ask for CSV or GeoJSON
+func AcceptResultFormat(accept string) ResultFormat {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		if f, ok := acceptFormats[strings.ToLower(mediaType)]; ok {
+			return f
+		}
+	}
+
+	return ""
+}
+
+// validateResultFormat checks the query options that cannot be combined with a $resultFormat other than json
+func (q *QueryOptions) validateResultFormat() error {
+	if len(q.ResultFormat) == 0 || q.ResultFormat == ResultFormatJSON {
+		return nil
+	}
+
+	if q.Aggregation != nil ||
+		(q.Value != nil && bool(*q.Value)) ||
+		(q.Ref != nil && bool(*q.Ref)) ||
+		(q.CollectionCount != nil && bool(*q.CollectionCount)) {
+		return gostErrors.NewBadRequestError(errors.New("$resultFormat cannot be combined with $apply, $value, $ref or /$count"))
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package odata
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResultFormat(t *testing.T) {
+	// act
+	dataArray, err := ParseResultFormat("dataarray")
+	geoJSON, _ := ParseResultFormat("geojson")
+	_, unknownErr := ParseResultFormat("xml")
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, ResultFormatDataArray, dataArray)
+	assert.Equal(t, ResultFormatGeoJSON, geoJSON)
+	assert.NotNil(t, unknownErr)
+}
+
+func TestAcceptResultFormat(t *testing.T) {
+	// assert
+	assert.Equal(t, ResultFormatCSV, AcceptResultFormat("text/csv; charset=utf-8"))
+	assert.Equal(t, ResultFormatGeoJSON, AcceptResultFormat("text/html;q=0.9, application/geo+json"))
+	assert.Equal(t, ResultFormat(""), AcceptResultFormat("application/json, */*"))
+}
+
+func TestGetQueryOptionsResultFormat(t *testing.T) {
+	// arrange
+	req, _ := http.NewRequest("GET", "/v1.0/Observations?$resultFormat=dataArray", nil)
+	acceptReq, _ := http.NewRequest("GET", "/v1.0/Locations", nil)
+	acceptReq.Header.Set("Accept", "application/vnd.geo+json")
+	applyReq, _ := http.NewRequest("GET", "/v1.0/Observations?$resultFormat=csv&$apply=groupby((interval(phenomenonTime,PT1H)),aggregate($count%20as%20count))", nil)
+
+	// act
+	qo, err := GetQueryOptions(req, 20)
+	acceptQo, _ := GetQueryOptions(acceptReq, 20)
+	_, applyErr := GetQueryOptions(applyReq, 20)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, ResultFormatDataArray, qo.ResultFormat)
+	assert.Equal(t, ResultFormatGeoJSON, acceptQo.ResultFormat)
+	assert.NotNil(t, applyErr)
+}
@@ -0,0 +1,85 @@
+package odata
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	gostErrors "github.com/gost/server/errors"
+)
+
+// SkipToken is the continuation token of a next link, it holds the values of the sort keys of the
+// last entity of the previous page in the order of SortKeys. The next page starts after this entity
+// so pages do not shift when entities are added and deep pages do not need an OFFSET
+type SkipToken []interface{}
+
+// SortKey is a property the entities are ordered by
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// errInvalidSkipToken is returned when a $skiptoken cannot be decoded or does not match the $orderby
+var errInvalidSkipToken = gostErrors.NewBadRequestError(errors.New("Invalid $skiptoken"))
+
+// SortKeys returns the keys the entities are ordered by, the $orderby items followed by id descending when
+// id is not ordered by. The id makes the order unique so a SkipToken points to a single position
+func (q *QueryOptions) SortKeys() []SortKey {
+	keys := make([]SortKey, 0)
+	hasID := false
+	if q != nil && q.OrderBy != nil {
+		for _, obi := range q.OrderBy.OrderByItems {
+			if obi.Field == nil {
+				continue
+			}
+
+			if strings.ToLower(obi.Field.Value) == "id" {
+				hasID = true
+			}
+
+			keys = append(keys, SortKey{Field: obi.Field.Value, Desc: strings.ToLower(obi.Order) == "desc"})
+		}
+	}
+
+	if !hasID {
+		keys = append(keys, SortKey{Field: "id", Desc: true})
+	}
+
+	return keys
+}
+
+// ParseSkipToken decodes a $skiptoken created by SkipToken.String
+func ParseSkipToken(token string) (SkipToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalidSkipToken
+	}
+
+	// keep numbers as json.Number to not lose precision of large ids
+	var values []interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&values); err != nil || len(values) == 0 {
+		return nil, errInvalidSkipToken
+	}
+
+	for i, v := range values {
+		if n, ok := v.(json.Number); ok {
+			if integer, err := n.Int64(); err == nil {
+				values[i] = integer
+			} else if float, err := n.Float64(); err == nil {
+				values[i] = float
+			}
+		}
+	}
+
+	return SkipToken(values), nil
+}
+
+// String encodes the SkipToken as opaque URL safe string
+func (t SkipToken) String() string {
+	b, _ := json.Marshal([]interface{}(t))
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,66 @@
+package odata
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/gost/godata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortKeys(t *testing.T) {
+	// arrange
+	qo := &QueryOptions{}
+	qo.OrderBy, _ = godata.ParseOrderByString("phenomenonTime desc,name")
+	qoID := &QueryOptions{}
+	qoID.OrderBy, _ = godata.ParseOrderByString("id asc")
+	var nilOptions *QueryOptions
+
+	// act
+	keys := qo.SortKeys()
+	idKeys := qoID.SortKeys()
+	defaultKeys := nilOptions.SortKeys()
+
+	// assert
+	assert.Equal(t, []SortKey{{Field: "phenomenonTime", Desc: true}, {Field: "name", Desc: false}, {Field: "id", Desc: true}}, keys)
+	assert.Equal(t, []SortKey{{Field: "id", Desc: false}}, idKeys)
+	assert.Equal(t, []SortKey{{Field: "id", Desc: true}}, defaultKeys)
+}
+
+func TestSkipTokenRoundTrip(t *testing.T) {
+	// arrange
+	token := SkipToken{"2017-01-01T00:00:00.000Z", nil, 1.5, int64(9007199254740993)}
+
+	// act
+	parsed, err := ParseSkipToken(token.String())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, token, parsed)
+}
+
+func TestParseInvalidSkipToken(t *testing.T) {
+	// act
+	_, notBase64 := ParseSkipToken("not a token!")
+	_, notArray := ParseSkipToken(SkipToken{}.String())
+
+	// assert
+	assert.NotNil(t, notBase64)
+	assert.NotNil(t, notArray)
+}
+
+func TestParseURLQuerySkipToken(t *testing.T) {
+	// arrange
+	token := SkipToken{"a", int64(3)}.String()
+	valid, _ := url.Parse("localhost/v1.0/things?$orderby=name&$top=2&$skiptoken=" + token)
+	mismatch, _ := url.Parse("localhost/v1.0/things?$top=2&$skiptoken=" + token)
+
+	// act
+	query, err := ParseURLQuery(valid.Query())
+	_, mismatchErr := ParseURLQuery(mismatch.Query())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, SkipToken{"a", int64(3)}, query.SkipToken)
+	assert.NotNil(t, mismatchErr, "token should not be accepted when it does not match the $orderby")
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 
+	entities "github.com/gost/core"
 	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/rest/endpoint"
 	"github.com/gost/server/sensorthings/rest/handlers"
@@ -12,6 +13,7 @@ import (
 func CreateCreateObservationsEndpoint(externalURL string) *endpoint.Endpoint {
 	return &endpoint.Endpoint{
 		Name:       "CreateObservations",
+		EntityType: entities.EntityTypeCreateObservations,
 		OutputInfo: false,
 		URL:        fmt.Sprintf("%s/%s/%s", externalURL, models.APIPrefix, fmt.Sprintf("%v", "CreateObservations")),
 		Operations: []models.EndpointOperation{
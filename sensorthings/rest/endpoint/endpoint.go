@@ -31,6 +31,11 @@ func (e *Endpoint) ShowOutputInfo() bool {
 	return e.OutputInfo
 }
 
+// GetEntityType returns the type of the entities served by this endpoint
+func (e *Endpoint) GetEntityType() entities.EntityType {
+	return e.EntityType
+}
+
 // GetURL returns the external url
 func (e *Endpoint) GetURL() string {
 	return e.URL
@@ -22,6 +22,7 @@ func TestEndPointGetNameShouldReturnCorrectName(t *testing.T) {
 	ops := endpoint.GetOperations()
 	expand := endpoint.GetSupportedExpandParams()
 	sel := endpoint.GetSupportedSelectParams()
+	et := endpoint.GetEntityType()
 	// point.AreQueryOptionsSupported()
 
 	//assert
@@ -31,6 +32,7 @@ func TestEndPointGetNameShouldReturnCorrectName(t *testing.T) {
 	assert.True(t, len(ops) == 0)
 	assert.True(t, len(expand) == 0)
 	assert.True(t, len(sel) == 0)
+	assert.Equal(t, endpoint.EntityType, et)
 }
 
 func TestIsDynamic(t *testing.T) {
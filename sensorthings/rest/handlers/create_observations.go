@@ -2,15 +2,23 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	entities "github.com/gost/core"
+	"github.com/gost/server/metrics"
 	"github.com/gost/server/sensorthings/models"
 )
 
-// HandlePostCreateObservations ...
+// HandlePostCreateObservations handles a dataArray post, the optional query option mode can be set
+// to atomic (all or nothing) or besteffort (default, create all valid observations)
 func HandlePostCreateObservations(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	ob := &entities.CreateObservations{}
-	handle := func() (interface{}, []error) { return a.PostCreateObservations(ob) }
+	mode := models.CreateObservationsMode(strings.ToLower(r.URL.Query().Get("mode")))
+	handle := func() (interface{}, []error) {
+		results, errs := a.PostCreateObservations(ob, mode)
+		metrics.ObservationsIngested.Add(float64(models.CountCreated(results, a.GetConfig().GetExternalServerURI())), metrics.TransportHTTP)
+		return results, errs
+	}
 	handlePostRequest(w, endpoint, r, ob, &handle, a.GetConfig().Server.IndentedJSON)
 }
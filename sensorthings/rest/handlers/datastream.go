@@ -13,7 +13,7 @@ import (
 func HandleGetDatastreams(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) { return a.GetDatastreams(q, path) }
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetDatastream retrieves a datastream by given id
@@ -22,7 +22,7 @@ func HandleGetDatastream(w http.ResponseWriter, r *http.Request, endpoint *model
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetDatastream(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetDatastreamByObservation ...
@@ -31,7 +31,7 @@ func HandleGetDatastreamByObservation(w http.ResponseWriter, r *http.Request, en
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetDatastreamByObservation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetDatastreamsByThing ...
@@ -40,7 +40,7 @@ func HandleGetDatastreamsByThing(w http.ResponseWriter, r *http.Request, endpoin
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetDatastreamsByThing(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetDatastreamsBySensor ...
@@ -49,7 +49,7 @@ func HandleGetDatastreamsBySensor(w http.ResponseWriter, r *http.Request, endpoi
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetDatastreamsBySensor(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetDatastreamsByObservedProperty ...
@@ -58,7 +58,7 @@ func HandleGetDatastreamsByObservedProperty(w http.ResponseWriter, r *http.Reque
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetDatastreamsByObservedProperty(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandlePostDatastream ...
@@ -13,7 +13,7 @@ import (
 func HandleGetFeatureOfInterests(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) { return a.GetFeatureOfInterests(q, path) }
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetFeatureOfInterest ...
@@ -22,7 +22,7 @@ func HandleGetFeatureOfInterest(w http.ResponseWriter, r *http.Request, endpoint
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetFeatureOfInterest(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetFeatureOfInterestByObservation ...
@@ -31,7 +31,7 @@ func HandleGetFeatureOfInterestByObservation(w http.ResponseWriter, r *http.Requ
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetFeatureOfInterestByObservation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandlePostFeatureOfInterest ...
@@ -15,7 +15,7 @@ func HandleGetHistoricalLocations(w http.ResponseWriter, r *http.Request, endpoi
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetHistoricalLocations(q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetHistoricalLocationsByThing ...
@@ -24,7 +24,7 @@ func HandleGetHistoricalLocationsByThing(w http.ResponseWriter, r *http.Request,
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetHistoricalLocationsByThing(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetHistoricalLocationsByLocation ...
@@ -33,7 +33,7 @@ func HandleGetHistoricalLocationsByLocation(w http.ResponseWriter, r *http.Reque
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetHistoricalLocationsByLocation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetHistoricalLocation ...
@@ -43,7 +43,7 @@ func HandleGetHistoricalLocation(w http.ResponseWriter, r *http.Request, endpoin
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetHistoricalLocation(id, q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandlePostHistoricalLocation ...
@@ -13,7 +13,7 @@ import (
 func HandleGetLocations(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) { return a.GetLocations(q, path) }
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetLocationsByHistoricalLocations retrieves the locations linked to the given Historical Location (id)
@@ -22,7 +22,7 @@ func HandleGetLocationsByHistoricalLocations(w http.ResponseWriter, r *http.Requ
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetLocationsByHistoricalLocation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetLocationsByThing retrieves the locations by given thing (id)
@@ -31,7 +31,7 @@ func HandleGetLocationsByThing(w http.ResponseWriter, r *http.Request, endpoint
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetLocationsByThing(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetLocation retrieves a location by given id
@@ -40,7 +40,7 @@ func HandleGetLocation(w http.ResponseWriter, r *http.Request, endpoint *models.
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetLocation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandlePostLocation posts a new location
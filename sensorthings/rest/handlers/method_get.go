@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"fmt"
 
+	gostErrors "github.com/gost/server/errors"
 	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/odata"
 	"github.com/gost/server/sensorthings/rest/writer"
@@ -12,6 +14,12 @@ import (
 
 // handleGetRequest is the default function to handle incoming GET requests
 func handleGetRequest(w http.ResponseWriter, e *models.Endpoint, r *http.Request, h *func(q *odata.QueryOptions, path string) (interface{}, error), indentJSON bool, maxEntities int, externalURI string) {
+	handleGetAggregateRequest(w, e, r, h, nil, indentJSON, maxEntities, externalURI)
+}
+
+// handleGetAggregateRequest handles incoming GET requests on a collection of Observations, requests containing
+// $apply are handled by aggregate, $apply is rejected when aggregate is nil
+func handleGetAggregateRequest(w http.ResponseWriter, e *models.Endpoint, r *http.Request, h *func(q *odata.QueryOptions, path string) (interface{}, error), aggregate *func(q *odata.QueryOptions, path string) (interface{}, error), indentJSON bool, maxEntities int, externalURI string) {
 	// Parse query options from request
 	queryOptions, err := odata.GetQueryOptions(r, maxEntities)
 	if err != nil && len(err) > 0 {
@@ -21,11 +29,20 @@ func handleGetRequest(w http.ResponseWriter, e *models.Endpoint, r *http.Request
 
 	// Run the handler func such as Api.GetThingById
 	handler := *h
+	if queryOptions != nil && queryOptions.Aggregation != nil {
+		if aggregate == nil {
+			writer.SendError(w, []error{gostErrors.NewBadRequestError(errors.New("$apply is only supported on Observations and Datastreams(id)/Observations"))}, indentJSON)
+			return
+		}
+
+		handler = *aggregate
+	}
+
 	data, err2 := handler(queryOptions, fmt.Sprintf(externalURI+r.URL.RawPath))
 	if err2 != nil {
 		writer.SendError(w, []error{err2}, indentJSON)
 		return
 	}
 
-	writer.SendJSONResponse(w, http.StatusOK, data, queryOptions, indentJSON)
+	writer.SendResponse(w, http.StatusOK, data, queryOptions, indentJSON)
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	entities "github.com/gost/core"
+	"github.com/gost/server/metrics"
 	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/odata"
 	"github.com/gost/server/sensorthings/rest/reader"
@@ -13,7 +14,10 @@ import (
 func HandleGetObservations(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) { return a.GetObservations(q, path) }
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	aggregate := func(q *odata.QueryOptions, path string) (interface{}, error) {
+		return a.GetObservationAggregates(nil, q, path)
+	}
+	handleGetAggregateRequest(w, endpoint, r, &handle, &aggregate, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetObservation ...
@@ -22,7 +26,7 @@ func HandleGetObservation(w http.ResponseWriter, r *http.Request, endpoint *mode
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetObservation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetObservationsByFeatureOfInterest ...
@@ -31,7 +35,7 @@ func HandleGetObservationsByFeatureOfInterest(w http.ResponseWriter, r *http.Req
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetObservationsByFeatureOfInterest(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetObservationsByDatastream ...
@@ -40,14 +44,24 @@ func HandleGetObservationsByDatastream(w http.ResponseWriter, r *http.Request, e
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetObservationsByDatastream(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	aggregate := func(q *odata.QueryOptions, path string) (interface{}, error) {
+		return a.GetObservationAggregates(reader.GetEntityID(r), q, path)
+	}
+	handleGetAggregateRequest(w, endpoint, r, &handle, &aggregate, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandlePostObservation ...
 func HandlePostObservation(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	ob := &entities.Observation{}
-	handle := func() (interface{}, []error) { return a.PostObservation(ob) }
+	handle := func() (interface{}, []error) {
+		o, errs := a.PostObservation(ob)
+		if len(errs) == 0 {
+			metrics.ObservationsIngested.Inc(metrics.TransportHTTP)
+		}
+
+		return o, errs
+	}
 	handlePostRequest(w, endpoint, r, ob, &handle, a.GetConfig().Server.IndentedJSON)
 }
 
@@ -55,7 +69,14 @@ func HandlePostObservation(w http.ResponseWriter, r *http.Request, endpoint *mod
 func HandlePostObservationByDatastream(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	ob := &entities.Observation{}
-	handle := func() (interface{}, []error) { return a.PostObservationByDatastream(reader.GetEntityID(r), ob) }
+	handle := func() (interface{}, []error) {
+		o, errs := a.PostObservationByDatastream(reader.GetEntityID(r), ob)
+		if len(errs) == 0 {
+			metrics.ObservationsIngested.Inc(metrics.TransportHTTP)
+		}
+
+		return o, errs
+	}
 	handlePostRequest(w, endpoint, r, ob, &handle, a.GetConfig().Server.IndentedJSON)
 }
 
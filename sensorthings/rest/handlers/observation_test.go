@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"testing"
 )
 
@@ -26,6 +27,18 @@ func TestGetObservationByFOI(t *testing.T) {
 	getAndAssertObservations("/v1.0/featuresofinterest(1)/observations", t)
 }
 
+func TestGetObservationAggregates(t *testing.T) {
+	getAndAssertObservations("/v1.0/datastreams(1)/observations?$apply="+url.QueryEscape("groupby((interval(phenomenonTime,PT1H)),aggregate(result with average as avg))"), t)
+}
+
+func TestGetThingsWithApplyIsRejected(t *testing.T) {
+	// act
+	r, _ := http.Get(getServer().URL + "/v1.0/things?$apply=" + url.QueryEscape("groupby((interval(phenomenonTime,PT1H)),aggregate($count as count))"))
+
+	// assert
+	assertStatusCode(http.StatusBadRequest, r, t)
+}
+
 func TestPostObservation(t *testing.T) {
 	// arrange
 	mockObs := newMockObservation(1)
@@ -13,7 +13,7 @@ import (
 func HandleGetThings(w http.ResponseWriter, r *http.Request, endpoint *models.Endpoint, api *models.API) {
 	a := *api
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) { return a.GetThings(q, path) }
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetThing retrieves and sends a specific Thing based on the given ID and filter
@@ -22,7 +22,7 @@ func HandleGetThing(w http.ResponseWriter, r *http.Request, endpoint *models.End
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetThing(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetThingByDatastream retrieves and sends a specific Thing based on the given datastream ID and filter
@@ -31,7 +31,7 @@ func HandleGetThingByDatastream(w http.ResponseWriter, r *http.Request, endpoint
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetThingByDatastream(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetThingsByLocation retrieves and sends Things based on the given Location ID and filter
@@ -40,7 +40,7 @@ func HandleGetThingsByLocation(w http.ResponseWriter, r *http.Request, endpoint
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetThingsByLocation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandleGetThingByHistoricalLocation retrieves and sends a specific Thing based on the given HistoricalLocation ID and filter
@@ -49,7 +49,7 @@ func HandleGetThingByHistoricalLocation(w http.ResponseWriter, r *http.Request,
 	handle := func(q *odata.QueryOptions, path string) (interface{}, error) {
 		return a.GetThingByHistoricalLocation(reader.GetEntityID(r), q, path)
 	}
-	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().Server.ExternalURI)
+	handleGetRequest(w, endpoint, r, &handle, a.GetConfig().Server.IndentedJSON, a.GetConfig().Server.MaxEntityResponse, a.GetConfig().GetExternalServerURI())
 }
 
 // HandlePostThing tries to insert a new Thing and sends back the created Thing
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -148,6 +149,10 @@ func newMockAPI() models.API {
 }
 
 func (a *MockAPI) Start() {}
+
+func (a *MockAPI) WithContext(ctx context.Context) models.API {
+	return a
+}
 func (a *MockAPI) GetConfig() *configuration.Config {
 	if a.config != nil {
 		return a.config
@@ -195,6 +200,11 @@ func (a *MockAPI) GetEndpoints() *map[entities.EntityType]models.Endpoint {
 
 func (a *MockAPI) initRest()                                               {}
 func (a *MockAPI) GetTopics(prefix string) *[]models.Topic                 { return nil }
+func (a *MockAPI) AddSubscription(clientID, topic string) error            { return nil }
+func (a *MockAPI) RemoveSubscription(clientID, topic string)               {}
+func (a *MockAPI) ClientConnected(clientID string, cleanSession bool)      {}
+func (a *MockAPI) ClientDisconnected(clientID string)                      {}
+func (a *MockAPI) MQTTPublish(topics []string, msg string, qos byte)       {}
 func (a *MockAPI) SetLinks(entity entities.Entity, qo *odata.QueryOptions) {}
 func (a *MockAPI) CreateNextLink(incomingURL string, qo *odata.QueryOptions) string {
 	return ""
@@ -469,6 +479,9 @@ func (a *MockAPI) GetObservationsByDatastream(datastreamID interface{}, qo *odat
 func (a *MockAPI) GetObservationsByFeatureOfInterest(foiID interface{}, qo *odata.QueryOptions, path string) (*entities.ArrayResponse, error) {
 	return getMockObservations()
 }
+func (a *MockAPI) GetObservationAggregates(datastreamID interface{}, qo *odata.QueryOptions, path string) (*entities.ArrayResponse, error) {
+	return getMockObservations()
+}
 func (a *MockAPI) PostObservation(observation *entities.Observation) (*entities.Observation, []error) {
 	return observation, nil
 }
@@ -532,7 +545,7 @@ func (a *MockAPI) GetVersionInfo() *models.VersionInfo {
 	return &versionInfo
 }
 
-func (a *MockAPI) PostCreateObservations(data *entities.CreateObservations) ([]string, []error) {
+func (a *MockAPI) PostCreateObservations(data *entities.CreateObservations, mode models.CreateObservationsMode) ([]string, []error) {
 	resp := make([]string, 0)
 	return resp, nil
 }
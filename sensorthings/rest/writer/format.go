@@ -0,0 +1,303 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	entities "github.com/gost/core"
+	gostErrors "github.com/gost/server/errors"
+	"github.com/gost/server/sensorthings/odata"
+)
+
+// csvColumns are the columns of a CSV response per entity type when no $select is given
+var csvColumns = map[entities.EntityType][]string{
+	entities.EntityTypeObservation: {"id", "phenomenonTime", "resultTime", "result", "resultQuality", "validTime", "parameters"},
+	entities.EntityTypeDatastream:  {"id", "name", "description", "unitOfMeasurement", "observationType", "observedArea", "phenomenonTime", "resultTime"},
+}
+
+// geometryProperties holds the property containing the geometry of the entity types that can be written as GeoJSON
+var geometryProperties = map[entities.EntityType]string{
+	entities.EntityTypeLocation:          "location",
+	entities.EntityTypeFeatureOfInterest: "feature",
+}
+
+// dataArrayComponents are the components of a dataArray response when no $select is given
+var dataArrayComponents = []string{"id", "phenomenonTime", "resultTime", "result"}
+
+// dataArray holds the Observations of a Datastream in the SensorThings dataArray format
+type dataArray struct {
+	Datastream string          `json:"Datastream@iot.navigationLink,omitempty"`
+	Components []string        `json:"components"`
+	Count      int             `json:"dataArray@iot.count"`
+	DataArray  [][]interface{} `json:"dataArray"`
+}
+
+// SendResponse sends data in the $resultFormat of the QueryOptions, SensorThings JSON is sent using
+// SendJSONResponse when no other format is requested
+func SendResponse(w http.ResponseWriter, status int, data interface{}, qo *odata.QueryOptions, indentJSON bool) {
+	if data == nil || qo == nil || len(qo.ResultFormat) == 0 || qo.ResultFormat == odata.ResultFormatJSON {
+		SendJSONResponse(w, status, data, qo, indentJSON)
+		return
+	}
+
+	ar, values, entityType, err := resultValues(data)
+	if err == nil {
+		switch {
+		case qo.ResultFormat == odata.ResultFormatDataArray && ar != nil && entityType == entities.EntityTypeObservation:
+			sendDataArray(w, status, ar, values, selectedColumns(qo, dataArrayComponents), indentJSON)
+			return
+		case qo.ResultFormat == odata.ResultFormatCSV && csvColumns[entityType] != nil:
+			sendCSV(w, status, ar, values, selectedColumns(qo, csvColumns[entityType]))
+			return
+		case qo.ResultFormat == odata.ResultFormatGeoJSON && len(geometryProperties[entityType]) > 0:
+			sendGeoJSON(w, status, ar, values, geometryProperties[entityType], indentJSON)
+			return
+		}
+
+		err = fmt.Errorf("$resultFormat %s is not supported for %s", qo.ResultFormat, entityType.ToString())
+	}
+
+	SendError(w, []error{gostErrors.NewBadRequestError(err)}, indentJSON)
+}
+
+// resultValues returns the entities in data as JSON objects and their entity type, the ArrayResponse is nil
+// when data is a single entity. An error is returned when data does not hold entities
+func resultValues(data interface{}) (*entities.ArrayResponse, []map[string]interface{}, entities.EntityType, error) {
+	var ar *entities.ArrayResponse
+	switch d := data.(type) {
+	case *entities.ArrayResponse:
+		ar = d
+	case entities.ArrayResponse:
+		ar = &d
+	}
+
+	list := reflect.ValueOf([]interface{}{data})
+	if ar != nil && ar.Data != nil {
+		list = reflect.ValueOf(*ar.Data)
+	}
+
+	// the type of an empty list is read from a new element
+	var entity entities.Entity
+	if list.Kind() == reflect.Slice && list.Len() > 0 {
+		entity, _ = list.Index(0).Interface().(entities.Entity)
+	} else if list.Kind() == reflect.Slice && list.Type().Elem().Kind() == reflect.Ptr {
+		entity, _ = reflect.New(list.Type().Elem().Elem()).Interface().(entities.Entity)
+	}
+
+	var entityType entities.EntityType
+	if entity == nil {
+		return nil, nil, entityType, errors.New("$resultFormat is not supported for this request")
+	}
+	entityType = entity.GetEntityType()
+
+	b, err := json.Marshal(list.Interface())
+	if err != nil {
+		return nil, nil, entityType, err
+	}
+
+	values := make([]map[string]interface{}, 0)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&values); err != nil {
+		return nil, nil, entityType, err
+	}
+
+	return ar, values, entityType, nil
+}
+
+// selectedColumns returns the properties selected by $select or the given defaults
+func selectedColumns(qo *odata.QueryOptions, defaults []string) []string {
+	if qo.Select == nil || len(qo.Select.SelectItems) == 0 {
+		return defaults
+	}
+
+	columns := make([]string, 0, len(qo.Select.SelectItems))
+	for _, item := range qo.Select.SelectItems {
+		if len(item.Segments) > 0 {
+			columns = append(columns, item.Segments[0].Value)
+		}
+	}
+
+	return columns
+}
+
+// propertyValue returns the value of a property of an entity, id is read from @iot.id
+func propertyValue(values map[string]interface{}, property string) interface{} {
+	if property == "id" {
+		return values["@iot.id"]
+	}
+
+	return values[property]
+}
+
+// envelope returns the members of the ArrayResponse other than its value, such as @iot.count and @iot.nextLink
+func envelope(ar *entities.ArrayResponse) map[string]interface{} {
+	members := make(map[string]interface{})
+	if ar == nil {
+		return members
+	}
+
+	e := *ar
+	e.Data = nil
+	b, _ := json.Marshal(e)
+	var raw map[string]json.RawMessage
+	json.Unmarshal(b, &raw)
+	for k, v := range raw {
+		if k != "value" {
+			members[k] = v
+		}
+	}
+
+	return members
+}
+
+// sendDataArray writes the Observations in the dataArray format, Observations with an expanded Datastream are
+// grouped by their Datastream, otherwise all Observations are written in a single dataArray
+func sendDataArray(w http.ResponseWriter, status int, ar *entities.ArrayResponse, values []map[string]interface{}, components []string, indentJSON bool) {
+	groups := make([]*dataArray, 0)
+	byDatastream := make(map[string]*dataArray)
+	for _, v := range values {
+		link := ""
+		if ds, ok := v["Datastream"].(map[string]interface{}); ok {
+			link, _ = ds["@iot.selfLink"].(string)
+		}
+
+		group, ok := byDatastream[link]
+		if !ok {
+			group = &dataArray{Datastream: link, Components: components, DataArray: make([][]interface{}, 0)}
+			byDatastream[link] = group
+			groups = append(groups, group)
+		}
+
+		row := make([]interface{}, len(components))
+		for i, c := range components {
+			row[i] = propertyValue(v, c)
+		}
+
+		group.DataArray = append(group.DataArray, row)
+		group.Count++
+	}
+
+	response := envelope(ar)
+	response["value"] = groups
+	sendFormattedJSON(w, status, "application/json; charset=UTF-8", response, indentJSON)
+}
+
+// sendCSV writes the entities as CSV with a header row holding the columns, the next link of the ArrayResponse
+// is sent in the Link header
+func sendCSV(w http.ResponseWriter, status int, ar *entities.ArrayResponse, values []map[string]interface{}, columns []string) {
+	w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+	if ar != nil && len(ar.NextLink) > 0 {
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", ar.NextLink))
+	}
+
+	w.WriteHeader(status)
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	for _, v := range values {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = csvValue(propertyValue(v, c))
+		}
+
+		cw.Write(record)
+	}
+
+	cw.Flush()
+}
+
+// csvValue converts a JSON value into a CSV field, objects and arrays are written as JSON
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// sendGeoJSON writes the entities as GeoJSON FeatureCollection, or as Feature for a single entity, the
+// @iot.count and @iot.nextLink of the ArrayResponse are added as members of the FeatureCollection
+func sendGeoJSON(w http.ResponseWriter, status int, ar *entities.ArrayResponse, values []map[string]interface{}, geometryProperty string, indentJSON bool) {
+	features := make([]map[string]interface{}, 0, len(values))
+	for _, v := range values {
+		features = append(features, geoJSONFeature(v, geometryProperty))
+	}
+
+	var response interface{}
+	if ar == nil && len(features) == 1 {
+		response = features[0]
+	} else {
+		collection := envelope(ar)
+		collection["type"] = "FeatureCollection"
+		collection["features"] = features
+		response = collection
+	}
+
+	sendFormattedJSON(w, status, "application/geo+json", response, indentJSON)
+}
+
+// geoJSONFeature converts an entity into a Feature, the geometry is read from geometryProperty and the
+// other properties of the entity become the properties of the Feature
+func geoJSONFeature(values map[string]interface{}, geometryProperty string) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for k, v := range values {
+		if k != geometryProperty && k != "@iot.id" {
+			properties[k] = v
+		}
+	}
+
+	feature := map[string]interface{}{
+		"type":       "Feature",
+		"geometry":   geoJSONGeometry(values[geometryProperty]),
+		"properties": properties,
+	}
+
+	if id, ok := values["@iot.id"]; ok {
+		feature["id"] = id
+	}
+
+	return feature
+}
+
+// geoJSONGeometry returns the GeoJSON geometry of a location or feature, the geometry of a Feature is
+// returned and nil is returned for locations in another encoding
+func geoJSONGeometry(location interface{}) interface{} {
+	g, ok := location.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	switch g["type"] {
+	case nil:
+		return nil
+	case "Feature":
+		return g["geometry"]
+	}
+
+	return g
+}
+
+func sendFormattedJSON(w http.ResponseWriter, status int, contentType string, data interface{}, indentJSON bool) {
+	b, err := JSONMarshal(data, true, indentJSON)
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(b)
+}
@@ -0,0 +1,93 @@
+package writer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	entities "github.com/gost/core"
+	"github.com/gost/godata"
+	"github.com/gost/server/sensorthings/odata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendResponseDataArray(t *testing.T) {
+	// arrange
+	rr := httptest.NewRecorder()
+	o1 := &entities.Observation{PhenomenonTime: "2017-01-01T00:00:00.000Z", Result: json.RawMessage("20.5")}
+	o1.ID = 1
+	o2 := &entities.Observation{PhenomenonTime: "2017-01-01T01:00:00.000Z", Result: json.RawMessage("21")}
+	o2.ID = 2
+	var data interface{} = []*entities.Observation{o1, o2}
+	ar := &entities.ArrayResponse{Count: 2, Data: &data}
+	qo := &odata.QueryOptions{ResultFormat: odata.ResultFormatDataArray}
+	qo.Select, _ = godata.ParseSelectString("id,result")
+
+	// act
+	SendResponse(rr, http.StatusOK, ar, qo, false)
+	body, _ := ioutil.ReadAll(rr.Body)
+
+	// assert
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"@iot.count":2,"value":[{"components":["id","result"],"dataArray@iot.count":2,"dataArray":[[1,20.5],[2,21]]}]}`, string(body))
+}
+
+func TestSendResponseCSV(t *testing.T) {
+	// arrange
+	rr := httptest.NewRecorder()
+	ds := &entities.Datastream{Name: "temperature, outside", Description: "test"}
+	ds.ID = 1
+	var data interface{} = []*entities.Datastream{ds}
+	ar := &entities.ArrayResponse{NextLink: "http://localhost:8080/v1.0/Datastreams?$resultFormat=csv&$skip=1", Data: &data}
+	qo := &odata.QueryOptions{ResultFormat: odata.ResultFormatCSV}
+	qo.Select, _ = godata.ParseSelectString("id,name,description")
+
+	// act
+	SendResponse(rr, http.StatusOK, ar, qo, false)
+	body, _ := ioutil.ReadAll(rr.Body)
+
+	// assert
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `<http://localhost:8080/v1.0/Datastreams?$resultFormat=csv&$skip=1>; rel="next"`, rr.Header().Get("Link"))
+	assert.Equal(t, "id,name,description\n1,\"temperature, outside\",test\n", string(body))
+}
+
+func TestSendResponseGeoJSON(t *testing.T) {
+	// arrange
+	rr := httptest.NewRecorder()
+	l := &entities.Location{Name: "home", EncodingType: "application/vnd.geo+json", Location: map[string]interface{}{"type": "Point", "coordinates": []float64{5.1, 52.1}}}
+	l.ID = 1
+	var data interface{} = []*entities.Location{l}
+	ar := &entities.ArrayResponse{Data: &data}
+	qo := &odata.QueryOptions{ResultFormat: odata.ResultFormatGeoJSON}
+
+	// act
+	SendResponse(rr, http.StatusOK, ar, qo, false)
+	var collection map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &collection)
+	feature := collection["features"].([]interface{})[0].(map[string]interface{})
+
+	// assert
+	assert.Equal(t, "application/geo+json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "FeatureCollection", collection["type"])
+	assert.Equal(t, 1.0, feature["id"])
+	assert.Equal(t, map[string]interface{}{"type": "Point", "coordinates": []interface{}{5.1, 52.1}}, feature["geometry"])
+	assert.Equal(t, "home", feature["properties"].(map[string]interface{})["name"])
+	assert.Nil(t, feature["properties"].(map[string]interface{})["location"])
+}
+
+func TestSendResponseUnsupportedFormat(t *testing.T) {
+	// arrange
+	rr := httptest.NewRecorder()
+	var data interface{} = []*entities.Thing{{Name: "thing"}}
+	ar := &entities.ArrayResponse{Data: &data}
+
+	// act
+	SendResponse(rr, http.StatusOK, ar, &odata.QueryOptions{ResultFormat: odata.ResultFormatGeoJSON}, false)
+
+	// assert
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
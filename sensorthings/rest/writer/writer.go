@@ -1,25 +1,33 @@
 package writer
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 
 	"errors"
 
+	entities "github.com/gost/core"
 	gostErrors "github.com/gost/server/errors"
 	"github.com/gost/server/sensorthings/models"
 	"github.com/gost/server/sensorthings/odata"
 )
 
 // SendJSONResponse sends the desired message to the user
-// the message will be marshalled into JSON
+// the message will be marshalled into JSON, the entities of an ArrayResponse are encoded one by one
 func SendJSONResponse(w http.ResponseWriter, status int, data interface{}, qo *odata.QueryOptions, indentJSON bool) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	if data != nil {
+		convert := qo != nil && ((qo.CollectionCount != nil && bool(*qo.CollectionCount)) || (qo.Value != nil && bool(*qo.Value)))
+		if !convert && streamArrayResponse(w, status, data, indentJSON) {
+			return
+		}
+
 		b, err := JSONMarshal(data, true, indentJSON)
 		if err != nil {
 			panic(err)
@@ -35,6 +43,7 @@ func SendJSONResponse(w http.ResponseWriter, status int, data interface{}, qo *o
 
 			if err != nil {
 				SendError(w, []error{err}, indentJSON)
+				return
 			}
 		}
 
@@ -43,6 +52,76 @@ func SendJSONResponse(w http.ResponseWriter, status int, data interface{}, qo *o
 	}
 }
 
+// streamArrayResponse writes an ArrayResponse holding a list of entities without marshalling the response
+// as a whole, the entities are encoded one by one into a buffered writer flushing to w. Only the JSON of the
+// response is not kept in memory, the entities are still loaded in full by the database before they are
+// written, streaming the rows of the database cursor is not supported. Returns false when data is not an
+// ArrayResponse with a list of entities
+func streamArrayResponse(w http.ResponseWriter, status int, data interface{}, indentJSON bool) bool {
+	var ar *entities.ArrayResponse
+	switch d := data.(type) {
+	case *entities.ArrayResponse:
+		ar = d
+	case entities.ArrayResponse:
+		ar = &d
+	}
+
+	if ar == nil || ar.Data == nil {
+		return false
+	}
+
+	list := reflect.ValueOf(*ar.Data)
+	if list.Kind() != reflect.Slice {
+		return false
+	}
+
+	// marshal the response without entities and stream the entities in place of the null value
+	envelope := *ar
+	envelope.Data = nil
+	b, err := JSONMarshal(envelope, true, indentJSON)
+	if err != nil {
+		panic(err)
+	}
+
+	start := bytes.Index(b, []byte(`"value":`))
+	if start < 0 {
+		return false
+	}
+
+	null := bytes.Index(b[start:], []byte("null"))
+	if null < 0 {
+		return false
+	}
+
+	null += start
+	bw := bufio.NewWriterSize(w, 32*1024)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	if indentJSON {
+		enc.SetIndent("   ", "   ")
+	}
+
+	w.WriteHeader(status)
+	bw.Write(b[:null])
+	bw.WriteString("[")
+	for i := 0; i < list.Len(); i++ {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+
+		// the response is already partly sent, an entity that cannot be encoded ends the response
+		if err := enc.Encode(list.Index(i).Interface()); err != nil {
+			break
+		}
+	}
+
+	bw.WriteString("]")
+	bw.Write(b[null+len("null"):])
+	bw.Flush()
+
+	return true
+}
+
 //JSONMarshal converts the data and converts special characters such as &
 func JSONMarshal(data interface{}, safeEncoding, indentJSON bool) ([]byte, error) {
 	var b []byte
@@ -177,3 +177,33 @@ func TestCountCollectionError(t *testing.T) {
 	// assert
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
+
+func TestSendJsonResponseStreamsArrayResponse(t *testing.T) {
+	// arrange
+	rr := httptest.NewRecorder()
+	var data interface{} = []*entities.Thing{{Name: "a<b"}, {Name: "c"}}
+	ar := &entities.ArrayResponse{Count: 2, NextLink: "http://localhost:8080/v1.0/Things?$skip=2", Data: &data}
+	expected, _ := JSONMarshal(ar, true, false)
+
+	// act
+	SendJSONResponse(rr, http.StatusOK, ar, nil, false)
+	body, _ := ioutil.ReadAll(rr.Body)
+
+	// assert
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, string(expected), string(body))
+}
+
+func TestSendJsonResponseStreamsEmptyArrayResponse(t *testing.T) {
+	// arrange
+	rr := httptest.NewRecorder()
+	var data interface{} = []*entities.Thing{}
+	ar := &entities.ArrayResponse{Data: &data}
+
+	// act
+	SendJSONResponse(rr, http.StatusOK, ar, nil, true)
+	body, _ := ioutil.ReadAll(rr.Body)
+
+	// assert
+	assert.JSONEq(t, `{"value": []}`, string(body))
+}